@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/apparentlymart/gopherhal/trainhal"
+)
+
+// analyze prints a summary of the sentences in the given corpus files,
+// without training anything.
+func analyze(corpusFiles []string) int {
+	if len(corpusFiles) == 0 {
+		os.Stderr.WriteString("Usage: gopherhal analyze <corpus-file>...\n")
+		return 1
+	}
+
+	sources := make([]trainhal.CorpusSource, 0, len(corpusFiles))
+	for _, filename := range corpusFiles {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open %s: %s\n", filename, err)
+			return 1
+		}
+		defer f.Close()
+		sources = append(sources, trainhal.CorpusSource{
+			Name:   filename,
+			Reader: f,
+		})
+	}
+
+	stats, err := trainhal.AnalyzeCorpus(sources)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to analyze corpus: %s\n", err)
+		return 1
+	}
+
+	if jsonOutput {
+		return printJSON(newAnalyzeSummary(stats))
+	}
+
+	fmt.Printf("Sources:         %d\n", stats.Sources)
+	fmt.Printf("Sentences:       %d\n", stats.Sentences)
+	fmt.Printf("Duplicates:      %.1f%% (%d sentences)\n", stats.DuplicateRatio()*100, stats.DuplicateSentences)
+	fmt.Printf("Words:           %d\n", stats.Words)
+	fmt.Printf("Vocabulary:      %d\n", stats.Vocabulary)
+	if stats.Sentences > 0 {
+		fmt.Printf("Sentence length: %d median, %d at 90th percentile, %d longest\n", stats.LengthPercentile(0.5), stats.LengthPercentile(0.9), len(stats.LengthCounts)-1)
+		fmt.Println("Sentences shorter than:")
+		for n := 2; n <= 6; n++ {
+			fmt.Printf("  %d words %8d\n", n, stats.ShortSentences(n))
+		}
+	}
+	langs := make([]string, 0, len(stats.Languages))
+	for lang := range stats.Languages {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		return stats.Languages[langs[i]] > stats.Languages[langs[j]]
+	})
+	fmt.Println("Languages:")
+	for _, lang := range langs {
+		name := lang
+		if name == "" {
+			name = "unknown"
+		}
+		fmt.Printf("  %-8s %d\n", name, stats.Languages[lang])
+	}
+	fmt.Println("Top tokens:")
+	for _, tc := range stats.TopTokens {
+		fmt.Printf("  %-16s %d\n", tc.Text, tc.Count)
+	}
+	return 0
+}
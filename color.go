@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+	prompt "github.com/c-bata/go-prompt"
+)
+
+// colorOutput is whether chat output is colored using ANSI escape sequences,
+// as decided by useColor.
+var colorOutput bool
+
+// The ANSI escape sequences for each kind of chat output.
+const (
+	ansiReset  = "\x1b[0m"
+	colorInput = "\x1b[36m"   // cyan
+	colorReply = "\x1b[1;32m" // bold green
+	colorTag   = "\x1b[33m"   // yellow
+	colorDebug = "\x1b[2m"    // faint
+)
+
+// useColor decides whether to color output, which we do only if it hasn't
+// been disabled with either the --no-color flag or the NO_COLOR environment
+// variable, and only if stdout is a terminal.
+func useColor(noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps the given string in the given color if output is colored,
+// or returns it unchanged otherwise.
+func colorize(color, s string) string {
+	if !colorOutput {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// colorTagged is like Sentence.StringTagged but with the words in the given
+// color and the tags in colorTag, if output is colored.
+func colorTagged(color string, s ghal.Sentence) string {
+	if !colorOutput {
+		return s.StringTagged()
+	}
+	var ret strings.Builder
+	for i, w := range s {
+		if i > 0 {
+			ret.WriteByte(' ')
+		}
+		ret.WriteString(colorize(color, w.Text))
+		ret.WriteString(colorize(colorTag, "/"+w.Tag))
+	}
+	return ret.String()
+}
+
+// chatPromptOptions returns the options for reading each line of chat
+// input, which color the input if output is colored.
+func chatPromptOptions() []prompt.Option {
+	if !colorOutput {
+		return nil
+	}
+	return []prompt.Option{
+		prompt.OptionInputTextColor(prompt.Cyan),
+	}
+}
+
+// debugWriter returns a writer for the brain's debug log, which writes each
+// line in colorDebug if output is colored, so that it can be told apart from
+// the chat itself.
+func debugWriter(w io.Writer) io.Writer {
+	if !colorOutput {
+		return w
+	}
+	return colorLineWriter{w, colorDebug}
+}
+
+// colorLineWriter is an io.Writer that wraps each write, which must be a
+// whole line, in a color.
+type colorLineWriter struct {
+	w     io.Writer
+	color string
+}
+
+func (w colorLineWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	_, err := io.WriteString(w.w, w.color+line+ansiReset+"\n")
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// compareColumnWidth is the width of the column for each brain's replies
+// in the output of the "compare" command.
+const compareColumnWidth = 38
+
+// compareStats accumulates the aggregate statistics the "compare" command
+// reports for each brain.
+type compareStats struct {
+	replies    int
+	words      int
+	score      int
+	candidates int
+}
+
+// compare implements the "compare" command, which generates a reply from
+// each of the two given brains to each line of the given input file and
+// prints them side by side, followed by statistics about the replies.
+//
+// Each brain replies to each line using a source of randomness seeded with
+// the same value, so that differences in the replies are due to differences
+// between the brains rather than chance.
+func compare(brainFiles [2]string, inputFile string, seed int64, opts ghal.GenerationOptions) int {
+	var brains [2]*ghal.Brain
+	for i, filename := range brainFiles {
+		var err error
+		brains[i], err = loadBrainFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", filename, err)
+			return 1
+		}
+	}
+
+	f, err := os.Open(inputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open %s: %s\n", inputFile, err)
+		return 1
+	}
+	defer f.Close()
+
+	if !jsonOutput {
+		printColumns(brainFiles)
+		fmt.Println()
+	}
+
+	summary := compareSummary{
+		Brains: brainFiles,
+		Inputs: []compareInput{},
+	}
+	var stats [2]compareStats
+	lines, same := 0, 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		input, err := parseChatText(line)
+		if err != nil || len(input) == 0 {
+			continue
+		}
+
+		var replies [2]string
+		for i, brain := range brains {
+			opts.Rand = rand.New(rand.NewSource(seed + int64(lines)))
+			reply := brain.MakeReplyWithOptions(opts, input...)
+			if len(reply.Sentence) == 0 {
+				continue
+			}
+			replies[i] = displayed(brain, reply.Sentence).String()
+			stats[i].replies++
+			stats[i].words += len(reply.Sentence)
+			stats[i].score += reply.Score
+			stats[i].candidates += reply.Candidates
+		}
+		lines++
+		if replies[0] == replies[1] {
+			same++
+		}
+
+		if jsonOutput {
+			summary.Inputs = append(summary.Inputs, compareInput{
+				Input:   line,
+				Replies: replies,
+			})
+			continue
+		}
+		fmt.Printf("> %s\n", line)
+		printColumns(replies)
+		fmt.Println()
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %s\n", inputFile, err)
+		return 1
+	}
+	if lines == 0 {
+		fmt.Fprintf(os.Stderr, "There are no lines to reply to in %s\n", inputFile)
+		return 1
+	}
+
+	if jsonOutput {
+		summary.Same = same
+		for i, s := range stats {
+			summary.Stats[i] = s.json(lines)
+		}
+		return printJSON(summary)
+	}
+
+	fmt.Printf("Inputs:             %d (%d identical replies)\n", lines, same)
+	for i, s := range stats {
+		fmt.Printf("\n%s:\n", brainFiles[i])
+		fmt.Printf("Reply rate:         %.1f%% (%d replies)\n", float64(s.replies)/float64(lines)*100, s.replies)
+		if s.replies > 0 {
+			fmt.Printf("Average words:      %.2f\n", float64(s.words)/float64(s.replies))
+			fmt.Printf("Average score:      %.2f\n", float64(s.score)/float64(s.replies))
+			fmt.Printf("Average candidates: %.2f\n", float64(s.candidates)/float64(s.replies))
+		}
+	}
+	return 0
+}
+
+// printColumns prints the given two texts side by side, wrapping each one
+// at word boundaries to fit its column. An empty text is shown as "-".
+func printColumns(texts [2]string) {
+	var cols [2][]string
+	for i, text := range texts {
+		if text == "" {
+			text = "-"
+		}
+		cols[i] = wrapText(text, compareColumnWidth)
+	}
+	for i := 0; i < len(cols[0]) || i < len(cols[1]); i++ {
+		var left, right string
+		if i < len(cols[0]) {
+			left = cols[0][i]
+		}
+		if i < len(cols[1]) {
+			right = cols[1][i]
+		}
+		line := fmt.Sprintf("  %-*s | %s", compareColumnWidth, left, right)
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+// wrapText splits the given text into lines of at most the given width,
+// measured in characters, breaking only between words. A single word longer
+// than the width gets a line of its own.
+func wrapText(text string, width int) []string {
+	var ret []string
+	var line strings.Builder
+	lineLen := 0
+	for _, word := range strings.Fields(text) {
+		wordLen := len([]rune(word))
+		if lineLen > 0 && lineLen+1+wordLen > width {
+			ret = append(ret, line.String())
+			line.Reset()
+			lineLen = 0
+		}
+		if lineLen > 0 {
+			line.WriteByte(' ')
+			lineLen++
+		}
+		line.WriteString(word)
+		lineLen += wordLen
+	}
+	if lineLen > 0 {
+		ret = append(ret, line.String())
+	}
+	return ret
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+	prompt "github.com/c-bata/go-prompt"
+)
+
+// chatCompleteWords is the number of the brain's most frequent nouns that
+// chat offers as suggestions while the user is typing.
+const chatCompleteWords = 5000
+
+// chatCompleteMax is the maximum number of words chat suggests at once.
+const chatCompleteMax = 10
+
+// chatCompleteMinPrefix is the number of characters of a word the user must
+// type before chat suggests words starting with them.
+const chatCompleteMinPrefix = 2
+
+var chatCommands = []prompt.Suggest{
+	{Text: "/why", Description: "show where the last reply came from"},
+	{Text: "/good", Description: "make the last reply more likely to be said again"},
+	{Text: "/bad", Description: "make the last reply less likely to be said again"},
+	{Text: "/topic", Description: "show the topic of the conversation"},
+	{Text: "/stats", Description: "show statistics about the brain"},
+	{Text: "/connect", Description: "find a sentence connecting two words"},
+	{Text: "/acrostic", Description: "write an acrostic of the given word"},
+	{Text: "/forget", Description: "forget a sentence that was learned"},
+}
+
+var chatSessionCommands = []prompt.Suggest{
+	{Text: "/commit", Description: "remember what was learned in this session"},
+	{Text: "/discard", Description: "forget what was learned in this session"},
+}
+
+// chatCompleter offers suggestions as the user types in chat: slash
+// commands at the start of the input, and otherwise nouns the brain knows
+// that start with the word being typed.
+type chatCompleter struct {
+	brain    *ghal.Brain
+	commands []prompt.Suggest
+
+	// nouns are the brain's most frequent nouns, in descending order of
+	// frequency, which are gathered only when needed since the brain may
+	// learn new ones as the chat goes on.
+	nouns []ghal.Word
+	stale bool
+}
+
+func newChatCompleter(brain *ghal.Brain, ephemeral bool) *chatCompleter {
+	commands := chatCommands
+	if ephemeral {
+		commands = append(commands[:len(commands):len(commands)], chatSessionCommands...)
+	}
+	return &chatCompleter{
+		brain:    brain,
+		commands: commands,
+		stale:    true,
+	}
+}
+
+// Learned tells the completer that the brain may have learned new words, so
+// that it will gather the nouns again before its next suggestion.
+func (c *chatCompleter) Learned() {
+	c.stale = true
+}
+
+// Complete is a prompt.Completer for chat.
+func (c *chatCompleter) Complete(d prompt.Document) []prompt.Suggest {
+	text := d.TextBeforeCursor()
+	if strings.HasPrefix(text, "/") && !strings.Contains(text, " ") {
+		return prompt.FilterHasPrefix(c.commands, text, true)
+	}
+
+	prefix := strings.ToLower(d.GetWordBeforeCursor())
+	if len(prefix) < chatCompleteMinPrefix {
+		return nil
+	}
+	if c.stale {
+		c.nouns = c.brain.TopWords(chatCompleteWords, ghal.Word.IsNoun)
+		c.stale = false
+	}
+	var ret []prompt.Suggest
+	seen := make(map[string]struct{})
+	for _, w := range c.nouns {
+		if len(ret) >= chatCompleteMax {
+			break
+		}
+		if !strings.HasPrefix(w.Text, prefix) || w.Text == prefix {
+			continue
+		}
+		if _, ok := seen[w.Text]; ok {
+			continue // the same text can be tagged as more than one kind of noun
+		}
+		seen[w.Text] = struct{}{}
+		ret = append(ret, prompt.Suggest{Text: w.Text})
+	}
+	return ret
+}
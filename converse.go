@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// converse makes the brains in the two given files talk to each other for
+// the given number of turns, printing the transcript. Each brain learns what
+// the other says if the corresponding element of learn is set, in which case
+// it is saved again afterwards.
+func converse(brainFiles []string, learn []bool, turns int, opts ghal.GenerationOptions, topicMemory int) int {
+	convs := make([]*ghal.Conversation, len(brainFiles))
+	names := make([]string, len(brainFiles))
+	for i, filename := range brainFiles {
+		brain, err := loadBrainFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", filename, err)
+			return 1
+		}
+		if !brain.IsTrained(0) {
+			fmt.Fprintf(os.Stderr, "The brain in %q needs training first; use \"gopherhal train\" to teach it\n", filename)
+			return 1
+		}
+		convs[i] = ghal.NewConversation(brain)
+		convs[i].Options = opts
+		convs[i].TopicMemory = topicMemory
+		names[i] = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	if names[0] == names[1] {
+		// A brain talking to itself, or to a brain with the same filename in
+		// another directory, would otherwise give an ambiguous transcript.
+		names[0] += "#1"
+		names[1] += "#2"
+	}
+
+	// The first brain opens with a question, just as in the chat command.
+	last := []ghal.Sentence{convs[0].Brain.MakeQuestion()}
+	if len(last[0]) == 0 {
+		fmt.Printf("%s is speechless :(\n", names[0])
+		return 0
+	}
+	convs[0].NoteOutput(last[0])
+	fmt.Printf("%s: %s\n", names[0], displayed(convs[0].Brain, last[0]))
+
+	for turn := 1; turn < turns; turn++ {
+		speaker, listener := turn%2, (turn+1)%2
+		conv := convs[speaker]
+		if learn[speaker] {
+			conv.Learn(trimPeriods(conv.Brain, last), brainFiles[listener])
+		}
+
+		reply := conv.MakeReply(last...).Sentence
+		if len(reply) == 0 {
+			// If we have nothing to say about what the other brain said then
+			// we'll try to change the subject.
+			reply = conv.Brain.MakeQuestion()
+			conv.NoteOutput(reply)
+		}
+		if len(reply) == 0 {
+			fmt.Printf("%s is speechless :(\n", names[speaker])
+			break
+		}
+		fmt.Printf("%s: %s\n", names[speaker], displayed(conv.Brain, reply))
+		last = []ghal.Sentence{reply}
+	}
+
+	for i, conv := range convs {
+		if learn[i] {
+			safeSaveBrain(conv.Brain, brainFiles[i])
+		}
+	}
+	return 0
+}
+
+// trimPeriods returns a copy of the given sentences with their trailing
+// periods trimmed, as recognized by the given brain, preserving the bots'
+// conversational style when learning.
+func trimPeriods(brain *ghal.Brain, ss []ghal.Sentence) []ghal.Sentence {
+	ret := make([]ghal.Sentence, len(ss))
+	for i, s := range ss {
+		ret[i] = brain.TrimPeriod(s)
+	}
+	return ret
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// diffSamples is the number of example sentences the "diff" command shows
+// for each direction of change.
+const diffSamples = 5
+
+// diffListMax is the maximum number of individual words or chains the
+// "diff" command lists in each category before summarizing the rest.
+const diffListMax = 20
+
+func diff(oldFile, newFile string) int {
+	oldBrain, err := loadBrainFile(oldFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", oldFile, err)
+		return 1
+	}
+	newBrain, err := loadBrainFile(newFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", newFile, err)
+		return 1
+	}
+
+	d := ghal.DiffBrains(oldBrain, newBrain, diffSamples)
+	if jsonOutput {
+		return printJSON(diffSummary{
+			Old:            oldFile,
+			New:            newFile,
+			Identical:      d.Empty(),
+			WordsAdded:     d.WordsAdded.Sorted(),
+			WordsRemoved:   d.WordsRemoved.Sorted(),
+			ChainsAdded:    chainSentences(d.ChainsAdded),
+			ChainsRemoved:  chainSentences(d.ChainsRemoved),
+			SamplesAdded:   sentenceTexts(d.SamplesAdded),
+			SamplesRemoved: sentenceTexts(d.SamplesRemoved),
+		})
+	}
+	if d.Empty() {
+		fmt.Printf("The brains in %s and %s have identical knowledge.\n", oldFile, newFile)
+		return 0
+	}
+
+	fmt.Printf("Words: +%d -%d\n", len(d.WordsAdded), len(d.WordsRemoved))
+	fmt.Printf("Chains: +%d -%d\n", len(d.ChainsAdded), len(d.ChainsRemoved))
+
+	printDiffWords("Words only in "+newFile, d.WordsAdded.Sorted())
+	printDiffWords("Words only in "+oldFile, d.WordsRemoved.Sorted())
+	printDiffChains("Chains only in "+newFile, d.ChainsAdded)
+	printDiffChains("Chains only in "+oldFile, d.ChainsRemoved)
+	printDiffSentences("Sample sentences only "+newFile+" can say", d.SamplesAdded)
+	printDiffSentences("Sample sentences only "+oldFile+" can say", d.SamplesRemoved)
+
+	return 0
+}
+
+func printDiffWords(title string, words []ghal.Word) {
+	if len(words) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for i, w := range words {
+		if i == diffListMax {
+			fmt.Printf("- (and %d more...)\n", len(words)-i)
+			break
+		}
+		fmt.Printf("- %s/%s\n", w.Text, w.Tag)
+	}
+}
+
+func printDiffChains(title string, cs []ghal.Chain) {
+	if len(cs) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for i, c := range cs {
+		if i == diffListMax {
+			fmt.Printf("- (and %d more...)\n", len(cs)-i)
+			break
+		}
+		fmt.Printf("- %s\n", c)
+	}
+}
+
+func printDiffSentences(title string, ss []ghal.Sentence) {
+	if len(ss) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for i, s := range ss {
+		if i == diffListMax {
+			fmt.Printf("- (and %d more...)\n", len(ss)-i)
+			break
+		}
+		fmt.Printf("- %s\n", s)
+	}
+}
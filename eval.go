@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+	"github.com/apparentlymart/gopherhal/trainhal"
+)
+
+// evaluate measures the quality of the brain in the given file against the
+// sentences in the given held-out corpus files, printing a report.
+func evaluate(brainFile string, corpusFiles []string, opts ghal.GenerationOptions) int {
+	if len(corpusFiles) == 0 {
+		os.Stderr.WriteString("Usage: gopherhal eval <held-out-corpus-file>...\n")
+		return 1
+	}
+
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	parser := newParser()
+	var sentences []ghal.Sentence
+	for _, filename := range corpusFiles {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open %s: %s\n", filename, err)
+			return 1
+		}
+		ss, err := trainhal.ParseTrainingInputWithParser(f, filename, "", parser)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read %s: %s\n", filename, err)
+			return 1
+		}
+		sentences = append(sentences, ss...)
+	}
+
+	report := ghal.Evaluate(brain, sentences, opts)
+	if jsonOutput {
+		return printJSON(evalSummary{
+			Sentences:         report.Sentences,
+			Replies:           report.Replies,
+			ReplyRate:         report.ReplyRate(),
+			AverageCandidates: report.AverageCandidates(),
+			DuplicateReplies:  report.DuplicateReplies,
+			DuplicateRate:     report.DuplicateRate(),
+			Perplexity:        report.Perplexity,
+			PerplexityWords:   report.PerplexityWords,
+		})
+	}
+	fmt.Printf("Sentences:          %d\n", report.Sentences)
+	fmt.Printf("Reply rate:         %.1f%% (%d replies)\n", report.ReplyRate()*100, report.Replies)
+	fmt.Printf("Average candidates: %.2f\n", report.AverageCandidates())
+	fmt.Printf("Duplicate rate:     %.1f%% (%d duplicates)\n", report.DuplicateRate()*100, report.DuplicateReplies)
+	fmt.Printf("Perplexity:         %.2f (over %d words)\n", report.Perplexity, report.PerplexityWords)
+	return 0
+}
@@ -0,0 +1,96 @@
+//go:build js && wasm
+
+// Command wasm is an example of running a brain entirely in a web browser,
+// exposing functions to JavaScript for loading a brain, learning from
+// messages, and replying to them.
+//
+// Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o gopherhal.wasm ./examples/wasm
+//
+// and load the result using the wasm_exec.js support script that comes with
+// Go. Once the program is running it defines these global functions:
+//
+//	gopherhalLoad(bytes)   // loads a brain file from a Uint8Array
+//	gopherhalLearn(text)   // learns the sentences in a message
+//	gopherhalReply(text)   // returns a reply to a message, or ""
+//	gopherhalSave()        // returns the brain file as a Uint8Array
+package main
+
+import (
+	"bytes"
+	"syscall/js"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+var conv = ghal.NewConversation(ghal.NewBrain())
+
+func main() {
+	js.Global().Set("gopherhalLoad", js.FuncOf(load))
+	js.Global().Set("gopherhalLearn", js.FuncOf(learn))
+	js.Global().Set("gopherhalReply", js.FuncOf(reply))
+	js.Global().Set("gopherhalSave", js.FuncOf(save))
+
+	// The functions can be called only while the program is running, so
+	// we must never exit.
+	select {}
+}
+
+func load(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return errorValue("gopherhalLoad requires a Uint8Array")
+	}
+	src := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(src, args[0])
+	brain, err := ghal.LoadBrainBytes(src)
+	if err != nil {
+		return errorValue(err.Error())
+	}
+	conv = ghal.NewConversation(brain)
+	return nil
+}
+
+func learn(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return errorValue("gopherhalLearn requires a string")
+	}
+	ss, err := ghal.ParseTextWithLimits(args[0].String(), ghal.ChatParseLimits)
+	if err != nil {
+		return errorValue(err.Error())
+	}
+	conv.Learn(ss, "")
+	return nil
+}
+
+func reply(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return errorValue("gopherhalReply requires a string")
+	}
+	ss, err := ghal.ParseTextWithLimits(args[0].String(), ghal.ChatParseLimits)
+	if err != nil {
+		return errorValue(err.Error())
+	}
+	r := conv.MakeReply(ss...)
+	if len(r.Sentence) == 0 {
+		return ""
+	}
+	return conv.Brain.TrimPeriod(r.Sentence).String()
+}
+
+func save(this js.Value, args []js.Value) any {
+	var buf bytes.Buffer
+	if err := conv.Brain.Save(&buf); err != nil {
+		return errorValue(err.Error())
+	}
+	ret := js.Global().Get("Uint8Array").New(buf.Len())
+	js.CopyBytesToJS(ret, buf.Bytes())
+	return ret
+}
+
+// errorValue returns a JavaScript Error with the given message, which the
+// functions return rather than throw since Go can't throw JavaScript
+// exceptions.
+func errorValue(msg string) js.Value {
+	return js.Global().Get("Error").New(msg)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+	prompt "github.com/c-bata/go-prompt"
+)
+
+// exploreListMax is the maximum number of items the "explore" command will
+// list at once.
+const exploreListMax = 30
+
+var exploreCommands = []prompt.Suggest{
+	{Text: "find", Description: "find the words with the given text"},
+	{Text: "word", Description: "select a word from the last search and list its chains"},
+	{Text: "chain", Description: "select a chain from the last list and show its neighbors"},
+	{Text: "before", Description: "walk backwards by prepending a word to the current chain"},
+	{Text: "after", Description: "walk forwards by appending a word to the current chain"},
+	{Text: "say", Description: "generate a sentence containing the current word"},
+	{Text: "help", Description: "list the available commands"},
+	{Text: "quit", Description: "leave the explorer"},
+}
+
+// explorer is the state of an interactive "explore" session.
+type explorer struct {
+	brain *ghal.Brain
+
+	// words are the results of the most recent "find" command.
+	words []ghal.Word
+
+	// word is the currently-selected word, if any.
+	word    ghal.Word
+	hasWord bool
+
+	// chains are the chains containing the currently-selected word.
+	chains []ghal.Chain
+
+	// chain describes the currently-selected chain, if any.
+	chain    ghal.ChainInfo
+	hasChain bool
+}
+
+func explore(brainFile string) int {
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	e := &explorer{brain: brain}
+	fmt.Printf("Exploring %s. Type \"help\" for a list of commands.\n", brainFile)
+	for {
+		inp := strings.TrimSpace(prompt.Input("explore> ", exploreComplete))
+		if inp == "" {
+			continue
+		}
+		cmd, arg := inp, ""
+		if i := strings.IndexByte(inp, ' '); i >= 0 {
+			cmd, arg = inp[:i], strings.TrimSpace(inp[i+1:])
+		}
+
+		switch cmd {
+		case "find":
+			e.find(arg)
+		case "word":
+			e.selectWord(arg)
+		case "chain":
+			e.selectChain(arg)
+		case "before":
+			e.walk(arg, false)
+		case "after":
+			e.walk(arg, true)
+		case "say":
+			e.say()
+		case "help":
+			for _, c := range exploreCommands {
+				fmt.Printf("  %-8s %s\n", c.Text, c.Description)
+			}
+		case "quit", "exit":
+			return 0
+		default:
+			fmt.Printf("Unknown command %q. Type \"help\" for a list of commands.\n", cmd)
+		}
+	}
+}
+
+func (e *explorer) find(text string) {
+	if text == "" {
+		fmt.Printf("Usage: find <text>\n")
+		return
+	}
+	want := ghal.MakeWord("", text).Text
+	e.words = e.brain.TopWords(exploreListMax, func(w ghal.Word) bool {
+		return w.Text == want
+	})
+	if len(e.words) == 0 {
+		fmt.Printf("The brain doesn't know the word %q.\n", want)
+		return
+	}
+	for i, w := range e.words {
+		fmt.Printf("%3d. %s/%s (in %d chains)\n", i+1, w.Text, w.Tag, e.brain.WordFrequency(w))
+	}
+	if len(e.words) == 1 {
+		e.selectWord("1")
+	}
+}
+
+func (e *explorer) selectWord(arg string) {
+	i, ok := exploreIndex(arg, len(e.words))
+	if !ok {
+		fmt.Printf("Usage: word <number from the last \"find\">\n")
+		return
+	}
+	e.word = e.words[i]
+	e.hasWord = true
+	e.chains = e.brain.ChainsWithWord(e.word)
+	fmt.Printf("The word %s/%s appears in %d chains:\n", e.word.Text, e.word.Tag, len(e.chains))
+	for i, c := range e.chains {
+		if i == exploreListMax {
+			fmt.Printf("     (and %d more...)\n", len(e.chains)-i)
+			break
+		}
+		fmt.Printf("%3d. %s\n", i+1, c.Words().StringTagged())
+	}
+}
+
+func (e *explorer) selectChain(arg string) {
+	i, ok := exploreIndex(arg, len(e.chains))
+	if !ok {
+		fmt.Printf("Usage: chain <number from the last \"word\">\n")
+		return
+	}
+	e.showChain(e.chains[i])
+}
+
+func (e *explorer) walk(arg string, forward bool) {
+	if !e.hasChain {
+		fmt.Printf("Select a chain first.\n")
+		return
+	}
+	candidates := e.chain.WordsBefore
+	if forward {
+		candidates = e.chain.WordsAfter
+	}
+	i, ok := exploreIndex(arg, len(candidates))
+	if !ok {
+		fmt.Printf("Usage: before|after <number of a neighboring word>\n")
+		return
+	}
+
+	next := e.chain.Chain.PushBefore(candidates[i])
+	if forward {
+		next = e.chain.Chain.PushAfter(candidates[i])
+	}
+	e.showChain(next)
+}
+
+func (e *explorer) showChain(c ghal.Chain) {
+	info, ok := e.brain.InspectChain(c)
+	if !ok {
+		fmt.Printf("The brain doesn't know the chain %q.\n", c)
+		return
+	}
+	e.chain = info
+	e.hasChain = true
+
+	fmt.Printf("Chain: %s\n", info.Chain.Words().StringTagged())
+	if info.CanStart {
+		fmt.Printf("  (can start a sentence)\n")
+	}
+	if info.CanEnd {
+		fmt.Printf("  (can end a sentence)\n")
+	}
+	printExploreWords("preceded by", info.WordsBefore, info.BeforeCounts)
+	printExploreWords("followed by", info.WordsAfter, info.AfterCounts)
+}
+
+func (e *explorer) say() {
+	if !e.hasWord {
+		fmt.Printf("Select a word first.\n")
+		return
+	}
+	s := e.brain.MakeSentenceWithKeyword(e.word)
+	if len(s) == 0 {
+		fmt.Printf("I couldn't make a sentence with that word.\n")
+		return
+	}
+	fmt.Printf("%s\n", s)
+}
+
+// printExploreWords prints the given words that can precede or succeed a
+// chain, along with how many times each has done so.
+func printExploreWords(title string, words []ghal.Word, counts []int) {
+	fmt.Printf("  %s %d words:\n", title, len(words))
+	for i, w := range words {
+		if i == exploreListMax {
+			fmt.Printf("     (and %d more...)\n", len(words)-i)
+			break
+		}
+		fmt.Printf("  %3d. %s/%s (%d times)\n", i+1, w.Text, w.Tag, counts[i])
+	}
+}
+
+// exploreIndex parses a one-based list index given by the user, returning the
+// equivalent zero-based index if it's in range for a list of length n.
+func exploreIndex(arg string, n int) (int, bool) {
+	i, err := strconv.Atoi(arg)
+	if err != nil || i < 1 || i > n {
+		return 0, false
+	}
+	return i - 1, true
+}
+
+func exploreComplete(d prompt.Document) []prompt.Suggest {
+	text := d.TextBeforeCursor()
+	if strings.Contains(text, " ") {
+		return nil
+	}
+	return prompt.FilterHasPrefix(exploreCommands, text, true)
+}
@@ -0,0 +1,64 @@
+package ghal
+
+import (
+	"math/rand"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MakeAcrostic constructs an acrostic for the given word: one sentence for
+// each letter in the word, where each sentence begins with a word starting
+// with the corresponding letter. Characters in the given word that are not
+// letters are ignored.
+//
+// The result has one element per letter. An element is nil if the brain
+// can't construct a sentence beginning with that letter. Returns nil if the
+// given word has no letters or if no sentence can be constructed for any of
+// them.
+func (b *Brain) MakeAcrostic(word string) []Sentence {
+	var letters []rune
+	for _, r := range strings.ToLower(word) {
+		if unicode.IsLetter(r) {
+			letters = append(letters, r)
+		}
+	}
+	if len(letters) == 0 {
+		return nil
+	}
+
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	// We'll gather up all of the start chains for the letters we need in a
+	// single pass, so that we only need to visit each start chain once
+	// regardless of how long the given word is.
+	candidates := make(map[rune][]chain, len(letters))
+	for _, r := range letters {
+		candidates[r] = nil
+	}
+	b.forEachStartChain(func(c chain) {
+		first, _ := utf8.DecodeRuneInString(c[0].Text)
+		if cs, needed := candidates[first]; needed {
+			candidates[first] = append(cs, c)
+		}
+	})
+
+	ret := make([]Sentence, len(letters))
+	built := false
+	for i, r := range letters {
+		cs := candidates[r]
+		if len(cs) == 0 {
+			debugf("no start chains beginning with letter %q", r)
+			continue
+		}
+		c := cs[rand.Intn(len(cs))]
+		ret[i] = b.allowedOrNil(b.makeSentenceFromChain(c, true, false, GenerationOptions{}))
+		built = built || len(ret[i]) > 0
+	}
+	if !built {
+		return nil
+	}
+	return ret
+}
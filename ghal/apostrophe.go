@@ -0,0 +1,208 @@
+package ghal
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	prose "gopkg.in/jdkato/prose.v2"
+)
+
+// The prose tokenizer splits some contractions like "don't" into the host
+// word and its clitic, "do" and "n't", as in the Penn Treebank, but it
+// silently drops the clitic when the contraction is followed by other
+// punctuation, as in "can't,", and drops words that begin with an
+// apostrophe altogether. It also can't tell a quote from a possessive.
+//
+// To avoid all of that, separateApostrophes splits contractions and quotes
+// into separate words before the prose tokenizer sees them, which it
+// handles correctly, and decides what role each standalone apostrophe plays
+// so that it can be tagged accordingly afterwards.
+
+// clitics are the contraction suffixes that are written as words of their
+// own, with no space before them, as in "do n't" and "dog 's".
+var clitics = []string{"n't", "'s", "'ll", "'re", "'m", "'ve", "'d"}
+
+// proclitics are the words beginning with an apostrophe that aren't quoted
+// words, and so are left intact.
+var proclitics = map[string]bool{
+	"'cause": true, "'em": true, "'til": true, "'tis": true, "'twas": true,
+	"'bout": true, "'round": true,
+}
+
+// possessiveTag is the tag of an apostrophe that marks a plural possessive,
+// as in "dogs '", which the Penn Treebank tags in the same way as "'s".
+const possessiveTag = "POS"
+
+// isApostrophe returns true if the given character is an apostrophe or a
+// single quote, which are indistinguishable until we look at how they're
+// used.
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '‘' || r == '’'
+}
+
+// separateApostrophes returns the given text with each contraction clitic
+// and each apostrophe used as a quote or as a plural possessive separated
+// from the word it is attached to, along with the tags for each of the
+// resulting standalone apostrophes, in order, which are open quotes, close
+// quotes, or possessives.
+//
+// Apostrophes within words, as in "o'clock", are left alone.
+func separateApostrophes(text string) (string, []string) {
+	if !strings.ContainsAny(text, "'‘’") {
+		return text, nil
+	}
+
+	var ret strings.Builder
+	var tags []string
+	quoteOpen := false
+	closeQuote := func(word string) string {
+		switch {
+		case quoteOpen:
+			quoteOpen = false
+			return "''"
+		case strings.HasSuffix(word, "s"):
+			return possessiveTag
+		default:
+			return "''"
+		}
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isWordRune(r) && !isApostrophe(r) {
+			ret.WriteString(text[i : i+size])
+			i += size
+			continue
+		}
+		end := i + size
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !isWordRune(r) && !isApostrophe(r) {
+				break
+			}
+			end += size
+		}
+		word := text[i:end]
+		i = end
+
+		if !strings.ContainsAny(word, "'‘’") || proclitics[normalizeApostrophes(word)] {
+			ret.WriteString(word)
+			continue
+		}
+		if strings.IndexFunc(word, isLetterOrDigit) < 0 {
+			// Apostrophes on their own alternate between opening and
+			// closing quotes.
+			for range word {
+				if quoteOpen {
+					tags = append(tags, "''")
+				} else {
+					tags = append(tags, "``")
+				}
+				quoteOpen = !quoteOpen
+				ret.WriteString(" ' ")
+			}
+			continue
+		}
+
+		for {
+			r, size := utf8.DecodeRuneInString(word)
+			if !isApostrophe(r) || proclitics[normalizeApostrophes(word)] {
+				break
+			}
+			ret.WriteString(" ' ")
+			tags = append(tags, "``")
+			quoteOpen = true
+			word = word[size:]
+		}
+		trailing := 0
+		for {
+			r, size := utf8.DecodeLastRuneInString(word)
+			if !isApostrophe(r) || proclitics[normalizeApostrophes(word)] {
+				break
+			}
+			trailing++
+			word = word[:len(word)-size]
+		}
+		host, clitic := splitClitic(word)
+		ret.WriteString(host)
+		if clitic != "" {
+			// The prose tokenizer also loses a clitic that has punctuation
+			// right after it, so we separate that too.
+			ret.WriteByte(' ')
+			ret.WriteString(clitic)
+			ret.WriteByte(' ')
+		}
+		for ; trailing > 0; trailing-- {
+			ret.WriteString(" ' ")
+			tags = append(tags, closeQuote(word))
+		}
+	}
+	return ret.String(), tags
+}
+
+// splitClitic splits the given word into a host word and a contraction
+// clitic, if it ends with one, or returns the word and an empty clitic if
+// not.
+func splitClitic(word string) (host, clitic string) {
+	normal := normalizeApostrophes(word)
+	for _, c := range clitics {
+		if !strings.HasSuffix(normal, c) || len(normal) == len(c) {
+			continue
+		}
+		// The clitic in the original word may have a curly apostrophe,
+		// which is longer than the straight one we matched, so we count
+		// characters rather than bytes.
+		split := len(word)
+		for range c {
+			_, size := utf8.DecodeLastRuneInString(word[:split])
+			split -= size
+		}
+		last, _ := utf8.DecodeLastRuneInString(word[:split])
+		if !unicode.IsLetter(last) {
+			continue
+		}
+		return word[:split], word[split:]
+	}
+	return word, ""
+}
+
+// normalizeApostrophes returns the given text with any curly apostrophes
+// replaced with straight ones.
+func normalizeApostrophes(text string) string {
+	return strings.NewReplacer("‘", "'", "’", "'").Replace(text)
+}
+
+// tagApostrophes assigns the given tags, as returned by separateApostrophes,
+// to the standalone apostrophes among the given tokens, in order. If the
+// tokenizer didn't produce exactly the standalone apostrophes we expected
+// then the tokens are left as-is, for fixupParsedSentence to do its best
+// with.
+func tagApostrophes(toks []prose.Token, tags []string) {
+	var idxs []int
+	for i, tok := range toks {
+		if tok.Text == "'" {
+			idxs = append(idxs, i)
+		}
+	}
+	if len(idxs) != len(tags) {
+		return
+	}
+	for i, idx := range idxs {
+		toks[idx].Tag = tags[i]
+	}
+}
+
+// isClitic returns true if the word is a contraction clitic or a possessive
+// apostrophe, which are written with no space before them.
+func (w Word) isClitic() bool {
+	if w.Text == "'" {
+		return w.Tag == possessiveTag
+	}
+	for _, c := range clitics {
+		if w.Text == c {
+			return true
+		}
+	}
+	return false
+}
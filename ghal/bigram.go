@@ -0,0 +1,135 @@
+package ghal
+
+// bigramFallbackChains is the number of chains below which a brain will fall
+// back on its bigram model if it can't construct a sentence using its chains.
+// Larger brains have enough chains that the bigram model's much less coherent
+// sentences would be worse than saying nothing at all.
+const bigramFallbackChains = 1000
+
+// bigramModel is a low-order model of the sentences a brain has learned,
+// which records only which pairs of words have appeared consecutively. It is
+// maintained alongside a brain's chains so that a brain that has learned
+// only a few sentences, and so has too few chains to construct sentences,
+// can still say something.
+type bigramModel struct {
+	// after and before map each word to the words that have followed and
+	// preceded it, respectively.
+	after  map[Word]WordSet
+	before map[Word]WordSet
+
+	// starts and ends are the words that have started and ended sentences,
+	// respectively.
+	starts WordSet
+	ends   WordSet
+}
+
+func newBigramModel() bigramModel {
+	return bigramModel{
+		after:  make(map[Word]WordSet),
+		before: make(map[Word]WordSet),
+		starts: make(WordSet),
+		ends:   make(WordSet),
+	}
+}
+
+// addSentence teaches the model about the given sentence, which must have at
+// least one word.
+func (m *bigramModel) addSentence(s Sentence) {
+	m.starts.Add(s[0])
+	m.ends.Add(s[len(s)-1])
+	for i := 1; i < len(s); i++ {
+		m.addPair(s[i-1], s[i])
+	}
+}
+
+// addPair records that the word a has been followed by the word b.
+func (m *bigramModel) addPair(a, b Word) {
+	if _, ok := m.after[a]; !ok {
+		m.after[a] = make(WordSet)
+	}
+	m.after[a].Add(b)
+	if _, ok := m.before[b]; !ok {
+		m.before[b] = make(WordSet)
+	}
+	m.before[b].Add(a)
+}
+
+// addChain teaches the model about the given chain, which may be a start
+// chain or an end chain or both. This is used to reconstruct the model from
+// a brain's chains, for brain files saved before the model existed.
+func (m *bigramModel) addChain(c chain, canStart, canEnd bool) {
+	if canStart {
+		m.starts.Add(c[0])
+	}
+	if canEnd {
+		m.ends.Add(c.last())
+	}
+	for i := 1; i < c.len(); i++ {
+		m.addPair(c[i-1], c[i])
+	}
+}
+
+// makeBigramSentence constructs a sentence containing the given keyword using
+// only the brain's bigram model, returning nil if the model doesn't know the
+// keyword. The caller must hold at least a read lock on the brain.
+//
+// mustBeStart and mustBeEnd have the same meaning as for makeSentence.
+func (b *Brain) makeBigramSentence(w Word, mustBeStart, mustBeEnd bool, opts GenerationOptions) Sentence {
+	if len(b.bigramsAfter(w)) == 0 && len(b.bigramsBefore(w)) == 0 && !b.bigramStarts(w) {
+		return nil
+	}
+	opts = b.withoutTaboo(opts)
+	if mustBeStart && !b.bigramStarts(w) {
+		return nil
+	}
+	if mustBeEnd && !b.bigramEnds(w) {
+		return nil
+	}
+
+	var before []Word // Built in reverse order first, and then reversed
+	current := w
+	for !mustBeStart {
+		if len(before) >= maxExtendWords {
+			debugf("gave up extending %s backwards in bigram model", w)
+			return nil
+		}
+		candidates := opts.allowedBefore(current, b.bigramsBefore(current))
+		if b.bigramStarts(current) && (len(candidates) == 0 || !opts.shouldContinue(false, len(before)+1)) {
+			break
+		}
+		if len(candidates) == 0 {
+			debugf("dead end: %s can't start a sentence in bigram model", current)
+			return nil
+		}
+		current = b.chooseWord(candidates, nil, opts)
+		before = append(before, current)
+	}
+
+	var after []Word
+	current = w
+	for !mustBeEnd {
+		if len(after) >= maxExtendWords {
+			debugf("gave up extending %s forwards in bigram model", w)
+			return nil
+		}
+		candidates := opts.allowedAfter(current, b.bigramsAfter(current))
+		if b.bigramEnds(current) && opts.Style.acceptsEnd(current, b.terms()) && (len(candidates) == 0 || !opts.shouldContinue(true, len(before)+len(after)+1)) {
+			break
+		}
+		if len(candidates) == 0 {
+			debugf("dead end: %s can't end a %s in bigram model", current, opts.Style)
+			return nil
+		}
+		current = b.chooseWord(candidates, nil, opts)
+		after = append(after, current)
+	}
+
+	ret := make(Sentence, 0, len(before)+1+len(after))
+	for i := len(before) - 1; i >= 0; i-- {
+		ret = append(ret, before[i])
+	}
+	ret = append(ret, w)
+	ret = append(ret, after...)
+	debugf("bigram model constructed %q", ret)
+	return balanceQuotes(ret)
+}
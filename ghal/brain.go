@@ -1,20 +1,38 @@
 package ghal
 
 import (
+	"fmt"
+	"math"
 	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// continueChance is the number of times out of 256 that we'll prefer to
-// continue constructing a sentence even though we've reached a valid end
-// point.
-const continueChance = 128
+// maxExtendWords is the maximum number of words we'll add in each direction
+// when extending a sequence of words into a whole sentence. Sentences in real
+// text are far shorter than this, so reaching it means that we've found a
+// cycle of chains that never reaches the start or end of a sentence.
+const maxExtendWords = 1000
+
+// defaultContinueChance is the probability that we'll continue constructing
+// a sentence even though we've reached a valid start or end point, unless
+// the generation options select another.
+const defaultContinueChance = 0.5
 
 // Brain is the main type in this package, containing all of the state for a
 // single instance of the chatbot.
 type Brain struct {
 	mut sync.RWMutex
 
+	// order is the number of words in each of this brain's chains, which
+	// is fixed when the brain is created.
+	order int
+
+	// frozen is set by freeze, after which the brain's contents never
+	// change and so readers need not acquire mut.
+	frozen atomic.Bool
+
 	// wordChains is a map from each of the words this brain knows to
 	// the chains containing those words.
 	wordChains map[Word]chainSet
@@ -22,73 +40,331 @@ type Brain struct {
 	// chains is a set containing all of the chains this brain knows.
 	chains chainSet
 
+	// firstWordChains and lastWordChains are maps from words to the chains
+	// that begin and end with them respectively, which are subsets of the
+	// corresponding wordChains sets.
+	firstWordChains map[Word]chainSet
+	lastWordChains  map[Word]chainSet
+
 	// wordsAfter and wordsBefore describe which words can succeed or
 	// precede (respectively) each chain.
 	wordsAfter  map[chain]WordSet
 	wordsBefore map[chain]WordSet
 
+	// afterCounts and beforeCounts are the number of times each of the
+	// transitions in wordsAfter and wordsBefore were learned, for those
+	// learned more than once.
+	afterCounts  transitionCounts
+	beforeCounts transitionCounts
+
 	// startChains and endChains are the chains that can start or end sentences,
 	// respectively.
 	startChains chainSet
 	endChains   chainSet
+
+	// startCounts and endCounts are the number of times each of the chains
+	// in startChains and endChains started or ended a sentence, for those
+	// that did so more than once.
+	startCounts chainCounts
+	endCounts   chainCounts
+
+	// discouraged is the number of times each chain has been discouraged
+	// using Discourage less the number of times it has been reinforced
+	// using Reinforce, for those where these differ.
+	discouraged map[chain]int
+
+	// startChainsByFirst and endChainsByLast are maps from words to the
+	// start chains beginning with them and the end chains ending with them,
+	// respectively.
+	startChainsByFirst map[Word]chainSet
+	endChainsByLast    map[Word]chainSet
+
+	// sources is a table of names of the training sources sentences have
+	// been learned from, and sourceIdxs is the reverse index of that table.
+	// chainSources records which of those sources contributed each chain,
+	// as indices into sources. These are populated only for sentences
+	// learned using AddSentencesFrom. sourceHashes has the hash of each
+	// source's name, for choosing which sources to keep for each chain.
+	sources      []string
+	sourceHashes []uint64
+	sourceIdxs   map[string]int
+	chainSources map[chain][]int
+
+	// sourceTimes is the earliest time recorded for each source using
+	// AddUtterances, keyed by source name.
+	sourceTimes map[string]time.Time
+
+	// bigrams is a low-order model of the same sentences the chains were
+	// built from, used when the brain is too small to construct sentences
+	// using chains alone.
+	bigrams bigramModel
+
+	// lowOrder is a model of the same sentences the chains were built from
+	// but with a shorter context, which can be interpolated with the chains
+	// during generation.
+	lowOrder lowOrderModel
+
+	// responseChains records, for each content word, some of the chains
+	// that were used in response to messages containing that word in
+	// dialogues learned using AddDialogue.
+	responseChains map[Word]chainSet
+
+	// responseMax caches the chain with the greatest hash in each full set
+	// of response chains, which is the next to be replaced. An entry must
+	// be removed whenever its set changes other than by addResponseChain.
+	responseMax map[Word]chain
+
+	// terminators is the terminating punctuation configured using
+	// SetTerminators, or nil if it hasn't been configured.
+	terminators *Terminators
+
+	// taboo is the set of words that are never used in generated sentences,
+	// as recorded using TabooWord.
+	taboo WordSet
+
+	// swaps maps the text of words to the text of the words they are
+	// swapped for when choosing keywords, as configured using SetSwap.
+	swaps map[string]string
+
+	// filter decides which sentences the brain may learn and say, as set
+	// using SetFilter, or is nil if it may learn and say anything.
+	filter func(Sentence) bool
+
+	// greetings is the text of the words used as keywords for greetings, as
+	// configured using SetGreetings, or nil if they haven't been configured.
+	greetings []string
+
+	// casing records how often words were capitalized in the brain's
+	// training material, keyed by the lowercase text of each word, as
+	// recorded using AddCaseCounts.
+	casing map[string]CaseCount
+
+	// wordMeta is a table of arbitrary metadata recorded for words using
+	// SetWordMeta, keyed by word and then by metadata key.
+	wordMeta map[Word]map[string]string
+
+	// trained is the training manifest recorded using RecordTrainedSource,
+	// keyed by source name.
+	trained map[string]TrainedSource
+
+	// learning is the recent history of how quickly this brain has been
+	// learning, which is not saved.
+	learning learningHistory
+
+	// strs is the table of interned strings used by the words in this
+	// brain, so that the text and tag of each distinct word are stored only
+	// once no matter how many chains and sets the word belongs to.
+	strs stringTable
+
+	// base is the brain this brain is layered over, if it was created using
+	// NewOverlay or LoadOverlay, or nil otherwise. The base is always frozen.
+	base *Brain
 }
 
 // NewBrain allocates and returns a new, empty brain, devoid of knowledge and
 // ready to learn.
 func NewBrain() *Brain {
+	return newBrainSized(DefaultOrder, 0, 0)
+}
+
+// NewBrainWithOrder is like NewBrain but creates a brain whose chains have
+// the given number of words, which must be between MinOrder and MaxOrder
+// inclusive.
+//
+// A brain with a lower order can recombine what it has learned more freely,
+// and so can say more different things after learning from less material,
+// but is more likely to say things that make no sense. A brain with a higher
+// order needs more material but tends to quote it more faithfully.
+func NewBrainWithOrder(n int) (*Brain, error) {
+	if n < MinOrder || n > MaxOrder {
+		return nil, fmt.Errorf("order must be between %d and %d, not %d", MinOrder, MaxOrder, n)
+	}
+	return newBrainSized(n, 0, 0), nil
+}
+
+// newBrainSized is like NewBrainWithOrder but pre-sizes the brain's maps for
+// the given number of words and chains, to avoid repeatedly growing them when
+// the eventual size is known in advance, such as when loading a brain file.
+// The caller must ensure that the order is valid.
+func newBrainSized(order, words, chains int) *Brain {
 	return &Brain{
-		wordChains:  make(map[Word]chainSet),
-		chains:      make(chainSet),
-		wordsAfter:  make(map[chain]WordSet),
-		wordsBefore: make(map[chain]WordSet),
-		startChains: make(chainSet),
-		endChains:   make(chainSet),
+		order:              order,
+		wordChains:         make(map[Word]chainSet, words),
+		chains:             make(chainSet, chains),
+		firstWordChains:    make(map[Word]chainSet, words),
+		lastWordChains:     make(map[Word]chainSet, words),
+		wordsAfter:         make(map[chain]WordSet, chains),
+		wordsBefore:        make(map[chain]WordSet, chains),
+		afterCounts:        make(transitionCounts),
+		beforeCounts:       make(transitionCounts),
+		startCounts:        make(chainCounts),
+		endCounts:          make(chainCounts),
+		discouraged:        make(map[chain]int),
+		startChains:        make(chainSet),
+		endChains:          make(chainSet),
+		startChainsByFirst: make(map[Word]chainSet),
+		endChainsByLast:    make(map[Word]chainSet),
+		sourceIdxs:         make(map[string]int),
+		chainSources:       make(map[chain][]int),
+		sourceTimes:        make(map[string]time.Time),
+		bigrams:            newBigramModel(),
+		lowOrder:           newLowOrderModel(),
+		responseChains:     make(map[Word]chainSet),
+		responseMax:        make(map[Word]chain),
+		taboo:              make(WordSet),
+		swaps:              make(map[string]string),
+		casing:             make(map[string]CaseCount),
+		wordMeta:           make(map[Word]map[string]string),
+		trained:            make(map[string]TrainedSource),
+		strs:               make(stringTable, words),
 	}
 }
 
+// Order returns the number of words in each of the brain's chains, as
+// given to NewBrainWithOrder, or DefaultOrder for a brain created using
+// NewBrain.
+func (b *Brain) Order() int {
+	return b.order
+}
+
 // AddSentence teaches the brain about the given sentence, allowing parts of
 // it to be used in constructing replies.
+//
+// AddSentence panics if the brain has been frozen using Freeze.
 func (b *Brain) AddSentence(s Sentence) {
-	if len(s) < chainLen {
-		// We need at least enough words to make one chain.
+	if len(s) == 0 {
 		return
 	}
 
-	b.mut.Lock()
+	b.lock()
 	defer b.mut.Unlock()
+	b.addSentence(s, noSource, 1)
+}
 
-	maxIdx := len(s) - (chainLen - 1)
-	for i := 0; i < maxIdx; i++ {
-		chn := makeChain(s[i : i+chainLen])
-		b.chains.Add(chn)
+// AddSentenceWeighted is like AddSentence but counts the sentence as if it
+// had been learned the given number of times, so that sentences from
+// important sources, such as the operator's own writing, have more
+// influence over which words the brain chooses than those from bulk
+// sources, such as text scraped from the web. A weight of zero or less
+// teaches the brain nothing.
+//
+// The weight affects only how often the brain chooses each word, and not
+// which words it knows, so it has no effect when generating with
+// UniformTransitions set in the GenerationOptions.
+//
+// AddSentenceWeighted panics if the brain has been frozen using Freeze.
+func (b *Brain) AddSentenceWeighted(s Sentence, weight int) {
+	if len(s) == 0 || weight <= 0 {
+		return
+	}
 
-		for _, w := range chn {
-			if _, ok := b.wordChains[w]; !ok {
-				b.wordChains[w] = make(chainSet)
-			}
-			b.wordChains[w].Add(chn)
+	b.lock()
+	defer b.mut.Unlock()
+	b.addSentence(s, noSource, weight)
+}
+
+// addSentence is the main implementation of AddSentence and
+// AddSentenceWeighted, which expects the caller to already be holding the
+// write lock. If src is not noSource then it is recorded as a source of each
+// of the sentence's chains. The sentence is counted as having been learned
+// weight times, which must be at least one. It returns false if the
+// sentence was empty or rejected by the brain's filter, and so wasn't
+// learned.
+func (b *Brain) addSentence(s Sentence, src int, weight int) bool {
+	if len(s) == 0 || !b.allows(s) {
+		return false
+	}
+	s = b.strs.internSentence(s)
+
+	// Sentences too short to make even one chain still contribute to the
+	// bigram model, which is what allows a brand new brain to say something.
+	b.bigrams.addSentence(s)
+	if len(s) < b.order {
+		b.learning.noteLearned(time.Now(), 0)
+		return true
+	}
+
+	maxIdx := len(s) - (b.order - 1)
+	newChains := 0
+	for i := 0; i < maxIdx; i++ {
+		chn := makeChain(s[i : i+b.order])
+		if b.addChain(chn) && (b.base == nil || !b.base.hasChain(chn)) {
+			newChains++
+		}
+		if src != noSource {
+			b.addChainSource(chn, src)
 		}
 
 		if i == 0 {
-			b.startChains.Add(chn)
+			b.addStartChain(chn, weight)
 		} else {
 			// The previous word can precede this chain.
-			if _, ok := b.wordsBefore[chn]; !ok {
-				b.wordsBefore[chn] = make(WordSet)
-			}
-			b.wordsBefore[chn].Add(s[i-1])
+			addTransitions(b.wordsBefore, b.beforeCounts, chn, s[i-1], weight)
 		}
 
 		if i == (maxIdx - 1) {
-			b.endChains.Add(chn)
+			b.addEndChain(chn, weight)
 		} else {
 			// The following word can succeed this chain.
-			if _, ok := b.wordsAfter[chn]; !ok {
-				b.wordsAfter[chn] = make(WordSet)
-			}
-			b.wordsAfter[chn].Add(s[i+chainLen])
+			addTransitions(b.wordsAfter, b.afterCounts, chn, s[i+b.order], weight)
 		}
+		b.lowOrder.addChain(chn, i == 0, i == maxIdx-1)
 	}
+	b.learning.noteLearned(time.Now(), newChains)
+	return true
+}
+
+// addChain adds the given chain to the brain's set of known chains and to
+// the indices of chains by word, if it isn't already present, returning
+// true if it was added. The caller must hold the write lock on the brain.
+func (b *Brain) addChain(c chain) bool {
+	if b.chains.Has(c) {
+		return false
+	}
+	b.chains.Add(c)
+	for _, w := range c.words() {
+		addToChainIndex(b.wordChains, w, c)
+	}
+	addToChainIndex(b.firstWordChains, c[0], c)
+	addToChainIndex(b.lastWordChains, c.last(), c)
+	return true
+}
+
+// addStartChain records that the given chain, which must already be known
+// to the brain, has started a sentence n more times. The caller must hold
+// the write lock on the brain.
+func (b *Brain) addStartChain(c chain, n int) {
+	if !b.startChains.Has(c) {
+		b.startChains.Add(c)
+		addToChainIndex(b.startChainsByFirst, c[0], c)
+		n--
+	}
+	if n > 0 {
+		b.startCounts.add(c, n)
+	}
+}
+
+// addEndChain records that the given chain, which must already be known
+// to the brain, has ended a sentence n more times. The caller must hold
+// the write lock on the brain.
+func (b *Brain) addEndChain(c chain, n int) {
+	if !b.endChains.Has(c) {
+		b.endChains.Add(c)
+		addToChainIndex(b.endChainsByLast, c.last(), c)
+		n--
+	}
+	if n > 0 {
+		b.endCounts.add(c, n)
+	}
+}
+
+// addToChainIndex adds the given chain to the set for the given word in
+// the given index, creating the set if necessary.
+func addToChainIndex(idx map[Word]chainSet, w Word, c chain) {
+	if _, ok := idx[w]; !ok {
+		idx[w] = make(chainSet)
+	}
+	idx[w].Add(c)
 }
 
 // AddSentences teaches the brain about all of the given sentences. This is
@@ -104,13 +380,34 @@ func (b *Brain) AddSentences(ss []Sentence) {
 //
 // Will return nil if no sentence can be constructed for the given keyword.
 func (b *Brain) MakeSentenceWithKeyword(w Word) Sentence {
-	return b.makeSentence(w, false, false)
+	return b.makeSentence(w, false, false, GenerationOptions{})
+}
+
+// MakeSentenceWithOptions is like MakeSentenceWithKeyword but allows the
+// caller to customize how the sentence is generated, including its maximum
+// length, how likely it is to continue growing, and how many times to retry
+// when a sentence doesn't meet the constraints in the options. The options
+// that apply only to replies, MinNovelty, Keywords, Generators, Scorers, and
+// Hashtags, are ignored.
+//
+// Will return nil if no sentence meeting the constraints can be constructed
+// for the given keyword.
+func (b *Brain) MakeSentenceWithOptions(w Word, opts GenerationOptions) Sentence {
+	opts.MinNovelty = 0
+	return b.makeReplyCandidate(w, nil, opts)
 }
 
 // MakeSentenceStartingKeyword is like MakeSentenceWithKeyword but the given
 // keyword must begin the sentence.
 func (b *Brain) MakeSentenceStartingKeyword(w Word) Sentence {
-	return b.makeSentence(w, true, false)
+	return b.makeSentence(w, true, false, GenerationOptions{})
+}
+
+// MakeSentenceEndingKeyword is like MakeSentenceWithKeyword but the given
+// keyword must end the sentence. This is most useful with sentence-terminating
+// punctuation, as in MakeQuestion.
+func (b *Brain) MakeSentenceEndingKeyword(w Word) Sentence {
+	return b.makeSentence(w, false, true, GenerationOptions{})
 }
 
 // MakeReply takes one or more sentences and constructs a sentence in reply
@@ -123,6 +420,39 @@ func (b *Brain) MakeSentenceStartingKeyword(w Word) Sentence {
 // know anything about the words in the given sentence. This is particularly
 // likely for smaller brains. In that case, the return value is a nil Sentence.
 func (b *Brain) MakeReply(ss ...Sentence) Sentence {
+	return b.MakeReplyScored(ss...).Sentence
+}
+
+// ScoredReply is a reply sentence along with some information about how it
+// was selected, as returned by Brain.MakeReplyScored.
+type ScoredReply struct {
+	// Sentence is the selected reply, or nil if no reply could be constructed.
+	Sentence Sentence
+
+	// Score is the relevance score assigned to the selected reply. Higher
+	// scores indicate that the reply has more in common with the input.
+	// The absolute value of a score is not meaningful, but scores for replies
+	// to the same or similar input can be compared to decide whether a
+	// reply is relevant enough to be worth sending.
+	Score int
+
+	// Candidates is the number of candidate sentences that were generated
+	// and scored in order to select the reply.
+	Candidates int
+}
+
+// MakeReplyScored is like MakeReply but also returns the relevance score of
+// the selected reply and the number of candidate sentences it was chosen
+// from. Callers can use this information to stay silent rather than
+// returning a barely-relevant reply.
+func (b *Brain) MakeReplyScored(ss ...Sentence) ScoredReply {
+	return b.MakeReplyWithOptions(GenerationOptions{}, ss...)
+}
+
+// MakeReplyWithOptions is like MakeReplyScored but allows the caller to
+// customize how the reply is generated.
+func (b *Brain) MakeReplyWithOptions(opts GenerationOptions, ss ...Sentence) ScoredReply {
+	input := ss
 	var allWords, nouns, properNouns WordSet
 	for _, s := range ss {
 		allWords = allWords.Union(s.Words())
@@ -130,45 +460,35 @@ func (b *Brain) MakeReply(ss ...Sentence) Sentence {
 		properNouns = properNouns.Union(s.ProperNouns())
 	}
 
-	keywords := properNouns
-	if len(keywords) < 2 {
-		// If there's only one proper noun in the sentences (likely) then we'll
-		// add the regular nouns into the mix too just so the responses aren't
-		// always so predictable when proper nouns are present. The priority
-		// we give to proper nouns during scoring will still serve to prioritize
-		// responses containing these, but there will be some small chance of
-		// selecting a sentence about something else if it has enough similar
-		// regular nouns.
-		keywords = nouns
-	}
-	if len(keywords) == 0 {
-		// If the sentence has no nouns then we don't have anything to say about it.
-		return nil
+	keywords := b.withoutTabooWords(b.swapKeywords(opts.keywordExtractor().Keywords(b, input)))
+	if len(keywords) == 0 && opts.Generators == nil {
+		// If the sentence has no keywords then we don't have anything to say
+		// about it, although other generators might.
+		return ScoredReply{}
 	}
 
 	debugf("building replies with keywords: %s", keywords)
 
-	// We'll try to produce a sentence for each of our keywords to start,
-	// and then we'll score those sentences by how many other
-	ss = make([]Sentence, 0, len(keywords))
-	for w := range keywords {
-		s := b.MakeSentenceWithKeyword(w)
-		if len(s) > 0 {
-			ss = append(ss, s)
-		}
-	}
+	// We'll collect candidate sentences from each of the generators, which
+	// by default make a sentence around each of our keywords, and then
+	// we'll score those sentences by how many words they share with the
+	// input.
+	ss = b.generateCandidates(ReplyRequest{
+		Input:    input,
+		Keywords: keywords,
+		Topic:    opts.topic,
+		Options:  opts,
+	})
 
 	if len(ss) == 0 {
 		debugf("no sentences were generated")
-		return nil
-	}
-	if len(ss) == 1 {
-		debugf("only on sentence generated, so it wins by default")
-		return ss[0]
+		return ScoredReply{}
 	}
 
-	var bestSentence Sentence
-	bestScore := -1
+	ret := ScoredReply{
+		Score:      -1,
+		Candidates: len(ss),
+	}
 	for _, s := range ss {
 		score := 0
 		for _, w := range s {
@@ -189,16 +509,88 @@ func (b *Brain) MakeReply(ss ...Sentence) Sentence {
 				score++
 			}
 		}
-		if score > bestScore {
-			bestScore = score
-			bestSentence = s
+		for _, scorer := range opts.Scorers {
+			score += scorer(s, input)
+		}
+		if score > ret.Score {
+			ret.Score = score
+			ret.Sentence = s
 			debugf("sentence %q was assigned score %d, which is the new winner", s, score)
 		} else {
 			debugf("sentence %q was assigned score %d, which is not good enough to beat the winner", s, score)
 		}
 	}
 
-	return bestSentence
+	if opts.Hashtags > 0 {
+		if tagged := b.addHashtags(ret.Sentence, keywords, opts); b.Allows(tagged) {
+			ret.Sentence = tagged
+		}
+	}
+	return ret
+}
+
+// makeReplyCandidate constructs a candidate reply sentence containing the
+// given keyword that meets the constraints in the given options, returning
+// nil if no suitable sentence can be constructed.
+//
+// input is the set of all of the words in the sentences being replied to.
+func (b *Brain) makeReplyCandidate(w Word, input WordSet, opts GenerationOptions) Sentence {
+	for i := 0; i < opts.attempts(); i++ {
+		s := b.makeSentence(w, false, false, opts)
+		if len(s) == 0 {
+			// If we can't make any sentence at all then retrying won't help.
+			return nil
+		}
+		if (opts.MaxWords > 0 && len(s) > opts.MaxWords) || opts.MaxChars > 0 {
+			locked := b.rlock()
+			s = b.trimToLength(s, opts.MaxWords, w)
+			if len(s) > 0 {
+				s = b.trimToChars(s, opts.MaxChars, w)
+			}
+			if locked {
+				b.mut.RUnlock()
+			}
+			if len(s) == 0 {
+				continue
+			}
+		}
+		if acceptableReply(s, input, opts, b.Terminators()) {
+			return s
+		}
+	}
+	debugf("no acceptable sentence for keyword %s", w)
+	return nil
+}
+
+// acceptableReply returns true if the given candidate reply meets all of the
+// constraints in the given options.
+func acceptableReply(s Sentence, input WordSet, opts GenerationOptions, terms Terminators) bool {
+	if !opts.Style.acceptsEnd(s[len(s)-1], terms) {
+		// This can happen if the sentence was trimmed to meet MaxWords.
+		debugf("sentence %q is not a %s", s, opts.Style)
+		return false
+	}
+	if opts.MinNovelty > 0 {
+		if novelty := s.Novelty(input); novelty < opts.MinNovelty {
+			debugf("sentence %q has novelty %.2f, below minimum %.2f", s, novelty, opts.MinNovelty)
+			return false
+		}
+	}
+	// Transitions are enforced while sentences are constructed, but the
+	// chain containing the keyword comes from the brain as-is.
+	for i := 1; i < len(s); i++ {
+		if !opts.allowsTransition(s[i-1], s[i]) {
+			debugf("sentence %q has a forbidden transition from %s to %s", s, s[i-1], s[i])
+			return false
+		}
+	}
+	for _, constraint := range opts.Constraints {
+		if !constraint(s) {
+			debugf("sentence %q does not meet all of the constraints", s)
+			return false
+		}
+	}
+	return true
 }
 
 // MakeQuestion constructs a random question sentence using all of the
@@ -209,7 +601,16 @@ func (b *Brain) MakeReply(ss ...Sentence) Sentence {
 // any sentences that terminate with a question mark.
 func (b *Brain) MakeQuestion() Sentence {
 	debugf("building a question sentence")
-	return b.makeSentence(QuestionMark, false, true)
+	// We'll try each of the question terminators in a random order, so
+	// that a brain that knows several will use all of them.
+	texts := b.Terminators().Question
+	for _, i := range rand.Perm(len(texts)) {
+		s := b.MakeSentenceEndingKeyword(MakeWord(".", texts[i]))
+		if len(s) > 0 {
+			return s
+		}
+	}
+	return nil
 }
 
 // MakeReason constructs a random constructs a response question starting
@@ -219,15 +620,39 @@ func (b *Brain) MakeQuestion() Sentence {
 // any sentences that begin with the word.
 func (b *Brain) MakeReason() Sentence {
 	debugf("building a reason sentence")
-	return b.makeSentence(QuestionMark, true, false)
+	return b.makeSentence(QuestionMark, true, false, GenerationOptions{})
 }
 
-func (b *Brain) makeSentence(w Word, mustBeStart bool, mustBeEnd bool) Sentence {
-	b.mut.RLock()
-	defer b.mut.RUnlock()
+func (b *Brain) makeSentence(w Word, mustBeStart bool, mustBeEnd bool, opts GenerationOptions) Sentence {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
 
+	if b.isTaboo(w) {
+		debugf("keyword %s is taboo", w)
+		return nil
+	}
+	// A sentence the filter rejects may be followed by one it allows, so
+	// we'll give the filter a few chances.
+	for i := 0; i < opts.attempts(); i++ {
+		s := b.makeChainSentence(w, mustBeStart, mustBeEnd, opts)
+		if len(s) == 0 && b.chainCount() < bigramFallbackChains {
+			debugf("falling back on bigram model for keyword %s", w)
+			s = b.makeBigramSentence(w, mustBeStart, mustBeEnd, opts)
+		}
+		if b.allows(s) {
+			return s
+		}
+	}
+	return nil
+}
+
+// makeChainSentence is the main implementation of makeSentence, which
+// constructs a sentence using the brain's chains. The caller must hold at
+// least a read lock on the brain.
+func (b *Brain) makeChainSentence(w Word, mustBeStart bool, mustBeEnd bool, opts GenerationOptions) Sentence {
 	debugf("building a sentence for keyword %s", w)
-	chains := b.wordChains[w]
+	chains := b.chainsWithoutTaboo(b.chainsWithWord(w))
 	if len(chains) == 0 {
 		// If we don't know the given word, we can't make a sentence.
 		return nil
@@ -237,54 +662,102 @@ func (b *Brain) makeSentence(w Word, mustBeStart bool, mustBeEnd bool) Sentence
 	// build sequences of words pseudorandomly both before and after that
 	// chain until we've got a complete sentence (starting and ending with
 	// chains from startChains and endChains as appropriate).
-	var middleChain chain
-	var before []Word // Built in reverse order first, and then reversed
-	var after []Word
-	if mustBeEnd {
-		// This case is trickier since we need to scan over potentially
-		// multiple chains containing our keyword until we find one that
-		// is both an end chain _and_ has the keyword at the end. This special
-		// case is used only to match terminal punctuation like question marks,
-		// and so we expect that _most_ chains containing these will meet
-		// our criteria, and we'll only be skipping odd situations like
-		// embedded quotations containing question marks.
-		for c := range chains {
-			if c[chainLen-1] != w || !b.endChains.Has(c) {
-				continue
-			}
-			middleChain = c
-			break
-		}
-	} else if mustBeStart {
-		foundOne := false
-		for c := range chains {
-			if c[0] != w || !b.startChains.Has(c) {
-				continue
-			}
-			middleChain = c
-			foundOne = true
-			break
+	var candidates chainSet
+	switch {
+	case mustBeEnd:
+		// The chain must both end with the keyword and be able to end a
+		// sentence, and our index of end chains by last word gives us
+		// exactly those chains.
+		candidates = b.chainsWithoutTaboo(b.endChainsWith(w))
+		if len(candidates) == 0 {
+			debugf("no end chains ending with %s", w)
+			return nil
 		}
-		if !foundOne {
+	case mustBeStart:
+		candidates = b.chainsWithoutTaboo(b.startChainsWith(w))
+		if len(candidates) == 0 {
 			debugf("no start chains beginning with %s", w)
 			return nil
 		}
-	} else {
+	default:
 		// Things are simpler if the keyword can be anywhere.
-		middleChain = chains.ChooseOneRandom()
+		candidates = chains
+	}
+
+	attempts := 1
+	if len(opts.Transitions) > 0 || b.hasTaboos() {
+		// Words that we mustn't use can leave some chains with no way to
+		// complete a sentence, so we'll give a few others a chance too.
+		attempts = opts.attempts()
+	}
+	for i := 0; i < attempts; i++ {
+		s := b.makeSentenceFromChain(opts.chooseChain(candidates), mustBeStart, mustBeEnd, opts)
+		if len(s) > 0 {
+			return s
+		}
 	}
+	return nil
+}
 
+// makeSentenceFromChain builds a sentence by pseudorandomly extending the
+// given chain in both directions until it reaches a start chain and an end
+// chain. The caller must hold at least a read lock on the brain.
+//
+// If fixedStart is set then the given chain must be a start chain and it
+// will not be extended backwards at all, so that it begins the sentence.
+// Likewise, if fixedEnd is set then the given chain must be an end chain
+// and it will end the sentence.
+func (b *Brain) makeSentenceFromChain(middleChain chain, fixedStart, fixedEnd bool, opts GenerationOptions) Sentence {
 	debugf("starting chain is %s", middleChain)
+	return b.completeSentence(middleChain.words(), fixedStart, fixedEnd, opts)
+}
+
+// completeSentence builds a sentence by pseudorandomly extending the given
+// sequence of words in both directions until it reaches a start chain and
+// an end chain. The given sequence must have at least as many words as the
+// brain's order, and its first and last words of that many must each be
+// chains the brain knows.
+// The caller must hold at least a read lock on the brain.
+//
+// fixedStart and fixedEnd have the same meaning as for makeSentenceFromChain.
+//
+// Returns nil if the sequence can't be extended into a complete sentence
+// within a reasonable number of words, or if it reaches a chain that is
+// neither a start (or end) chain nor has any words before (or after) it,
+// which can happen if the brain has been pruned or was only partially loaded.
+func (b *Brain) completeSentence(middle []Word, fixedStart, fixedEnd bool, opts GenerationOptions) Sentence {
+	if len(middle) < b.order {
+		debugf("can't complete %q, which is shorter than a chain", Sentence(middle))
+		return nil
+	}
+	if b.containsTaboo(middle) {
+		debugf("can't complete %q, which contains a taboo word", Sentence(middle))
+		return nil
+	}
+	opts = b.withoutTaboo(opts)
+
+	var before []Word // Built in reverse order first, and then reversed
+	var after []Word
 
 	// First we will work backwards to the beginning of the sentence.
-	current := middleChain
+	current := makeChain(middle[:b.order])
 	for {
-		if b.startChains.Has(current) {
-			if len(b.wordsBefore[current]) > 0 {
+		if len(before) >= maxExtendWords {
+			// We've probably found a cycle of chains that never reaches
+			// a start chain, so we'll give up rather than loop forever.
+			debugf("gave up extending %q backwards after %d words", Sentence(middle), len(before))
+			return nil
+		}
+		high, low, canStart := b.wordsBeforeMixed(current, opts)
+		if canStart {
+			if fixedStart {
+				break
+			}
+			if len(high)+len(low) > 0 {
 				// If this is both a start chain _and_ a chain with words before
-				// then we'll have a small random chance to continue growing
-				// the sentence rather than stopping here.
-				if rand.Intn(256) >= continueChance {
+				// then we'll have a random chance to continue growing the
+				// sentence rather than stopping here.
+				if !opts.shouldContinue(false, len(before)+len(middle)) {
 					break
 				}
 			} else {
@@ -296,21 +769,41 @@ func (b *Brain) makeSentence(w Word, mustBeStart bool, mustBeEnd bool) Sentence
 		// Choose randomly one word that has preceeded this chain before,
 		// thus adding one more word to the beginning of our sentence and
 		// selecting a new chain for the next iteration.
-		newWord := b.wordsBefore[current].ChooseOneRandom() // must exist if not in startChains
+		if len(high)+len(low) == 0 {
+			debugf("dead end: %s is not a start chain but has no words before it", current)
+			return nil
+		}
+		newWord := b.chooseMixedWord(high, low, func(w Word) float64 {
+			next := current
+			next.PushBefore(w)
+			return float64(b.beforeCount(current, w)) * b.chainWeight(next)
+		}, opts)
 		before = append(before, newWord)
 		current.PushBefore(newWord)
 	}
 	debugf("before words are %s", before)
 
 	// Now we'll work forwards to the end of the sentence, in the same way.
-	current = middleChain
+	terms := b.terms()
+	current = makeChain(middle[len(middle)-b.order:])
 	for {
-		if b.endChains.Has(current) {
-			if len(b.wordsAfter[current]) > 0 {
+		if len(after) >= maxExtendWords {
+			debugf("gave up extending %q forwards after %d words", Sentence(middle), len(after))
+			return nil
+		}
+		high, low, canEnd := b.wordsAfterMixed(current, opts)
+		if canEnd {
+			if fixedEnd {
+				break
+			}
+			if !opts.Style.acceptsEnd(current.last(), terms) {
+				// This chain can end a sentence, but not in the requested
+				// style, so we must keep going.
+			} else if len(high)+len(low) > 0 {
 				// If this is both an end chain _and_ a chain with words after
-				// then we'll have a small random chance to continue growing
-				// the sentence rather than stopping here.
-				if rand.Intn(256) >= continueChance {
+				// then we'll have a random chance to continue growing the
+				// sentence rather than stopping here.
+				if !opts.shouldContinue(true, len(before)+len(middle)+len(after)) {
 					break
 				}
 			} else {
@@ -322,18 +815,89 @@ func (b *Brain) makeSentence(w Word, mustBeStart bool, mustBeEnd bool) Sentence
 		// Choose randomly one word that has preceeded this chain before,
 		// thus adding one more word to the beginning of our sentence and
 		// selecting a new chain for the next iteration.
-		newWord := b.wordsAfter[current].ChooseOneRandom() // must exist if not in endChains
+		if len(high)+len(low) == 0 {
+			debugf("dead end: %s can't end a %s but has no words after it", current, opts.Style)
+			return nil
+		}
+		newWord := b.chooseMixedWord(high, low, func(w Word) float64 {
+			next := current
+			next.PushAfter(w)
+			return float64(b.afterCount(current, w)) * b.chainWeight(next)
+		}, opts)
 		after = append(after, newWord)
 		current.PushAfter(newWord)
 	}
 	debugf("after words are %s", after)
 
-	wordCount := len(before) + len(middleChain) + len(after)
+	wordCount := len(before) + len(middle) + len(after)
 	ret := make(Sentence, 0, wordCount)
 	for i := len(before) - 1; i >= 0; i-- { // the "before" sequence is in reverse order
 		ret = append(ret, before[i])
 	}
-	ret = append(ret, middleChain[:]...)
+	ret = append(ret, middle...)
 	ret = append(ret, after...)
-	return ret
+	return balanceQuotes(ret)
+}
+
+// chooseWord selects one word pseudorandomly from the given set, which must
+// not be empty, with the probability of each word being selected decided by
+// the given function, which returns the weight of each word in the current
+// position, such as the number of times it was seen there, and by the
+// temperature in the given options. If the function is nil, or if the
+// options request uniform transitions, then the weight is ignored. The
+// caller must hold at least a read lock on the brain.
+func (b *Brain) chooseWord(ws WordSet, weightOf func(Word) float64, opts GenerationOptions) Word {
+	if opts.UniformTransitions {
+		weightOf = nil
+	}
+	if (opts.Temperature <= 0 && weightOf == nil) || len(ws) < 2 {
+		return opts.chooseWord(ws)
+	}
+
+	// Each word is weighted by the number of times it was seen in this
+	// position, multiplied by its overall frequency in the brain raised to
+	// the power of the inverse of the temperature: a temperature of 1 makes
+	// selection directly proportional to frequency, lower temperatures
+	// exaggerate the differences between words so that common words are
+	// chosen more often, and higher temperatures flatten out the differences
+	// so that selection tends towards uniform.
+	//
+	// At low temperatures those powers quickly overflow, so we calculate
+	// the logarithms of the weights and then scale them all relative to
+	// the greatest, which means that as the temperature approaches zero
+	// selection approaches always choosing the most frequent word.
+	words := make([]Word, 0, len(ws))
+	weights := make([]float64, 0, len(ws)) // logarithms, to begin with
+	maxLogWeight := math.Inf(-1)
+	for _, w := range opts.orderedWords(ws) {
+		logWeight := 0.0
+		if weightOf != nil {
+			logWeight = math.Log(weightOf(w))
+		}
+		if opts.Temperature > 0 {
+			logWeight += math.Log(float64(b.wordFrequency(w))) / opts.Temperature
+		}
+		words = append(words, w)
+		weights = append(weights, logWeight)
+		maxLogWeight = math.Max(maxLogWeight, logWeight)
+	}
+	if math.IsInf(maxLogWeight, 0) || math.IsNaN(maxLogWeight) {
+		// If no word has any weight at all then we'll just fall back on
+		// uniform selection.
+		return opts.chooseWord(ws)
+	}
+	total := 0.0
+	for i, logWeight := range weights {
+		weights[i] = math.Exp(logWeight - maxLogWeight)
+		total += weights[i]
+	}
+
+	target := opts.float64() * total
+	for i, weight := range weights {
+		target -= weight
+		if target < 0 {
+			return words[i]
+		}
+	}
+	return words[len(words)-1] // rounding error may leave a tiny remainder
 }
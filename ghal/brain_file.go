@@ -1,182 +1,871 @@
 package ghal
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"os"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/vmihailenco/msgpack"
 )
 
 // LoadBrain reads a serialized brain from the given reader, which must
 // be in the format created by Brain.Save.
+//
+// The chains, which make up most of a large brain file, are read and added
+// to the brain one at a time, so loading a brain needs little memory beyond
+// that of the brain itself. Files saved by earlier versions, which stored the
+// whole brain as a single value, can still be loaded, but must be read into
+// memory in full first.
 func LoadBrain(r io.Reader) (*Brain, error) {
-	var fb fBrain
-	src, err := ioutil.ReadAll(r)
-	if err != nil {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(fMagic))
+	_, err := io.ReadFull(br, magic)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("not a brain file")
+	} else if err != nil {
 		return nil, err
 	}
-	if len(src) < 4 || !bytes.Equal(src[:4], fMagic) {
+
+	switch {
+	case bytes.Equal(magic, fMagicStream):
+		return brainFromStream(msgpack.NewDecoder(br))
+	case bytes.Equal(magic, fMagic):
+		var fb fBrain
+		err = msgpack.NewDecoder(br).Decode(&fb)
+		if err != nil {
+			return nil, fmt.Errorf("invalid brain file: %s", err)
+		}
+		return brainFromFile(&fb)
+	default:
+		return nil, fmt.Errorf("not a brain file")
+	}
+}
+
+// LoadBrainBytes is like LoadBrain but reads the serialized brain from the
+// given byte slice, such as one embedded in a program using go:embed, which
+// avoids copying the data through a reader. The resulting brain doesn't
+// retain the given slice.
+func LoadBrainBytes(src []byte) (*Brain, error) {
+	switch {
+	case bytes.HasPrefix(src, fMagicStream):
+		return brainFromStream(msgpack.NewDecoder(bytes.NewReader(src[len(fMagicStream):])))
+	case bytes.HasPrefix(src, fMagic):
+		var fb fBrain
+		err := msgpack.Unmarshal(src[len(fMagic):], &fb)
+		if err != nil {
+			return nil, fmt.Errorf("invalid brain file: %s", err)
+		}
+		return brainFromFile(&fb)
+	default:
 		return nil, fmt.Errorf("not a brain file")
 	}
-	err = msgpack.Unmarshal(src[4:], &fb)
+}
+
+// LoadBrainFS is like LoadBrainFile but reads the file with the given name
+// from the given filesystem, such as an embed.FS.
+func LoadBrainFS(fsys fs.FS, name string) (*Brain, error) {
+	src, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return LoadBrainBytes(src)
+}
+
+// brainFromFile builds a brain from the decoded representation of a file in
+// the original format, where the whole brain is a single fBrain value.
+func brainFromFile(fb *fBrain) (*Brain, error) {
+	l, err := newBrainLoader(&fb.fHeader, len(fb.Chains))
 	if err != nil {
+		return nil, err
+	}
+	for i := range fb.Chains {
+		if err := l.addChain(&fb.Chains[i]); err != nil {
+			return nil, err
+		}
+	}
+	return l.finish(&fb.fTrailer)
+}
+
+// brainFromStream builds a brain from a file in the streaming format, whose
+// contents after the magic number are read from the given decoder: an
+// fHeader, followed by as many fChain values as it gives in ChainCount,
+// followed by an fTrailer.
+func brainFromStream(dec *msgpack.Decoder) (*Brain, error) {
+	var fh fHeader
+	if err := dec.Decode(&fh); err != nil {
 		return nil, fmt.Errorf("invalid brain file: %s", err)
 	}
+	if fh.ChainCount < 0 {
+		return nil, fmt.Errorf("invalid chain count %d", fh.ChainCount)
+	}
 
-	if fb.ChainLen != chainLen {
-		return nil, fmt.Errorf("wrong chain length %d; need %d", fb.ChainLen, chainLen)
+	// The chain count is only a size hint for the brain's maps until the
+	// chains have actually been read, so we limit it to avoid a corrupt file
+	// making us allocate huge maps up front.
+	l, err := newBrainLoader(&fh, int(min(fh.ChainCount, maxChainsSizeHint)))
+	if err != nil {
+		return nil, err
 	}
+	for i := int64(0); i < fh.ChainCount; i++ {
+		var fc fChain
+		if err := dec.Decode(&fc); err != nil {
+			return nil, fmt.Errorf("invalid brain file: chain %d: %s", i, err)
+		}
+		if err := l.addChain(&fc); err != nil {
+			return nil, err
+		}
+	}
+
+	var ft fTrailer
+	if err := dec.Decode(&ft); err != nil {
+		return nil, fmt.Errorf("invalid brain file: %s", err)
+	}
+	return l.finish(&ft)
+}
+
+// maxChainsSizeHint is the largest number of chains that brainFromStream
+// will size a brain's maps for before reading them.
+const maxChainsSizeHint = 1 << 22
+
+// brainLoader builds a brain from the parts of a brain file as they are
+// decoded, so that a file's chains can be added to the brain one at a time
+// rather than all needing to be in memory at once.
+type brainLoader struct {
+	ret   *Brain
+	words []Word
 
-	ret := NewBrain()
+	// chains are the chains added so far, in the order they appeared in the
+	// file, which other parts of the file refer to by index.
+	chains []chain
+}
+
+// newBrainLoader validates the given file header and prepares to build a
+// brain from it, sizing the brain for the given number of chains.
+func newBrainLoader(fh *fHeader, chainCount int) (*brainLoader, error) {
+	if fh.ChainLen < MinOrder || fh.ChainLen > MaxOrder {
+		return nil, fmt.Errorf("unsupported chain length %d; must be between %d and %d", fh.ChainLen, MinOrder, MaxOrder)
+	}
+	order := int(fh.ChainLen)
 
-	wordByIdx := func(i fIndex) Word {
-		if int(i) >= len(fb.Words) || i < 0 {
-			return Word{} // invalid
+	ret := newBrainSized(order, len(fh.Words), chainCount)
+	for i, name := range fh.Sources {
+		ret.sourceIdx(name)
+		if i < len(fh.SourceTimes) && fh.SourceTimes[i] != 0 {
+			ret.sourceTimes[name] = time.Unix(fh.SourceTimes[i], 0)
 		}
-		return Word{
-			Text: fb.Words[i].Text,
-			Tag:  fb.Words[i].Tag,
+	}
+
+	// We convert the word table only once, so that the chains can then
+	// share the resulting strings rather than each building their own.
+	// The tags in particular are repeated for many words, so we intern them.
+	words := make([]Word, len(fh.Words))
+	for i, fw := range fh.Words {
+		words[i] = ret.strs.internWord(Word{
+			Text: fw.Text,
+			Tag:  fw.Tag,
+		})
+	}
+
+	return &brainLoader{
+		ret:    ret,
+		words:  words,
+		chains: make([]chain, 0, chainCount),
+	}, nil
+}
+
+func (l *brainLoader) wordByIdx(i fIndex) Word {
+	if int(i) >= len(l.words) || i < 0 {
+		return Word{} // invalid
+	}
+	return l.words[i]
+}
+
+// addChain validates the given chain from the file and adds it to the brain
+// along with its transitions, sources, and start and end counts.
+func (l *brainLoader) addChain(fc *fChain) error {
+	ret := l.ret
+	i := len(l.chains)
+
+	var c chain
+	if got, want := len(fc.Words), ret.order; got != want {
+		return fmt.Errorf("chain %d has wrong length %d; need %d", i, got, want)
+	}
+	for j, wi := range fc.Words {
+		if int(wi) >= len(l.words) || wi < 0 {
+			// An empty word would be mistaken for the end of a chain
+			// shorter than maxChainLen.
+			return fmt.Errorf("chain %d has invalid word index %d", i, wi)
 		}
+		c[j] = l.words[wi]
+	}
+	for _, si := range fc.Sources {
+		if int(si) >= len(ret.sources) || si < 0 {
+			return fmt.Errorf("chain %d has invalid source index %d", i, si)
+		}
+	}
+	if err := checkTransitionCounts(fc.AfterCounts, fc.WordsAfter); err != nil {
+		return fmt.Errorf("chain %d has invalid counts of words after: %s", i, err)
+	}
+	if err := checkTransitionCounts(fc.BeforeCounts, fc.WordsBefore); err != nil {
+		return fmt.Errorf("chain %d has invalid counts of words before: %s", i, err)
+	}
+	if fc.StartCount < 0 || (fc.StartCount > 1 && !fc.CanStart) {
+		return fmt.Errorf("chain %d has invalid start count %d", i, fc.StartCount)
+	}
+	if fc.EndCount < 0 || (fc.EndCount > 1 && !fc.CanEnd) {
+		return fmt.Errorf("chain %d has invalid end count %d", i, fc.EndCount)
 	}
 
-	for i, fc := range fb.Chains {
-		if got, want := len(fc.Words), chainLen; got != want {
-			return nil, fmt.Errorf("chain %d has wrong length %d; need %d", i, got, want)
+	l.chains = append(l.chains, c)
+	ret.chains.Add(c)
+	for _, si := range fc.Sources {
+		ret.addChainSource(c, int(si))
+	}
+	if len(fc.WordsAfter) > 0 {
+		ws := ret.wordsAfter[c]
+		if ws == nil {
+			ws = make(WordSet, len(fc.WordsAfter))
+			ret.wordsAfter[c] = ws
+		}
+		for _, wi := range fc.WordsAfter {
+			ws.Add(l.wordByIdx(wi))
 		}
-		var c chain
-		for i, wi := range fc.Words {
-			c[i] = wordByIdx(wi)
+		loadTransitionCounts(ret.afterCounts, c, fc.AfterCounts, fc.WordsAfter, l.wordByIdx)
+	}
+	if len(fc.WordsBefore) > 0 {
+		ws := ret.wordsBefore[c]
+		if ws == nil {
+			ws = make(WordSet, len(fc.WordsBefore))
+			ret.wordsBefore[c] = ws
 		}
-		ret.chains.Add(c)
-		for _, w := range c {
-			if _, exists := ret.wordChains[w]; !exists {
-				ret.wordChains[w] = make(chainSet)
+		for _, wi := range fc.WordsBefore {
+			ws.Add(l.wordByIdx(wi))
+		}
+		loadTransitionCounts(ret.beforeCounts, c, fc.BeforeCounts, fc.WordsBefore, l.wordByIdx)
+	}
+	if fc.CanStart {
+		ret.addStartChain(c, int(max(fc.StartCount, 1)))
+	}
+	if fc.CanEnd {
+		ret.addEndChain(c, int(max(fc.EndCount, 1)))
+	}
+	if fc.Discouraged != 0 {
+		ret.discouraged[c] = int(fc.Discouraged)
+	}
+	return nil
+}
+
+// finish validates and loads the rest of the brain from the given file
+// trailer, once all of the chains have been added, and then builds the
+// brain's indices of its chains.
+func (l *brainLoader) finish(ft *fTrailer) (*Brain, error) {
+	ret, words, chains := l.ret, l.words, l.chains
+
+	for i, fm := range ft.WordMeta {
+		if int(fm.Word) >= len(words) || fm.Word < 0 {
+			return nil, fmt.Errorf("metadata %d has invalid word index %d", i, fm.Word)
+		}
+		ret.setWordMeta(words[fm.Word], ret.strs.intern(fm.Key), fm.Value)
+	}
+
+	for i, fr := range ft.Responses {
+		if int(fr.Word) >= len(words) || fr.Word < 0 {
+			return nil, fmt.Errorf("response %d has invalid word index %d", i, fr.Word)
+		}
+		rcs := make(chainSet, len(fr.Chains))
+		for _, ci := range fr.Chains {
+			if int(ci) >= len(chains) || ci < 0 {
+				return nil, fmt.Errorf("response %d has invalid chain index %d", i, ci)
 			}
-			ret.wordChains[w].Add(c)
+			rcs.Add(chains[ci])
+		}
+		ret.responseChains[words[fr.Word]] = rcs
+	}
+
+	for i, fbg := range ft.Bigrams {
+		if int(fbg.Word) >= len(words) || fbg.Word < 0 {
+			return nil, fmt.Errorf("bigram %d has invalid word index %d", i, fbg.Word)
 		}
-		if _, exists := ret.wordsAfter[c]; !exists {
-			ret.wordsAfter[c] = make(WordSet)
+		w := words[fbg.Word]
+		if fbg.CanStart {
+			ret.bigrams.starts.Add(w)
 		}
-		if _, exists := ret.wordsBefore[c]; !exists {
-			ret.wordsBefore[c] = make(WordSet)
+		if fbg.CanEnd {
+			ret.bigrams.ends.Add(w)
 		}
+		for _, wi := range fbg.After {
+			ret.bigrams.addPair(w, l.wordByIdx(wi))
+		}
+	}
 
-		for _, wi := range fc.WordsAfter {
-			ret.wordsAfter[c].Add(wordByIdx(wi))
+	for i, wi := range ft.Taboo {
+		if int(wi) >= len(words) || wi < 0 {
+			return nil, fmt.Errorf("taboo word %d has invalid word index %d", i, wi)
 		}
-		for _, wi := range fc.WordsBefore {
-			ret.wordsBefore[c].Add(wordByIdx(wi))
+		ret.taboo.Add(words[wi])
+	}
+
+	for _, fsw := range ft.Swaps {
+		ret.swaps[ret.strs.intern(fsw.From)] = ret.strs.intern(fsw.To)
+	}
+	if ft.Greetings != nil {
+		ret.greetings = make([]string, len(ft.Greetings))
+		for i, text := range ft.Greetings {
+			ret.greetings[i] = ret.strs.intern(text)
+		}
+	}
+
+	if t := ft.Terminators; t != nil {
+		ret.terminators = &Terminators{
+			Statement:   t.Statement,
+			Question:    t.Question,
+			Exclamation: t.Exclamation,
+		}
+	}
+
+	for _, fc := range ft.Casing {
+		ret.addCaseCount(fc.Text, CaseCount{
+			Seen:        int(fc.Seen),
+			Capitalized: int(fc.Capitalized),
+			AllCaps:     int(fc.AllCaps),
+		})
+	}
+
+	for _, fts := range ft.Trained {
+		ret.trained[fts.Name] = TrainedSource{
+			Name:      fts.Name,
+			Hash:      fts.Hash,
+			Size:      fts.Size,
+			Time:      time.Unix(fts.Time, 0),
+			Sentences: int(fts.Sentences),
+			Partial:   int(fts.Partial),
 		}
+	}
 
-		if fc.CanStart {
-			ret.startChains.Add(c)
+	// Each of the remaining indices is a separate map derived only from the
+	// chains and whether they can start and end a sentence, so we can build
+	// them all concurrently. This is the most expensive part of loading a
+	// large brain.
+	var wg sync.WaitGroup
+	build := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	build(func() {
+		for _, c := range chains {
+			for _, w := range c.words() {
+				addToChainIndex(ret.wordChains, w, c)
+			}
+		}
+	})
+	build(func() {
+		for _, c := range chains {
+			addToChainIndex(ret.firstWordChains, c[0], c)
+			addToChainIndex(ret.lastWordChains, c.last(), c)
 		}
-		if fc.CanEnd {
-			ret.endChains.Add(c)
+	})
+	build(func() {
+		for _, c := range chains {
+			ret.lowOrder.addChain(c, ret.startChains.Has(c), ret.endChains.Has(c))
 		}
+	})
+	if len(ft.Bigrams) == 0 {
+		// Files saved before brains had bigram models don't include one, but
+		// we can reconstruct most of it from the chains.
+		build(func() {
+			for _, c := range chains {
+				ret.bigrams.addChain(c, ret.startChains.Has(c), ret.endChains.Has(c))
+			}
+		})
 	}
+	wg.Wait()
 
 	return ret, nil
 }
 
 // Save writes a snapshot of the receiving brain's contents into the given
 // writer in a binary format that can be reloaded later with LoadBrain.
+//
+// The output is reproducible: brains with the same contents always produce
+// byte-for-byte identical files, regardless of the order in which they
+// learned their sentences, so files can be compared by hash.
+//
+// The brain's chains are encoded and written one at a time, so saving a
+// large brain needs little memory beyond the brain itself. Save buffers its
+// output, so the given writer needn't be buffered.
 func (b *Brain) Save(w io.Writer) error {
-	b.mut.RLock()
-	defer b.mut.RUnlock()
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
 
-	var fb fBrain
-	fb.ChainLen = chainLen
-	fb.Chains = make([]fChain, 0, len(b.chains))
-	fb.Words = make([]fWord, 0, len(b.wordChains))
+	var fh fHeader
+	fh.ChainLen = int64(b.order)
 
-	wordIdxs := map[Word]fIndex{}
+	// The in-memory sources table is in the order the sources were first
+	// seen, so we write it sorted by name instead and then translate the
+	// chains' source indices into that order.
+	var sourceIdxs []fIndex
+	if len(b.sources) > 0 {
+		fh.Sources = append([]string(nil), b.sources...)
+		sort.Strings(fh.Sources)
+		sourceIdxs = make([]fIndex, len(b.sources))
+		for i, name := range fh.Sources {
+			sourceIdxs[b.sourceIdxs[name]] = fIndex(i)
+			if t, ok := b.sourceTimes[name]; ok {
+				if fh.SourceTimes == nil {
+					fh.SourceTimes = make([]int64, len(fh.Sources))
+				}
+				fh.SourceTimes[i] = t.Unix()
+			}
+		}
+	}
 
+	// Most of the words in the brain appear in at least one chain, so
+	// wordChains gives us most of the word table, but words can also have
+	// metadata or appear in the bigram model without appearing in any chains.
+	// Since the table is sorted, sorting indices into it also sorts the
+	// corresponding words.
+	words := make([]Word, 0, len(b.wordChains))
+	for w := range b.wordChains {
+		words = append(words, w)
+	}
+	seen := func(w Word) bool {
+		_, exists := b.wordChains[w]
+		return exists
+	}
+	extra := make(WordSet)
+	for w := range b.wordMeta {
+		if !seen(w) {
+			extra.Add(w)
+		}
+	}
+	for w := range b.bigrams.after {
+		if !seen(w) {
+			extra.Add(w)
+		}
+	}
+	for w := range b.bigrams.before {
+		if !seen(w) {
+			extra.Add(w)
+		}
+	}
+	for w := range b.bigrams.starts {
+		if !seen(w) {
+			extra.Add(w)
+		}
+	}
+	for w := range b.bigrams.ends {
+		if !seen(w) {
+			extra.Add(w)
+		}
+	}
+	for w := range b.responseChains {
+		if !seen(w) {
+			extra.Add(w)
+		}
+	}
+	for w := range b.taboo {
+		if !seen(w) {
+			extra.Add(w)
+		}
+	}
+	for w := range extra {
+		words = append(words, w)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		return wordLess(words[i], words[j])
+	})
+	fh.Words = make([]fWord, len(words))
+	wordIdxs := make(map[Word]fIndex, len(words))
+	for i, w := range words {
+		fh.Words[i] = fWord{
+			Tag:  w.Tag,
+			Text: w.Text,
+		}
+		wordIdxs[w] = fIndex(i)
+	}
+
+	// The word table is written before the chains, so unlike in files that
+	// stored the whole brain as a single value we can't add a word that is
+	// somehow missing from it once we've started writing the chains. That
+	// should never happen, but we'll report it rather than produce a broken
+	// file.
+	var missing *Word
 	wordIdx := func(w Word) fIndex {
 		wIdx, exists := wordIdxs[w]
-		if !exists {
-			wIdx = fIndex(len(fb.Words))
-			wordIdxs[w] = wIdx
-			fb.Words = append(fb.Words, fWord{
-				Tag:  w.Tag,
-				Text: w.Text,
-			})
+		if !exists && missing == nil {
+			missing = &w
 		}
 		return wIdx
 	}
-
-	for c := range b.chains {
-		var fc fChain
-		wds := make(fIndices, chainLen)
-		for i, w := range c {
-			wds[i] = wordIdx(w)
+	wordIdxsSorted := func(ws WordSet) fIndices {
+		if len(ws) == 0 {
+			return nil
 		}
-		fc.Words = wds
-		for w := range b.wordsAfter[c] {
-			fc.WordsAfter = append(fc.WordsAfter, wordIdx(w))
+		ret := make(fIndices, 0, len(ws))
+		for w := range ws {
+			ret = append(ret, wordIdx(w))
 		}
-		for w := range b.wordsBefore[c] {
-			fc.WordsBefore = append(fc.WordsBefore, wordIdx(w))
+		sort.Slice(ret, func(i, j int) bool {
+			return ret[i] < ret[j]
+		})
+		return ret
+	}
+
+	transitionCountsSorted := func(counts map[Word]int, idxs fIndices) []int64 {
+		if len(counts) == 0 {
+			return nil
+		}
+		ret := make([]int64, len(idxs))
+		for i, wi := range idxs {
+			ret[i] = 1
+			if n := counts[words[wi]]; n > 0 {
+				ret[i] = int64(n)
+			}
 		}
-		fc.CanStart = b.startChains.Has(c)
-		fc.CanEnd = b.endChains.Has(c)
-		fb.Chains = append(fb.Chains, fc)
+		return ret
 	}
 
-	src, err := msgpack.Marshal(&fb)
-	if err != nil {
+	chains := sortedChains(b.chains)
+	fh.ChainCount = int64(len(chains))
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(fMagicStream); err != nil {
 		return err
 	}
-	_, err = w.Write(fMagic)
-	if err != nil {
+	enc := msgpack.NewEncoder(bw)
+	if err := enc.Encode(&fh); err != nil {
+		return err
+	}
+
+	// Each chain is encoded as soon as it's built, so that we never have
+	// more than one of them in memory at once.
+	wds := make(fIndices, b.order)
+	for _, c := range chains {
+		for j, w := range c.words() {
+			wds[j] = wordIdx(w)
+		}
+		fc := fChain{
+			Words:       wds,
+			WordsAfter:  wordIdxsSorted(b.wordsAfter[c]),
+			WordsBefore: wordIdxsSorted(b.wordsBefore[c]),
+			CanStart:    b.startChains.Has(c),
+			CanEnd:      b.endChains.Has(c),
+			StartCount:  int64(b.startCounts[c]),
+			EndCount:    int64(b.endCounts[c]),
+			Discouraged: int64(b.discouraged[c]),
+		}
+		if missing != nil {
+			return fmt.Errorf("word %q is missing from the brain's word table", missing.Text)
+		}
+		fc.AfterCounts = transitionCountsSorted(b.afterCounts[c], fc.WordsAfter)
+		fc.BeforeCounts = transitionCountsSorted(b.beforeCounts[c], fc.WordsBefore)
+		if srcs := b.chainSources[c]; len(srcs) > 0 {
+			fc.Sources = make(fIndices, len(srcs))
+			for j, si := range srcs {
+				fc.Sources[j] = sourceIdxs[si]
+			}
+			sort.Slice(fc.Sources, func(i, j int) bool {
+				return fc.Sources[i] < fc.Sources[j]
+			})
+		}
+		if err := enc.Encode(&fc); err != nil {
+			return err
+		}
+	}
+
+	var ft fTrailer
+	for _, w := range words {
+		after := b.bigrams.after[w]
+		canStart := b.bigrams.starts.Has(w)
+		canEnd := b.bigrams.ends.Has(w)
+		if len(after) == 0 && !canStart && !canEnd {
+			continue
+		}
+		ft.Bigrams = append(ft.Bigrams, fBigram{
+			Word:     wordIdxs[w],
+			After:    wordIdxsSorted(after),
+			CanStart: canStart,
+			CanEnd:   canEnd,
+		})
+	}
+
+	if len(b.responseChains) > 0 {
+		chainIdxs := make(map[chain]fIndex, len(chains))
+		for i, c := range chains {
+			chainIdxs[c] = fIndex(i)
+		}
+		for _, w := range words {
+			rcs := b.responseChains[w]
+			if len(rcs) == 0 {
+				continue
+			}
+			fr := fResponse{
+				Word:   wordIdxs[w],
+				Chains: make(fIndices, 0, len(rcs)),
+			}
+			for c := range rcs {
+				if ci, ok := chainIdxs[c]; ok {
+					fr.Chains = append(fr.Chains, ci)
+				}
+			}
+			sort.Slice(fr.Chains, func(i, j int) bool {
+				return fr.Chains[i] < fr.Chains[j]
+			})
+			ft.Responses = append(ft.Responses, fr)
+		}
+	}
+
+	for _, w := range words {
+		meta := b.wordMeta[w]
+		if len(meta) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(meta))
+		for k := range meta {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ft.WordMeta = append(ft.WordMeta, fWordMeta{
+				Word:  wordIdxs[w],
+				Key:   k,
+				Value: meta[k],
+			})
+		}
+	}
+
+	ft.Taboo = wordIdxsSorted(b.taboo)
+	if t := b.terminators; t != nil {
+		ft.Terminators = &fTerminators{
+			Statement:   t.Statement,
+			Question:    t.Question,
+			Exclamation: t.Exclamation,
+		}
+	}
+
+	for _, from := range sortedSwaps(b.swaps) {
+		ft.Swaps = append(ft.Swaps, fSwap{
+			From: from,
+			To:   b.swaps[from],
+		})
+	}
+	ft.Greetings = b.greetings
+
+	for _, text := range sortedCaseTexts(b.casing) {
+		count := b.casing[text]
+		ft.Casing = append(ft.Casing, fCaseCount{
+			Text:        text,
+			Seen:        int64(count.Seen),
+			Capitalized: int64(count.Capitalized),
+			AllCaps:     int64(count.AllCaps),
+		})
+	}
+
+	for _, ts := range b.trained {
+		ft.Trained = append(ft.Trained, fTrainedSource{
+			Name:      ts.Name,
+			Hash:      ts.Hash,
+			Size:      ts.Size,
+			Time:      ts.Time.Unix(),
+			Sentences: int64(ts.Sentences),
+			Partial:   int64(ts.Partial),
+		})
+	}
+	sort.Slice(ft.Trained, func(i, j int) bool {
+		return ft.Trained[i].Name < ft.Trained[j].Name
+	})
+
+	if err := enc.Encode(&ft); err != nil {
 		return err
 	}
-	_, err = w.Write(src)
-	return err
+	return bw.Flush()
 }
 
-// LoadBrainFile is like LoadBrain but it first opens the given filename
-// and then reads data from it.
-func LoadBrainFile(filename string) (*Brain, error) {
-	f, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// checkTransitionCounts returns an error if the given counts of the given
+// words from a brain file are invalid.
+func checkTransitionCounts(counts []int64, words fIndices) error {
+	if len(counts) == 0 {
+		return nil
 	}
-	return LoadBrain(f)
+	if len(counts) != len(words) {
+		return fmt.Errorf("%d counts for %d words", len(counts), len(words))
+	}
+	for _, n := range counts {
+		if n < 1 {
+			return fmt.Errorf("invalid count %d", n)
+		}
+	}
+	return nil
 }
 
-// SaveFile is like Save but it creates a file with the given filename
-// and then writes the data to it.
-func (b *Brain) SaveFile(filename string) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
+// loadTransitionCounts records the given counts of the given words, which
+// must already have been checked using checkTransitionCounts, following or
+// preceding the given chain.
+func loadTransitionCounts(tc transitionCounts, c chain, counts []int64, words fIndices, wordByIdx func(fIndex) Word) {
+	for i, n := range counts {
+		if n > 1 {
+			tc.add(c, wordByIdx(words[i]), int(n)-1)
+		}
 	}
-	return b.Save(f)
 }
 
+// fMagic begins brain files in the original format, where the rest of the
+// file is a single fBrain value.
 var fMagic = []byte{'Q', 'W', 'O', 'K'}
 
+// fMagicStream begins brain files in the streaming format written by
+// Brain.Save, where the rest of the file is an fHeader, followed by as many
+// fChain values as it gives in ChainCount, followed by an fTrailer.
+var fMagicStream = []byte{'Q', 'W', 'O', 'S'}
+
 type fBrain struct {
-	ChainLen int64 `msgpack:"chainLen"`
+	fHeader
 
-	// indices into these lists are used in the other structures to keep the
-	// file format relatively compact, storing each distinct word and chain
-	// only once in the file.
 	Chains []fChain `msgpack:"chains"`
-	Words  []fWord  `msgpack:"words"`
+
+	fTrailer
+}
+
+// fHeader is the part of a brain file that must be read before its chains.
+type fHeader struct {
+	ChainLen int64 `msgpack:"chainLen"`
+
+	// ChainCount is the number of chains that follow the header in the
+	// streaming format. It is omitted in the original format, which stores
+	// the chains in fBrain.Chains instead.
+	ChainCount int64 `msgpack:"chainCount,omitempty"`
+
+	// indices into Words and the list of chains are used in the other
+	// structures to keep the file format relatively compact, storing each
+	// distinct word and chain only once in the file.
+	Words []fWord `msgpack:"words"`
+
+	// Sources are the names of the training sources recorded for provenance
+	// purposes, which chains refer to by index.
+	Sources []string `msgpack:"sources,omitempty"`
+
+	// SourceTimes are the earliest times recorded for each of the sources,
+	// as Unix timestamps with the same indices as Sources, or zero where no
+	// time was recorded. This is omitted if no times were recorded at all.
+	SourceTimes []int64 `msgpack:"sourceTimes,omitempty"`
+}
+
+// fTrailer is the part of a brain file that is read after its chains.
+type fTrailer struct {
+	// WordMeta is the metadata recorded for words, with one element per
+	// metadata value, ordered by word index and then by key.
+	WordMeta []fWordMeta `msgpack:"meta,omitempty"`
+
+	// Bigrams is the brain's bigram model, with one element per word that
+	// is known to the model, ordered by word index.
+	Bigrams []fBigram `msgpack:"bigrams,omitempty"`
+
+	// Responses records the chains used in response to each word in
+	// dialogues, with one element per word, ordered by word index.
+	Responses []fResponse `msgpack:"responses,omitempty"`
+
+	// Taboo is the brain's taboo list, as indices into Words in ascending
+	// order.
+	Taboo fIndices `msgpack:"taboo,omitempty"`
+
+	// Terminators is the brain's configured terminating punctuation, or nil
+	// if it hasn't been configured.
+	Terminators *fTerminators `msgpack:"terminators,omitempty"`
+
+	// Casing is the brain's case counts, ordered by word text.
+	Casing []fCaseCount `msgpack:"casing,omitempty"`
+
+	// Swaps are the word swaps used when choosing keywords, in order of the
+	// text of the word being swapped.
+	Swaps []fSwap `msgpack:"swaps,omitempty"`
+
+	// Greetings are the words used as keywords for greetings, if they have
+	// been configured.
+	Greetings []string `msgpack:"greetings,omitempty"`
+
+	// Trained is the brain's training manifest, ordered by source name.
+	Trained []fTrainedSource `msgpack:"trained,omitempty"`
 }
 
 type fChain struct {
 	Words       fIndices `msgpack:"w"`
 	WordsAfter  fIndices `msgpack:"a"`
 	WordsBefore fIndices `msgpack:"b"`
+	Sources     fIndices `msgpack:"src,omitempty"`
+
+	// AfterCounts and BeforeCounts are the number of times each of the
+	// words in WordsAfter and WordsBefore were learned, in the same order.
+	// They are omitted if all of the words were learned only once.
+	AfterCounts  []int64 `msgpack:"ac,omitempty"`
+	BeforeCounts []int64 `msgpack:"bc,omitempty"`
 
 	CanStart bool `msgpack:"s"`
 	CanEnd   bool `msgpack:"e"`
+
+	// StartCount and EndCount are the number of times the chain started
+	// and ended a sentence, which are omitted if it did so only once.
+	StartCount int64 `msgpack:"sc,omitempty"`
+	EndCount   int64 `msgpack:"ec,omitempty"`
+
+	// Discouraged is the number of times the chain was discouraged using
+	// Brain.Discourage less the number of times it was reinforced using
+	// Brain.Reinforce, which is omitted if these are the same.
+	Discouraged int64 `msgpack:"d,omitempty"`
+}
+
+type fBigram struct {
+	Word  fIndex   `msgpack:"w"`
+	After fIndices `msgpack:"a"`
+
+	CanStart bool `msgpack:"s"`
+	CanEnd   bool `msgpack:"e"`
+}
+
+type fResponse struct {
+	Word   fIndex   `msgpack:"w"`
+	Chains fIndices `msgpack:"c"`
+}
+
+type fWordMeta struct {
+	Word  fIndex `msgpack:"w"`
+	Key   string `msgpack:"k"`
+	Value string `msgpack:"v"`
+}
+
+type fTerminators struct {
+	Statement   []string `msgpack:"s"`
+	Question    []string `msgpack:"q"`
+	Exclamation []string `msgpack:"e"`
+}
+
+type fCaseCount struct {
+	Text        string `msgpack:"t"`
+	Seen        int64  `msgpack:"n"`
+	Capitalized int64  `msgpack:"c"`
+	AllCaps     int64  `msgpack:"a,omitempty"`
+}
+
+type fSwap struct {
+	From string `msgpack:"f"`
+	To   string `msgpack:"t"`
+}
+
+type fTrainedSource struct {
+	Name      string `msgpack:"n"`
+	Hash      string `msgpack:"h"`
+	Size      int64  `msgpack:"z,omitempty"`
+	Time      int64  `msgpack:"t"`
+	Sentences int64  `msgpack:"c"`
+	Partial   int64  `msgpack:"p,omitempty"`
 }
 
 type fWord struct {
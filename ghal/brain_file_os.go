@@ -0,0 +1,37 @@
+//go:build !js
+
+package ghal
+
+import (
+	"os"
+)
+
+// The helpers for loading and saving brain files are not available when
+// compiling for JavaScript, where there is usually no filesystem. Use
+// LoadBrain, LoadBrainBytes, or LoadBrainFS and Save instead.
+
+// LoadBrainFile is like LoadBrain but it first opens the given filename
+// and then reads data from it.
+func LoadBrainFile(filename string) (*Brain, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadBrain(f)
+}
+
+// SaveFile is like Save but it creates a file with the given filename
+// and then writes the data to it.
+func (b *Brain) SaveFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = b.Save(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
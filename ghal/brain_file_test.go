@@ -0,0 +1,210 @@
+package ghal
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// syntheticBrain returns a brain that has learned the given number of
+// pseudorandom sentences drawn from a small vocabulary, which always produces
+// the same brain for the same number of sentences.
+func syntheticBrain(sentences int) *Brain {
+	rnd := rand.New(rand.NewSource(1))
+	vocab := make([]string, 500)
+	for i := range vocab {
+		vocab[i] = fmt.Sprintf("word%d", i)
+	}
+	b := NewBrain()
+	for i := 0; i < sentences; i++ {
+		texts := make([]string, 4+rnd.Intn(10))
+		for j := range texts {
+			texts[j] = vocab[rnd.Intn(len(vocab))]
+		}
+		b.AddSentence(testSentence(texts...))
+	}
+	b.AddSentence(testSentence("hi")) // learned only by the bigram model
+	b.TabooWord(MakeWord("NN", "word1"))
+	return b
+}
+
+// saveBrain returns the brain serialized using Save.
+func saveBrain(t testing.TB, b *Brain) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := b.Save(&buf); err != nil {
+		t.Fatalf("failed to save brain: %s", err)
+	}
+	return buf.Bytes()
+}
+
+// originalFormat converts a brain file in the streaming format into the
+// original format, where the whole brain is a single fBrain value, so that
+// tests can exercise loading files saved by earlier versions.
+func originalFormat(t testing.TB, src []byte, withBigrams bool) []byte {
+	t.Helper()
+	dec := msgpack.NewDecoder(bytes.NewReader(src[len(fMagicStream):]))
+	var fb fBrain
+	if err := dec.Decode(&fb.fHeader); err != nil {
+		t.Fatalf("failed to decode header: %s", err)
+	}
+	fb.Chains = make([]fChain, fb.ChainCount)
+	for i := range fb.Chains {
+		if err := dec.Decode(&fb.Chains[i]); err != nil {
+			t.Fatalf("failed to decode chain %d: %s", i, err)
+		}
+	}
+	if err := dec.Decode(&fb.fTrailer); err != nil {
+		t.Fatalf("failed to decode trailer: %s", err)
+	}
+	fb.ChainCount = 0
+	if !withBigrams {
+		fb.Bigrams = nil
+	}
+	ret, err := msgpack.Marshal(&fb)
+	if err != nil {
+		t.Fatalf("failed to encode brain: %s", err)
+	}
+	return append(append([]byte(nil), fMagic...), ret...)
+}
+
+// checkLoadedIndices fails the test if the indices that loading a brain
+// builds from its chains differ from those of the original brain.
+func checkLoadedIndices(t *testing.T, got, want *Brain, withBigrams bool) {
+	t.Helper()
+	if !reflect.DeepEqual(got.chains, want.chains) {
+		t.Errorf("wrong chains")
+	}
+	if !reflect.DeepEqual(got.wordChains, want.wordChains) {
+		t.Errorf("wrong wordChains")
+	}
+	if !reflect.DeepEqual(got.firstWordChains, want.firstWordChains) {
+		t.Errorf("wrong firstWordChains")
+	}
+	if !reflect.DeepEqual(got.lastWordChains, want.lastWordChains) {
+		t.Errorf("wrong lastWordChains")
+	}
+	if !reflect.DeepEqual(got.lowOrder, want.lowOrder) {
+		t.Errorf("wrong low-order model")
+	}
+	if withBigrams {
+		if !reflect.DeepEqual(got.bigrams, want.bigrams) {
+			t.Errorf("wrong bigram model")
+		}
+	} else if len(got.bigrams.after) == 0 {
+		t.Errorf("bigram model was not reconstructed from the chains")
+	}
+}
+
+// TestLoadBrain loads the same brain several times at once in each of the
+// file formats, so that running the tests with -race checks the concurrent
+// building of each brain's indices.
+func TestLoadBrain(t *testing.T) {
+	want := syntheticBrain(200)
+	src := saveBrain(t, want)
+
+	tests := []struct {
+		name        string
+		src         []byte
+		withBigrams bool
+	}{
+		{"streaming", src, true},
+		{"original", originalFormat(t, src, true), true},
+		{"original without bigrams", originalFormat(t, src, false), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loaded := make([]*Brain, 4)
+			errs := make([]error, len(loaded))
+			var wg sync.WaitGroup
+			for i := range loaded {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if i%2 == 0 {
+						loaded[i], errs[i] = LoadBrain(bytes.NewReader(test.src))
+					} else {
+						loaded[i], errs[i] = LoadBrainBytes(test.src)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			for i, got := range loaded {
+				if errs[i] != nil {
+					t.Fatalf("failed to load brain: %s", errs[i])
+				}
+				checkLoadedIndices(t, got, want, test.withBigrams)
+				if test.withBigrams && !bytes.Equal(saveBrain(t, got), src) {
+					t.Errorf("brain saved differently after loading")
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBrainTruncated(t *testing.T) {
+	src := saveBrain(t, syntheticBrain(50))
+	for _, n := range []int{0, 2, len(fMagicStream), len(src) / 2, len(src) - 1} {
+		if _, err := LoadBrainBytes(src[:n]); err == nil {
+			t.Errorf("no error loading the first %d of %d bytes", n, len(src))
+		}
+		if _, err := LoadBrain(bytes.NewReader(src[:n])); err == nil {
+			t.Errorf("no error reading the first %d of %d bytes", n, len(src))
+		}
+	}
+}
+
+func BenchmarkLoadBrain(b *testing.B) {
+	src := saveBrain(b, syntheticBrain(5000))
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadBrain(bytes.NewReader(src)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestSaveReproducible checks that brains that learned the same sentences in
+// different orders save identically, even when more sources contributed a
+// chain, or more chains responded to a word, than the brain keeps.
+func TestSaveReproducible(t *testing.T) {
+	var sources []string
+	for i := 0; i < maxChainSources*2; i++ {
+		sources = append(sources, fmt.Sprintf("source%02d", i))
+	}
+	var dialogue []DialogueTurn
+	for i := 0; i < maxResponseChains*2; i++ {
+		dialogue = append(dialogue,
+			DialogueTurn{Sentences: []Sentence{testSentence("hello", "there")}},
+			DialogueTurn{Sentences: []Sentence{testSentence("reply", fmt.Sprintf("number%d", i), "here")}},
+		)
+	}
+
+	forwards := NewBrain()
+	for _, source := range sources {
+		forwards.AddSentencesFrom([]Sentence{testSentence("the", "cat", "sat")}, source)
+	}
+	for i := 0; i < len(dialogue); i += 2 {
+		forwards.AddDialogue(dialogue[i:i+2], "chat")
+	}
+
+	backwards := NewBrain()
+	for i := len(sources) - 1; i >= 0; i-- {
+		backwards.AddSentencesFrom([]Sentence{testSentence("the", "cat", "sat")}, sources[i])
+	}
+	for i := len(dialogue) - 2; i >= 0; i -= 2 {
+		backwards.AddDialogue(dialogue[i:i+2], "chat")
+	}
+
+	if !bytes.Equal(saveBrain(t, forwards), saveBrain(t, backwards)) {
+		t.Errorf("brains saved differently")
+	}
+}
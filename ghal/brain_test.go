@@ -0,0 +1,140 @@
+package ghal
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// testSentence returns a sentence of nouns with the given texts, ending with
+// a full stop, so that tests don't depend on the part-of-speech tagger.
+func testSentence(texts ...string) Sentence {
+	s := make(Sentence, 0, len(texts)+1)
+	for _, text := range texts {
+		s = append(s, MakeWord("NN", text))
+	}
+	return append(s, MakeWord(".", "."))
+}
+
+func TestEmptyBrainGeneratesNothing(t *testing.T) {
+	b := NewBrain()
+	w := MakeWord("NN", "cat")
+
+	if got := b.MakeSentenceWithKeyword(w); got != nil {
+		t.Errorf("MakeSentenceWithKeyword returned %q; want nil", got)
+	}
+	if got := b.MakeSentenceStartingKeyword(w); got != nil {
+		t.Errorf("MakeSentenceStartingKeyword returned %q; want nil", got)
+	}
+	if got := b.MakeSentenceEndingKeyword(w); got != nil {
+		t.Errorf("MakeSentenceEndingKeyword returned %q; want nil", got)
+	}
+	if got := b.MakeReply(testSentence("cat")); got != nil {
+		t.Errorf("MakeReply returned %q; want nil", got)
+	}
+	if got := b.MakeQuestion(); got != nil {
+		t.Errorf("MakeQuestion returned %q; want nil", got)
+	}
+	if got := b.MakeReason(); got != nil {
+		t.Errorf("MakeReason returned %q; want nil", got)
+	}
+}
+
+func TestUnknownKeywordGeneratesNothing(t *testing.T) {
+	b := NewBrain()
+	b.AddSentence(testSentence("cat", "sat", "on", "mat"))
+
+	if got := b.MakeSentenceWithKeyword(MakeWord("NN", "dog")); got != nil {
+		t.Errorf("MakeSentenceWithKeyword returned %q; want nil", got)
+	}
+}
+
+func TestKeywordThatNeverEndsASentence(t *testing.T) {
+	b := NewBrain()
+	b.AddSentence(testSentence("cat", "sat", "on", "mat"))
+
+	// "sat" appears only in the middle of a sentence, so there is no end
+	// chain ending with it.
+	if got := b.MakeSentenceEndingKeyword(MakeWord("NN", "sat")); got != nil {
+		t.Errorf("MakeSentenceEndingKeyword returned %q; want nil", got)
+	}
+	if got := b.MakeSentenceStartingKeyword(MakeWord("NN", "sat")); got != nil {
+		t.Errorf("MakeSentenceStartingKeyword returned %q; want nil", got)
+	}
+}
+
+func TestCompleteSentenceDeadEnds(t *testing.T) {
+	s := testSentence("the", "cat", "sat", "on", "the", "mat")
+
+	t.Run("no words before", func(t *testing.T) {
+		b := NewBrain()
+		b.AddSentence(s)
+		middle := makeChain(s[1 : 1+b.order])
+		if b.isStartChain(middle) {
+			t.Fatalf("%s is a start chain", middle)
+		}
+		// This is the state a brain can be left in if the chains before
+		// this one were pruned away.
+		delete(b.wordsBefore, middle)
+
+		if got := b.makeSentenceFromChain(middle, false, false, GenerationOptions{}); got != nil {
+			t.Errorf("makeSentenceFromChain returned %q; want nil", got)
+		}
+	})
+	t.Run("no words after", func(t *testing.T) {
+		b := NewBrain()
+		b.AddSentence(s)
+		middle := makeChain(s[1 : 1+b.order])
+		if b.isEndChain(middle) {
+			t.Fatalf("%s is an end chain", middle)
+		}
+		delete(b.wordsAfter, middle)
+
+		if got := b.makeSentenceFromChain(middle, false, false, GenerationOptions{}); got != nil {
+			t.Errorf("makeSentenceFromChain returned %q; want nil", got)
+		}
+	})
+	t.Run("shorter than a chain", func(t *testing.T) {
+		b := NewBrain()
+		b.AddSentence(s)
+		middle := s[1:b.order]
+
+		if got := b.completeSentence(middle, false, false, GenerationOptions{}); got != nil {
+			t.Errorf("completeSentence returned %q; want nil", got)
+		}
+	})
+	t.Run("intact", func(t *testing.T) {
+		b := NewBrain()
+		b.AddSentence(s)
+		middle := makeChain(s[1 : 1+b.order])
+
+		// With just one sentence learned, the only way to complete it is
+		// to reproduce that sentence.
+		got := b.makeSentenceFromChain(middle, false, false, GenerationOptions{})
+		if got.String() != s.String() {
+			t.Errorf("makeSentenceFromChain returned %q; want %q", got, s)
+		}
+	})
+}
+
+func TestChooseWordLowTemperature(t *testing.T) {
+	b := NewBrain()
+	b.AddSentence(testSentence("the", "cat", "sat", "on", "the", "mat"))
+	b.AddSentence(testSentence("the", "cat", "ate", "the", "fish"))
+	b.AddSentence(testSentence("a", "dog", "ran", "home"))
+	cat, dog := MakeWord("NN", "cat"), MakeWord("NN", "dog")
+	if b.wordFrequency(cat) <= b.wordFrequency(dog) {
+		t.Fatalf("%s is not more frequent than %s", cat, dog)
+	}
+
+	// At a temperature this low, the weights would overflow if they were
+	// calculated directly, but the most frequent word should always win.
+	opts := GenerationOptions{
+		Temperature: 0.001,
+		Rand:        rand.New(rand.NewSource(1)),
+	}
+	for i := 0; i < 100; i++ {
+		if got := b.chooseWord(WordSet{cat: {}, dog: {}}, nil, opts); got != cat {
+			t.Fatalf("chose %s; want %s", got, cat)
+		}
+	}
+}
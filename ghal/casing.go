@@ -0,0 +1,191 @@
+package ghal
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CaseCount records how many times a word was seen in training material
+// other than at the start of a sentence, where it would be capitalized
+// regardless, and how many of those times it was capitalized or written
+// entirely in capitals.
+type CaseCount struct {
+	Seen        int
+	Capitalized int
+	AllCaps     int
+}
+
+// CaseCounts is a table of case counts, keyed by the lowercase text of each
+// word.
+type CaseCounts map[string]CaseCount
+
+// DefaultCaseThreshold is a reasonable threshold for Brain.Capitalize, which
+// capitalizes a word if it was capitalized at least half of the times it
+// was seen.
+const DefaultCaseThreshold = 0.5
+
+// add records one sighting of the given word, as written in the original
+// text, unless it has no letters that have case.
+func (c CaseCounts) add(word string) {
+	var letters, upper int
+	for _, r := range word {
+		if unicode.IsUpper(r) || unicode.IsTitle(r) {
+			letters++
+			upper++
+		} else if unicode.IsLower(r) {
+			letters++
+		}
+	}
+	if letters == 0 {
+		return
+	}
+	key := strings.ToLower(word)
+	first, _ := utf8.DecodeRuneInString(word)
+	count := c[key]
+	count.Seen++
+	if unicode.IsUpper(first) || unicode.IsTitle(first) {
+		count.Capitalized++
+	}
+	if upper == letters && letters > 1 {
+		count.AllCaps++
+	}
+	c[key] = count
+}
+
+// countCase records the case of each word in the given text, which must not
+// yet have been converted to lowercase, skipping the words at the start of
+// each sentence and each line.
+func (c CaseCounts) countCase(text string) {
+	sentenceStart := true
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isLetterOrDigit(r) {
+			if r == '\n' || r == '.' || r == '?' || r == '!' || punctTag(r) == "." {
+				sentenceStart = true
+			}
+			i += size
+			continue
+		}
+		end := i + size
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !isWordRune(r) {
+				break
+			}
+			end += size
+		}
+		if !sentenceStart {
+			c.add(text[i:end])
+		}
+		sentenceStart = false
+		i = end
+	}
+}
+
+// TakeCaseCounts returns the case counts the parser has collected from the
+// text it has parsed since it was created or since TakeCaseCounts was last
+// called, for teaching to a brain using Brain.AddCaseCounts. The parser
+// starts collecting afresh after each call.
+func (p *Parser) TakeCaseCounts() CaseCounts {
+	ret := p.casing
+	p.casing = nil
+	return ret
+}
+
+// AddCaseCounts teaches the brain how often words were capitalized in its
+// training material, so that Capitalize can restore the capitalization of
+// proper nouns and the like in the sentences the brain constructs. Use
+// Parser.TakeCaseCounts to obtain the counts for the text a parser has
+// parsed.
+//
+// AddCaseCounts panics if the brain has been frozen using Freeze.
+func (b *Brain) AddCaseCounts(counts CaseCounts) {
+	b.lock()
+	defer b.mut.Unlock()
+	for text, count := range counts {
+		b.addCaseCount(text, count)
+	}
+}
+
+// addCaseCount is the main implementation of AddCaseCounts for a single
+// word, which expects the caller to already be holding the write lock.
+func (b *Brain) addCaseCount(text string, count CaseCount) {
+	text = b.strs.intern(text)
+	existing := b.casing[text]
+	existing.Seen += count.Seen
+	existing.Capitalized += count.Capitalized
+	existing.AllCaps += count.AllCaps
+	b.casing[text] = existing
+}
+
+// CaseCount returns the case counts the brain has learned for words with
+// the given text, which are zero if it has never learned any.
+func (b *Brain) CaseCount(text string) CaseCount {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	return b.caseCount(strings.ToLower(text))
+}
+
+// caseCount is the main implementation of CaseCount, which combines the
+// counts of the brain with those of any brain it is layered over. Like the
+// other layered read methods, the caller must hold at least a read lock on
+// the brain.
+func (b *Brain) caseCount(text string) CaseCount {
+	ret := b.casing[text]
+	if b.base != nil {
+		base := b.base.caseCount(text)
+		ret.Seen += base.Seen
+		ret.Capitalized += base.Capitalized
+		ret.AllCaps += base.AllCaps
+	}
+	return ret
+}
+
+// Capitalize returns a copy of the given sentence in which each word that
+// the brain has seen capitalized in at least the given fraction of the times
+// it has seen it is capitalized, or written entirely in capitals if it was
+// usually written that way, as recorded using AddCaseCounts. Words the brain
+// has no case counts for are left in lowercase.
+//
+// Capitalize is intended for displaying a sentence the brain constructed.
+// The words of the resulting sentence don't match the brain's own words and
+// so the result should not be passed back to the brain. A threshold of zero
+// or less leaves every word in lowercase.
+func (b *Brain) Capitalize(s Sentence, threshold float64) Sentence {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	ret := make(Sentence, len(s))
+	copy(ret, s)
+	if threshold <= 0 {
+		return ret
+	}
+	for i, w := range s {
+		count := b.caseCount(w.Text)
+		if count.Seen == 0 {
+			continue
+		}
+		switch {
+		case float64(count.AllCaps)/float64(count.Seen) >= threshold:
+			ret[i].Text = strings.ToUpper(w.Text)
+		case float64(count.Capitalized)/float64(count.Seen) >= threshold:
+			first, size := utf8.DecodeRuneInString(w.Text)
+			ret[i].Text = string(unicode.ToTitle(first)) + w.Text[size:]
+		}
+	}
+	return ret
+}
+
+// sortedCaseTexts returns the texts of the words in the given table of case
+// counts, in sorted order.
+func sortedCaseTexts(counts map[string]CaseCount) []string {
+	ret := make([]string, 0, len(counts))
+	for text := range counts {
+		ret = append(ret, text)
+	}
+	sort.Strings(ret)
+	return ret
+}
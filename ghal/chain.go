@@ -2,40 +2,82 @@ package ghal
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math/rand"
 )
 
-const chainLen = 4
+// maxChainLen is the greatest number of words in a chain. Brains with a
+// lower order leave the remaining words at the end of each of their chains
+// empty, so that chains of every order have the same type.
+const maxChainLen = 4
 
-type chain [chainLen]Word
+// DefaultOrder is the order of brains created using NewBrain, which is the
+// number of words in each of their chains. MinOrder and MaxOrder are the
+// lowest and highest orders allowed by NewBrainWithOrder.
+const (
+	DefaultOrder = maxChainLen
+	MinOrder     = 2
+	MaxOrder     = maxChainLen
+)
+
+type chain [maxChainLen]Word
 
 func makeChain(words []Word) chain {
-	if len(words) != chainLen {
+	if len(words) < MinOrder || len(words) > maxChainLen {
 		panic("incorrect number of words for chain")
 	}
 	var ret chain
-	for i := range ret {
-		ret[i] = words[i]
-	}
+	copy(ret[:], words)
 	return ret
 }
 
+// len returns the number of words in the chain, which is the order of the
+// brain it belongs to.
+func (c *chain) len() int {
+	n := maxChainLen
+	for n > 0 && c[n-1] == (Word{}) {
+		n--
+	}
+	return n
+}
+
+// words returns the words of the chain, without the empty words that follow
+// them in a chain shorter than maxChainLen. The result shares the receiver's
+// memory.
+func (c *chain) words() []Word {
+	return c[:c.len()]
+}
+
+// last returns the last word of the chain, or an empty word if the chain
+// is empty.
+func (c *chain) last() Word {
+	n := c.len()
+	if n == 0 {
+		return Word{}
+	}
+	return c[n-1]
+}
+
 func (c *chain) GoString() string {
-	return fmt.Sprintf("ghal.makeChain(%#v)", c[:])
+	return fmt.Sprintf("ghal.makeChain(%#v)", c.words())
 }
 
-// PushBefore modifies the receiver in-place so that the first three words
-// are shifted along one position, the final word is lost, and the given
-// new word is placed in the first position.
+// PushBefore modifies the receiver in-place so that all but the last word
+// are shifted along one position, the last word is lost, and the given new
+// word is placed in the first position.
 func (c *chain) PushBefore(word Word) {
-	c[0], c[1], c[2], c[3] = word, c[0], c[1], c[2]
+	n := c.len()
+	copy(c[1:n], c[:n-1])
+	c[0] = word
 }
 
-// PushAfter modifies the receiver in-place so that the last three words
-// are shifted back one position, the first word is lost, and the given
-// new word is placed in the last position.
+// PushAfter modifies the receiver in-place so that all but the first word
+// are shifted back one position, the first word is lost, and the given new
+// word is placed in the last position.
 func (c *chain) PushAfter(word Word) {
-	c[0], c[1], c[2], c[3] = c[1], c[2], c[3], word
+	n := c.len()
+	copy(c[:n-1], c[1:n])
+	c[n-1] = word
 }
 
 type chainSet map[chain]struct{}
@@ -49,6 +91,72 @@ func (s chainSet) Add(c chain) {
 	s[c] = struct{}{}
 }
 
+// Union returns a new set containing all of the chains in the receiver and
+// in all of the other given sets.
+func (s chainSet) Union(others ...chainSet) chainSet {
+	ret := make(chainSet, len(s))
+	for c := range s {
+		ret.Add(c)
+	}
+	for _, os := range others {
+		for c := range os {
+			ret.Add(c)
+		}
+	}
+	return ret
+}
+
+// Intersect returns a new set containing only the chains that are in both
+// the receiver and all of the other given sets.
+func (s chainSet) Intersect(others ...chainSet) chainSet {
+	ret := make(chainSet)
+Chains:
+	for c := range s {
+		for _, os := range others {
+			if !os.Has(c) {
+				continue Chains
+			}
+		}
+		ret.Add(c)
+	}
+	return ret
+}
+
+// Subtract returns a new set containing the chains in the receiver that are
+// not in any of the other given sets.
+func (s chainSet) Subtract(others ...chainSet) chainSet {
+	ret := make(chainSet)
+Chains:
+	for c := range s {
+		for _, os := range others {
+			if os.Has(c) {
+				continue Chains
+			}
+		}
+		ret.Add(c)
+	}
+	return ret
+}
+
+// Equal returns true if the receiver and the given set contain exactly the
+// same chains.
+func (s chainSet) Equal(other chainSet) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for c := range s {
+		if !other.Has(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the number of chains in the set.
+func (s chainSet) Len() int {
+	return len(s)
+}
+
 // ChooseRandom will choose up to n chains pseudo-randomly from the receiving
 // set, returning a slice with n or fewer elements.
 func (s chainSet) ChooseRandom(n int) []chain {
@@ -94,3 +202,38 @@ func (s chainSet) ChooseRandomInto(into []chain) []chain {
 	}
 	return into
 }
+
+// hash returns a hash of the chain's words, for choosing which chains to keep
+// where only some can be kept, independently of the order they were learned
+// in and without favoring any particular words.
+func (c *chain) hash() uint64 {
+	h := fnv.New64a()
+	for _, w := range c.words() {
+		h.Write([]byte(w.Tag))
+		h.Write([]byte{0})
+		h.Write([]byte(w.Text))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// chainHashLess orders chains by their hashes, falling back on chainLess
+// for chains whose hashes collide so that the order is still total.
+func chainHashLess(a, b chain) bool {
+	ha, hb := a.hash(), b.hash()
+	if ha != hb {
+		return ha < hb
+	}
+	return chainLess(a, b)
+}
+
+// chainLess defines a total order over chains, for situations where we need
+// to produce results in a stable order.
+func chainLess(a, b chain) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return wordLess(a[i], b[i])
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package ghal
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Chain is a sequence of consecutive words that a brain has learned. Chains
+// are the unit of knowledge in a brain: each sentence a brain learns is
+// broken into overlapping chains, and new sentences are generated by
+// stitching chains back together.
+//
+// All of the chains of a particular brain have the same number of words,
+// which is the brain's order as returned by Brain.Order. Chain values are
+// comparable and can be used as map keys.
+type Chain struct {
+	c chain
+}
+
+// MakeChain constructs a chain from the given words, returning an error if
+// the number of words is not between MinOrder and MaxOrder inclusive or if
+// any of them is the empty word, Word{}, which chains use to mark the unused
+// positions after their last word. The resulting chain is known to a brain
+// only if the number of words is the brain's order.
+func MakeChain(words ...Word) (Chain, error) {
+	if len(words) < MinOrder || len(words) > MaxOrder {
+		return Chain{}, fmt.Errorf("a chain must have between %d and %d words, not %d", MinOrder, MaxOrder, len(words))
+	}
+	for i, w := range words {
+		if w == (Word{}) {
+			return Chain{}, fmt.Errorf("word %d of a chain can't be empty", i)
+		}
+	}
+	return Chain{makeChain(words)}, nil
+}
+
+// Len returns the number of words in the chain.
+func (c Chain) Len() int {
+	return c.c.len()
+}
+
+// Word returns the word at the given index in the chain, which must be
+// between zero and Len()-1 inclusive.
+func (c Chain) Word(i int) Word {
+	return c.c[i]
+}
+
+// First returns the first word in the chain.
+func (c Chain) First() Word {
+	return c.c[0]
+}
+
+// Last returns the last word in the chain.
+func (c Chain) Last() Word {
+	return c.c.last()
+}
+
+// Words returns the words of the chain as a new sentence, which the caller
+// may modify without affecting the chain.
+func (c Chain) Words() Sentence {
+	return append(Sentence(nil), c.c.words()...)
+}
+
+// Has returns true if the given word appears anywhere in the chain.
+func (c Chain) Has(w Word) bool {
+	for _, cw := range c.c.words() {
+		if cw == w {
+			return true
+		}
+	}
+	return false
+}
+
+// PushBefore returns a new chain that begins with the given word followed
+// by all but the last word of the receiver. If the receiver is the zero
+// Chain, which has no words, the result is also the zero Chain.
+func (c Chain) PushBefore(w Word) Chain {
+	if c.c.len() == 0 {
+		return Chain{}
+	}
+	c.c.PushBefore(w)
+	return c
+}
+
+// PushAfter returns a new chain that contains all but the first word of the
+// receiver followed by the given word. If the receiver is the zero Chain,
+// which has no words, the result is also the zero Chain.
+func (c Chain) PushAfter(w Word) Chain {
+	if c.c.len() == 0 {
+		return Chain{}
+	}
+	c.c.PushAfter(w)
+	return c
+}
+
+func (c Chain) String() string {
+	return c.Words().String()
+}
+
+func (c Chain) GoString() string {
+	return c.c.GoString()
+}
+
+// ChainsWithWord returns all of the chains the brain knows that contain the
+// given word, in a stable order.
+func (b *Brain) ChainsWithWord(w Word) []Chain {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	return exportChains(sortedChains(b.chainsWithWord(w)))
+}
+
+// ChainsStartingWith returns all of the chains the brain knows whose first
+// word is the given word, in a stable order.
+func (b *Brain) ChainsStartingWith(w Word) []Chain {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	return exportChains(sortedChains(b.chainsStartingWith(w)))
+}
+
+// ChainsEndingWith returns all of the chains the brain knows whose last word
+// is the given word, in a stable order.
+func (b *Brain) ChainsEndingWith(w Word) []Chain {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	return exportChains(sortedChains(b.chainsEndingWith(w)))
+}
+
+// sortedChains returns the chains from the given set as a slice, in a stable
+// order.
+func sortedChains(s chainSet) []chain {
+	ret := make([]chain, 0, len(s))
+	for c := range s {
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return chainLess(ret[i], ret[j])
+	})
+	return ret
+}
+
+func exportChains(cs []chain) []Chain {
+	if len(cs) == 0 {
+		return nil
+	}
+	ret := make([]Chain, len(cs))
+	for i, c := range cs {
+		ret[i] = Chain{c}
+	}
+	return ret
+}
@@ -0,0 +1,24 @@
+package ghal
+
+import (
+	"testing"
+)
+
+func TestMakeChainRejectsEmptyWord(t *testing.T) {
+	if _, err := MakeChain(MakeWord("NN", "cat"), Word{}, MakeWord("NN", "sat")); err == nil {
+		t.Errorf("no error for a chain containing an empty word")
+	}
+	if _, err := MakeChain(MakeWord("NN", "cat"), MakeWord("NN", "sat")); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestZeroChainPush(t *testing.T) {
+	w := MakeWord("NN", "cat")
+	if got := (Chain{}).PushBefore(w); got != (Chain{}) {
+		t.Errorf("PushBefore returned %#v; want the zero Chain", got)
+	}
+	if got := (Chain{}).PushAfter(w); got != (Chain{}) {
+		t.Errorf("PushAfter returned %#v; want the zero Chain", got)
+	}
+}
@@ -0,0 +1,179 @@
+package ghal
+
+import (
+	"strings"
+)
+
+// SentenceConstraint is a function that decides whether a generated sentence
+// is acceptable, returning true if it is.
+//
+// Constraints are given in GenerationOptions, and any candidate sentence
+// that fails at least one constraint is discarded and regenerated.
+type SentenceConstraint func(Sentence) bool
+
+// MustContainTag returns a constraint requiring that at least one word in
+// the sentence has a tag starting with the given prefix. For example, the
+// prefix "VB" matches all of the verb tags, and so MustContainTag("VB")
+// requires that a sentence contain at least one verb.
+func MustContainTag(prefix string) SentenceConstraint {
+	return func(s Sentence) bool {
+		for _, w := range s {
+			if strings.HasPrefix(w.Tag, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MustNotEndWithTag returns a constraint requiring that the last word in the
+// sentence, ignoring any trailing punctuation, does not have a tag starting
+// with the given prefix. For example, MustNotEndWithTag("IN") rejects
+// sentences ending with a preposition.
+func MustNotEndWithTag(prefix string) SentenceConstraint {
+	return func(s Sentence) bool {
+		for i := len(s) - 1; i >= 0; i-- {
+			if s[i].IsPunctuation() {
+				continue
+			}
+			return !strings.HasPrefix(s[i].Tag, prefix)
+		}
+		return true
+	}
+}
+
+// MustContainPOS returns a constraint requiring that at least one word in
+// the sentence has one of the given Universal POS tags, as returned by
+// Word.UPOS. This is like MustContainTag but doesn't depend on the tag set
+// the words were tagged with.
+func MustContainPOS(pos ...UPOS) SentenceConstraint {
+	return func(s Sentence) bool {
+		for _, w := range s {
+			if hasPOS(w, pos) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MustNotEndWithPOS is like MustNotEndWithTag but rejects sentences whose
+// last word, ignoring any trailing punctuation, has one of the given
+// Universal POS tags. For example, MustNotEndWithPOS(ghal.ADP, ghal.DET)
+// rejects sentences ending with a preposition or a determiner.
+func MustNotEndWithPOS(pos ...UPOS) SentenceConstraint {
+	return func(s Sentence) bool {
+		for i := len(s) - 1; i >= 0; i-- {
+			if s[i].IsPunctuation() {
+				continue
+			}
+			return !hasPOS(s[i], pos)
+		}
+		return true
+	}
+}
+
+func hasPOS(w Word, pos []UPOS) bool {
+	wpos := w.UPOS()
+	for _, p := range pos {
+		if wpos == p {
+			return true
+		}
+	}
+	return false
+}
+
+// MustContainContentWord returns a constraint requiring that the sentence
+// contains at least one noun, verb, adjective, or adverb, thus rejecting
+// sentences made only of "stopwords" like determiners, pronouns, and
+// prepositions.
+func MustContainContentWord() SentenceConstraint {
+	return func(s Sentence) bool {
+		for _, w := range s {
+			if w.IsContentWord() {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TransitionConstraint is a function that decides whether the word a may be
+// immediately followed by the word b in a generated sentence, returning true
+// if it may.
+//
+// Transition constraints are given in GenerationOptions and, unlike
+// SentenceConstraint, are enforced while a sentence is being constructed,
+// by never choosing a word that would make a forbidden transition. This is
+// much cheaper than discarding and regenerating whole sentences.
+type TransitionConstraint func(a, b Word) bool
+
+// ForbidTagSequence returns a transition constraint forbidding a word with a
+// tag starting with the prefix first from being immediately followed by a
+// word with a tag starting with the prefix second. For example,
+// ForbidTagSequence(",", ".") prevents a sentence from ending immediately
+// after a comma.
+func ForbidTagSequence(first, second string) TransitionConstraint {
+	return func(a, b Word) bool {
+		return !(strings.HasPrefix(a.Tag, first) && strings.HasPrefix(b.Tag, second))
+	}
+}
+
+// ForbidConsecutive returns a transition constraint forbidding two words
+// that both match the given function from appearing next to each other.
+// For example, ForbidConsecutive(ghal.Word.IsAtMention) prevents runs of
+// several @mentions.
+func ForbidConsecutive(match func(Word) bool) TransitionConstraint {
+	return func(a, b Word) bool {
+		return !(match(a) && match(b))
+	}
+}
+
+// allowsTransition returns true if the word a may be immediately followed by
+// the word b according to all of the transition constraints in the given
+// options.
+func (o GenerationOptions) allowsTransition(a, b Word) bool {
+	for _, constraint := range o.Transitions {
+		if !constraint(a, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowedAfter returns the subset of the given words that may immediately
+// follow the word a according to the transition constraints in the options,
+// and allowedBefore the subset that may immediately precede the word b. The
+// given set is returned as-is if there are no transition constraints.
+func (o GenerationOptions) allowedAfter(a Word, ws WordSet) WordSet {
+	if len(o.Transitions) == 0 {
+		return ws
+	}
+	return filterWords(ws, func(w Word) bool {
+		return o.allowsTransition(a, w)
+	})
+}
+
+func (o GenerationOptions) allowedBefore(b Word, ws WordSet) WordSet {
+	if len(o.Transitions) == 0 {
+		return ws
+	}
+	return filterWords(ws, func(w Word) bool {
+		return o.allowsTransition(w, b)
+	})
+}
+
+// filterWords returns a new set containing only the words in the given set
+// for which keep returns true, or the given set itself if it is empty.
+func filterWords(ws WordSet, keep func(Word) bool) WordSet {
+	if len(ws) == 0 {
+		return ws
+	}
+	ret := make(WordSet, len(ws))
+	for w := range ws {
+		if keep(w) {
+			ret.Add(w)
+		}
+	}
+	return ret
+}
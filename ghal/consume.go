@@ -0,0 +1,130 @@
+package ghal
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+// defaultConsumeBatchSize and defaultCheckpointInterval are the batch size
+// and checkpoint interval used by Consume when they are not given.
+const (
+	defaultConsumeBatchSize   = 1000
+	defaultCheckpointInterval = 5 * time.Minute
+)
+
+// ConsumeOptions are the options for Brain.Consume.
+type ConsumeOptions struct {
+	// Source is the name recorded as the source of the sentences, in the
+	// same sense as the source argument to AddSentencesFrom, or an empty
+	// string to record no provenance information.
+	Source string
+
+	// BatchSize is the number of sentences learned at once, while holding
+	// the brain's lock. Larger batches learn faster, but make other users
+	// of the brain wait longer and hold more sentences in memory. Zero
+	// means 1000.
+	BatchSize int
+
+	// Weight is the number of times each sentence counts as having been
+	// learned, as for Brain.AddSentenceWeighted. Zero or less means one, as
+	// for a sentence learned using Brain.AddSentence.
+	Weight int
+
+	// Checkpoint, if set, is called periodically while consuming with the
+	// number of sentences learned so far, so that the caller can save the
+	// brain or otherwise record progress, such as taking the case counts
+	// from the parser producing the sentences. Consume stops with the
+	// returned error if it isn't nil.
+	//
+	// Checkpoint is called without holding the brain's lock, so it may use
+	// any of the brain's methods, but it is not called after the last
+	// sentence; the caller should save the brain itself once Consume
+	// returns.
+	Checkpoint func(learned int) error
+
+	// CheckpointInterval is how often Checkpoint is called. Zero means five
+	// minutes.
+	CheckpointInterval time.Duration
+}
+
+// Consume teaches the brain all of the sentences in the given sequence,
+// such as one returned by trainhal.Stream, returning the number of sentences
+// learned, which doesn't include any that the brain's filter rejected.
+//
+// The sentences are learned in batches as they are produced, so the memory
+// used while consuming, other than by the brain itself and by whatever is
+// producing the sentences, doesn't depend on how many sentences there are.
+// This makes it possible to train a brain on a corpus far larger than the
+// memory available, as long as the brain it produces fits.
+//
+// Consume stops at the first error in the sequence, returning that error
+// after learning all of the sentences produced before it. If the given
+// context is cancelled, Consume stops at the end of the current batch,
+// returning the context's error.
+//
+// Consume panics if the brain has been frozen using Freeze.
+func (b *Brain) Consume(ctx context.Context, seq iter.Seq2[Sentence, error], opts ConsumeOptions) (int, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultConsumeBatchSize
+	}
+	interval := opts.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+
+	weight := max(opts.Weight, 1)
+
+	src := noSource
+	if opts.Source != "" {
+		b.lock()
+		src = b.sourceIdx(opts.Source)
+		b.mut.Unlock()
+	}
+
+	learned := 0
+	batch := make([]Sentence, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		b.lock()
+		for _, s := range batch {
+			if b.addSentence(s, src, weight) {
+				learned++
+			}
+		}
+		b.mut.Unlock()
+		clear(batch) // so that the sentences can be garbage collected
+		batch = batch[:0]
+	}
+
+	nextCheckpoint := time.Now().Add(interval)
+	for s, err := range seq {
+		if err != nil {
+			flush()
+			return learned, err
+		}
+		if len(s) == 0 {
+			continue
+		}
+		batch = append(batch, s)
+		if len(batch) < batchSize {
+			continue
+		}
+
+		flush()
+		if err := ctx.Err(); err != nil {
+			return learned, err
+		}
+		if opts.Checkpoint != nil && !time.Now().Before(nextCheckpoint) {
+			if err := opts.Checkpoint(learned); err != nil {
+				return learned, err
+			}
+			nextCheckpoint = time.Now().Add(interval)
+		}
+	}
+	flush()
+	return learned, nil
+}
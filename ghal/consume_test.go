@@ -0,0 +1,33 @@
+package ghal
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConsumeCountsOnlyLearnedSentences(t *testing.T) {
+	b := NewBrain()
+	b.SetFilter(func(s Sentence) bool {
+		return !s.Words().Has(MakeWord("NN", "dog"))
+	})
+	sentences := []Sentence{
+		testSentence("the", "cat", "sat", "on", "the", "mat"),
+		testSentence("the", "dog", "sat", "on", "the", "mat"),
+		testSentence("a", "bird", "flew", "over", "the", "house"),
+	}
+	seq := func(yield func(Sentence, error) bool) {
+		for _, s := range sentences {
+			if !yield(s, nil) {
+				return
+			}
+		}
+	}
+
+	learned, err := b.Consume(context.Background(), seq, ConsumeOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if learned != 2 {
+		t.Errorf("learned %d sentences; want 2", learned)
+	}
+}
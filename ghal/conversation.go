@@ -0,0 +1,256 @@
+package ghal
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// Conversation tracks the state of an ongoing conversation with a brain, so
+// that each reply can take into account what came before it.
+//
+// A Conversation is not safe for concurrent use, but any number of
+// conversations can share the same brain.
+type Conversation struct {
+	// Brain is the brain that constructs the replies.
+	Brain *Brain
+
+	// Options customizes how each reply is generated.
+	Options GenerationOptions
+
+	// TopicMemory is the number of previous exchanges whose topics continue
+	// to influence the choice of reply, so that the conversation tends to
+	// stay on the same subject for a while. Zero disables this behavior.
+	TopicMemory int
+
+	// TopicBonus is the number of points awarded to a candidate reply for
+	// each word it shares with the topic of the previous exchange.
+	TopicBonus int
+
+	// TopicDecay is the factor, between zero and one, by which the bonus for
+	// the topic of each exchange decreases for each more recent exchange,
+	// so that older topics have less influence than newer ones.
+	TopicDecay float64
+
+	// OutputMemory is the number of the conversation's most recent replies
+	// that Learn and FilterOwnOutput will refuse to learn, which prevents
+	// a feedback loop where a brain learns from its own output, such as
+	// when two bots talk to each other or when a user echoes replies back.
+	// Zero disables this behavior.
+	OutputMemory int
+
+	// ReplyCacheSize is the number of distinct inputs for which the
+	// conversation remembers its recent replies, so that when the same
+	// input is received repeatedly, MakeReply avoids repeating a recent
+	// reply to it. Inputs are considered the same if they have the same
+	// keywords. Zero disables this behavior.
+	ReplyCacheSize int
+
+	// topics is the topic of each of the most recent exchanges, from oldest
+	// to newest.
+	topics []WordSet
+
+	// outputs is the hash of each of the most recent replies, from oldest
+	// to newest, as returned by sentenceHash.
+	outputs []uint64
+
+	// replies is the cache of recent replies to each input, limited to
+	// ReplyCacheSize entries.
+	replies replyCache
+
+	// session is the brain that learns in place of Brain while a session of
+	// ephemeral learning is in progress, or nil if there is none, and
+	// sessionLearned is everything it has learned, to be learned by Brain
+	// if the session is committed.
+	session        *Brain
+	sessionLearned []Utterance
+}
+
+// NewConversation starts a new conversation with the given brain, using the
+// default options.
+func NewConversation(b *Brain) *Conversation {
+	return &Conversation{
+		Brain:       b,
+		TopicMemory: 3,
+		TopicBonus:  3,
+		TopicDecay:  0.5,
+
+		OutputMemory:   20,
+		ReplyCacheSize: 32,
+	}
+}
+
+// MakeReply constructs a reply to the given sentences in the same way as
+// Brain.MakeReplyWithOptions, but also preferring replies that continue the
+// topic of the recent exchanges in the conversation. The topic of this
+// exchange is then remembered for future replies.
+//
+// If ReplyCacheSize is set, MakeReply avoids repeating any of its recent
+// replies to the same input, and if that isn't possible it avoids at least
+// repeating the most recent one. If even that isn't possible, such as when
+// the brain knows only one way to reply, it repeats itself.
+func (c *Conversation) MakeReply(ss ...Sentence) ScoredReply {
+	opts := c.Options
+	opts.topic = c.Topic()
+	if len(c.topics) > 0 && c.TopicBonus > 0 {
+		// We mustn't modify the caller's slice of scorers.
+		opts.Scorers = append(opts.Scorers[:len(opts.Scorers):len(opts.Scorers)], c.topicScorer())
+	}
+
+	var ret ScoredReply
+	var cacheKey string
+	if c.ReplyCacheSize > 0 {
+		cacheKey = replyCacheKey(opts.keywordExtractor().Keywords(c.Brain, ss))
+		recent := c.replies.recent(cacheKey)
+		for len(recent) > 0 {
+			excludeOpts := opts
+			excludeOpts.Constraints = append(opts.Constraints[:len(opts.Constraints):len(opts.Constraints)], excludeReplies(recent))
+			ret = c.makeReply(excludeOpts, ss)
+			if len(ret.Sentence) > 0 || len(recent) == 1 {
+				break
+			}
+			// If we can't avoid all of the recent replies then we'll try
+			// again avoiding only the most recent one.
+			recent = recent[len(recent)-1:]
+		}
+		if len(ret.Sentence) == 0 {
+			// Repeating ourselves is better than not replying at all.
+			ret = c.makeReply(opts, ss)
+		}
+		if len(ret.Sentence) > 0 {
+			c.replies.add(cacheKey, ret.Sentence, c.ReplyCacheSize)
+		}
+	} else {
+		ret = c.makeReply(opts, ss)
+	}
+
+	c.rememberTopic(ss, ret.Sentence)
+	c.NoteOutput(ret.Sentence)
+	return ret
+}
+
+// NoteOutput records that the given sentence was sent as a reply in the
+// conversation, so that it won't be learned if it is later received as
+// input. Replies constructed by MakeReply are noted automatically, but
+// callers must use NoteOutput for replies constructed in other ways.
+func (c *Conversation) NoteOutput(s Sentence) {
+	if c.OutputMemory <= 0 {
+		c.outputs = nil
+		return
+	}
+	if len(s) == 0 {
+		return
+	}
+	c.outputs = append(c.outputs, sentenceHash(s))
+	if excess := len(c.outputs) - c.OutputMemory; excess > 0 {
+		c.outputs = c.outputs[excess:]
+	}
+}
+
+// FilterOwnOutput returns the given sentences except for any that are the
+// same as one of the conversation's recent replies, as limited by
+// OutputMemory.
+func (c *Conversation) FilterOwnOutput(ss []Sentence) []Sentence {
+	if len(c.outputs) == 0 {
+		return ss
+	}
+	ret := make([]Sentence, 0, len(ss))
+Sentences:
+	for _, s := range ss {
+		h := sentenceHash(s)
+		for _, oh := range c.outputs {
+			if h == oh {
+				debugf("not learning %q, which the conversation said itself", s)
+				continue Sentences
+			}
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+// Learn teaches the conversation's brain the given sentences in the same way
+// as Brain.AddSentencesFrom, except for any that FilterOwnOutput would
+// exclude. While a session begun with BeginSession is in progress, the
+// sentences are learned only for the session instead.
+func (c *Conversation) Learn(ss []Sentence, source string) {
+	ss = c.FilterOwnOutput(ss)
+	if c.session != nil {
+		c.learnInSession(ss, source)
+		return
+	}
+	c.Brain.AddSentencesFrom(ss, source)
+}
+
+// sentenceHash returns a hash of the given sentence for the purpose of
+// recognizing a previous reply. Only the text of the words is considered,
+// since parsing a reply that has been echoed back may tag its words
+// differently, and trailing periods are ignored, since they are often
+// trimmed from both input and output.
+func sentenceHash(s Sentence) uint64 {
+	h := fnv.New64a()
+	for _, w := range s.TrimPeriod() {
+		h.Write([]byte(w.Text))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// Topic returns all of the words that make up the recent topic of the
+// conversation, which influence the choice of subsequent replies.
+func (c *Conversation) Topic() WordSet {
+	ret := make(WordSet)
+	for _, topic := range c.topics {
+		for w := range topic {
+			ret.Add(w)
+		}
+	}
+	return ret
+}
+
+// ResetTopic forgets the topics of all of the previous exchanges, so that
+// the next reply will be chosen as if the conversation had just started.
+func (c *Conversation) ResetTopic() {
+	c.topics = nil
+}
+
+// topicScorer returns a scorer that awards points to candidate replies for
+// each word they share with the topics of the recent exchanges, with more
+// points for more recent exchanges.
+func (c *Conversation) topicScorer() ReplyScorer {
+	// We'll snapshot the weights now, since they can't change while we're
+	// constructing a reply.
+	topics := c.topics
+	weights := make([]float64, len(topics))
+	weight := float64(c.TopicBonus)
+	for i := len(topics) - 1; i >= 0; i-- {
+		weights[i] = weight
+		weight *= c.TopicDecay
+	}
+
+	return func(candidate Sentence, input []Sentence) int {
+		score := 0.0
+		for _, w := range candidate {
+			for i, topic := range topics {
+				if topic.Has(w) {
+					score += weights[i]
+				}
+			}
+		}
+		return int(math.Round(score))
+	}
+}
+
+// rememberTopic records the topic of an exchange made of the given input
+// and reply, which is the keywords of the input along with the nouns in
+// the reply, discarding the oldest topic if we've reached TopicMemory.
+func (c *Conversation) rememberTopic(input []Sentence, reply Sentence) {
+	if c.TopicMemory <= 0 {
+		c.topics = nil
+		return
+	}
+	topic := c.Options.keywordExtractor().Keywords(c.Brain, input).Union(reply.Nouns())
+	c.topics = append(c.topics, topic)
+	if excess := len(c.topics) - c.TopicMemory; excess > 0 {
+		c.topics = c.topics[excess:]
+	}
+}
@@ -0,0 +1,23 @@
+package ghal
+
+import (
+	"testing"
+)
+
+func TestConversationRepeatsOnlyPossibleReply(t *testing.T) {
+	b := NewBrain()
+	b.AddSentence(testSentence("the", "cat", "sat", "on", "the", "mat"))
+	c := NewConversation(b)
+	input := testSentence("cat")
+
+	first := c.MakeReply(input)
+	if len(first.Sentence) == 0 {
+		t.Fatalf("no first reply")
+	}
+	// The brain knows only one sentence, so the only reply it can make is
+	// the one it just made.
+	second := c.MakeReply(input)
+	if second.Sentence.String() != first.Sentence.String() {
+		t.Errorf("second reply is %q; want %q", second.Sentence, first.Sentence)
+	}
+}
@@ -0,0 +1,261 @@
+package ghal
+
+import (
+	"sort"
+)
+
+// maxResponseChains is the maximum number of response chains we'll record
+// for each stimulus word. Common words precede a great many messages, so we
+// only keep the few with the smallest hashes to avoid the response index
+// dominating the brain's memory usage. As for maxChainSources, choosing them
+// by hash means the chains kept don't depend on the order they were learned
+// in, without favoring any particular words.
+const maxResponseChains = 64
+
+// DialogueTurn is one participant's contribution to a dialogue, such as a
+// message in a chat log or a line of subtitles, for use with AddDialogue.
+type DialogueTurn struct {
+	// Speaker identifies who contributed the turn. Consecutive turns with
+	// the same non-empty speaker are treated as a single turn, while an
+	// empty speaker is treated as different from all others.
+	Speaker string
+
+	Sentences []Sentence
+}
+
+// AddDialogue is like AddSentencesFrom but also records which chains were
+// used in response to which words, by treating each turn of the given
+// dialogue as a response to the turn before it.
+//
+// The brain doesn't use this information when generating sentences, but it
+// can be used to prefer candidate replies that resemble how people have
+// actually responded to similar messages, using ResponseScorer.
+//
+// AddDialogue panics if the brain has been frozen using Freeze.
+func (b *Brain) AddDialogue(turns []DialogueTurn, source string) {
+	b.lock()
+	defer b.mut.Unlock()
+
+	src := b.sourceIdx(source)
+	var prev []Sentence
+	for i, turn := range turns {
+		// Sentences the filter rejects are neither learned nor recorded as
+		// part of a response.
+		ss := b.allowedSentences(turn.Sentences)
+		for _, s := range ss {
+			b.addSentence(s, src, 1)
+		}
+		if i > 0 {
+			if speaker := turns[i-1].Speaker; speaker == "" || speaker != turn.Speaker {
+				b.addResponse(prev, ss)
+			}
+		}
+		prev = ss
+	}
+}
+
+// addResponse records that the chains in the given response sentences were
+// used in response to the content words in the given stimulus sentences.
+// The caller must hold the write lock on the brain.
+func (b *Brain) addResponse(stimulus, response []Sentence) {
+	for _, ss := range stimulus {
+		for _, w := range ss {
+			if !w.IsContentWord() {
+				continue
+			}
+			w = b.strs.internWord(w)
+			chains := b.responseChains[w]
+			for _, s := range response {
+				for i := 0; i+b.order <= len(s); i++ {
+					chains = b.addResponseChain(w, chains, makeChain(s[i:i+b.order]))
+				}
+			}
+			if chains != nil {
+				b.responseChains[w] = chains
+			}
+		}
+	}
+}
+
+// addResponseChain adds the given chain to the given set of response chains
+// for the given word, allocating the set if it is nil, and returns the set.
+// If the set already has the maximum number of chains then the new chain
+// replaces the one with the greatest hash, if the new chain's hash is
+// smaller. The caller must hold the write lock on the brain.
+func (b *Brain) addResponseChain(w Word, chains chainSet, c chain) chainSet {
+	if chains == nil {
+		chains = make(chainSet)
+	}
+	if chains.Has(c) {
+		return chains
+	}
+	if len(chains) < maxResponseChains {
+		chains.Add(c)
+		return chains
+	}
+	greatest, ok := b.responseMax[w]
+	if !ok {
+		greatest = greatestHashChain(chains)
+	}
+	if !chainHashLess(c, greatest) {
+		b.responseMax[w] = greatest
+		return chains
+	}
+	delete(chains, greatest)
+	chains.Add(c)
+	// Most chains are rejected above once the set has been full for a
+	// while, so it's only the rarer replacements that look for a new
+	// greatest chain.
+	b.responseMax[w] = greatestHashChain(chains)
+	return chains
+}
+
+// greatestHashChain returns the chain in the given non-empty set that sorts
+// last by chainHashLess.
+func greatestHashChain(chains chainSet) chain {
+	var ret chain
+	var retHash uint64
+	first := true
+	for c := range chains {
+		h := c.hash()
+		if first || h > retHash || (h == retHash && chainLess(ret, c)) {
+			ret, retHash = c, h
+			first = false
+		}
+	}
+	return ret
+}
+
+// pruneResponseChains removes chains the brain no longer knows from its
+// response chains, along with any words left with no response chains. The
+// caller must hold the write lock on the brain.
+func (b *Brain) pruneResponseChains() {
+	for w, cs := range b.responseChains {
+		pruned := false
+		for c := range cs {
+			if !b.chains.Has(c) {
+				delete(cs, c)
+				pruned = true
+			}
+		}
+		if pruned {
+			delete(b.responseMax, w)
+		}
+		if len(cs) == 0 {
+			delete(b.responseChains, w)
+		}
+	}
+}
+
+// ResponseScorer returns a scorer that awards the given bonus for each chain
+// in the candidate that was used, in a dialogue learned with AddDialogue, in
+// response to a message containing one of the content words in the input.
+func (b *Brain) ResponseScorer(bonus int) ReplyScorer {
+	return func(candidate Sentence, input []Sentence) int {
+		if b.rlock() {
+			defer b.mut.RUnlock()
+		}
+
+		if !b.hasResponses() {
+			return 0
+		}
+		score := 0
+		for i := 0; i+b.order <= len(candidate); i++ {
+			c := makeChain(candidate[i : i+b.order])
+		inputs:
+			for _, s := range input {
+				for _, w := range s {
+					if b.responsesTo(w).Has(c) {
+						score += bonus
+						break inputs
+					}
+				}
+			}
+		}
+		return score
+	}
+}
+
+// ResponseKeywords returns a KeywordExtractor that adds to the keywords
+// selected by base up to n more words that have often appeared in responses
+// to messages containing the content words of the input, in dialogues learned
+// using AddDialogue. If base is nil, DefaultKeywords is used.
+//
+// This biases replies towards what people have actually said in response
+// to similar messages, so that, for example, a question about the weather
+// can be answered with a sentence about rain even if the question didn't
+// mention rain. If the brain hasn't learned any dialogues, the result is
+// the same as for base alone.
+func ResponseKeywords(base KeywordExtractor, n int) KeywordExtractor {
+	if base == nil {
+		base = DefaultKeywords
+	}
+	return KeywordExtractorFunc(func(b *Brain, input []Sentence) WordSet {
+		ret := base.Keywords(b, input)
+
+		inputWords := make(WordSet)
+		for _, s := range input {
+			for _, w := range s {
+				inputWords.Add(w)
+			}
+		}
+
+		// Each candidate is scored by the number of chains it appeared in that
+		// were used in response to any of the input words.
+		scores := make(map[Word]int)
+		func() {
+			if b.rlock() {
+				defer b.mut.RUnlock()
+			}
+			for w := range inputWords {
+				for c := range b.responsesTo(w) {
+					for i, rw := range c.words() {
+						if !rw.IsNoun() || inputWords.Has(rw) {
+							continue
+						}
+						if indexOfWord(c[:i], rw) >= 0 {
+							continue // only count each word once per chain
+						}
+						scores[rw]++
+					}
+				}
+			}
+		}()
+
+		candidates := make([]Word, 0, len(scores))
+		for w := range scores {
+			candidates = append(candidates, w)
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if scores[a] != scores[b] {
+				return scores[a] > scores[b]
+			}
+			return wordLess(a, b)
+		})
+		if len(candidates) > n {
+			candidates = candidates[:n]
+		}
+		if len(candidates) == 0 {
+			return ret
+		}
+
+		added := make(WordSet, len(candidates))
+		for _, w := range candidates {
+			debugf("keyword %s appeared in %d responses to similar messages", w, scores[w])
+			added.Add(w)
+		}
+		return ret.Union(added)
+	})
+}
+
+// indexOfWord returns the index of the first occurrence of the given word in
+// the given slice, or -1 if it isn't present.
+func indexOfWord(ws []Word, w Word) int {
+	for i, candidate := range ws {
+		if candidate == w {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,94 @@
+package ghal
+
+// BrainDiff describes the differences in knowledge between two brains, as
+// returned by DiffBrains.
+type BrainDiff struct {
+	// WordsAdded and WordsRemoved are the words known only to the new brain
+	// and only to the old brain, respectively.
+	WordsAdded   WordSet
+	WordsRemoved WordSet
+
+	// ChainsAdded and ChainsRemoved are the chains known only to the new
+	// brain and only to the old brain, respectively, in a stable order.
+	ChainsAdded   []Chain
+	ChainsRemoved []Chain
+
+	// SamplesAdded and SamplesRemoved are some example sentences that can be
+	// generated only by the new brain and only by the old brain, respectively.
+	// Each one passes through at least one chain the other brain doesn't know.
+	SamplesAdded   []Sentence
+	SamplesRemoved []Sentence
+}
+
+// Empty returns true if the diff describes no differences at all.
+func (d *BrainDiff) Empty() bool {
+	return len(d.WordsAdded) == 0 && len(d.WordsRemoved) == 0 && len(d.ChainsAdded) == 0 && len(d.ChainsRemoved) == 0
+}
+
+// DiffBrains compares the knowledge in the two given brains, returning a
+// description of what is present in only one of them. The result describes
+// changes in the direction from oldB to newB, so for example a word known
+// only to newB is reported as added.
+//
+// The samples argument gives the maximum number of example sentences to
+// generate in each direction. Samples are generated pseudorandomly, so they
+// will vary between calls even for the same pair of brains.
+func DiffBrains(oldB, newB *Brain, samples int) *BrainDiff {
+	if oldB.rlock() {
+		defer oldB.mut.RUnlock()
+	}
+	if newB != oldB {
+		if newB.rlock() {
+			defer newB.mut.RUnlock()
+		}
+	}
+
+	ret := &BrainDiff{
+		WordsAdded:   make(WordSet),
+		WordsRemoved: make(WordSet),
+	}
+
+	for w := range newB.wordChains {
+		if _, exists := oldB.wordChains[w]; !exists {
+			ret.WordsAdded.Add(w)
+		}
+	}
+	for w := range oldB.wordChains {
+		if _, exists := newB.wordChains[w]; !exists {
+			ret.WordsRemoved.Add(w)
+		}
+	}
+
+	added := sortedChains(newB.chains.Subtract(oldB.chains))
+	removed := sortedChains(oldB.chains.Subtract(newB.chains))
+	ret.ChainsAdded = exportChains(added)
+	ret.ChainsRemoved = exportChains(removed)
+	ret.SamplesAdded = sampleSentencesThrough(newB, added, samples)
+	ret.SamplesRemoved = sampleSentencesThrough(oldB, removed, samples)
+
+	return ret
+}
+
+// sampleSentencesThrough generates up to n sentences from the given brain,
+// each constructed around a different one of the given chains. The caller
+// must hold at least a read lock on the brain.
+func sampleSentencesThrough(b *Brain, cs []chain, n int) []Sentence {
+	if n <= 0 || len(cs) == 0 {
+		return nil
+	}
+	var ret []Sentence
+	// We'll spread our samples over the whole set of chains, rather than
+	// just taking the first few, so that we're not biased towards any
+	// particular part of the sort order.
+	step := len(cs) / n
+	if step < 1 {
+		step = 1
+	}
+	for i := 0; i < len(cs) && len(ret) < n; i += step {
+		s := b.makeSentenceFromChain(cs[i], false, false, GenerationOptions{})
+		if len(s) > 0 {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
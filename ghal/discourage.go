@@ -0,0 +1,103 @@
+package ghal
+
+import (
+	"math"
+)
+
+// discourageFactor is the factor by which a chain's weight is multiplied
+// each time it is discouraged.
+const discourageFactor = 0.5
+
+// Discourage makes the brain less likely to construct the given sentence
+// again, such as when a reply it constructed received a "thumbs down" from
+// whoever it was talking to. Each time a chain in the sentence is discouraged,
+// its weight is halved whenever it is a candidate for extending a sentence
+// being constructed, so that the words that lead to it are less likely to be
+// chosen where there are others that could be chosen instead.
+//
+// Unlike ForgetSentence, this doesn't remove anything the brain learned, so
+// it can still construct the sentence when there is no alternative, and
+// learning the sentence again doesn't undo it. Because a chain can be shared
+// with many other sentences, those sentences are discouraged too. The
+// discouragement affects only how often the brain chooses each word, so it
+// has no effect when generating with UniformTransitions set in the
+// GenerationOptions.
+//
+// Returns false if the brain knows none of the sentence's chains, in which
+// case nothing is changed. For an overlay brain, only the chains that the
+// overlay itself learned are discouraged.
+//
+// Discourage panics if the brain has been frozen using Freeze.
+func (b *Brain) Discourage(s Sentence) bool {
+	b.lock()
+	defer b.mut.Unlock()
+
+	return b.adjustSentence(s, 1)
+}
+
+// Reinforce is the opposite of Discourage, making the brain more likely to
+// construct the given sentence again, such as when a reply it constructed
+// was well received, by doubling the weight of each of the chains in the
+// sentence. Reinforcing a sentence that was discouraged undoes the
+// discouragement.
+//
+// Returns false if the brain knows none of the sentence's chains, in which
+// case nothing is changed. For an overlay brain, only the chains that the
+// overlay itself learned are reinforced.
+//
+// Reinforce panics if the brain has been frozen using Freeze.
+func (b *Brain) Reinforce(s Sentence) bool {
+	b.lock()
+	defer b.mut.Unlock()
+
+	return b.adjustSentence(s, -1)
+}
+
+// adjustSentence adds the given number to the discouragement of each of the
+// chains in the given sentence that the brain knows, returning false if it
+// knows none of them. The caller must hold the write lock on the brain.
+func (b *Brain) adjustSentence(s Sentence, n int) bool {
+	if len(s) < b.order {
+		return false
+	}
+	adjusted := false
+	for i := 0; i+b.order <= len(s); i++ {
+		chn := makeChain(s[i : i+b.order])
+		if !b.chains.Has(chn) {
+			continue
+		}
+		b.adjustDiscouraged(chn, n)
+		adjusted = true
+	}
+	return adjusted
+}
+
+// adjustDiscouraged adds the given number to the discouragement of the given
+// chain. The caller must hold the write lock on the brain.
+func (b *Brain) adjustDiscouraged(c chain, n int) {
+	if m := b.discouraged[c] + n; m != 0 {
+		b.discouraged[c] = m
+	} else {
+		delete(b.discouraged, c)
+	}
+}
+
+// chainWeight returns the factor by which the weight of the given chain is
+// multiplied as a candidate for extending a sentence, due to it being
+// discouraged or reinforced in the brain's own layer or in its base. The
+// caller must hold at least a read lock on the brain.
+func (b *Brain) chainWeight(c chain) float64 {
+	n := b.discouragement(c)
+	if n == 0 {
+		return 1
+	}
+	return math.Pow(discourageFactor, float64(n))
+}
+
+func (b *Brain) discouragement(c chain) int {
+	n := b.discouraged[c]
+	if b.base != nil {
+		n += b.base.discouragement(c)
+	}
+	return n
+}
@@ -0,0 +1,196 @@
+package ghal
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// EmojiTag is the tag assigned to emoji and emoticons, like "🍕" and ":)",
+// which the parser keeps intact as standalone words rather than leaving them
+// to the tokenizer, which would split multi-codepoint emoji apart.
+const EmojiTag = "EMOJI"
+
+// IsEmoji returns true if the word is an emoji or an emoticon.
+func (w Word) IsEmoji() bool {
+	return w.Tag == EmojiTag
+}
+
+// emoticons are the text emoticons the parser recognizes as emoji, when they
+// are surrounded by whitespace.
+var emoticons = map[string]bool{
+	":)": true, ":-)": true, ":(": true, ":-(": true, ":D": true, ":-D": true,
+	";)": true, ";-)": true, ":P": true, ":-P": true, ":p": true, ":-p": true,
+	":O": true, ":o": true, ":/": true, ":-/": true, ":|": true, ":-|": true,
+	":*": true, ":'(": true, "<3": true, "</3": true, "xD": true, "XD": true,
+	"^_^": true, "^^": true, "o_O": true, "O_o": true, "-_-": true, ":3": true,
+}
+
+// emojiPlaceholderPrefix and emojiPlaceholderSuffix surround the index of an
+// emoji in the placeholder words that replace emoji before tokenizing, which
+// the tokenizer treats as ordinary words.
+const (
+	emojiPlaceholderPrefix = "qqemoji"
+	emojiPlaceholderSuffix = "qq"
+)
+
+// extractEmoji replaces each emoji and emoticon in the given text with a
+// placeholder word, returning the new text and the emoji, in order, so that
+// the placeholders can be replaced again after tokenizing using
+// restoreEmoji.
+func extractEmoji(text string) (string, []string) {
+	var ret strings.Builder
+	var emoji []string
+	addPlaceholder := func(e string) {
+		ret.WriteByte(' ')
+		ret.WriteString(emojiPlaceholderPrefix)
+		ret.WriteString(strconv.Itoa(len(emoji)))
+		ret.WriteString(emojiPlaceholderSuffix)
+		ret.WriteByte(' ')
+		emoji = append(emoji, e)
+	}
+
+	for i := 0; i < len(text); {
+		if n := emojiLen(text[i:]); n > 0 {
+			addPlaceholder(text[i : i+n])
+			i += n
+			continue
+		}
+		if i == 0 || isSpaceByte(text[i-1]) {
+			end := strings.IndexAny(text[i:], " \t\r\n")
+			if end < 0 {
+				end = len(text) - i
+			}
+			if emoticons[text[i:i+end]] {
+				addPlaceholder(text[i : i+end])
+				i += end
+				continue
+			}
+		}
+		ret.WriteByte(text[i])
+		i++
+	}
+	if len(emoji) == 0 {
+		return text, nil
+	}
+	return ret.String(), emoji
+}
+
+// restoreEmoji returns the emoji that the given token is a placeholder for,
+// as produced by extractEmoji, or false if the token isn't a placeholder.
+func restoreEmoji(token string, emoji []string) (string, bool) {
+	if len(emoji) == 0 || !strings.HasPrefix(token, emojiPlaceholderPrefix) || !strings.HasSuffix(token, emojiPlaceholderSuffix) {
+		return "", false
+	}
+	idx, err := strconv.Atoi(token[len(emojiPlaceholderPrefix) : len(token)-len(emojiPlaceholderSuffix)])
+	if err != nil || idx < 0 || idx >= len(emoji) {
+		return "", false
+	}
+	return emoji[idx], true
+}
+
+// emojiLen returns the length in bytes of the emoji at the start of the given
+// text, including any modifiers, variation selectors, and joined emoji that
+// make up a single emoji sequence, or zero if the text doesn't start with an
+// emoji.
+func emojiLen(text string) int {
+	r, n := utf8.DecodeRuneInString(text)
+	switch {
+	case isRegionalIndicator(r):
+		// Flags are pairs of regional indicators.
+		if r2, n2 := utf8.DecodeRuneInString(text[n:]); isRegionalIndicator(r2) {
+			return n + n2
+		}
+		return n
+	case isKeycapBase(r):
+		// Keycaps are a digit, "#", or "*", optionally followed by a
+		// variation selector, followed by the combining keycap.
+		i := n
+		if r2, n2 := utf8.DecodeRuneInString(text[i:]); r2 == '\uFE0F' {
+			i += n2
+		}
+		if r2, n2 := utf8.DecodeRuneInString(text[i:]); r2 == '\u20E3' {
+			return i + n2
+		}
+		return 0
+	case !isPictographic(r):
+		return 0
+	}
+
+	i := n
+	for i < len(text) {
+		r, n := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\uFE0F' || (r >= 0x1F3FB && r <= 0x1F3FF) || (r >= 0xE0020 && r <= 0xE007F):
+			// Variation selectors, skin tone modifiers, and the tags used
+			// in subdivision flags all belong to the preceding emoji.
+			i += n
+		case r == '\u200D':
+			// A zero-width joiner joins the following emoji to this one.
+			next, nextLen := utf8.DecodeRuneInString(text[i+n:])
+			if !isPictographic(next) {
+				return i
+			}
+			i += n + nextLen
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// isPictographic returns true if the given rune is in one of the blocks of
+// pictographic symbols that are displayed as emoji.
+func isPictographic(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		return !isRegionalIndicator(r) && !(r >= 0x1F3FB && r <= 0x1F3FF)
+	case r >= 0x2600 && r <= 0x27BF, // Miscellaneous Symbols and Dingbats
+		r >= 0x2B05 && r <= 0x2B55, // arrows, squares, stars, and circles
+		r >= 0x231A && r <= 0x23FF, // watches, hourglasses, and media controls
+		r == 0x203C, r == 0x2049, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	default:
+		return false
+	}
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func isKeycapBase(r rune) bool {
+	return (r >= '0' && r <= '9') || r == '#' || r == '*'
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// EmojiKeywords returns a KeywordExtractor that adds any emoji in the input
+// to the keywords selected by base, so that replies can be constructed
+// around them. By default, emoji are never keywords. If base is nil,
+// DefaultKeywords is used.
+func EmojiKeywords(base KeywordExtractor) KeywordExtractor {
+	if base == nil {
+		base = DefaultKeywords
+	}
+	return KeywordExtractorFunc(func(b *Brain, input []Sentence) WordSet {
+		ret := base.Keywords(b, input)
+		var emoji WordSet
+		for _, s := range input {
+			for _, w := range s {
+				if w.IsEmoji() {
+					if emoji == nil {
+						emoji = make(WordSet)
+					}
+					emoji.Add(w)
+				}
+			}
+		}
+		if len(emoji) == 0 {
+			return ret
+		}
+		return ret.Union(emoji)
+	})
+}
@@ -0,0 +1,135 @@
+package ghal
+
+import (
+	"math"
+)
+
+// evalSmoothing is the fraction of the probability of each word that
+// Evaluate reserves for words the brain hasn't seen following a chain, so
+// that a single unfamiliar word doesn't make the perplexity infinite.
+const evalSmoothing = 0.01
+
+// EvalReport is a summary of how well a brain deals with a corpus of
+// sentences it hasn't learned, as returned by Evaluate.
+type EvalReport struct {
+	// Sentences is the number of sentences that were evaluated.
+	Sentences int
+
+	// Replies is the number of sentences that the brain was able to reply to.
+	Replies int
+
+	// Candidates is the total number of candidate replies generated while
+	// replying to all of the sentences.
+	Candidates int
+
+	// DuplicateReplies is the number of replies that were exactly the same
+	// as an earlier reply to a different sentence.
+	DuplicateReplies int
+
+	// Perplexity measures how surprised the brain is by the sentences, as
+	// the exponential of the average negative log probability of each word
+	// given the chain before it. Lower is better, and a brain that always
+	// predicted the next word perfectly would have a perplexity of one.
+	Perplexity float64
+
+	// PerplexityWords is the number of words the perplexity was measured
+	// over. Sentences shorter than a chain don't contribute any words.
+	PerplexityWords int
+}
+
+// ReplyRate returns the fraction of sentences the brain was able to reply
+// to, between zero and one.
+func (r *EvalReport) ReplyRate() float64 {
+	if r.Sentences == 0 {
+		return 0
+	}
+	return float64(r.Replies) / float64(r.Sentences)
+}
+
+// AverageCandidates returns the average number of candidates generated for
+// each reply.
+func (r *EvalReport) AverageCandidates() float64 {
+	if r.Replies == 0 {
+		return 0
+	}
+	return float64(r.Candidates) / float64(r.Replies)
+}
+
+// DuplicateRate returns the fraction of replies that duplicated an earlier
+// reply, between zero and one.
+func (r *EvalReport) DuplicateRate() float64 {
+	if r.Replies == 0 {
+		return 0
+	}
+	return float64(r.DuplicateReplies) / float64(r.Replies)
+}
+
+// Evaluate measures how well the brain deals with the given sentences, which
+// should be held out from the brain's training material, by replying to each
+// of them using the given options and by measuring the perplexity of the
+// brain's model on them.
+//
+// The perplexity considers only which words the brain has seen following
+// each chain, with each of those words as likely as the number of times it
+// was seen there, since that is how the brain chooses words by default.
+// Replies are generated pseudorandomly, so the other measurements vary
+// somewhat between calls.
+func Evaluate(b *Brain, sentences []Sentence, opts GenerationOptions) *EvalReport {
+	ret := &EvalReport{
+		Sentences: len(sentences),
+	}
+
+	seen := make(map[string]struct{})
+	for _, s := range sentences {
+		reply := b.MakeReplyWithOptions(opts, s)
+		if len(reply.Sentence) == 0 {
+			continue
+		}
+		ret.Replies++
+		ret.Candidates += reply.Candidates
+		str := reply.Sentence.String()
+		if _, dup := seen[str]; dup {
+			ret.DuplicateReplies++
+		}
+		seen[str] = struct{}{}
+	}
+
+	logProb, words := b.logProbability(sentences)
+	ret.PerplexityWords = words
+	if words > 0 {
+		ret.Perplexity = math.Exp(-logProb / float64(words))
+	}
+
+	return ret
+}
+
+// logProbability returns the total natural log probability of each word
+// after the first chain of each of the given sentences, along with the
+// number of words that were considered.
+func (b *Brain) logProbability(sentences []Sentence) (float64, int) {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	// Unfamiliar words share the smoothing probability equally between all
+	// of the words the brain knows, plus one for all of the words it doesn't.
+	unknown := evalSmoothing / float64(b.wordCount()+1)
+
+	total, words := 0.0, 0
+	for _, s := range sentences {
+		for i := b.order; i < len(s); i++ {
+			c := makeChain(s[i-b.order : i])
+			p := unknown
+			if after := b.wordsAfterChain(c); after.Has(s[i]) {
+				seen := 0
+				for w := range after {
+					seen += b.afterCount(c, w)
+				}
+				p += (1 - evalSmoothing) * float64(b.afterCount(c, s[i])) / float64(seen)
+			}
+			total += math.Log(p)
+			words++
+		}
+	}
+	return total, words
+}
@@ -0,0 +1,122 @@
+package ghal
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// SetFilter sets a function that decides which sentences the brain may learn
+// and say, returning true for those it may, or removes the filter if the
+// given function is nil. This is intended for keeping a bot that learns
+// from the public from learning or repeating offensive language, for which
+// BlocklistFilter is a simple starting point.
+//
+// Sentences that the filter rejects are ignored when learning, by all of the
+// methods that teach the brain sentences, and are never returned by the
+// methods that construct sentences, which try again or return nil instead.
+// Unlike the taboo list, the filter applies to whole sentences and can
+// consider every word in them, whatever its part of speech, but it doesn't
+// affect what the brain has already learned; use PurgeMatching to forget
+// sentences that were learned before the filter was set.
+//
+// The filter is called while the brain is locked, so it must not call any
+// of the brain's methods. It is not saved along with the brain, so it must
+// be set again each time the brain is loaded. An overlay brain also applies
+// the filter of its base, if the base had one when it was frozen.
+//
+// SetFilter panics if the brain has been frozen using Freeze.
+func (b *Brain) SetFilter(f func(Sentence) bool) {
+	b.lock()
+	defer b.mut.Unlock()
+	b.filter = f
+}
+
+// Allows returns true if the brain's filter, as set using SetFilter, allows
+// the given sentence, or if the brain has no filter.
+func (b *Brain) Allows(s Sentence) bool {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	return b.allows(s)
+}
+
+// allows is the main implementation of Allows, which expects the caller to
+// already be holding at least a read lock on the brain. An empty sentence is
+// always allowed, so that callers can report that no sentence was
+// constructed.
+func (b *Brain) allows(s Sentence) bool {
+	if len(s) == 0 {
+		return true
+	}
+	if b.filter != nil && !b.filter(s) {
+		debugf("sentence %q is rejected by the filter", s)
+		return false
+	}
+	return b.base == nil || b.base.allows(s)
+}
+
+// allowedOrNil returns the given sentence if the brain's filter allows it,
+// or nil otherwise. The caller must hold at least a read lock on the brain.
+func (b *Brain) allowedOrNil(s Sentence) Sentence {
+	if !b.allows(s) {
+		return nil
+	}
+	return s
+}
+
+// allowedSentences returns the sentences from the given slice that the
+// brain's filter allows, which is the slice itself if it allows them all.
+// The caller must hold at least a read lock on the brain.
+func (b *Brain) allowedSentences(ss []Sentence) []Sentence {
+	for i, s := range ss {
+		if b.allows(s) {
+			continue
+		}
+		ret := append([]Sentence(nil), ss[:i]...)
+		for _, s := range ss[i+1:] {
+			if b.allows(s) {
+				ret = append(ret, s)
+			}
+		}
+		return ret
+	}
+	return ss
+}
+
+// BlocklistFilter returns a filter for use with SetFilter that rejects any
+// sentence containing one of the given words, which are compared with the
+// text of each word in the sentence without regard to case or part of
+// speech. A hashtag in the sentence, like "#word", is compared without its
+// "#".
+func BlocklistFilter(words ...string) func(Sentence) bool {
+	blocked := make(map[string]struct{}, len(words))
+	for _, text := range words {
+		blocked[MakeWord("", text).Text] = struct{}{}
+	}
+	return func(s Sentence) bool {
+		for _, w := range s {
+			if _, ok := blocked[strings.TrimPrefix(w.Text, "#")]; ok {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ReadBlocklist reads words for use with BlocklistFilter from the given
+// reader, which should have one word on each line. Blank lines, and lines
+// starting with "#" to allow for comments, are ignored, along with any
+// space around each word.
+func ReadBlocklist(r io.Reader) ([]string, error) {
+	var ret []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ret = append(ret, line)
+	}
+	return ret, sc.Err()
+}
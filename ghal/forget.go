@@ -0,0 +1,250 @@
+package ghal
+
+// transitionKey identifies one transition of a chain, for tallying the
+// transitions of a sentence in ForgetSentence.
+type transitionKey struct {
+	c chain
+	w Word
+}
+
+// ForgetSentence removes what the brain learned from the given sentence when
+// it was taught it using AddSentence or one of the similar methods, returning
+// false without changing anything if the brain hasn't learned the sentence.
+// This is intended for unlearning something offensive that the brain learned
+// in chat; to forget particular words wherever they appear, use
+// PurgeMatching instead.
+//
+// The brain counts how many times it has learned each chain and each
+// transition between them, so the chains and transitions that the sentence
+// shares with other sentences the brain has learned are kept. A sentence
+// learned with a weight, such as by using AddSentenceWeighted, counts as
+// having been learned that many times, and so must be forgotten that many
+// times to be forgotten entirely. Brain files
+// saved before the brain counted how many times each chain started and ended
+// a sentence record every chain as having done so once, so a sentence that
+// was learned more than once by such a brain may need to be forgotten fewer
+// times than it was learned.
+//
+// The brain's bigram model, which is used to say something when the brain
+// knows only a few sentences, records only which words have been next to
+// each other, and so the pairs of words in the sentence, and its first and
+// last words as the start and end of a sentence, are kept only if they
+// appear within chains the brain still knows. What was learned only from
+// other sentences too short to make a chain may therefore be forgotten too.
+// The sources of the remaining chains, the case counts of the sentence's
+// words, and the training manifest are not changed.
+//
+// For an overlay brain, this can only forget what the overlay itself
+// learned, and so returns false for a sentence that only its base learned.
+//
+// ForgetSentence panics if the brain has been frozen using Freeze.
+func (b *Brain) ForgetSentence(s Sentence) bool {
+	if len(s) == 0 {
+		return false
+	}
+
+	b.lock()
+	defer b.mut.Unlock()
+
+	if len(s) < b.order {
+		if !b.bigrams.hasSentence(s) {
+			return false
+		}
+		b.forgetBigrams(s)
+		return true
+	}
+
+	// First we tally how many times the sentence uses each chain as a start
+	// or end and each transition, so that we can check that the brain has
+	// learned them at least that many times before changing anything.
+	maxIdx := len(s) - (b.order - 1)
+	chains := make([]chain, maxIdx)
+	starts := make(map[chain]int)
+	ends := make(map[chain]int)
+	befores := make(map[transitionKey]int)
+	afters := make(map[transitionKey]int)
+	for i := range chains {
+		chn := makeChain(s[i : i+b.order])
+		chains[i] = chn
+		if i == 0 {
+			starts[chn]++
+		} else {
+			befores[transitionKey{chn, s[i-1]}]++
+		}
+		if i == maxIdx-1 {
+			ends[chn]++
+		} else {
+			afters[transitionKey{chn, s[i+b.order]}]++
+		}
+	}
+	for c, n := range starts {
+		if !b.startChains.Has(c) || b.startCounts.count(c) < n {
+			return false
+		}
+	}
+	for c, n := range ends {
+		if !b.endChains.Has(c) || b.endCounts.count(c) < n {
+			return false
+		}
+	}
+	for t, n := range befores {
+		if !b.wordsBefore[t.c].Has(t.w) || b.beforeCounts.count(t.c, t.w) < n {
+			return false
+		}
+	}
+	for t, n := range afters {
+		if !b.wordsAfter[t.c].Has(t.w) || b.afterCounts.count(t.c, t.w) < n {
+			return false
+		}
+	}
+
+	for i, chn := range chains {
+		if i == 0 {
+			if b.startCounts.remove(chn) == 0 {
+				delete(b.startChains, chn)
+				removeFromChainIndex(b.startChainsByFirst, chn[0], chn)
+			}
+		} else {
+			b.forgetTransition(b.wordsBefore, b.beforeCounts, chn, s[i-1])
+		}
+		if i == maxIdx-1 {
+			if b.endCounts.remove(chn) == 0 {
+				delete(b.endChains, chn)
+				removeFromChainIndex(b.endChainsByLast, chn.last(), chn)
+			}
+		} else {
+			b.forgetTransition(b.wordsAfter, b.afterCounts, chn, s[i+b.order])
+		}
+	}
+
+	// Each time a chain was learned, it either started a sentence or had a
+	// word before it, so a chain that now has neither is no longer part of
+	// any sentence the brain knows.
+	removed := false
+	for _, chn := range chains {
+		if b.chains.Has(chn) && !b.startChains.Has(chn) && len(b.wordsBefore[chn]) == 0 {
+			b.removeChain(chn)
+			removed = true
+		}
+	}
+	if removed {
+		b.pruneResponseChains()
+	}
+
+	// The low-order model is derived from the chains, so we rebuild the
+	// parts of it that this sentence contributed to from the chains that
+	// remain.
+	for i := 0; i+lowOrderLen <= len(s); i++ {
+		b.rebuildLowOrderContext(makeLowOrderContext(s[i : i+lowOrderLen]))
+	}
+	b.forgetBigrams(s)
+	return true
+}
+
+// forgetTransition records that the given word has followed or preceded the
+// given chain one fewer time, in the given set of transitions and its
+// counts, removing it from the set if it no longer has. The caller must hold
+// the write lock on the brain.
+func (b *Brain) forgetTransition(sets map[chain]WordSet, counts transitionCounts, c chain, w Word) {
+	if counts.remove(c, w) > 0 {
+		return
+	}
+	ws := sets[c]
+	delete(ws, w)
+	if len(ws) == 0 {
+		delete(sets, c)
+	}
+}
+
+// rebuildLowOrderContext recomputes the transitions of the given context in
+// the brain's low-order model from the chains that contain it. The caller
+// must hold the write lock on the brain.
+func (b *Brain) rebuildLowOrderContext(ctx lowOrderContext) {
+	m := &b.lowOrder
+	delete(m.after, ctx)
+	delete(m.before, ctx)
+	delete(m.starts, ctx)
+	delete(m.ends, ctx)
+	for c := range b.wordChains[ctx[0]] {
+		n := c.len()
+		for i := 0; i+lowOrderLen <= n; i++ {
+			if makeLowOrderContext(c[i:i+lowOrderLen]) != ctx {
+				continue
+			}
+			if i+lowOrderLen < n {
+				addToAdjacency(m.after, ctx, c[i+lowOrderLen])
+			}
+			if i > 0 {
+				addToAdjacency(m.before, ctx, c[i-1])
+			}
+			if i == 0 && b.startChains.Has(c) {
+				m.starts[ctx] = struct{}{}
+			}
+			if i+lowOrderLen == n && b.endChains.Has(c) {
+				m.ends[ctx] = struct{}{}
+			}
+		}
+	}
+}
+
+// forgetBigrams removes the pairs of words in the given sentence from the
+// brain's bigram model, along with its first and last words as starts and
+// ends, except for those that still appear within the brain's chains. The
+// caller must hold the write lock on the brain.
+func (b *Brain) forgetBigrams(s Sentence) {
+	m := &b.bigrams
+	if len(b.startChainsByFirst[s[0]]) == 0 {
+		delete(m.starts, s[0])
+	}
+	if len(b.endChainsByLast[s[len(s)-1]]) == 0 {
+		delete(m.ends, s[len(s)-1])
+	}
+	for i := 1; i < len(s); i++ {
+		x, y := s[i-1], s[i]
+		if b.chainsHavePair(x, y) {
+			continue
+		}
+		if after := m.after[x]; after != nil {
+			delete(after, y)
+			if len(after) == 0 {
+				delete(m.after, x)
+			}
+		}
+		if before := m.before[y]; before != nil {
+			delete(before, x)
+			if len(before) == 0 {
+				delete(m.before, y)
+			}
+		}
+	}
+}
+
+// chainsHavePair returns true if the word x is followed by the word y within
+// any of the brain's own chains. The caller must hold at least a read lock
+// on the brain.
+func (b *Brain) chainsHavePair(x, y Word) bool {
+	for c := range b.wordChains[x] {
+		ws := c.words()
+		for i := 1; i < len(ws); i++ {
+			if ws[i-1] == x && ws[i] == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasSentence returns true if the model has learned every pair of words in
+// the given sentence, which must have at least one word, and that it can
+// start and end with its first and last words.
+func (m *bigramModel) hasSentence(s Sentence) bool {
+	if !m.starts.Has(s[0]) || !m.ends.Has(s[len(s)-1]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !m.after[s[i-1]].Has(s[i]) {
+			return false
+		}
+	}
+	return true
+}
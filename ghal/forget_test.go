@@ -0,0 +1,50 @@
+package ghal
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestForgetSentenceRestoresBrain checks that learning a sentence and then
+// forgetting it leaves the brain as it was, including where the sentence
+// shares chains and transitions with sentences the brain still knows.
+func TestForgetSentenceRestoresBrain(t *testing.T) {
+	tests := []struct {
+		name   string
+		forget Sentence
+	}{
+		{"unrelated", testSentence("a", "bird", "flew", "over", "the", "house")},
+		{"overlapping", testSentence("the", "cat", "sat", "on", "the", "rug")},
+		{"already known", testSentence("the", "cat", "sat", "on", "the", "mat")},
+		{"shorter than a chain", testSentence("hi")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := NewBrain()
+			b.AddSentence(testSentence("the", "cat", "sat", "on", "the", "mat"))
+			b.AddSentence(testSentence("a", "dog", "sat", "on", "the", "mat", "too"))
+			want := saveBrain(t, b)
+
+			b.AddSentence(test.forget)
+			if !b.ForgetSentence(test.forget) {
+				t.Fatalf("brain didn't forget the sentence")
+			}
+			if got := saveBrain(t, b); !bytes.Equal(got, want) {
+				t.Errorf("brain saved differently after forgetting")
+			}
+		})
+	}
+}
+
+func TestForgetSentenceUnknown(t *testing.T) {
+	b := NewBrain()
+	b.AddSentence(testSentence("the", "cat", "sat", "on", "the", "mat"))
+	want := saveBrain(t, b)
+
+	if b.ForgetSentence(testSentence("the", "cat", "sat", "on", "the", "rug")) {
+		t.Errorf("brain forgot a sentence it never learned")
+	}
+	if got := saveBrain(t, b); !bytes.Equal(got, want) {
+		t.Errorf("brain changed after failing to forget")
+	}
+}
@@ -0,0 +1,93 @@
+package ghal
+
+// Freeze returns a read-only snapshot of the receiver's current contents.
+// The snapshot is independent of the receiver, which can go on learning
+// without affecting it.
+//
+// A frozen brain can still generate sentences and replies, and can be saved
+// and inspected, but any attempt to teach it anything new will panic. In
+// return, reading from a frozen brain doesn't need any locking at all, so
+// many goroutines can generate replies from the same frozen brain at once
+// without contending with one another.
+//
+// The snapshot is a standalone brain, as returned by Flatten, that applies
+// the same filters as the receiver. Taking it costs as much time and memory
+// as copying the receiver, except that freezing a brain that is already
+// frozen just returns it. There is no way to unfreeze a brain, but a frozen
+// brain can be saved and then reloaded to obtain a new brain that can learn.
+func (b *Brain) Freeze() *Brain {
+	if b.frozen.Load() {
+		return b
+	}
+	ret := b.Flatten()
+	ret.filter = b.combinedFilter()
+	return ret.freeze()
+}
+
+// freeze makes the receiver itself permanently read-only, as for the brains
+// returned by Freeze, and then returns it, for convenience. It waits for any
+// learning already in progress to complete before it returns.
+func (b *Brain) freeze() *Brain {
+	b.mut.Lock()
+	b.frozen.Store(true)
+	b.mut.Unlock()
+	return b
+}
+
+// Frozen returns true if the brain is read-only, either because it was
+// returned by Freeze or because it is the base of an overlay brain.
+func (b *Brain) Frozen() bool {
+	return b.frozen.Load()
+}
+
+// combinedFilter returns a filter that allows only the sentences that the
+// filters of the receiver and of all of the brains beneath it allow, or nil
+// if none of them has a filter.
+func (b *Brain) combinedFilter() func(Sentence) bool {
+	var filters []func(Sentence) bool
+	for layer := b; layer != nil; layer = layer.base {
+		locked := layer.rlock()
+		if layer.filter != nil {
+			filters = append(filters, layer.filter)
+		}
+		if locked {
+			layer.mut.RUnlock()
+		}
+	}
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	default:
+		return func(s Sentence) bool {
+			for _, f := range filters {
+				if !f(s) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+}
+
+// rlock acquires a read lock on the brain unless it is frozen, in which case
+// no lock is needed. It returns true if the caller must eventually release
+// the lock with b.mut.RUnlock.
+func (b *Brain) rlock() bool {
+	if b.frozen.Load() {
+		return false
+	}
+	b.mut.RLock()
+	return true
+}
+
+// lock acquires the write lock on the brain, which the caller must eventually
+// release with b.mut.Unlock. It panics if the brain is frozen.
+func (b *Brain) lock() {
+	b.mut.Lock()
+	if b.frozen.Load() {
+		b.mut.Unlock()
+		panic("attempt to modify a frozen brain")
+	}
+}
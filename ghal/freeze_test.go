@@ -0,0 +1,49 @@
+package ghal
+
+import (
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	b := NewBrain()
+	b.AddSentence(testSentence("the", "cat", "sat", "on", "the", "mat"))
+	b.SetFilter(func(s Sentence) bool {
+		return !s.Words().Has(MakeWord("NN", "dog"))
+	})
+
+	frozen := b.Freeze()
+	if !frozen.Frozen() {
+		t.Fatalf("snapshot is not frozen")
+	}
+	if b.Frozen() {
+		t.Fatalf("receiver was frozen")
+	}
+	if frozen.Freeze() != frozen {
+		t.Errorf("freezing a frozen brain made another copy")
+	}
+
+	// The receiver can still learn, without affecting the snapshot.
+	b.AddSentence(testSentence("a", "bird", "flew", "over", "the", "house"))
+	bird := MakeWord("NN", "bird")
+	if b.WordFrequency(bird) == 0 {
+		t.Errorf("receiver didn't learn after freezing")
+	}
+	if frozen.WordFrequency(bird) != 0 {
+		t.Errorf("snapshot learned from the receiver")
+	}
+	if frozen.WordFrequency(MakeWord("NN", "cat")) == 0 {
+		t.Errorf("snapshot doesn't know what the receiver knew")
+	}
+
+	// The snapshot applies the receiver's filter.
+	if frozen.Allows(testSentence("the", "dog", "sat")) {
+		t.Errorf("snapshot doesn't apply the receiver's filter")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("teaching the snapshot didn't panic")
+		}
+	}()
+	frozen.AddSentence(testSentence("a", "bird", "flew", "over", "the", "house"))
+}
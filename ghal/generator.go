@@ -0,0 +1,114 @@
+package ghal
+
+// ReplyGenerator produces candidate replies to some input sentences, which
+// then compete with the candidates of any other generators in the relevance
+// scoring that chooses the reply. The brain's own Markov chain model is
+// MarkovGenerator, but other generators can contribute rule-based
+// responses, quotations retrieved from a database, or the output of an
+// external model.
+//
+// Generators are given in GenerationOptions. Candidates from generators
+// other than MarkovGenerator must still meet all of the constraints in the
+// options, and candidates containing taboo words are discarded, but
+// generators are responsible for keeping their candidates within MaxWords
+// and MaxChars.
+type ReplyGenerator interface {
+	// Candidates returns candidate replies to the request from the given
+	// brain. The generator may use the brain, but must not modify it.
+	Candidates(b *Brain, req ReplyRequest) []Sentence
+}
+
+// ReplyGeneratorFunc is an adapter allowing an ordinary function to be used
+// as a ReplyGenerator.
+type ReplyGeneratorFunc func(b *Brain, req ReplyRequest) []Sentence
+
+// Candidates calls the receiving function.
+func (f ReplyGeneratorFunc) Candidates(b *Brain, req ReplyRequest) []Sentence {
+	return f(b, req)
+}
+
+// ReplyRequest describes the reply that a ReplyGenerator is asked to
+// produce candidates for.
+type ReplyRequest struct {
+	// Input is the sentences being replied to.
+	Input []Sentence
+
+	// Keywords are the keywords selected from the input by the keyword
+	// extractor in Options, after any swaps and with taboo words removed.
+	Keywords WordSet
+
+	// Topic is the words making up the topic of the recent exchanges, for a
+	// reply made using Conversation.MakeReply, or nil otherwise.
+	Topic WordSet
+
+	// Options are the options the reply is being generated with.
+	Options GenerationOptions
+}
+
+// MarkovGenerator is the ReplyGenerator used if none are specified in
+// GenerationOptions, which constructs a candidate around each of the
+// keywords using the brain's chains.
+var MarkovGenerator ReplyGenerator = markovGenerator{}
+
+type markovGenerator struct{}
+
+func (markovGenerator) Candidates(b *Brain, req ReplyRequest) []Sentence {
+	var input WordSet
+	for _, s := range req.Input {
+		input = input.Union(s.Words())
+	}
+	ret := make([]Sentence, 0, len(req.Keywords))
+	for _, w := range req.Options.orderedWords(req.Keywords) {
+		s := b.makeReplyCandidate(w, input, req.Options)
+		if len(s) > 0 {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// generators returns the reply generators selected by the receiver, which
+// is only MarkovGenerator if none are selected.
+func (o GenerationOptions) generators() []ReplyGenerator {
+	if o.Generators == nil {
+		return []ReplyGenerator{MarkovGenerator}
+	}
+	return o.Generators
+}
+
+// generateCandidates collects the candidate replies to the given request
+// from each of the generators in its options, discarding any from other
+// generators than MarkovGenerator that aren't acceptable replies.
+func (b *Brain) generateCandidates(req ReplyRequest) []Sentence {
+	var input WordSet
+	for _, s := range req.Input {
+		input = input.Union(s.Words())
+	}
+	terms := b.Terminators()
+
+	var ret []Sentence
+	for _, g := range req.Options.generators() {
+		// The Markov generator's candidates are checked as they are made.
+		_, checked := g.(markovGenerator)
+		for _, s := range g.Candidates(b, req) {
+			if len(s) == 0 {
+				continue
+			}
+			if !checked && (b.sentenceIsTaboo(s) || !b.Allows(s) || !acceptableReply(s, input, req.Options, terms)) {
+				debugf("discarding unacceptable candidate %q", s)
+				continue
+			}
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// sentenceIsTaboo returns true if the given sentence contains any taboo
+// words.
+func (b *Brain) sentenceIsTaboo(s Sentence) bool {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	return b.containsTaboo(s)
+}
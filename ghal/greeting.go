@@ -0,0 +1,79 @@
+package ghal
+
+import (
+	"math/rand"
+)
+
+// SetGreetings configures the words the brain uses as keywords for the
+// sentence it opens a conversation with, as constructed by MakeGreeting, in
+// the same way as MegaHAL's greeting list. The texts are normalized in the
+// same way as the text of words. Greetings are saved and loaded along with
+// the rest of the brain. An overlay brain uses the greetings of its base
+// unless they are set for the overlay itself.
+//
+// SetGreetings panics if the brain has been frozen using Freeze.
+func (b *Brain) SetGreetings(texts []string) {
+	b.lock()
+	defer b.mut.Unlock()
+	b.greetings = make([]string, len(texts))
+	for i, text := range texts {
+		b.greetings[i] = b.strs.intern(MakeWord("", text).Text)
+	}
+}
+
+// Greetings returns the greeting words configured using SetGreetings.
+func (b *Brain) Greetings() []string {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	greetings := b.greets()
+	ret := make([]string, len(greetings))
+	copy(ret, greetings)
+	return ret
+}
+
+// greets is the main implementation of Greetings, which expects the caller
+// to already be holding at least a read lock.
+func (b *Brain) greets() []string {
+	switch {
+	case b.greetings != nil:
+		return b.greetings
+	case b.base != nil:
+		return b.base.greets()
+	default:
+		return nil
+	}
+}
+
+// MakeGreeting constructs a sentence for opening a conversation, containing
+// one of the greeting words configured using SetGreetings, chosen at random
+// from those the brain knows. It returns nil if there are no greetings or
+// the brain can't construct a sentence with any of them.
+func (b *Brain) MakeGreeting() Sentence {
+	var words []Word
+	locked := b.rlock()
+	greetings := b.greets()
+	texts := make(map[string]struct{}, len(greetings))
+	for _, text := range greetings {
+		texts[text] = struct{}{}
+	}
+	known := b.wordsWithTexts(texts)
+	for _, text := range greetings {
+		if w, ok := b.mostFrequentWord(known[text]); ok && !b.isTaboo(w) {
+			words = append(words, w)
+		}
+	}
+	if locked {
+		b.mut.RUnlock()
+	}
+
+	rand.Shuffle(len(words), func(i, j int) {
+		words[i], words[j] = words[j], words[i]
+	})
+	for _, w := range words {
+		if s := b.MakeSentenceWithKeyword(w); len(s) > 0 {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package ghal
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// hashtagTextBonus is the score given to a hashtag whose text, without the
+// leading "#", is one of a reply's keywords, which is enough to rank it above
+// hashtags that merely appear near the keywords.
+const hashtagTextBonus = 1000
+
+// Hashtags returns a set of all of the distinct hashtags in the sentence.
+func (s Sentence) Hashtags() WordSet {
+	ret := make(WordSet)
+	for _, w := range s {
+		if w.IsHashtag() {
+			ret.Add(w)
+		}
+	}
+	return ret
+}
+
+// isHashtagText returns true if the given token text looks like a hashtag,
+// which is a "#" followed by a letter.
+func isHashtagText(text string) bool {
+	if len(text) < 2 || text[0] != '#' {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(text[1:])
+	return unicode.IsLetter(r)
+}
+
+// addHashtags returns a copy of the given reply with hashtags related to the
+// given keywords appended to it, as described for GenerationOptions.Hashtags.
+func (b *Brain) addHashtags(reply Sentence, keywords WordSet, opts GenerationOptions) Sentence {
+	existing := reply.Hashtags()
+	n := opts.Hashtags - len(existing)
+	if opts.MaxWords > 0 && len(reply)+n > opts.MaxWords {
+		n = opts.MaxWords - len(reply)
+	}
+	if n <= 0 {
+		return reply
+	}
+
+	tags := b.relatedHashtags(keywords, existing)
+	if len(tags) == 0 {
+		return reply
+	}
+	ret := make(Sentence, 0, len(reply)+n)
+	ret = append(ret, reply...)
+	for _, tag := range tags {
+		if len(ret)-len(reply) >= n {
+			break
+		}
+		if opts.MaxChars > 0 && append(ret, tag).charLen() > opts.MaxChars {
+			continue // a shorter one might still fit
+		}
+		debugf("adding hashtag %s", tag)
+		ret = append(ret, tag)
+	}
+	return ret
+}
+
+// relatedHashtags returns the hashtags the brain knows that are related to
+// the given keywords, except for those in exclude, with the most closely
+// related first.
+//
+// A hashtag is related to a keyword if the keyword appears in the same chain
+// as the hashtag, or if the hashtag is the keyword itself with a "#" added.
+func (b *Brain) relatedHashtags(keywords, exclude WordSet) []Word {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	scores := make(map[Word]int)
+	for kw := range keywords {
+		for c := range b.chainsWithWord(kw) {
+			for i, w := range c.words() {
+				if !w.IsHashtag() || exclude.Has(w) || b.isTaboo(w) || indexOfWord(c[:i], w) >= 0 {
+					continue
+				}
+				scores[w]++
+			}
+		}
+		if kw.IsHashtag() {
+			continue
+		}
+		tag := Word{Tag: "NN", Text: "#" + kw.Text}
+		if !exclude.Has(tag) && !b.isTaboo(tag) && b.wordFrequency(tag) > 0 {
+			scores[tag] += hashtagTextBonus
+		}
+	}
+
+	ret := make([]Word, 0, len(scores))
+	for w := range scores {
+		ret = append(ret, w)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return wordLess(a, b)
+	})
+	return ret
+}
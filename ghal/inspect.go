@@ -0,0 +1,54 @@
+package ghal
+
+// ChainInfo describes what a brain knows about one particular chain, as
+// returned by Brain.InspectChain.
+type ChainInfo struct {
+	Chain Chain
+
+	// WordsBefore and WordsAfter are the words that have been seen to
+	// precede and succeed the chain respectively, in a stable order.
+	WordsBefore []Word
+	WordsAfter  []Word
+
+	// BeforeCounts and AfterCounts are the number of times each of the
+	// words in WordsBefore and WordsAfter respectively has been seen to
+	// precede or succeed the chain, in the same order as those words.
+	BeforeCounts []int
+	AfterCounts  []int
+
+	// CanStart and CanEnd are true if the chain has been seen at the
+	// beginning and end of a sentence respectively.
+	CanStart bool
+	CanEnd   bool
+}
+
+// InspectChain returns information about the given chain. The second return
+// value is false if the brain doesn't know the chain at all.
+//
+// This is intended for debugging and analysis of a brain, and is not used
+// during normal sentence generation.
+func (b *Brain) InspectChain(c Chain) (ChainInfo, bool) {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	if !b.hasChain(c.c) {
+		return ChainInfo{}, false
+	}
+	ret := ChainInfo{
+		Chain:       c,
+		WordsBefore: b.wordsBeforeChain(c.c).Sorted(),
+		WordsAfter:  b.wordsAfterChain(c.c).Sorted(),
+		CanStart:    b.isStartChain(c.c),
+		CanEnd:      b.isEndChain(c.c),
+	}
+	ret.BeforeCounts = make([]int, len(ret.WordsBefore))
+	for i, w := range ret.WordsBefore {
+		ret.BeforeCounts[i] = b.beforeCount(c.c, w)
+	}
+	ret.AfterCounts = make([]int, len(ret.WordsAfter))
+	for i, w := range ret.WordsAfter {
+		ret.AfterCounts[i] = b.afterCount(c.c, w)
+	}
+	return ret, true
+}
@@ -0,0 +1,35 @@
+package ghal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInspectChainCounts(t *testing.T) {
+	b := NewBrain()
+	b.AddSentence(testSentence("a", "cat", "sat", "on", "the", "mat"))
+	b.AddSentence(testSentence("a", "cat", "sat", "on", "the", "mat"))
+	b.AddSentence(testSentence("a", "cat", "sat", "on", "the", "rug"))
+	b.AddSentence(testSentence("my", "cat", "sat", "on", "the", "mat"))
+
+	c, err := MakeChain(testSentence("cat", "sat", "on", "the")[:4]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, ok := b.InspectChain(c)
+	if !ok {
+		t.Fatalf("brain doesn't know %s", c)
+	}
+	if want := []Word{MakeWord("NN", "a"), MakeWord("NN", "my")}; !reflect.DeepEqual(info.WordsBefore, want) {
+		t.Errorf("wrong words before\ngot:  %v\nwant: %v", info.WordsBefore, want)
+	}
+	if want := []int{3, 1}; !reflect.DeepEqual(info.BeforeCounts, want) {
+		t.Errorf("wrong counts before\ngot:  %v\nwant: %v", info.BeforeCounts, want)
+	}
+	if want := []Word{MakeWord("NN", "mat"), MakeWord("NN", "rug")}; !reflect.DeepEqual(info.WordsAfter, want) {
+		t.Errorf("wrong words after\ngot:  %v\nwant: %v", info.WordsAfter, want)
+	}
+	if want := []int{3, 1}; !reflect.DeepEqual(info.AfterCounts, want) {
+		t.Errorf("wrong counts after\ngot:  %v\nwant: %v", info.AfterCounts, want)
+	}
+}
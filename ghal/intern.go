@@ -0,0 +1,54 @@
+package ghal
+
+// stringTable is a table of interned strings, used to ensure that each
+// distinct string is stored in memory only once even if it appears in many
+// words.
+type stringTable map[string]string
+
+// intern returns the table's single copy of the given string, recording
+// the given string as that copy if it hasn't been seen before.
+func (t stringTable) intern(s string) string {
+	if existing, ok := t[s]; ok {
+		return existing
+	}
+	t[s] = s
+	return s
+}
+
+// internWord returns a copy of the given word whose text and tag are the
+// table's single copies of those strings.
+func (t stringTable) internWord(w Word) Word {
+	return Word{
+		Tag:  t.intern(w.Tag),
+		Text: t.intern(w.Text),
+	}
+}
+
+// internSentence returns a copy of the given sentence whose words have all
+// been interned in the table.
+func (t stringTable) internSentence(s Sentence) Sentence {
+	ret := make(Sentence, len(s))
+	for i, w := range s {
+		ret[i] = t.internWord(w)
+	}
+	return ret
+}
+
+// internChain returns a copy of the given chain whose words have all been
+// interned in the table.
+func (t stringTable) internChain(c chain) chain {
+	for i, w := range c {
+		c[i] = t.internWord(w)
+	}
+	return c
+}
+
+// internWords returns a copy of the given set whose words have all been
+// interned in the table.
+func (t stringTable) internWords(ws WordSet) WordSet {
+	ret := make(WordSet, len(ws))
+	for w := range ws {
+		ret.Add(t.internWord(w))
+	}
+	return ret
+}
@@ -0,0 +1,180 @@
+package ghal
+
+import (
+	"math"
+	"sort"
+)
+
+// KeywordExtractor selects the keywords that a reply to some input sentences
+// should be about. The brain tries to construct a candidate reply around
+// each keyword, so the choice of keywords largely decides how relevant
+// replies are to their input.
+type KeywordExtractor interface {
+	// Keywords returns the keywords for a reply to the given sentences from
+	// the given brain. The extractor may use the brain to decide which words
+	// are most interesting, but must not modify it.
+	Keywords(b *Brain, input []Sentence) WordSet
+}
+
+// KeywordExtractorFunc is an adapter allowing an ordinary function to be used
+// as a KeywordExtractor.
+type KeywordExtractorFunc func(b *Brain, input []Sentence) WordSet
+
+// Keywords calls the receiving function.
+func (f KeywordExtractorFunc) Keywords(b *Brain, input []Sentence) WordSet {
+	return f(b, input)
+}
+
+// DefaultKeywords is the KeywordExtractor used if none is specified in
+// GenerationOptions. It selects the proper nouns from the input if there are
+// at least two, and otherwise selects all of the nouns.
+var DefaultKeywords KeywordExtractor = KeywordExtractorFunc(nounKeywords)
+
+func nounKeywords(b *Brain, input []Sentence) WordSet {
+	var nouns, properNouns WordSet
+	for _, s := range input {
+		nouns = nouns.Union(s.Nouns())
+		properNouns = properNouns.Union(s.ProperNouns())
+	}
+
+	if len(properNouns) < 2 {
+		// If there's only one proper noun in the sentences (likely) then we'll
+		// add the regular nouns into the mix too just so the responses aren't
+		// always so predictable when proper nouns are present. The priority
+		// we give to proper nouns during scoring will still serve to prioritize
+		// responses containing these, but there will be some small chance of
+		// selecting a sentence about something else if it has enough similar
+		// regular nouns.
+		return nouns
+	}
+	return properNouns
+}
+
+// RAKEKeywords returns a KeywordExtractor that uses the Rapid Automatic
+// Keyword Extraction algorithm to select the words from the most significant
+// phrases in the input.
+//
+// The input is split into candidate phrases at punctuation and at words that
+// aren't content words, as defined by MustContainContentWord. Each word is
+// scored by the total length of the phrases it appears in divided by the
+// number of times it appears, which favors words that appear in long phrases,
+// and each phrase is scored by the sum of its word scores. The keywords are
+// all of the words in the maxPhrases highest-scoring phrases.
+func RAKEKeywords(maxPhrases int) KeywordExtractor {
+	return KeywordExtractorFunc(func(b *Brain, input []Sentence) WordSet {
+		var phrases [][]Word
+		for _, s := range input {
+			var current []Word
+			for _, w := range s {
+				if w.IsContentWord() {
+					current = append(current, w)
+					continue
+				}
+				if len(current) > 0 {
+					phrases = append(phrases, current)
+					current = nil
+				}
+			}
+			if len(current) > 0 {
+				phrases = append(phrases, current)
+			}
+		}
+
+		freq := make(map[Word]int)
+		degree := make(map[Word]int)
+		for _, p := range phrases {
+			for _, w := range p {
+				freq[w]++
+				degree[w] += len(p)
+			}
+		}
+		scores := make([]float64, len(phrases))
+		for i, p := range phrases {
+			for _, w := range p {
+				scores[i] += float64(degree[w]) / float64(freq[w])
+			}
+		}
+
+		// We sort indices rather than the phrases themselves so that we can
+		// keep the phrases in their original order when their scores are
+		// equal, making the result deterministic.
+		order := make([]int, len(phrases))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return scores[order[i]] > scores[order[j]]
+		})
+		if len(order) > maxPhrases {
+			order = order[:maxPhrases]
+		}
+
+		ret := make(WordSet)
+		for _, i := range order {
+			for _, w := range phrases[i] {
+				ret.Add(w)
+			}
+		}
+		return ret
+	})
+}
+
+// TFIDFKeywords returns a KeywordExtractor that selects up to n of the nouns
+// in the input, preferring those that are rare in the brain.
+//
+// Each noun is weighted by the number of times it appears in the input
+// multiplied by its inverse document frequency, which is the logarithm of
+// the number of chains the brain knows divided by the number of chains that
+// contain the noun. Nouns that appear in a large fraction of the brain's
+// chains, like "people" or "time", therefore have a low weight and are
+// selected only if there are no more distinctive nouns. Nouns the brain
+// doesn't know at all are never selected, since no reply could contain them.
+func TFIDFKeywords(n int) KeywordExtractor {
+	return KeywordExtractorFunc(func(b *Brain, input []Sentence) WordSet {
+		tf := make(map[Word]int)
+		for _, s := range input {
+			for _, w := range s {
+				if w.IsNoun() {
+					tf[w]++
+				}
+			}
+		}
+
+		type candidate struct {
+			word   Word
+			weight float64
+		}
+		candidates := make([]candidate, 0, len(tf))
+		func() {
+			if b.rlock() {
+				defer b.mut.RUnlock()
+			}
+			total := float64(b.chainCount())
+			for w, count := range tf {
+				freq := b.wordFrequency(w)
+				if freq == 0 {
+					continue
+				}
+				idf := math.Log(total / float64(freq))
+				candidates = append(candidates, candidate{w, float64(count) * idf})
+			}
+		}()
+
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].weight != candidates[j].weight {
+				return candidates[i].weight > candidates[j].weight
+			}
+			return wordLess(candidates[i].word, candidates[j].word)
+		})
+		if len(candidates) > n {
+			candidates = candidates[:n]
+		}
+
+		ret := make(WordSet, len(candidates))
+		for _, c := range candidates {
+			debugf("keyword %s has tf-idf weight %.2f", c.word, c.weight)
+			ret.Add(c.word)
+		}
+		return ret
+	})
+}
@@ -0,0 +1,176 @@
+package ghal
+
+import (
+	"time"
+)
+
+// learningHistoryMinutes is the number of minutes of learning activity a
+// brain remembers, which is the period over which the usual rate of
+// learning is established for the purpose of detecting spikes.
+const learningHistoryMinutes = 60
+
+// learningRateMinutes is the number of minutes over which LearningRate
+// averages the recent learning activity.
+const learningRateMinutes = 5
+
+// LearningRate describes how quickly a brain has been learning recently,
+// averaged over the last few minutes.
+type LearningRate struct {
+	// SentencesPerMinute is the number of sentences learned per minute.
+	SentencesPerMinute float64
+
+	// ChainsPerMinute is the number of chains per minute that were new to
+	// the brain when they were learned. A high rate of sentences with a low
+	// rate of new chains suggests that the same text is being repeated.
+	ChainsPerMinute float64
+}
+
+// LearningSpike describes a minute in which a brain learned abnormally
+// quickly, as reported to the LearningAlert callback.
+type LearningSpike struct {
+	// Time is the start of the minute in which the spike occurred.
+	Time time.Time
+
+	// Sentences and Chains are the numbers of sentences and new chains
+	// learned so far in that minute.
+	Sentences, Chains int
+
+	// Baseline is the average number of sentences learned per minute in the
+	// preceding hour.
+	Baseline float64
+}
+
+// LearningAlert configures a callback that is called when a brain learns
+// sentences abnormally quickly, which may indicate that someone is flooding
+// a bot with text in an attempt to poison its brain.
+type LearningAlert struct {
+	// Ratio is how many times greater than the baseline the number of
+	// sentences learned in one minute must be to be considered a spike.
+	Ratio float64
+
+	// MinSentences is the minimum number of sentences that must be learned
+	// in one minute to be considered a spike, so that a brain that learns
+	// only rarely doesn't report every burst of activity.
+	MinSentences int
+
+	// Func is called, on its own goroutine, at most once per minute when
+	// the number of sentences learned in that minute is a spike. Nil
+	// disables alerts.
+	Func func(LearningSpike)
+}
+
+// learningHistory records how many sentences and new chains a brain learned
+// in each of the last learningHistoryMinutes minutes.
+type learningHistory struct {
+	// minutes is a ring of per-minute counts, indexed by the minute number
+	// modulo its length.
+	minutes [learningHistoryMinutes]learningMinute
+
+	alert LearningAlert
+
+	// alerted is the minute number of the most recent spike reported to
+	// alert.Func, so that each spike is reported only once.
+	alerted int64
+}
+
+type learningMinute struct {
+	// minute is the number of minutes since the Unix epoch that this entry
+	// counts, which distinguishes current entries from stale ones left in
+	// the ring from an earlier hour.
+	minute int64
+
+	sentences, chains int
+}
+
+// current returns the entry for the given minute, resetting it if it is
+// left over from an earlier hour.
+func (h *learningHistory) current(minute int64) *learningMinute {
+	m := &h.minutes[minute%learningHistoryMinutes]
+	if m.minute != minute {
+		*m = learningMinute{minute: minute}
+	}
+	return m
+}
+
+// count returns the sentences and chains learned in the given minute.
+func (h *learningHistory) count(minute int64) (int, int) {
+	m := h.minutes[minute%learningHistoryMinutes]
+	if m.minute != minute {
+		return 0, 0
+	}
+	return m.sentences, m.chains
+}
+
+// noteLearned records that a sentence was learned at the given time, which
+// contained the given number of chains that were new to the brain, and
+// reports a spike if that makes this minute abnormally busy.
+func (h *learningHistory) noteLearned(now time.Time, newChains int) {
+	minute := now.Unix() / 60
+	m := h.current(minute)
+	m.sentences++
+	m.chains += newChains
+
+	if h.alert.Func == nil || h.alerted == minute || m.sentences < h.alert.MinSentences {
+		return
+	}
+	total := 0
+	for i := int64(1); i < learningHistoryMinutes; i++ {
+		sentences, _ := h.count(minute - i)
+		total += sentences
+	}
+	baseline := float64(total) / (learningHistoryMinutes - 1)
+	// A brain that has learned nothing at all in the last hour has a
+	// baseline of zero, which would make any activity a spike, so we
+	// assume at least one sentence per minute.
+	if float64(m.sentences) < h.alert.Ratio*max(baseline, 1) {
+		return
+	}
+	h.alerted = minute
+	spike := LearningSpike{
+		Time:      time.Unix(minute*60, 0),
+		Sentences: m.sentences,
+		Chains:    m.chains,
+		Baseline:  baseline,
+	}
+	debugf("learning spike: %d sentences this minute, baseline %.1f", spike.Sentences, spike.Baseline)
+	go h.alert.Func(spike)
+}
+
+// rate returns the learning rate averaged over the learningRateMinutes
+// minutes up to the given time, including the current, partial minute.
+func (h *learningHistory) rate(now time.Time) LearningRate {
+	minute := now.Unix() / 60
+	sentences, chains := 0, 0
+	for i := int64(0); i < learningRateMinutes; i++ {
+		s, c := h.count(minute - i)
+		sentences += s
+		chains += c
+	}
+	// The current minute is only partly over, so we count only the part
+	// of it that has elapsed.
+	elapsed := learningRateMinutes - 1 + float64(now.Unix()%60+1)/60
+	return LearningRate{
+		SentencesPerMinute: float64(sentences) / elapsed,
+		ChainsPerMinute:    float64(chains) / elapsed,
+	}
+}
+
+// LearningRate returns how quickly the brain has been learning new sentences
+// over the last few minutes. Only sentences learned since the brain was
+// created or loaded are counted; the learning history isn't saved.
+func (b *Brain) LearningRate() LearningRate {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	return b.learning.rate(time.Now())
+}
+
+// SetLearningAlert configures a callback to be notified when the brain
+// learns sentences abnormally quickly, replacing any previous alert.
+func (b *Brain) SetLearningAlert(alert LearningAlert) {
+	// This is configuration rather than learning, so it's allowed even
+	// for a frozen brain, although such a brain will never alert.
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.learning.alert = alert
+}
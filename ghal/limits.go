@@ -0,0 +1,87 @@
+package ghal
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseLimits constrains how much text ParseTextWithLimits will process, to
+// protect against untrusted input that could otherwise take a very long time
+// to parse or teach a brain absurdly long sentences.
+//
+// Each field that is zero imposes no limit.
+type ParseLimits struct {
+	// MaxTextLen is the maximum length of the text to parse, in bytes.
+	// Longer text is truncated at the last whitespace before the limit,
+	// before any parsing takes place.
+	MaxTextLen int
+
+	// MaxSentences is the maximum number of sentences to return. Any
+	// further sentences in the text are discarded.
+	MaxSentences int
+
+	// MaxSentenceWords is the maximum number of words, including
+	// punctuation, in each sentence. Longer sentences are discarded
+	// altogether, since truncating them would produce a sentence ending
+	// that never appeared in the original text.
+	MaxSentenceWords int
+}
+
+// ChatParseLimits are limits suitable for parsing messages received from
+// people chatting with a bot, which are generally short.
+var ChatParseLimits = ParseLimits{
+	MaxTextLen:       4096,
+	MaxSentences:     16,
+	MaxSentenceWords: 100,
+}
+
+// ParseTextWithLimits is like ParseText but enforces the given limits on how
+// much of the text is parsed. It should be used for any text from untrusted
+// sources, such as chat messages received over the network.
+//
+// The text is always divided into words as for SegmentWords, with numbers
+// kept as-is. To enforce limits while parsing text in a language that
+// doesn't put spaces between words, set ParseOptions.Limits and use
+// ParseTextWithOptions instead.
+func ParseTextWithLimits(text string, limits ParseLimits) ([]Sentence, error) {
+	return parseText(text, nil, ParseOptions{Limits: limits})
+}
+
+// truncateText returns a prefix of the given text that is no longer than
+// the given number of bytes, preferring to cut at whitespace so that we
+// don't produce a partial word.
+func truncateText(text string, maxLen int) string {
+	if maxLen <= 0 || len(text) <= maxLen {
+		return text
+	}
+	orig := text
+	text = text[:maxLen]
+	if i := strings.LastIndexFunc(text, unicode.IsSpace); i > 0 {
+		return text[:i]
+	}
+	// If there's no whitespace at all then we'll just make sure we don't
+	// cut in the middle of a multi-byte character, or between a character
+	// and the combining marks that follow it.
+	rest := orig[len(text):]
+	for len(text) > 0 {
+		r, size := utf8.DecodeLastRuneInString(text)
+		next, _ := utf8.DecodeRuneInString(rest)
+		if (r != utf8.RuneError || size != 1) && !unicode.IsMark(next) {
+			break
+		}
+		text, rest = text[:len(text)-size], text[len(text)-size:]+rest
+	}
+	return text
+}
+
+// tooManyWords returns true if the given sentence text certainly has more
+// than the given maximum number of words, which we can determine cheaply
+// before running the tagger because each whitespace-separated field produces
+// at least one token.
+func tooManyWords(text string, maxWords int) bool {
+	if maxWords <= 0 {
+		return false
+	}
+	return len(strings.Fields(text)) > maxWords
+}
@@ -0,0 +1,59 @@
+//go:build !js
+
+package ghal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrBrainInUse is returned by LockBrainFile when another process holds the
+// lock on the brain file and the caller didn't ask to wait for it.
+var ErrBrainInUse = errors.New("brain is in use by another process")
+
+// FileLock is an advisory lock on a brain file, as returned by
+// LockBrainFile.
+type FileLock struct {
+	f *os.File
+}
+
+// LockBrainFile takes an exclusive advisory lock on the brain file with the
+// given name, so that programs that load a brain, change it, and then save
+// it again can avoid doing so at the same time as each other, in which case
+// all but the last to save would lose their changes. If another process
+// holds the lock, LockBrainFile either waits for it to be released, if wait
+// is true, or returns ErrBrainInUse.
+//
+// Because SaveFileWithSnapshots replaces the brain file rather than writing
+// into it, the lock is actually taken on a hidden file alongside it, which
+// is created if necessary and left in place afterwards. The lock is
+// advisory, so it only excludes other callers of LockBrainFile, and is held
+// until it is released using Unlock or the process exits. Callers must keep
+// a reference to the returned lock for as long as they want to hold it.
+//
+// Locking is supported only on Unix-like systems. On others, LockBrainFile
+// always succeeds without excluding anything.
+func LockBrainFile(filename string, wait bool) (*FileLock, error) {
+	f, err := os.OpenFile(lockFilename(filename), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, wait); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock, after which it must not be used again.
+func (l *FileLock) Unlock() error {
+	return l.f.Close()
+}
+
+// lockFilename returns the name of the hidden file alongside the given brain
+// file that LockBrainFile locks.
+func lockFilename(filename string) string {
+	dir, base := filepath.Split(filename)
+	return filepath.Join(dir, "."+base+".lock")
+}
@@ -0,0 +1,12 @@
+//go:build !unix && !js
+
+package ghal
+
+import (
+	"os"
+)
+
+// lockFile does nothing on platforms without flock.
+func lockFile(f *os.File, wait bool) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package ghal
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on the given file, returning
+// ErrBrainInUse if another process holds it and wait is false.
+func lockFile(f *os.File, wait bool) error {
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		switch err {
+		case syscall.EINTR:
+			continue
+		case syscall.EWOULDBLOCK:
+			return ErrBrainInUse
+		}
+		return err
+	}
+}
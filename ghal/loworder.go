@@ -0,0 +1,192 @@
+package ghal
+
+// lowOrderLen is the number of words of context used by a brain's low-order
+// model, as opposed to the brain's order for its chains. This is no greater
+// than MinOrder, so that every chain contains at least one context.
+const lowOrderLen = 2
+
+// lowOrderContext is a sequence of consecutive words in the low-order model,
+// which plays the same role as a chain does in the main model.
+type lowOrderContext [lowOrderLen]Word
+
+// lowOrderModel is a model of the same sentences as a brain's chains but
+// with a shorter context, which records which words have followed and
+// preceded each pair of consecutive words. Its transitions are interpolated
+// with those of the chains during generation if
+// GenerationOptions.LowOrderWeight is set, which trades some of the
+// coherence of the chains for more variety.
+//
+// Every transition in the model also appears within some chain, so rather
+// than being saved it is rebuilt from the chains when a brain is loaded.
+type lowOrderModel struct {
+	// after and before map each context to the words that have followed and
+	// preceded it, respectively.
+	after  map[lowOrderContext]WordSet
+	before map[lowOrderContext]WordSet
+
+	// starts and ends are the contexts that have started and ended
+	// sentences, respectively.
+	starts map[lowOrderContext]struct{}
+	ends   map[lowOrderContext]struct{}
+}
+
+func newLowOrderModel() lowOrderModel {
+	return lowOrderModel{
+		after:  make(map[lowOrderContext]WordSet),
+		before: make(map[lowOrderContext]WordSet),
+		starts: make(map[lowOrderContext]struct{}),
+		ends:   make(map[lowOrderContext]struct{}),
+	}
+}
+
+// addChain teaches the model the transitions within the given chain, which
+// may be a start chain or an end chain or both.
+func (m *lowOrderModel) addChain(c chain, canStart, canEnd bool) {
+	n := c.len()
+	if canStart {
+		m.starts[makeLowOrderContext(c[:lowOrderLen])] = struct{}{}
+	}
+	if canEnd {
+		m.ends[makeLowOrderContext(c[n-lowOrderLen:n])] = struct{}{}
+	}
+	for i := 0; i+lowOrderLen < n; i++ {
+		addToAdjacency(m.after, makeLowOrderContext(c[i:i+lowOrderLen]), c[i+lowOrderLen])
+		addToAdjacency(m.before, makeLowOrderContext(c[i+1:i+1+lowOrderLen]), c[i])
+	}
+}
+
+func makeLowOrderContext(words []Word) lowOrderContext {
+	var ret lowOrderContext
+	copy(ret[:], words)
+	return ret
+}
+
+// addToAdjacency adds the given word to the set for the given context in
+// the given index, creating the set if necessary.
+func addToAdjacency(idx map[lowOrderContext]WordSet, ctx lowOrderContext, w Word) {
+	if _, ok := idx[ctx]; !ok {
+		idx[ctx] = make(WordSet)
+	}
+	idx[ctx].Add(w)
+}
+
+// lowOrderAfter and lowOrderBefore return the words that have followed and
+// preceded the given context, respectively, in the brain's low-order model,
+// and isLowOrderStart and isLowOrderEnd return true if the given context has
+// started or ended a sentence. Like the other layered read methods, the
+// caller must hold at least a read lock on the brain.
+func (b *Brain) lowOrderAfter(ctx lowOrderContext) WordSet {
+	if b.base == nil {
+		return b.lowOrder.after[ctx]
+	}
+	return layeredWords(b.lowOrder.after[ctx], b.base.lowOrderAfter(ctx))
+}
+
+func (b *Brain) lowOrderBefore(ctx lowOrderContext) WordSet {
+	if b.base == nil {
+		return b.lowOrder.before[ctx]
+	}
+	return layeredWords(b.lowOrder.before[ctx], b.base.lowOrderBefore(ctx))
+}
+
+func (b *Brain) isLowOrderStart(ctx lowOrderContext) bool {
+	_, ok := b.lowOrder.starts[ctx]
+	return ok || (b.base != nil && b.base.isLowOrderStart(ctx))
+}
+
+func (b *Brain) isLowOrderEnd(ctx lowOrderContext) bool {
+	_, ok := b.lowOrder.ends[ctx]
+	return ok || (b.base != nil && b.base.isLowOrderEnd(ctx))
+}
+
+// wordsAfterMixed returns the words that can follow the given chain in each
+// of the brain's models, and whether the chain can end a sentence, for
+// extending a sentence forwards in completeSentence. The caller must hold at
+// least a read lock on the brain.
+//
+// If the options don't call for interpolation then the result comes only
+// from the chains. Otherwise, once the sentence has wandered onto a sequence
+// of words that isn't one of the brain's chains, only the low-order model
+// has anything to say about it.
+//
+// Either way, words that would break one of the transition constraints in
+// the options are excluded, along with words that would lead immediately to
+// a dead end because everything that could follow them is excluded.
+func (b *Brain) wordsAfterMixed(c chain, opts GenerationOptions) (high, low WordSet, canEnd bool) {
+	high, low, canEnd = b.allowedAfterMixed(c, opts)
+	if len(opts.Transitions) > 0 {
+		viable := func(w Word) bool {
+			next := c
+			next.PushAfter(w)
+			nextHigh, nextLow, nextCanEnd := b.allowedAfterMixed(next, opts)
+			return nextCanEnd || len(nextHigh)+len(nextLow) > 0
+		}
+		high, low = filterWords(high, viable), filterWords(low, viable)
+	}
+	return high, low, canEnd
+}
+
+// wordsBeforeMixed is like wordsAfterMixed but for extending a sentence
+// backwards, returning whether the chain can start a sentence.
+func (b *Brain) wordsBeforeMixed(c chain, opts GenerationOptions) (high, low WordSet, canStart bool) {
+	high, low, canStart = b.allowedBeforeMixed(c, opts)
+	if len(opts.Transitions) > 0 {
+		viable := func(w Word) bool {
+			next := c
+			next.PushBefore(w)
+			nextHigh, nextLow, nextCanStart := b.allowedBeforeMixed(next, opts)
+			return nextCanStart || len(nextHigh)+len(nextLow) > 0
+		}
+		high, low = filterWords(high, viable), filterWords(low, viable)
+	}
+	return high, low, canStart
+}
+
+// allowedAfterMixed and allowedBeforeMixed are like wordsAfterMixed and
+// wordsBeforeMixed but without excluding words that lead to a dead end.
+func (b *Brain) allowedAfterMixed(c chain, opts GenerationOptions) (high, low WordSet, canEnd bool) {
+	last := c.last()
+	if opts.LowOrderWeight <= 0 {
+		return opts.allowedAfter(last, b.wordsAfterChain(c)), nil, b.isEndChain(c)
+	}
+	n := c.len()
+	ctx := makeLowOrderContext(c[n-lowOrderLen : n])
+	low = opts.allowedAfter(last, b.lowOrderAfter(ctx))
+	if !b.hasChain(c) {
+		return nil, low, b.isLowOrderEnd(ctx)
+	}
+	return opts.allowedAfter(last, b.wordsAfterChain(c)), low, b.isEndChain(c)
+}
+
+func (b *Brain) allowedBeforeMixed(c chain, opts GenerationOptions) (high, low WordSet, canStart bool) {
+	first := c[0]
+	if opts.LowOrderWeight <= 0 {
+		return opts.allowedBefore(first, b.wordsBeforeChain(c)), nil, b.isStartChain(c)
+	}
+	ctx := makeLowOrderContext(c[:lowOrderLen])
+	low = opts.allowedBefore(first, b.lowOrderBefore(ctx))
+	if !b.hasChain(c) {
+		return nil, low, b.isLowOrderStart(ctx)
+	}
+	return opts.allowedBefore(first, b.wordsBeforeChain(c)), low, b.isStartChain(c)
+}
+
+// chooseMixedWord selects one word pseudorandomly from the interpolation of
+// the given sets of candidates from the chains and the low-order model, at
+// least one of which must not be empty. The low-order candidates are chosen
+// from with probability LowOrderWeight, unless either set is empty, in which
+// case the other is used. The candidates from the chains are weighted using
+// the given function as for chooseWord. The caller must hold at least a read
+// lock on the brain.
+func (b *Brain) chooseMixedWord(high, low WordSet, highWeight func(Word) float64, opts GenerationOptions) Word {
+	switch {
+	case len(low) == 0:
+		return b.chooseWord(high, highWeight, opts)
+	case len(high) == 0:
+		return b.chooseWord(low, nil, opts)
+	case opts.float64() < opts.LowOrderWeight:
+		return b.chooseWord(low, nil, opts)
+	default:
+		return b.chooseWord(high, highWeight, opts)
+	}
+}
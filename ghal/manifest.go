@@ -0,0 +1,89 @@
+package ghal
+
+import (
+	"sort"
+	"time"
+)
+
+// TrainedSource is an entry in a brain's training manifest, describing a
+// training source that the brain has already learned from so that it need
+// not be learned again.
+type TrainedSource struct {
+	// Name identifies the source, such as a filename or a URL.
+	Name string
+
+	// Hash is a digest of the source's content at the time it was learned,
+	// in whatever format the caller prefers, which allows callers to notice
+	// when a source has changed since it was learned.
+	Hash string
+
+	// Size is the number of bytes of the source that have been learned, for
+	// callers that learn growing sources incrementally. If set, Hash is a
+	// digest of only those bytes.
+	Size int64
+
+	// Time is when the source was learned.
+	Time time.Time
+
+	// Sentences is the number of sentences that were learned from the source.
+	Sentences int
+
+	// Partial, if nonzero, means that learning the source was interrupted
+	// after reading this many sentences from it, starting after its first
+	// Size bytes, so that the caller can resume where it left off rather
+	// than learn those sentences again. Hash is then a digest of the whole
+	// source, so that the caller can tell whether it has changed since.
+	Partial int
+}
+
+// RecordTrainedSource adds the given source to the brain's training
+// manifest, replacing any existing entry with the same name.
+//
+// The brain doesn't use the manifest itself, and recording a source doesn't
+// teach the brain anything; it's for callers to keep track of which
+// sources they have already taught the brain using AddSentencesFrom.
+//
+// RecordTrainedSource panics if the brain has been frozen using Freeze.
+func (b *Brain) RecordTrainedSource(ts TrainedSource) {
+	b.lock()
+	defer b.mut.Unlock()
+	b.trained[ts.Name] = ts
+}
+
+// TrainedSource returns the training manifest entry with the given name. The
+// second return value is false if there is no such entry.
+func (b *Brain) TrainedSource(name string) (TrainedSource, bool) {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	ts, ok := b.trained[name]
+	if !ok && b.base != nil {
+		return b.base.TrainedSource(name)
+	}
+	return ts, ok
+}
+
+// TrainedSources returns all of the entries in the brain's training manifest,
+// ordered by name.
+func (b *Brain) TrainedSources() []TrainedSource {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	ret := make([]TrainedSource, 0, len(b.trained))
+	for _, ts := range b.trained {
+		ret = append(ret, ts)
+	}
+	if b.base != nil {
+		for _, ts := range b.base.TrainedSources() {
+			if _, overridden := b.trained[ts.Name]; !overridden {
+				ret = append(ret, ts)
+			}
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
@@ -0,0 +1,160 @@
+package ghal
+
+import (
+	"time"
+	"unsafe"
+)
+
+// These are rough approximations of the memory used by the Go runtime's
+// data structures, used by MemoryEstimate. They don't need to be exact, since
+// the estimate is only intended to be accurate to within a small factor.
+const (
+	// mapHeaderSize is the approximate fixed cost of a map.
+	mapHeaderSize = 48
+
+	// mapGroupSlots is the number of slots the runtime allocates at once for
+	// map entries, which is also the minimum number of slots in a non-empty
+	// map.
+	mapGroupSlots = 8
+
+	wordSize          = int64(unsafe.Sizeof(Word{}))
+	chainSize         = int64(unsafe.Sizeof(chain{}))
+	ptrSize           = int64(unsafe.Sizeof(uintptr(0)))
+	intSize           = int64(unsafe.Sizeof(0))
+	hashSize          = int64(unsafe.Sizeof(uint64(0)))
+	stringSize        = int64(unsafe.Sizeof(""))
+	sliceHeaderSize   = int64(unsafe.Sizeof([]int(nil)))
+	timeSize          = int64(unsafe.Sizeof(time.Time{}))
+	trainedSourceSize = int64(unsafe.Sizeof(TrainedSource{}))
+	caseCountSize     = int64(unsafe.Sizeof(CaseCount{}))
+)
+
+// MemoryEstimate returns an estimate of the number of bytes of memory the
+// brain is using, based on the number of words and chains it knows and the
+// lengths of the strings in its words.
+//
+// The estimate is intended only to give a sense of scale, such as to warn
+// when a brain is approaching the memory available to it, and may differ from
+// the actual usage by a small factor depending on the Go runtime version.
+func (b *Brain) MemoryEstimate() int64 {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	// Each distinct string is stored only once, due to interning.
+	ret := mapSize(len(b.strs), 2*stringSize)
+	for s := range b.strs {
+		ret += int64(len(s))
+	}
+
+	ret += mapSize(len(b.chains), chainSize)
+	ret += mapSize(len(b.startChains), chainSize)
+	ret += mapSize(len(b.endChains), chainSize)
+	ret += mapSize(len(b.startCounts), chainSize+intSize)
+	ret += mapSize(len(b.endCounts), chainSize+intSize)
+	ret += mapSize(len(b.discouraged), chainSize+intSize)
+	ret += chainIndexSize(b.wordChains)
+	ret += chainIndexSize(b.firstWordChains)
+	ret += chainIndexSize(b.lastWordChains)
+	ret += chainIndexSize(b.startChainsByFirst)
+	ret += chainIndexSize(b.endChainsByLast)
+	ret += adjacencySize(b.wordsAfter)
+	ret += adjacencySize(b.wordsBefore)
+	ret += transitionCountsSize(b.afterCounts)
+	ret += transitionCountsSize(b.beforeCounts)
+
+	ret += mapSize(len(b.chainSources), chainSize+sliceHeaderSize)
+	for _, srcs := range b.chainSources {
+		ret += int64(cap(srcs)) * ptrSize
+	}
+	ret += adjacencyIndexSize(b.bigrams.after)
+	ret += adjacencyIndexSize(b.bigrams.before)
+	ret += mapSize(len(b.bigrams.starts), wordSize)
+	ret += mapSize(len(b.bigrams.ends), wordSize)
+	ret += lowOrderAdjacencySize(b.lowOrder.after)
+	ret += lowOrderAdjacencySize(b.lowOrder.before)
+	ret += mapSize(len(b.lowOrder.starts), lowOrderLen*wordSize)
+	ret += mapSize(len(b.lowOrder.ends), lowOrderLen*wordSize)
+
+	ret += chainIndexSize(b.responseChains)
+	ret += mapSize(len(b.responseMax), wordSize+chainSize)
+	ret += mapSize(len(b.taboo), wordSize)
+	ret += mapSize(len(b.swaps), 2*stringSize)
+	ret += int64(cap(b.greetings)) * stringSize
+	ret += mapSize(len(b.casing), stringSize+caseCountSize)
+	ret += mapSize(len(b.wordMeta), wordSize+ptrSize)
+	for _, meta := range b.wordMeta {
+		ret += mapSize(len(meta), 2*stringSize)
+		for _, v := range meta {
+			ret += int64(len(v))
+		}
+	}
+	ret += mapSize(len(b.trained), stringSize+trainedSourceSize)
+	for name, ts := range b.trained {
+		ret += int64(len(name) + len(ts.Hash))
+	}
+	ret += mapSize(len(b.sourceIdxs), stringSize+ptrSize)
+	ret += mapSize(len(b.sourceTimes), stringSize+timeSize)
+	for _, name := range b.sources {
+		ret += int64(len(name)) + stringSize
+	}
+	ret += int64(cap(b.sourceHashes)) * hashSize
+
+	return ret
+}
+
+// mapSize estimates the memory used by a map with the given number of
+// entries, each of which occupies a slot of the given size.
+func mapSize(n int, slotSize int64) int64 {
+	if n == 0 {
+		return mapHeaderSize
+	}
+	// The runtime keeps maps no more than 7/8 full, and grows them by
+	// doubling, so on average a map has some way to go before it is full.
+	slots := int64(mapGroupSlots)
+	for slots*7/8 < int64(n) {
+		slots *= 2
+	}
+	// Each slot also has one byte of control information.
+	return mapHeaderSize + slots*(slotSize+1)
+}
+
+func chainIndexSize(idx map[Word]chainSet) int64 {
+	ret := mapSize(len(idx), wordSize+ptrSize)
+	for _, s := range idx {
+		ret += mapSize(len(s), chainSize)
+	}
+	return ret
+}
+
+func adjacencyIndexSize(adj map[Word]WordSet) int64 {
+	ret := mapSize(len(adj), wordSize+ptrSize)
+	for _, s := range adj {
+		ret += mapSize(len(s), wordSize)
+	}
+	return ret
+}
+
+func adjacencySize(adj map[chain]WordSet) int64 {
+	ret := mapSize(len(adj), chainSize+ptrSize)
+	for _, s := range adj {
+		ret += mapSize(len(s), wordSize)
+	}
+	return ret
+}
+
+func transitionCountsSize(tc transitionCounts) int64 {
+	ret := mapSize(len(tc), chainSize+ptrSize)
+	for _, m := range tc {
+		ret += mapSize(len(m), wordSize+intSize)
+	}
+	return ret
+}
+
+func lowOrderAdjacencySize(adj map[lowOrderContext]WordSet) int64 {
+	ret := mapSize(len(adj), lowOrderLen*wordSize+ptrSize)
+	for _, s := range adj {
+		ret += mapSize(len(s), wordSize)
+	}
+	return ret
+}
@@ -0,0 +1,63 @@
+package ghal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Merge teaches the brain everything that the given brain knows, including
+// everything from the brains it is layered over if it is an overlay, without
+// needing the sentences either brain learned them from. This allows, for
+// example, combining brains that learned separately in different channels.
+//
+// The chains, the ways they can start and end sentences, and the words that
+// can follow and precede them are combined, adding together how many times
+// each was learned, along with the provenance information, case counts,
+// taboo words, and training manifest. Where the brains disagree about
+// settings like terminators, greetings, and swaps, the receiver's settings
+// are kept, and the given brain's are used only where the receiver has none.
+// For an overlay brain, everything is merged into the overlay's own layer.
+//
+// The given brain isn't changed, but it is first copied in full, so merging
+// needs enough memory for an additional copy of it. Merge returns an error
+// if the brains have different orders.
+//
+// Merge panics if the receiver has been frozen using Freeze.
+func (b *Brain) Merge(other *Brain) error {
+	if other == b {
+		return errors.New("can't merge a brain with itself")
+	}
+	if other.Order() != b.Order() {
+		return fmt.Errorf("can't merge a brain of order %d into a brain of order %d", other.Order(), b.Order())
+	}
+
+	// Working from a copy means that we never hold the locks of both brains
+	// at once, which could otherwise deadlock with a concurrent merge in the
+	// opposite direction.
+	src := other.Flatten()
+
+	b.lock()
+	defer b.mut.Unlock()
+
+	for l := b; l != nil; l = l.base {
+		if l.terminators != nil {
+			src.terminators = nil
+		}
+		if l.greetings != nil {
+			src.greetings = nil
+		}
+		for from := range l.swaps {
+			delete(src.swaps, from)
+		}
+		for w, meta := range l.wordMeta {
+			for k := range meta {
+				delete(src.wordMeta[w], k)
+			}
+		}
+		for name := range l.trained {
+			delete(src.trained, name)
+		}
+	}
+	b.mergeLayer(src)
+	return nil
+}
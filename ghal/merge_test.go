@@ -0,0 +1,43 @@
+package ghal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMergeAddsCounts(t *testing.T) {
+	mat := testSentence("the", "cat", "sat", "on", "the", "mat")
+	rug := testSentence("the", "cat", "sat", "on", "the", "rug")
+	dog := testSentence("a", "dog", "ran", "home")
+
+	a := NewBrain()
+	a.AddSentencesFrom([]Sentence{mat, mat, rug}, "a")
+	b := NewBrain()
+	b.AddSentencesFrom([]Sentence{mat, dog}, "b")
+	b.AddSentenceWeighted(rug, 3)
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("failed to merge: %s", err)
+	}
+
+	want := NewBrain()
+	want.AddSentencesFrom([]Sentence{mat, mat, rug}, "a")
+	want.AddSentencesFrom([]Sentence{mat, dog}, "b")
+	want.AddSentenceWeighted(rug, 3)
+	if !bytes.Equal(saveBrain(t, a), saveBrain(t, want)) {
+		t.Errorf("merged brain differs from a brain that learned everything")
+	}
+
+	c, err := MakeChain(mat[1:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := a.InspectChain(c)
+	if len(info.AfterCounts) != 2 || info.AfterCounts[0] != 3 || info.AfterCounts[1] != 4 {
+		t.Errorf("wrong counts after %s: %v %v", c, info.WordsAfter, info.AfterCounts)
+	}
+
+	if err := a.Merge(a); err == nil {
+		t.Errorf("no error merging a brain with itself")
+	}
+}
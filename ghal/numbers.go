@@ -0,0 +1,79 @@
+package ghal
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NumberHandling selects what parsing does with numbers in the text, which
+// are rarely useful to learn verbatim since the same number is unlikely to
+// appear again in the same context.
+type NumberHandling int
+
+const (
+	// KeepNumbers keeps numbers as words of their own, like any other word.
+	KeepNumbers NumberHandling = iota
+
+	// CollapseNumbers replaces each number with NumberWord, so that a brain
+	// learns that a number appears in a particular context without learning
+	// every distinct number.
+	CollapseNumbers
+
+	// DropNumeric discards sentences where more than half of the words are
+	// numbers, such as tables of figures or lists of times, and keeps
+	// numbers in other sentences verbatim.
+	DropNumeric
+)
+
+// NumberWord is the word that replaces each number when parsing with
+// CollapseNumbers.
+var NumberWord = MakeWord("CD", "#")
+
+// isNumberText returns true if the given word text is a number, which is a
+// run of digits possibly punctuated as in a decimal, a time, a date, or a
+// percentage.
+func isNumberText(text string) bool {
+	digits := false
+	for _, r := range text {
+		switch {
+		case unicode.IsDigit(r):
+			digits = true
+		case strings.ContainsRune(".,:/-+%", r):
+		default:
+			return false
+		}
+	}
+	return digits
+}
+
+// handleNumbers applies the given number handling to the given sentence,
+// returning the sentence to keep or nil if it should be discarded. Like
+// fixupParsedSentence, it applies its changes in-place.
+func handleNumbers(s Sentence, h NumberHandling) Sentence {
+	switch h {
+	case CollapseNumbers:
+		for i, w := range s {
+			if isNumberText(w.Text) {
+				s[i] = NumberWord
+			}
+		}
+		return s
+	case DropNumeric:
+		var words, numbers int
+		for _, w := range s {
+			switch {
+			case isNumberText(w.Text):
+				numbers++
+				words++
+			case strings.IndexFunc(w.Text, isLetterOrDigit) >= 0:
+				words++
+			}
+		}
+		if numbers*2 > words {
+			return nil
+		}
+		return s
+	default:
+		return s
+	}
+}
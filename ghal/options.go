@@ -0,0 +1,210 @@
+package ghal
+
+import (
+	"math"
+	"math/rand"
+)
+
+// GenerationOptions customizes how a brain constructs sentences. The zero
+// value selects the default behavior, so callers need only set the fields
+// they wish to change.
+type GenerationOptions struct {
+	// MinNovelty is the minimum fraction of words in a reply that must not
+	// also appear in the input it is replying to, as measured by
+	// Sentence.Novelty. Candidates that are too similar to the input are
+	// regenerated, up to Attempts times per keyword.
+	//
+	// Zero disables this constraint.
+	MinNovelty float64
+
+	// Temperature controls how words are chosen while extending a sentence.
+	// At a temperature of 1, each candidate word is chosen with probability
+	// proportional to how frequently it appears in the brain. Lower
+	// temperatures favor frequent words even more strongly, producing
+	// coherent but repetitive sentences, and approaching zero always
+	// chooses the most frequent word, while higher temperatures approach
+	// uniform selection, producing more chaotic sentences. This is in
+	// addition to the weighting described for UniformTransitions.
+	//
+	// Zero selects the default behavior, which ignores the frequency of
+	// each word in the brain as a whole.
+	Temperature float64
+
+	// UniformTransitions disables weighting each candidate word while
+	// extending a sentence by the number of times the brain learned that
+	// word in that position, so that a word learned there once is as likely
+	// to be chosen as one learned there a thousand times. This was the
+	// behavior of earlier versions, and produces more varied but less
+	// natural sentences.
+	UniformTransitions bool
+
+	// LowOrderWeight is the weight, between zero and one, given to the
+	// brain's low-order model when choosing each word while extending a
+	// sentence. The low-order model predicts each word from only the two
+	// words beside it, rather than from a whole chain, so interpolating it
+	// with the chains lets sentences combine parts of what the brain has
+	// learned in more ways than the chains alone allow, at the expense of
+	// some coherence. Once a sentence strays onto a sequence of words that
+	// isn't one of the brain's chains, only the low-order model is used
+	// until it returns to one.
+	//
+	// Zero selects the default behavior, which is to use only the chains.
+	LowOrderWeight float64
+
+	// ContinueBackward and ContinueForward are the probabilities, between
+	// zero and one, that a sentence being extended backwards or forwards,
+	// respectively, continues past a point where it could start or end
+	// rather than starting or ending there. Higher values produce longer
+	// sentences, and the best values depend on the corpus: a brain that
+	// learned short chat messages may need higher values to say anything
+	// substantial, while one that learned long, rambling prose may need
+	// lower values to avoid run-on sentences.
+	//
+	// Zero selects the default probability of one half. A negative value
+	// stops extending at the first opportunity.
+	ContinueBackward float64
+	ContinueForward  float64
+
+	// ContinueHalfLife makes the probabilities ContinueBackward and
+	// ContinueForward decay as a sentence grows, halving them each time it
+	// grows by this many words, so that short sentences are likely to be
+	// extended but long ones are not.
+	//
+	// Zero means that the probabilities don't depend on the length of the
+	// sentence.
+	ContinueHalfLife int
+
+	// Constraints are additional rules that each reply must conform to,
+	// such as those returned by MustContainPOS and MustNotEndWithPOS.
+	// Candidates that fail any constraint are regenerated, up to Attempts
+	// times per keyword.
+	Constraints []SentenceConstraint
+
+	// Attempts is the number of times a candidate is generated for each
+	// keyword before giving up on that keyword, when the earlier candidates
+	// don't meet MinNovelty, Constraints, Style, MaxWords, or MaxChars, or
+	// can't be completed without breaking one of the Transitions or using a
+	// taboo word. More attempts make it more likely that a strictly
+	// constrained reply can be found, at the expense of taking longer to
+	// give up when it can't.
+	//
+	// Zero selects the default of five attempts.
+	Attempts int
+
+	// Transitions are rules about which words may appear next to each other
+	// in each reply, such as those returned by ForbidTagSequence and
+	// ForbidConsecutive. Unlike Constraints, they are enforced while each
+	// sentence is constructed, by never extending it with a word that would
+	// break one of the rules.
+	Transitions []TransitionConstraint
+
+	// Scorers are additional scoring functions, such as those returned by
+	// AlliterationScorer and RhymeScorer, whose points are added to the
+	// built-in relevance score of each candidate reply.
+	Scorers []ReplyScorer
+
+	// Style constrains the kind of sentence each reply must be, by its
+	// terminating punctuation. The default, AnyStyle, allows any kind of
+	// sentence.
+	Style ReplyStyle
+
+	// Keywords selects the keywords that candidate replies are constructed
+	// around. If nil, DefaultKeywords is used.
+	Keywords KeywordExtractor
+
+	// Generators are the sources of candidate replies, whose candidates all
+	// compete in the relevance scoring that chooses the reply. If nil, only
+	// MarkovGenerator is used, so callers adding other generators should
+	// usually include MarkovGenerator too.
+	Generators []ReplyGenerator
+
+	// topic is the topic of the recent exchanges in a conversation, as set
+	// by Conversation.MakeReply, which is passed on to the generators.
+	topic WordSet
+
+	// MaxWords is the maximum number of words, including punctuation, in
+	// each reply. Longer candidates are trimmed back to the nearest point
+	// where a sentence can end or to the nearest clause boundary, and are
+	// discarded only if there is no suitable place to trim them.
+	//
+	// Zero means that there is no maximum.
+	MaxWords int
+
+	// MaxChars is the maximum number of characters in each reply, as
+	// returned by Sentence.String, for replies that must fit within the
+	// message length limit of a particular platform. Longer candidates are
+	// trimmed in the same way as for MaxWords.
+	//
+	// Zero means that there is no maximum.
+	MaxChars int
+
+	// Hashtags is the number of hashtags that each reply should include,
+	// for bots posting to social networks. Hashtags the brain knows that
+	// are related to the reply's keywords are added to the end of the reply
+	// until it includes this many, or until there are no more related
+	// hashtags, without exceeding MaxWords or MaxChars.
+	//
+	// Zero means that no hashtags are added.
+	Hashtags int
+
+	// Rand is the source of randomness for the pseudorandom choices made
+	// while constructing replies. If nil, math/rand's global source is used.
+	//
+	// A brain with the same contents, given the same input and options and
+	// a source seeded the same way, always makes the same reply, which is
+	// useful for comparing brains or options. Making the choices this way
+	// is a little slower, because the candidates for each choice must be
+	// sorted. A rand.Rand isn't safe for concurrent use, so calls that may
+	// run concurrently must each have their own.
+	Rand *rand.Rand
+}
+
+// continueChance returns the probability of extending a sentence that has
+// the given number of words so far past a point where it could stop,
+// extending it forwards if forward is set and otherwise backwards.
+func (o GenerationOptions) continueChance(forward bool, words int) float64 {
+	p := o.ContinueBackward
+	if forward {
+		p = o.ContinueForward
+	}
+	switch {
+	case p == 0:
+		p = defaultContinueChance
+	case p < 0:
+		return 0
+	}
+	if o.ContinueHalfLife > 0 {
+		p *= math.Pow(0.5, float64(words)/float64(o.ContinueHalfLife))
+	}
+	return min(p, 1)
+}
+
+// shouldContinue randomly decides whether to extend a sentence that has the
+// given number of words so far past a point where it could stop, with the
+// probability returned by continueChance.
+func (o GenerationOptions) shouldContinue(forward bool, words int) bool {
+	return o.float64() < o.continueChance(forward, words)
+}
+
+// keywordExtractor returns the keyword extractor selected by the receiver,
+// which is DefaultKeywords if none is selected.
+func (o GenerationOptions) keywordExtractor() KeywordExtractor {
+	if o.Keywords == nil {
+		return DefaultKeywords
+	}
+	return o.Keywords
+}
+
+// attempts returns the number of times we'll try to generate a candidate
+// reply that meets all of the constraints in the options for each keyword
+// before giving up on that keyword.
+func (o GenerationOptions) attempts() int {
+	if o.Attempts <= 0 {
+		return defaultCandidateAttempts
+	}
+	return o.Attempts
+}
+
+// defaultCandidateAttempts is the number of attempts used when the options
+// don't specify one.
+const defaultCandidateAttempts = 5
@@ -0,0 +1,372 @@
+package ghal
+
+import (
+	"fmt"
+	"io"
+)
+
+// NewOverlay returns a new, empty brain layered over the given base brain.
+// Unlike Freeze, this makes the base itself permanently read-only if it isn't
+// already, so that a large base can be shared between many overlays without
+// being copied; any later attempt to teach the base directly will panic.
+//
+// An overlay brain knows everything its base knows, and can generate
+// sentences using chains from both, but it learns only into its own layer,
+// leaving the base unchanged. This allows a large pre-trained base brain to
+// be shared between many deployments, each learning separately in a small
+// overlay of its own.
+//
+// Save and SaveFile save only the overlay's own layer, which can be loaded
+// again over the same base using LoadOverlay, and MemoryEstimate counts only
+// the overlay's own layer. Use Flatten to combine the layers into a single
+// standalone brain.
+//
+// The overlay has the same order as its base.
+func NewOverlay(base *Brain) *Brain {
+	ret := newBrainSized(base.Order(), 0, 0)
+	ret.base = base.freeze()
+	return ret
+}
+
+// LoadOverlay is like LoadBrain but layers the loaded brain over the given
+// base brain, as with NewOverlay. It is intended for loading the layer saved
+// from an overlay brain, but any brain can be layered over any other brain
+// of the same order.
+func LoadOverlay(base *Brain, r io.Reader) (*Brain, error) {
+	ret, err := LoadBrain(r)
+	if err != nil {
+		return nil, err
+	}
+	if ret.order != base.Order() {
+		return nil, fmt.Errorf("brain of order %d can't be layered over a base of order %d", ret.order, base.Order())
+	}
+	ret.base = base.freeze()
+	return ret, nil
+}
+
+// Base returns the brain that the receiver is layered over, or nil if it is
+// not an overlay brain.
+func (b *Brain) Base() *Brain {
+	return b.base
+}
+
+// Flatten returns a new standalone brain that knows everything that the
+// receiver knows, including everything from the brains it is layered over
+// if it is an overlay brain.
+func (b *Brain) Flatten() *Brain {
+	ret := newBrainSized(b.Order(), 0, 0)
+	b.flattenInto(ret)
+	return ret
+}
+
+// flattenInto merges the receiver's own layer into the given brain after
+// first merging the layers beneath it, so that the upper layers take
+// precedence where layers disagree. The given brain must not be shared with
+// any other goroutine.
+func (b *Brain) flattenInto(into *Brain) {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	if b.base != nil {
+		b.base.flattenInto(into)
+	}
+	into.mergeLayer(b)
+}
+
+// mergeLayer adds everything in the given brain's own layer, ignoring any
+// base it is layered over, to the receiver. The caller must hold the write
+// lock on the receiver and at least a read lock on the given brain.
+func (b *Brain) mergeLayer(other *Brain) {
+	srcs := make([]int, len(other.sources))
+	for i, name := range other.sources {
+		srcs[i] = b.sourceIdx(name)
+	}
+	for name, t := range other.sourceTimes {
+		b.noteSourceTime(name, t)
+	}
+
+	for c := range other.chains {
+		c = b.strs.internChain(c)
+		b.addChain(c)
+		if other.startChains.Has(c) {
+			b.addStartChain(c, other.startCounts.count(c))
+		}
+		if other.endChains.Has(c) {
+			b.addEndChain(c, other.endCounts.count(c))
+		}
+		for w := range other.wordsAfter[c] {
+			addTransitions(b.wordsAfter, b.afterCounts, c, b.strs.internWord(w), other.afterCounts.count(c, w))
+		}
+		for w := range other.wordsBefore[c] {
+			addTransitions(b.wordsBefore, b.beforeCounts, c, b.strs.internWord(w), other.beforeCounts.count(c, w))
+		}
+		if n := other.discouraged[c]; n != 0 {
+			b.adjustDiscouraged(c, n)
+		}
+		for _, src := range other.chainSources[c] {
+			b.addChainSource(c, srcs[src])
+		}
+		b.lowOrder.addChain(c, other.startChains.Has(c), other.endChains.Has(c))
+	}
+
+	for w, after := range other.bigrams.after {
+		for a := range after {
+			b.bigrams.addPair(b.strs.internWord(w), b.strs.internWord(a))
+		}
+	}
+	for w := range other.bigrams.starts {
+		b.bigrams.starts.Add(b.strs.internWord(w))
+	}
+	for w := range other.bigrams.ends {
+		b.bigrams.ends.Add(b.strs.internWord(w))
+	}
+
+	for w, chains := range other.responseChains {
+		w = b.strs.internWord(w)
+		existing := b.responseChains[w]
+		for c := range chains {
+			existing = b.addResponseChain(w, existing, b.strs.internChain(c))
+		}
+		if existing != nil {
+			b.responseChains[w] = existing
+		}
+	}
+
+	if other.terminators != nil {
+		terms := *other.terminators
+		b.terminators = &terms
+	}
+	for w := range other.taboo {
+		b.taboo.Add(b.strs.internWord(w))
+	}
+	for from, to := range other.swaps {
+		b.swaps[b.strs.intern(from)] = b.strs.intern(to)
+	}
+	if other.greetings != nil {
+		b.greetings = make([]string, len(other.greetings))
+		for i, text := range other.greetings {
+			b.greetings[i] = b.strs.intern(text)
+		}
+	}
+	for text, count := range other.casing {
+		b.addCaseCount(text, count)
+	}
+	for w, meta := range other.wordMeta {
+		for k, v := range meta {
+			b.setWordMeta(b.strs.internWord(w), b.strs.intern(k), v)
+		}
+	}
+	for name, ts := range other.trained {
+		b.trained[name] = ts
+	}
+}
+
+// The following methods read the brain's chain model, consulting both the
+// brain's own layer and, for an overlay brain, the layers beneath it. The
+// caller must hold at least a read lock on the brain, but needs no lock on
+// the base since it is always frozen.
+//
+// The sets these methods return may belong to the brain, so callers must
+// not modify them.
+
+// hasChain returns true if the brain knows the given chain.
+func (b *Brain) hasChain(c chain) bool {
+	return b.chains.Has(c) || (b.base != nil && b.base.hasChain(c))
+}
+
+// chainCount returns the number of distinct chains the brain knows.
+func (b *Brain) chainCount() int {
+	if b.base == nil {
+		return len(b.chains)
+	}
+	ret := len(b.chains) + b.base.chainCount()
+	for c := range b.chains {
+		if b.base.hasChain(c) {
+			ret-- // counted twice
+		}
+	}
+	return ret
+}
+
+// chainsWithWord returns the chains containing the given word.
+func (b *Brain) chainsWithWord(w Word) chainSet {
+	if b.base == nil {
+		return b.wordChains[w]
+	}
+	return layeredChains(b.wordChains[w], b.base.chainsWithWord(w))
+}
+
+// chainsStartingWith and chainsEndingWith return the chains whose first and
+// last words, respectively, are the given word.
+func (b *Brain) chainsStartingWith(w Word) chainSet {
+	if b.base == nil {
+		return b.firstWordChains[w]
+	}
+	return layeredChains(b.firstWordChains[w], b.base.chainsStartingWith(w))
+}
+
+func (b *Brain) chainsEndingWith(w Word) chainSet {
+	if b.base == nil {
+		return b.lastWordChains[w]
+	}
+	return layeredChains(b.lastWordChains[w], b.base.chainsEndingWith(w))
+}
+
+// startChainsWith returns the start chains whose first word is the given
+// word, and endChainsWith the end chains whose last word is the given word.
+func (b *Brain) startChainsWith(w Word) chainSet {
+	if b.base == nil {
+		return b.startChainsByFirst[w]
+	}
+	return layeredChains(b.startChainsByFirst[w], b.base.startChainsWith(w))
+}
+
+func (b *Brain) endChainsWith(w Word) chainSet {
+	if b.base == nil {
+		return b.endChainsByLast[w]
+	}
+	return layeredChains(b.endChainsByLast[w], b.base.endChainsWith(w))
+}
+
+// isStartChain and isEndChain return true if the given chain can start or
+// end a sentence, respectively.
+func (b *Brain) isStartChain(c chain) bool {
+	return b.startChains.Has(c) || (b.base != nil && b.base.isStartChain(c))
+}
+
+func (b *Brain) isEndChain(c chain) bool {
+	return b.endChains.Has(c) || (b.base != nil && b.base.isEndChain(c))
+}
+
+// forEachStartChain calls the given function once for each start chain.
+func (b *Brain) forEachStartChain(fn func(chain)) {
+	for c := range b.startChains {
+		fn(c)
+	}
+	if b.base != nil {
+		b.base.forEachStartChain(func(c chain) {
+			if !b.startChains.Has(c) {
+				fn(c)
+			}
+		})
+	}
+}
+
+// wordsAfterChain and wordsBeforeChain return the words that can succeed
+// and precede the given chain, respectively.
+func (b *Brain) wordsAfterChain(c chain) WordSet {
+	if b.base == nil {
+		return b.wordsAfter[c]
+	}
+	return layeredWords(b.wordsAfter[c], b.base.wordsAfterChain(c))
+}
+
+func (b *Brain) wordsBeforeChain(c chain) WordSet {
+	if b.base == nil {
+		return b.wordsBefore[c]
+	}
+	return layeredWords(b.wordsBefore[c], b.base.wordsBeforeChain(c))
+}
+
+// wordCount returns the number of distinct words appearing in the brain's
+// chains.
+func (b *Brain) wordCount() int {
+	ret := 0
+	b.forEachWord(func(Word) { ret++ })
+	return ret
+}
+
+// forEachWord calls the given function once for each distinct word
+// appearing in the brain's chains.
+func (b *Brain) forEachWord(fn func(Word)) {
+	for w := range b.wordChains {
+		fn(w)
+	}
+	if b.base != nil {
+		b.base.forEachWord(func(w Word) {
+			if _, ok := b.wordChains[w]; !ok {
+				fn(w)
+			}
+		})
+	}
+}
+
+// wordFrequency returns the number of distinct chains containing the given
+// word, as for WordFrequency.
+func (b *Brain) wordFrequency(w Word) int {
+	if b.base == nil {
+		return len(b.wordChains[w])
+	}
+	ret := len(b.wordChains[w]) + b.base.wordFrequency(w)
+	for c := range b.wordChains[w] {
+		if b.base.hasChain(c) {
+			ret-- // counted twice
+		}
+	}
+	return ret
+}
+
+// responsesTo returns the chains used in response to the given word in
+// dialogues, and hasResponses returns true if there are any such chains for
+// any word.
+func (b *Brain) responsesTo(w Word) chainSet {
+	if b.base == nil {
+		return b.responseChains[w]
+	}
+	return layeredChains(b.responseChains[w], b.base.responsesTo(w))
+}
+
+func (b *Brain) hasResponses() bool {
+	return len(b.responseChains) > 0 || (b.base != nil && b.base.hasResponses())
+}
+
+// bigramsAfter and bigramsBefore return the words that have followed and
+// preceded the given word, respectively, in the brain's bigram model, and
+// bigramStarts and bigramEnds return true if the given word has started or
+// ended a sentence.
+func (b *Brain) bigramsAfter(w Word) WordSet {
+	if b.base == nil {
+		return b.bigrams.after[w]
+	}
+	return layeredWords(b.bigrams.after[w], b.base.bigramsAfter(w))
+}
+
+func (b *Brain) bigramsBefore(w Word) WordSet {
+	if b.base == nil {
+		return b.bigrams.before[w]
+	}
+	return layeredWords(b.bigrams.before[w], b.base.bigramsBefore(w))
+}
+
+func (b *Brain) bigramStarts(w Word) bool {
+	return b.bigrams.starts.Has(w) || (b.base != nil && b.base.bigramStarts(w))
+}
+
+func (b *Brain) bigramEnds(w Word) bool {
+	return b.bigrams.ends.Has(w) || (b.base != nil && b.base.bigramEnds(w))
+}
+
+// layeredChains returns the union of the given sets, avoiding a copy if
+// either is empty.
+func layeredChains(a, b chainSet) chainSet {
+	switch {
+	case len(b) == 0:
+		return a
+	case len(a) == 0:
+		return b
+	default:
+		return a.Union(b)
+	}
+}
+
+// layeredWords is like layeredChains but for sets of words.
+func layeredWords(a, b WordSet) WordSet {
+	switch {
+	case len(b) == 0:
+		return a
+	case len(a) == 0:
+		return b
+	default:
+		return a.Union(b)
+	}
+}
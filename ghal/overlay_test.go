@@ -0,0 +1,54 @@
+package ghal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOverlayRoundTrip(t *testing.T) {
+	base := NewBrain()
+	base.AddSentencesFrom([]Sentence{
+		testSentence("the", "cat", "sat", "on", "the", "mat"),
+		testSentence("a", "dog", "ran", "home"),
+	}, "corpus")
+	base.SetSwap("my", "your")
+	baseSrc := saveBrain(t, base)
+
+	overlay := NewOverlay(base)
+	overlay.AddSentencesFrom([]Sentence{
+		testSentence("the", "cat", "sat", "on", "the", "rug"),
+		testSentence("the", "cat", "sat", "on", "the", "mat"),
+		testSentence("hi"),
+	}, "chat")
+	overlay.SetSwap("i", "you")
+	src := saveBrain(t, overlay)
+
+	loaded, err := LoadOverlay(base, bytes.NewReader(src))
+	if err != nil {
+		t.Fatalf("failed to load overlay: %s", err)
+	}
+	if got := saveBrain(t, loaded); !bytes.Equal(got, src) {
+		t.Errorf("overlay saved differently after loading")
+	}
+	if got := saveBrain(t, base); !bytes.Equal(got, baseSrc) {
+		t.Errorf("base changed")
+	}
+
+	// Flattening the loaded overlay gives the same brain as one that
+	// learned everything itself.
+	want := NewBrain()
+	want.AddSentencesFrom([]Sentence{
+		testSentence("the", "cat", "sat", "on", "the", "mat"),
+		testSentence("a", "dog", "ran", "home"),
+	}, "corpus")
+	want.AddSentencesFrom([]Sentence{
+		testSentence("the", "cat", "sat", "on", "the", "rug"),
+		testSentence("the", "cat", "sat", "on", "the", "mat"),
+		testSentence("hi"),
+	}, "chat")
+	want.SetSwap("my", "your")
+	want.SetSwap("i", "you")
+	if got := saveBrain(t, loaded.Flatten()); !bytes.Equal(got, saveBrain(t, want)) {
+		t.Errorf("flattened overlay differs from a brain that learned everything")
+	}
+}
@@ -0,0 +1,101 @@
+package ghal
+
+// MakeReplyMulti is like MakeReply but constructs a short paragraph of
+// between one and max sentences, for deployments where a single sentence
+// would seem terse.
+//
+// The first sentence is the reply that MakeReply would return. Each
+// following sentence is constructed around the keywords of the sentence
+// before it that weren't already keywords of the input or of an earlier
+// sentence, so that the paragraph drifts along from one related subject to
+// the next. The paragraph ends early if there are no new keywords or if no
+// follow-up sentence can be constructed. Any hashtags selected by the
+// conversation's options are added only to the first sentence, and MaxWords
+// and MaxChars apply to each sentence separately.
+//
+// Returns nil if MakeReply would return no reply at all.
+func (c *Conversation) MakeReplyMulti(max int, ss ...Sentence) []Sentence {
+	primary := c.MakeReply(ss...)
+	if len(primary.Sentence) == 0 {
+		return nil
+	}
+	ret := []Sentence{primary.Sentence}
+
+	opts := c.Options
+	opts.Hashtags = 0
+	extractor := opts.keywordExtractor()
+	used := extractor.Keywords(c.Brain, ss)
+	hashes := []uint64{sentenceHash(primary.Sentence)}
+	for len(ret) < max {
+		prev := ret[len(ret)-1]
+		keywords := extractor.Keywords(c.Brain, []Sentence{prev}).Subtract(used)
+		if len(keywords) == 0 {
+			break
+		}
+		debugf("building follow-up with keywords: %s", keywords)
+		used = used.Union(keywords)
+
+		followOpts := opts
+		followOpts.Keywords = KeywordExtractorFunc(func(*Brain, []Sentence) WordSet {
+			return keywords
+		})
+		// We mustn't repeat a sentence that's already in the paragraph.
+		followOpts.Constraints = append(opts.Constraints[:len(opts.Constraints):len(opts.Constraints)], excludeReplies(hashes))
+		follow := c.makeReply(followOpts, []Sentence{prev})
+		if len(follow.Sentence) == 0 {
+			break
+		}
+		ret = append(ret, follow.Sentence)
+		hashes = append(hashes, sentenceHash(follow.Sentence))
+		c.NoteOutput(follow.Sentence)
+	}
+	return ret
+}
+
+// MakeParagraph constructs a loosely-coherent paragraph of up to the given
+// number of sentences, for generating novelty text rather than replying to
+// anything in particular.
+//
+// The first sentence contains the given keyword, and each following
+// sentence contains one of the nouns of the sentence before it that hasn't
+// already seeded an earlier sentence, chosen at random, so that each
+// sentence leads on to the next. The paragraph ends early if no new
+// sentence can be constructed from any of those nouns. No sentence is
+// repeated within a paragraph.
+//
+// Returns nil if the brain can't construct any sentence containing the
+// keyword.
+func (b *Brain) MakeParagraph(keyword Word, sentences int) []Sentence {
+	var ret []Sentence
+	used := make(WordSet)
+	seen := make(map[uint64]struct{})
+	seeds := []Word{keyword}
+	for len(ret) < sentences && len(seeds) > 0 {
+		var next Sentence
+	Seeds:
+		for _, w := range seeds {
+			for i := 0; i < defaultCandidateAttempts; i++ {
+				s := b.MakeSentenceWithKeyword(w)
+				if len(s) == 0 {
+					// If we can't make any sentence at all then retrying
+					// won't help.
+					break
+				}
+				if _, repeated := seen[sentenceHash(s)]; !repeated {
+					debugf("paragraph continues with keyword %s", w)
+					used.Add(w)
+					next = s
+					break Seeds
+				}
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		ret = append(ret, next)
+		seen[sentenceHash(next)] = struct{}{}
+		candidates := next.Nouns().Subtract(used)
+		seeds = candidates.ChooseRandom(len(candidates))
+	}
+	return ret
+}
@@ -0,0 +1,117 @@
+package ghal
+
+// parserSlabSize is the number of words a Parser allocates at once to hold
+// the sentences it returns.
+const parserSlabSize = 4096
+
+// Parser is a reusable text parser that behaves like ParseText but shares
+// memory between the sentences it returns, which greatly reduces the number
+// of allocations made when parsing a large amount of training material.
+//
+// A Parser interns the text and tags of the words it produces, so that each
+// distinct string is stored only once no matter how many times it appears,
+// and it allocates the words for many sentences at once from larger blocks.
+// The cost is that a Parser retains every distinct string it has seen, and
+// that a block remains in memory for as long as any of the sentences in it
+// are in use, so a Parser should be discarded once the text it is parsing
+// has been learned.
+//
+// A Parser also counts how often each word it sees is capitalized, which
+// is lost when the text is converted to lowercase for tagging. Use
+// TakeCaseCounts to teach those counts to a brain.
+//
+// A Parser is not safe for concurrent use.
+type Parser struct {
+	// Limits constrains how much text ParseText will process for each call.
+	// The zero value imposes no limits.
+	Limits ParseLimits
+
+	// SkipTagging disables part-of-speech tagging, which is by far the
+	// slowest part of parsing, so that all of the resulting words have an
+	// empty tag. Sentences parsed in this way are not suitable for teaching
+	// a brain, but can be used to quickly analyze text.
+	SkipTagging bool
+
+	// Segmentation selects how text is divided into words. The zero value
+	// is SegmentWords.
+	Segmentation Segmentation
+
+	// Numbers selects what to do with numbers in the text. The zero value
+	// is KeepNumbers.
+	Numbers NumberHandling
+
+	// Tagger, if set, assigns the part-of-speech tags in place of the tagger
+	// built in to this package. It is not used if SkipTagging is set.
+	Tagger Tagger
+
+	strs   stringTable
+	slab   []Word
+	casing CaseCounts
+}
+
+// NewParser returns a new Parser that has not yet seen any text.
+func NewParser() *Parser {
+	return &Parser{
+		strs: make(stringTable),
+	}
+}
+
+// ParseText is like the package-level function ParseText but uses the
+// receiver's shared memory for the sentences it returns.
+//
+// It is valid to call ParseText on a nil Parser, in which case it behaves
+// exactly like the package-level function.
+func (p *Parser) ParseText(text string) ([]Sentence, error) {
+	if p == nil {
+		return parseText(text, nil, ParseOptions{})
+	}
+	return parseText(text, p, ParseOptions{
+		Limits:       p.Limits,
+		Segmentation: p.Segmentation,
+		Numbers:      p.Numbers,
+		Tagger:       p.Tagger,
+	})
+}
+
+// Intern returns a copy of the given sentence whose words use the receiver's
+// shared memory, for sentences that were obtained without parsing, such as
+// by decoding them from JSON. It returns the given sentence verbatim if called
+// on a nil Parser.
+func (p *Parser) Intern(s Sentence) Sentence {
+	if p == nil {
+		return s
+	}
+	ret := p.makeSentence(len(s))
+	for i, w := range s {
+		ret[i] = p.strs.internWord(w)
+	}
+	return ret
+}
+
+// makeSentence returns a new sentence of the given length, allocated from
+// the receiver's current block if possible.
+func (p *Parser) makeSentence(n int) Sentence {
+	if p == nil || n > parserSlabSize/4 {
+		// Unusually long sentences get their own allocation so that we
+		// don't waste most of a block.
+		return make(Sentence, n)
+	}
+	if len(p.slab) < n {
+		p.slab = make([]Word, parserSlabSize)
+	}
+	// We limit the capacity of the result so that appending to it can't
+	// overwrite the sentence that follows it in the block.
+	ret := p.slab[:n:n]
+	p.slab = p.slab[n:]
+	return ret
+}
+
+// makeWord is like MakeWord but interns the resulting strings in the
+// receiver, if it is not nil.
+func (p *Parser) makeWord(tag, text string) Word {
+	w := MakeWord(tag, text)
+	if p == nil {
+		return w
+	}
+	return p.strs.internWord(w)
+}
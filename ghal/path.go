@@ -0,0 +1,86 @@
+package ghal
+
+// FindPath searches the brain's knowledge for a sequence of words that
+// begins with the word "from" and later reaches the word "to", and then
+// builds a complete sentence around that sequence.
+//
+// maxLen limits how far the search may go, as the maximum number of words
+// in the connecting sequence, not counting any words added before or after
+// it to complete the sentence. The search is breadth-first, so the result
+// uses the shortest connecting sequence the brain knows.
+//
+// Returns nil if the brain knows no way to connect the two words within
+// the given length.
+func (b *Brain) FindPath(from, to Word, maxLen int) Sentence {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	debugf("searching for a path from %s to %s", from, to)
+
+	// Each step is a chain we've reached along with the index of the step
+	// that led to it, so that we can retrace our path once we reach a
+	// chain containing the target word.
+	type step struct {
+		chain chain
+		prev  int
+	}
+	var steps []step
+	var frontier []int
+	visited := make(chainSet)
+
+	for c := range b.chainsWithWord(from) {
+		// If the target word appears after our starting word within the
+		// same chain then we don't need to search at all.
+		seenFrom := false
+		for _, w := range c.words() {
+			if w == from {
+				seenFrom = true
+			} else if seenFrom && w == to {
+				debugf("chain %s already connects the words", c)
+				return b.allowedOrNil(b.completeSentence(c.words(), false, false, GenerationOptions{}))
+			}
+		}
+		visited.Add(c)
+		steps = append(steps, step{chain: c, prev: -1})
+		frontier = append(frontier, len(steps)-1)
+	}
+
+	for length := b.order + 1; length <= maxLen && len(frontier) > 0; length++ {
+		var next []int
+		for _, si := range frontier {
+			c := steps[si].chain
+			for w := range b.wordsAfterChain(c) {
+				nc := c
+				nc.PushAfter(w)
+				if visited.Has(nc) {
+					continue
+				}
+				visited.Add(nc)
+				steps = append(steps, step{chain: nc, prev: si})
+				if w != to {
+					next = append(next, len(steps)-1)
+					continue
+				}
+
+				// We've found our target, so now we'll retrace our steps
+				// back to the start to find the connecting sequence.
+				words := make([]Word, length)
+				i := len(words) - 1
+				at := len(steps) - 1
+				for steps[at].prev >= 0 {
+					words[i] = steps[at].chain.last()
+					i--
+					at = steps[at].prev
+				}
+				copy(words[:b.order], steps[at].chain.words())
+				debugf("found connecting sequence %s", Sentence(words))
+				return b.allowedOrNil(b.completeSentence(words, false, false, GenerationOptions{}))
+			}
+		}
+		frontier = next
+	}
+
+	debugf("no path from %s to %s within %d words", from, to, maxLen)
+	return nil
+}
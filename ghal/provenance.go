@@ -0,0 +1,264 @@
+package ghal
+
+import (
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// noSource is a placeholder source index used when a sentence is being
+// learned without any provenance information.
+const noSource = -1
+
+// maxChainSources is the maximum number of distinct sources we'll record for
+// each chain. Common chains can appear in a great many sources, so we only
+// keep the few whose names have the smallest hashes to avoid the provenance
+// information dominating the brain's memory usage. Choosing them by hash,
+// rather than keeping the first few we saw, means the sources kept don't
+// depend on the order in which they were learned, without favoring sources
+// whose names sort first.
+const maxChainSources = 8
+
+// SourceSpan describes which training sources contributed to a particular
+// range of words in a sentence, as returned by Brain.Attribution.
+type SourceSpan struct {
+	// Start and End are the indices of the first word in the span and of the
+	// word after the last word in the span, respectively, so that
+	// s[span.Start:span.End] is the sequence of words the span describes.
+	Start, End int
+
+	// Sources are the names of the sources that contributed this sequence
+	// of words, as given to AddSentencesFrom. This is empty if the sequence
+	// was learned only from sentences without provenance information.
+	Sources []string
+}
+
+// AddSentencesFrom is like AddSentences but also records the given source
+// name as the origin of each of the chains in the given sentences, so that
+// the contributions of different sources can be retrieved later using
+// Attribution.
+//
+// The source name is an arbitrary string, but would typically be a filename
+// or URL for training data or a name like "chat" for sentences learned
+// from conversation.
+//
+// AddSentencesFrom panics if the brain has been frozen using Freeze.
+func (b *Brain) AddSentencesFrom(ss []Sentence, source string) {
+	b.lock()
+	defer b.mut.Unlock()
+
+	src := b.sourceIdx(source)
+	for _, s := range ss {
+		b.addSentence(s, src, 1)
+	}
+}
+
+// Utterance is a sentence along with optional information about where and
+// when it originated, for use with AddUtterances.
+type Utterance struct {
+	Sentence Sentence
+
+	// Source is the name of the source the sentence came from, in the same
+	// sense as the source argument to AddSentencesFrom, or an empty string if
+	// the sentence should be attributed to the default source.
+	Source string
+
+	// Time is when the sentence was originally written or said, or the zero
+	// time if that isn't known. The brain doesn't remember the time of each
+	// sentence, only the earliest time of each source, as described for
+	// AddUtterances.
+	Time time.Time
+
+	// Weight is the number of times the sentence counts as having been
+	// learned, as for AddSentenceWeighted. Zero or less means one, as for a
+	// sentence learned using AddSentence.
+	Weight int
+}
+
+// AddUtterances is like AddSentencesFrom but allows each sentence to have
+// its own source, using the given default source for any utterance that
+// doesn't specify one.
+//
+// The brain also remembers the earliest time given for each source, which
+// can be retrieved using SourceTime. The times of the individual sentences
+// are otherwise discarded, so to keep finer-grained times, give each group
+// of sentences that should share a time a source of its own.
+//
+// AddUtterances panics if the brain has been frozen using Freeze.
+func (b *Brain) AddUtterances(us []Utterance, defaultSource string) {
+	b.lock()
+	defer b.mut.Unlock()
+
+	for _, u := range us {
+		source := u.Source
+		if source == "" {
+			source = defaultSource
+		}
+		b.addSentence(u.Sentence, b.sourceIdx(source), max(u.Weight, 1))
+		if !u.Time.IsZero() {
+			b.noteSourceTime(source, u.Time)
+		}
+	}
+}
+
+// SourceTime returns the earliest time recorded for any sentence learned
+// from the given source using AddUtterances. The second return value is
+// false if no time was recorded for that source.
+func (b *Brain) SourceTime(source string) (time.Time, bool) {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	t, ok := b.sourceTimes[source]
+	if b.base != nil {
+		if bt, bok := b.base.SourceTime(source); bok && (!ok || bt.Before(t)) {
+			return bt, true
+		}
+	}
+	return t, ok
+}
+
+// Sources returns the names of all of the sources that the brain has recorded
+// provenance information for, in the order they were first seen. For a brain
+// loaded from a file, the sources the file recorded are listed first, sorted
+// by name.
+func (b *Brain) Sources() []string {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	if b.base == nil {
+		return append([]string(nil), b.sources...)
+	}
+	ret := b.base.Sources()
+	for _, source := range b.sources {
+		if !containsString(ret, source) {
+			ret = append(ret, source)
+		}
+	}
+	return ret
+}
+
+// Attribution returns a description of which sources contributed each of
+// the chains in the given sentence, which would typically be a sentence
+// previously generated by the same brain.
+//
+// The result has one span for each chain in the sentence, in order. Spans
+// overlap because consecutive chains share all but one of their words.
+// Chains the brain doesn't know at all are reported with no sources.
+func (b *Brain) Attribution(s Sentence) []SourceSpan {
+	if len(s) < b.order {
+		return nil
+	}
+
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	maxIdx := len(s) - (b.order - 1)
+	ret := make([]SourceSpan, maxIdx)
+	for i := range ret {
+		chn := makeChain(s[i : i+b.order])
+		span := SourceSpan{
+			Start: i,
+			End:   i + b.order,
+		}
+		span.Sources = b.chainSourceNames(chn)
+		ret[i] = span
+	}
+	return ret
+}
+
+// chainSourceNames returns the names of the sources that contributed the
+// given chain, including those recorded in the base of an overlay brain. The
+// caller must hold at least a read lock on the brain.
+func (b *Brain) chainSourceNames(c chain) []string {
+	var ret []string
+	if b.base != nil {
+		ret = b.base.chainSourceNames(c)
+	}
+	for _, src := range b.chainSources[c] {
+		if name := b.sources[src]; !containsString(ret, name) {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+func containsString(ss []string, s string) bool {
+	for _, candidate := range ss {
+		if candidate == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceIdx returns the index of the given source name in the brain's
+// table of sources, adding it if necessary. The caller must hold the write
+// lock on the brain.
+func (b *Brain) sourceIdx(source string) int {
+	if idx, exists := b.sourceIdxs[source]; exists {
+		return idx
+	}
+	idx := len(b.sources)
+	b.sources = append(b.sources, source)
+	b.sourceHashes = append(b.sourceHashes, sourceHash(source))
+	b.sourceIdxs[source] = idx
+	return idx
+}
+
+// noteSourceTime records the given time for the given source, unless an
+// earlier time has already been recorded. The caller must hold the write
+// lock on the brain.
+func (b *Brain) noteSourceTime(source string, t time.Time) {
+	if existing, ok := b.sourceTimes[source]; ok && !t.Before(existing) {
+		return
+	}
+	b.sourceTimes[source] = t
+}
+
+// addChainSource records that the source with the given index contributed
+// the given chain, unless the chain already has that source. A chain's
+// sources are kept ordered by sourceLess, so if the chain already has the
+// maximum number of sources then the new source replaces the last one, if
+// the new source sorts before it. The caller must hold the write lock on the
+// brain.
+func (b *Brain) addChainSource(c chain, src int) {
+	existing := b.chainSources[c]
+	for _, idx := range existing {
+		if idx == src {
+			return
+		}
+	}
+	if len(existing) == maxChainSources {
+		if !b.sourceLess(src, existing[len(existing)-1]) {
+			return
+		}
+		existing = existing[:len(existing)-1]
+	}
+	i := sort.Search(len(existing), func(i int) bool {
+		return b.sourceLess(src, existing[i])
+	})
+	existing = append(existing, 0)
+	copy(existing[i+1:], existing[i:])
+	existing[i] = src
+	b.chainSources[c] = existing
+}
+
+// sourceLess orders the sources with the given indices by the hashes of
+// their names, falling back on the names themselves for names whose hashes
+// collide. The caller must hold at least a read lock on the brain.
+func (b *Brain) sourceLess(i, j int) bool {
+	if b.sourceHashes[i] != b.sourceHashes[j] {
+		return b.sourceHashes[i] < b.sourceHashes[j]
+	}
+	return b.sources[i] < b.sources[j]
+}
+
+// sourceHash returns the hash of the given source name used by sourceLess.
+func sourceHash(source string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(source))
+	return h.Sum64()
+}
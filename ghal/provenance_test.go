@@ -0,0 +1,35 @@
+package ghal
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestAttributionKeepsSourcesBySmallestHash(t *testing.T) {
+	var sources []string
+	for i := 0; i < maxChainSources*3; i++ {
+		sources = append(sources, fmt.Sprintf("source%02d", i))
+	}
+	b := NewBrain()
+	for _, source := range sources {
+		b.AddSentencesFrom([]Sentence{testSentence("the", "cat", "sat")}, source)
+	}
+
+	want := append([]string(nil), sources...)
+	sort.Slice(want, func(i, j int) bool {
+		return sourceHash(want[i]) < sourceHash(want[j])
+	})
+	want = want[:maxChainSources]
+	sort.Strings(want)
+
+	spans := b.Attribution(testSentence("the", "cat", "sat"))
+	if len(spans) == 0 {
+		t.Fatalf("no spans")
+	}
+	got := append([]string(nil), spans[0].Sources...)
+	sort.Strings(got)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("wrong sources\ngot:  %q\nwant: %q", got, want)
+	}
+}
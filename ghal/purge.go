@@ -0,0 +1,232 @@
+package ghal
+
+import (
+	"regexp"
+)
+
+// PurgeResult describes what PurgeMatching removed from a brain.
+type PurgeResult struct {
+	// Words is the number of distinct words removed.
+	Words int
+
+	// Swaps is the number of swaps removed because the text of the word
+	// swapped or the text it was swapped for matched.
+	Swaps int
+
+	// Greetings is the number of greeting words removed.
+	Greetings int
+}
+
+// PurgeMatching permanently removes from the brain every word whose text
+// matches the given regular expression, along with every chain containing
+// any of those words, and returns what was removed. This is intended for
+// deleting personal information like names, email addresses, and URLs after
+// they have been learned. Use MatchingWords first to see which words would
+// be removed.
+//
+// Unlike TabooWord, this cannot be reversed, and so the brain also forgets
+// the case counts, metadata, and taboo status of the removed words, along
+// with any swap configured using SetSwap to or from a matching text and any
+// matching greeting configured using SetGreetings, since those are saved
+// with the brain too. The brain may be left unable to construct some
+// sentences that it could before, since chains that could only be continued
+// by way of a removed word can no longer be continued at all.
+//
+// For an overlay brain, this cannot remove anything from its base, so the
+// base must be purged separately for the words to be forgotten entirely.
+//
+// PurgeMatching panics if the brain has been frozen using Freeze.
+func (b *Brain) PurgeMatching(pattern *regexp.Regexp) PurgeResult {
+	b.lock()
+	defer b.mut.Unlock()
+
+	var ret PurgeResult
+	for from, to := range b.swaps {
+		if pattern.MatchString(from) || pattern.MatchString(to) {
+			delete(b.swaps, from)
+			ret.Swaps++
+		}
+	}
+	if b.greetings != nil {
+		greetings := b.greetings[:0]
+		for _, text := range b.greetings {
+			if pattern.MatchString(text) {
+				ret.Greetings++
+				continue
+			}
+			greetings = append(greetings, text)
+		}
+		b.greetings = greetings
+	}
+
+	purge := b.matchingWords(pattern)
+	for w := range b.taboo {
+		if pattern.MatchString(w.Text) {
+			delete(b.taboo, w)
+		}
+	}
+	for w := range b.wordMeta {
+		if pattern.MatchString(w.Text) {
+			delete(b.wordMeta, w)
+		}
+	}
+	for text := range b.casing {
+		if pattern.MatchString(text) {
+			delete(b.casing, text)
+		}
+	}
+	ret.Words = len(purge)
+	if len(purge) == 0 {
+		return ret
+	}
+
+	for w := range purge {
+		for c := range b.wordChains[w] {
+			b.removeChain(c)
+		}
+		delete(b.responseChains, w)
+		delete(b.responseMax, w)
+	}
+	for c, after := range b.wordsAfter {
+		b.wordsAfter[c] = removeWords(after, purge)
+		if len(b.wordsAfter[c]) == 0 {
+			delete(b.wordsAfter, c)
+		}
+	}
+	for c, before := range b.wordsBefore {
+		b.wordsBefore[c] = removeWords(before, purge)
+		if len(b.wordsBefore[c]) == 0 {
+			delete(b.wordsBefore, c)
+		}
+	}
+	b.afterCounts.removeWords(purge)
+	b.beforeCounts.removeWords(purge)
+	b.pruneResponseChains()
+	b.bigrams.removeWords(purge)
+
+	// Every transition in the low-order model appears within some chain,
+	// so it's simplest to rebuild it from the chains that remain.
+	b.lowOrder = newLowOrderModel()
+	for c := range b.chains {
+		b.lowOrder.addChain(c, b.startChains.Has(c), b.endChains.Has(c))
+	}
+
+	return ret
+}
+
+// MatchingWords returns, in sorted order, the words that PurgeMatching would
+// remove from the brain for the given regular expression. Unlike
+// SearchRegexp, for an overlay brain this includes only the words learned
+// by the overlay itself, since PurgeMatching can't remove any from its base.
+func (b *Brain) MatchingWords(pattern *regexp.Regexp) []Word {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	return b.matchingWords(pattern).Sorted()
+}
+
+// matchingWords is the main implementation of MatchingWords, which returns
+// the matching words as a set. The caller must hold at least a read lock on
+// the brain.
+func (b *Brain) matchingWords(pattern *regexp.Regexp) WordSet {
+	ret := make(WordSet)
+	addMatching := func(w Word) {
+		if pattern.MatchString(w.Text) {
+			ret.Add(w)
+		}
+	}
+	for w := range b.wordChains {
+		addMatching(w)
+	}
+	// Sentences shorter than a chain are learned only by the bigram model.
+	for w := range b.bigrams.after {
+		addMatching(w)
+	}
+	for w := range b.bigrams.before {
+		addMatching(w)
+	}
+	for w := range b.bigrams.starts {
+		addMatching(w)
+	}
+	for w := range b.bigrams.ends {
+		addMatching(w)
+	}
+	return ret
+}
+
+// removeChain removes the given chain from the brain's set of known chains
+// and from all of the indices of chains, undoing addChain, addStartChain,
+// and addEndChain. The caller must hold the write lock on the brain.
+func (b *Brain) removeChain(c chain) {
+	delete(b.chains, c)
+	for _, w := range c.words() {
+		removeFromChainIndex(b.wordChains, w, c)
+	}
+	removeFromChainIndex(b.firstWordChains, c[0], c)
+	removeFromChainIndex(b.lastWordChains, c.last(), c)
+	delete(b.startChains, c)
+	delete(b.startCounts, c)
+	removeFromChainIndex(b.startChainsByFirst, c[0], c)
+	delete(b.endChains, c)
+	delete(b.endCounts, c)
+	delete(b.discouraged, c)
+	removeFromChainIndex(b.endChainsByLast, c.last(), c)
+	delete(b.wordsAfter, c)
+	delete(b.wordsBefore, c)
+	delete(b.afterCounts, c)
+	delete(b.beforeCounts, c)
+	delete(b.chainSources, c)
+}
+
+// removeFromChainIndex removes the given chain from the set for the given
+// word in the given index, removing the set entirely if it becomes empty.
+func removeFromChainIndex(idx map[Word]chainSet, w Word, c chain) {
+	cs, ok := idx[w]
+	if !ok {
+		return
+	}
+	delete(cs, c)
+	if len(cs) == 0 {
+		delete(idx, w)
+	}
+}
+
+// removeWords removes the given words from the model, along with every pair
+// of words that includes one of them.
+func (m *bigramModel) removeWords(ws WordSet) {
+	for w := range ws {
+		delete(m.after, w)
+		delete(m.before, w)
+		delete(m.starts, w)
+		delete(m.ends, w)
+	}
+	for w, after := range m.after {
+		m.after[w] = removeWords(after, ws)
+		if len(m.after[w]) == 0 {
+			delete(m.after, w)
+		}
+	}
+	for w, before := range m.before {
+		m.before[w] = removeWords(before, ws)
+		if len(m.before[w]) == 0 {
+			delete(m.before, w)
+		}
+	}
+}
+
+// removeWords removes the given words from the given set, in-place, and
+// returns the set for convenience.
+func removeWords(s WordSet, ws WordSet) WordSet {
+	if len(s) < len(ws) {
+		for w := range s {
+			if ws.Has(w) {
+				delete(s, w)
+			}
+		}
+		return s
+	}
+	for w := range ws {
+		delete(s, w)
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package ghal
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// TestPurgeMatchingRemovesEveryTrace checks that nothing about a purged word
+// remains in the saved brain, while what was learned from the rest of the
+// sentences it appeared in, and their provenance, is kept.
+func TestPurgeMatchingRemovesEveryTrace(t *testing.T) {
+	alice := MakeWord("NN", "alice")
+	b := NewBrain()
+	b.AddSentencesFrom([]Sentence{
+		testSentence("the", "cat", "sat", "on", "the", "mat", "with", "alice"),
+		testSentence("alice"), // learned only by the bigram model
+	}, "chat")
+	b.AddDialogue([]DialogueTurn{
+		{Speaker: "x", Sentences: []Sentence{testSentence("hello", "alice")}},
+		{Speaker: "y", Sentences: []Sentence{testSentence("alice", "says", "hello", "back")}},
+	}, "log")
+	b.TabooWord(alice)
+	b.SetWordMeta(alice, "kind", "name")
+	b.AddCaseCounts(CaseCounts{"alice": {Seen: 1, Capitalized: 1}})
+	b.SetSwap("alice", "bob")
+	b.SetSwap("you", "alice")
+	b.SetSwap("my", "your")
+	b.SetGreetings([]string{"hello", "alice"})
+
+	result := b.PurgeMatching(regexp.MustCompile("^alice$"))
+	want := PurgeResult{Words: 1, Swaps: 2, Greetings: 1}
+	if result != want {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", result, want)
+	}
+
+	if src := saveBrain(t, b); bytes.Contains(src, []byte("alice")) {
+		t.Errorf("saved brain still mentions alice")
+	}
+	for c := range b.chainSources {
+		if (Chain{c}).Has(alice) {
+			t.Errorf("provenance still recorded for %s", Chain{c})
+		}
+	}
+
+	// The chains that don't contain the word are kept, along with their
+	// sources.
+	kept := Sentence{MakeWord("NN", "the"), MakeWord("NN", "cat"), MakeWord("NN", "sat"), MakeWord("NN", "on"), MakeWord("NN", "the")}
+	spans := b.Attribution(kept)
+	if len(spans) == 0 || !reflect.DeepEqual(spans[0].Sources, []string{"chat"}) {
+		t.Errorf("wrong attribution for %s: %#v", kept, spans)
+	}
+	if got, want := b.Swaps(), map[string]string{"my": "your"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong swaps\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if got, want := b.Greetings(), []string{"hello"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong greetings\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package ghal
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxQuoteCandidates is the greatest number of quotes that a QuoteDB offers
+// as candidates for each reply, so that a large database of quotes doesn't
+// crowd out the candidates of other generators.
+const maxQuoteCandidates = 3
+
+// QuoteDB is a collection of verbatim quotations, such as the contents of a
+// fortune file, which is also a ReplyGenerator offering the quotations that
+// contain the reply's keywords, unchanged, as candidate replies. Using it
+// alongside MarkovGenerator makes the bot occasionally reply with a real
+// quotation when one is more relevant than anything it can construct.
+//
+// Words are matched by their text alone, ignoring case and tags, since the
+// same word may be tagged differently in a quotation than in the input.
+//
+// A QuoteDB is safe for concurrent use.
+type QuoteDB struct {
+	mut    sync.RWMutex
+	quotes []Sentence
+
+	// byText maps the lowercase text of each content word to the indices
+	// of the quotes containing it.
+	byText map[string][]int
+}
+
+// NewQuoteDB returns a new QuoteDB containing the given quotes.
+func NewQuoteDB(quotes ...Sentence) *QuoteDB {
+	ret := &QuoteDB{
+		byText: make(map[string][]int),
+	}
+	for _, q := range quotes {
+		ret.Add(q)
+	}
+	return ret
+}
+
+// Add adds the given quote to the database. A quotation of more than one
+// sentence can be added by concatenating its sentences, since candidate
+// replies are not required to be a single sentence.
+func (q *QuoteDB) Add(quote Sentence) {
+	if len(quote) == 0 {
+		return
+	}
+
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	idx := len(q.quotes)
+	q.quotes = append(q.quotes, append(Sentence(nil), quote...))
+	seen := make(map[string]bool)
+	for _, w := range quote {
+		if !w.IsContentWord() {
+			continue
+		}
+		text := strings.ToLower(w.Text)
+		if seen[text] {
+			continue
+		}
+		seen[text] = true
+		q.byText[text] = append(q.byText[text], idx)
+	}
+}
+
+// Len returns the number of quotes in the database.
+func (q *QuoteDB) Len() int {
+	q.mut.RLock()
+	defer q.mut.RUnlock()
+	return len(q.quotes)
+}
+
+// Candidates implements ReplyGenerator by returning the quotes that contain
+// the most keywords from the request, preferring those that also contain
+// words from the topic, up to a small maximum. Quotes that are longer than
+// the MaxWords or MaxChars of the request's options are never returned.
+func (q *QuoteDB) Candidates(b *Brain, req ReplyRequest) []Sentence {
+	q.mut.RLock()
+	defer q.mut.RUnlock()
+
+	// Keywords count double, so that the topic only breaks ties between
+	// quotes matching the same number of keywords.
+	scores := make(map[int]int)
+	for w := range req.Keywords {
+		for _, idx := range q.byText[strings.ToLower(w.Text)] {
+			scores[idx] += 2
+		}
+	}
+	if len(scores) == 0 {
+		return nil
+	}
+	for w := range req.Topic {
+		for _, idx := range q.byText[strings.ToLower(w.Text)] {
+			if _, ok := scores[idx]; ok {
+				scores[idx]++
+			}
+		}
+	}
+
+	opts := req.Options
+	idxs := make([]int, 0, len(scores))
+	for idx := range scores {
+		quote := q.quotes[idx]
+		if opts.MaxWords > 0 && len(quote) > opts.MaxWords {
+			continue
+		}
+		if opts.MaxChars > 0 && quote.charLen() > opts.MaxChars {
+			continue
+		}
+		idxs = append(idxs, idx)
+	}
+
+	// We shuffle before sorting so that equally-good quotes take turns. The
+	// indices are sorted first so that the shuffle alone decides their
+	// order, rather than the order of iterating over the scores.
+	sort.Ints(idxs)
+	opts.shuffle(len(idxs), func(i, j int) {
+		idxs[i], idxs[j] = idxs[j], idxs[i]
+	})
+	sort.SliceStable(idxs, func(i, j int) bool {
+		return scores[idxs[i]] > scores[idxs[j]]
+	})
+	if len(idxs) > maxQuoteCandidates {
+		idxs = idxs[:maxQuoteCandidates]
+	}
+
+	ret := make([]Sentence, len(idxs))
+	for i, idx := range idxs {
+		// The caller may modify the candidate, so we return a copy.
+		ret[i] = append(Sentence(nil), q.quotes[idx]...)
+	}
+	debugf("quote candidates for %s are %q", req.Keywords, ret)
+	return ret
+}
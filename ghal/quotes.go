@@ -0,0 +1,75 @@
+package ghal
+
+// quoteKinds maps the text of each quotation mark to a representative of
+// the kind of quote it is, so that a closing quote can be matched with an
+// opening quote of the same kind regardless of whether they are straight
+// or curly.
+var quoteKinds = map[string]string{
+	`"`: `"`, "“": `"`, "”": `"`,
+	"'": "'", "‘": "'", "’": "'",
+	"«": "«", "»": "«",
+	"‹": "‹", "›": "‹",
+}
+
+func quoteKind(text string) string {
+	if kind, ok := quoteKinds[text]; ok {
+		return kind
+	}
+	return text
+}
+
+// balanceQuotes removes from the given sentence any opening quote that is
+// never closed and any closing quote that was never opened, as can happen
+// when a sentence is stitched together from chains learned from different
+// sentences. It modifies the given sentence in-place, but returns the
+// resulting sentence anyway since it may be shorter.
+//
+// Each closing quote is matched with the most recent unclosed opening quote
+// of the same kind, so that a stray quote of one kind doesn't prevent
+// quotes of another kind from being matched.
+func balanceQuotes(s Sentence) Sentence {
+	type openQuote struct {
+		idx  int
+		kind string
+	}
+	var open []openQuote
+	var unbalanced map[int]bool
+	markUnbalanced := func(idx int) {
+		if unbalanced == nil {
+			unbalanced = make(map[int]bool)
+		}
+		unbalanced[idx] = true
+	}
+
+Words:
+	for i, w := range s {
+		switch w.Tag {
+		case "``":
+			open = append(open, openQuote{i, quoteKind(w.Text)})
+		case "''":
+			kind := quoteKind(w.Text)
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j].kind == kind {
+					open = append(open[:j], open[j+1:]...)
+					continue Words
+				}
+			}
+			markUnbalanced(i)
+		}
+	}
+	for _, q := range open {
+		markUnbalanced(q.idx)
+	}
+	if len(unbalanced) == 0 {
+		return s
+	}
+
+	debugf("removing %d unbalanced quotes from %q", len(unbalanced), s)
+	ret := s[:0]
+	for i, w := range s {
+		if !unbalanced[i] {
+			ret = append(ret, w)
+		}
+	}
+	return ret
+}
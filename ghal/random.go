@@ -0,0 +1,74 @@
+package ghal
+
+import (
+	"math/rand"
+)
+
+// The functions in this file make the pseudorandom choices involved in
+// constructing sentences, using the source of randomness given in
+// GenerationOptions.Rand if there is one, or math/rand's global source
+// otherwise.
+//
+// With a given source, the choices also avoid depending on the order of
+// iterating over maps, which the Go runtime randomizes independently of any
+// source, so that the same source seeded the same way always produces the
+// same sentences from the same brain.
+
+// float64 returns a pseudorandom number in [0.0,1.0).
+func (o GenerationOptions) float64() float64 {
+	if o.Rand != nil {
+		return o.Rand.Float64()
+	}
+	return rand.Float64()
+}
+
+// intn returns a pseudorandom number in [0,n), where n must be positive.
+func (o GenerationOptions) intn(n int) int {
+	if o.Rand != nil {
+		return o.Rand.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
+// shuffle pseudorandomly shuffles n elements using the given swap function,
+// as for rand.Shuffle.
+func (o GenerationOptions) shuffle(n int, swap func(i, j int)) {
+	if o.Rand != nil {
+		o.Rand.Shuffle(n, swap)
+		return
+	}
+	rand.Shuffle(n, swap)
+}
+
+// orderedWords returns the words in the given set in the order in which
+// pseudorandom choices should consider them: sorted if the receiver has a
+// source of randomness, or in the set's own order otherwise.
+func (o GenerationOptions) orderedWords(ws WordSet) []Word {
+	if o.Rand != nil {
+		return ws.Sorted()
+	}
+	ret := make([]Word, 0, len(ws))
+	for w := range ws {
+		ret = append(ret, w)
+	}
+	return ret
+}
+
+// chooseWord selects one word pseudorandomly from the given set, which must
+// not be empty, with each word equally likely.
+func (o GenerationOptions) chooseWord(ws WordSet) Word {
+	if o.Rand == nil {
+		return ws.ChooseOneRandom()
+	}
+	words := ws.Sorted()
+	return words[o.Rand.Intn(len(words))]
+}
+
+// chooseChain is like chooseWord but for a set of chains.
+func (o GenerationOptions) chooseChain(cs chainSet) chain {
+	if o.Rand == nil {
+		return cs.ChooseOneRandom()
+	}
+	chains := sortedChains(cs)
+	return chains[o.Rand.Intn(len(chains))]
+}
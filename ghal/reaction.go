@@ -0,0 +1,80 @@
+package ghal
+
+import (
+	"strings"
+)
+
+// positiveReactions and negativeReactions are the reactions that
+// ReactionFeedback recognizes, including the names that chat services like
+// Slack and Discord give to some of them.
+var (
+	positiveReactions = map[string]bool{
+		"👍": true, "❤": true, "😂": true, "🤣": true, "😄": true, "😀": true,
+		"😆": true, "😍": true, "🥰": true, "🎉": true, "💯": true, "⭐": true,
+		"🌟": true, "🔥": true, "👏": true, "🙌": true, "✅": true, "💖": true,
+		":)": true, ":-)": true, ":D": true, ":-D": true, "<3": true, "xD": true,
+		"XD": true, "+1": true, "thumbsup": true, "heart": true, "joy": true,
+		"tada": true, "100": true, "star": true, "fire": true, "clap": true,
+		"white_check_mark": true,
+	}
+	negativeReactions = map[string]bool{
+		"👎": true, "😠": true, "😡": true, "🤬": true, "🤮": true, "🤢": true,
+		"💩": true, "🙄": true, "😒": true, "😬": true, "❌": true, "🚫": true,
+		":(": true, ":-(": true, "</3": true, "-1": true, "thumbsdown": true,
+		"angry": true, "rage": true, "face_vomiting": true, "nauseated_face": true,
+		"poop": true, "roll_eyes": true, "x": true, "no_entry_sign": true,
+	}
+)
+
+// ReactionFeedback classifies the given reaction, such as an emoji reaction
+// to one of a bot's messages on a chat service, as positive feedback,
+// returning 1, as negative feedback, returning -1, or as neither, returning
+// zero. Skin tone modifiers and variation selectors are ignored, so that
+// "👍🏽" is positive just like "👍", and reactions given by name, like
+// ":thumbsup:" or "+1", are recognized for some common emoji.
+func ReactionFeedback(reaction string) int {
+	reaction = strings.TrimSpace(reaction)
+	if len(reaction) > 2 && strings.HasPrefix(reaction, ":") && strings.HasSuffix(reaction, ":") {
+		reaction = reaction[1 : len(reaction)-1]
+	}
+	if !emoticons[reaction] {
+		// Emoticons are case sensitive, but names are not.
+		reaction = strings.ToLower(reaction)
+	}
+	reaction = strings.Map(func(r rune) rune {
+		if r == '\uFE0F' || (r >= '\U0001F3FB' && r <= '\U0001F3FF') {
+			return -1
+		}
+		return r
+	}, reaction)
+	switch {
+	case positiveReactions[reaction]:
+		return 1
+	case negativeReactions[reaction]:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// React treats the given reaction to the given sentence, which the brain
+// constructed, as feedback on the sentence, reinforcing it as with
+// Reinforce for positive reactions or discouraging it as with Discourage
+// for negative ones, as classified by ReactionFeedback. This allows a bot
+// to learn from how people react to its messages.
+//
+// Returns false if the reaction is neither positive nor negative or if the
+// brain knows none of the sentence's chains, in which case nothing is
+// changed.
+//
+// React panics if the brain has been frozen using Freeze.
+func (b *Brain) React(s Sentence, reaction string) bool {
+	switch ReactionFeedback(reaction) {
+	case 1:
+		return b.Reinforce(s)
+	case -1:
+		return b.Discourage(s)
+	default:
+		return false
+	}
+}
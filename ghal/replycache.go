@@ -0,0 +1,97 @@
+package ghal
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+)
+
+// replyCacheReplies is the number of recent replies the reply cache
+// remembers for each input.
+const replyCacheReplies = 3
+
+// replyCache is a least-recently-used cache of the recent replies to each
+// distinct set of input keywords, used by Conversation to avoid giving the
+// same reply to the same prompt repeatedly.
+type replyCache struct {
+	// order is the cache's entries, most recently used first, as
+	// *replyCacheEntry values.
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type replyCacheEntry struct {
+	key string
+
+	// replies are hashes of the recent replies, from oldest to newest, as
+	// returned by sentenceHash.
+	replies []uint64
+}
+
+// replyCacheKey returns the key under which replies to input with the given
+// keywords are recorded. Only the text of the keywords is used, so that
+// inputs that differ in ways that don't affect the keywords, like in their
+// punctuation or word order, are treated as the same.
+func replyCacheKey(keywords WordSet) string {
+	texts := make([]string, 0, len(keywords))
+	for w := range keywords {
+		texts = append(texts, w.Text)
+	}
+	sort.Strings(texts)
+	return strings.Join(texts, "\x00")
+}
+
+// recent returns the hashes of the recent replies recorded under the given
+// key, from oldest to newest, marking the entry as recently used.
+func (c *replyCache) recent(key string) []uint64 {
+	if c == nil || c.entries == nil {
+		return nil
+	}
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*replyCacheEntry).replies
+}
+
+// add records the given reply under the given key, evicting the least
+// recently used entry if the cache then has more than size entries.
+func (c *replyCache) add(key string, reply Sentence, size int) {
+	if c.entries == nil {
+		c.order = list.New()
+		c.entries = make(map[string]*list.Element)
+	}
+	elem, ok := c.entries[key]
+	if !ok {
+		elem = c.order.PushFront(&replyCacheEntry{key: key})
+		c.entries[key] = elem
+	}
+	c.order.MoveToFront(elem)
+	entry := elem.Value.(*replyCacheEntry)
+	entry.replies = append(entry.replies, sentenceHash(reply))
+	if excess := len(entry.replies) - replyCacheReplies; excess > 0 {
+		entry.replies = entry.replies[excess:]
+	}
+
+	for c.order.Len() > size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*replyCacheEntry).key)
+	}
+}
+
+// excludeReplies returns a constraint that rejects any sentence whose hash is
+// one of the given hashes.
+func excludeReplies(hashes []uint64) SentenceConstraint {
+	return func(s Sentence) bool {
+		h := sentenceHash(s)
+		for _, excluded := range hashes {
+			if h == excluded {
+				debugf("sentence %q was a recent reply to the same input", s)
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,132 @@
+package ghal
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// PronouncingDict is a table of word pronunciations used to detect rhymes.
+//
+// The zero value of PronouncingDict, and also a nil *PronouncingDict, is a
+// valid empty dictionary that detects rhymes using spelling alone.
+type PronouncingDict struct {
+	// rhymes maps each known word to the part of its pronunciation that
+	// must match for another word to rhyme with it: the phonemes from the
+	// last stressed vowel onwards.
+	rhymes map[string]string
+}
+
+// LoadPronouncingDict reads a pronouncing dictionary in the format of the
+// CMU Pronouncing Dictionary, where each line contains a word followed by
+// its phonemes in ARPAbet notation with stress markers on the vowels:
+//
+//	RHYME  R AY1 M
+//
+// Lines starting with ";;;" are comments. Alternative pronunciations, which
+// the CMU dictionary marks with a parenthesized number after the word, are
+// ignored after the first.
+//
+// This package doesn't include a dictionary, so callers must obtain one
+// separately, such as by downloading the CMU Pronouncing Dictionary itself.
+func LoadPronouncingDict(r io.Reader) (*PronouncingDict, error) {
+	ret := &PronouncingDict{
+		rhymes: make(map[string]string),
+	}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, ";;;") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		word := strings.ToLower(fields[0])
+		if strings.HasSuffix(word, ")") {
+			continue // alternative pronunciation
+		}
+		if _, exists := ret.rhymes[word]; exists {
+			continue
+		}
+		ret.rhymes[word] = rhymingPhonemes(fields[1:])
+	}
+	return ret, sc.Err()
+}
+
+// Rhymes returns true if the two given words rhyme. Identical words are not
+// considered to rhyme.
+func (d *PronouncingDict) Rhymes(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return false
+	}
+	if d != nil {
+		ra, aOk := d.rhymes[a]
+		rb, bOk := d.rhymes[b]
+		if aOk && bOk {
+			return ra != "" && ra == rb
+		}
+	}
+	ra, rb := rhymingSpelling(a), rhymingSpelling(b)
+	return ra != "" && ra == rb
+}
+
+// rhymingPhonemes returns the phonemes from the last stressed vowel onwards
+// in the given ARPAbet pronunciation, joined by spaces. If no vowel has
+// primary or secondary stress then the last vowel is used.
+func rhymingPhonemes(phonemes []string) string {
+	start := -1
+	lastVowel := -1
+	for i, p := range phonemes {
+		if p == "" {
+			continue
+		}
+		switch p[len(p)-1] {
+		case '1', '2':
+			start = i
+			lastVowel = i
+		case '0':
+			lastVowel = i
+		}
+	}
+	if start < 0 {
+		start = lastVowel
+	}
+	if start < 0 {
+		return ""
+	}
+	return strings.Join(phonemes[start:], " ")
+}
+
+// rhymingSpelling approximates the rhyming part of a word from its spelling
+// alone, returning the letters from its last group of vowels onwards. A
+// trailing silent "e" is skipped over when finding that group, so that
+// "house" and "mouse" both produce "ouse".
+func rhymingSpelling(word string) string {
+	end := len(word)
+	if end > 2 && word[end-1] == 'e' && !isSpellingVowel(word[end-2]) {
+		end--
+	}
+	i := end - 1
+	for i >= 0 && !isSpellingVowel(word[i]) {
+		i--
+	}
+	if i < 0 {
+		return ""
+	}
+	for i > 0 && isSpellingVowel(word[i-1]) {
+		i--
+	}
+	return word[i:]
+}
+
+func isSpellingVowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	default:
+		return false
+	}
+}
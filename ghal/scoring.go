@@ -0,0 +1,78 @@
+package ghal
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// ReplyScorer is a function that assigns additional relevance points to a
+// candidate reply, given the sentences it is replying to.
+//
+// Scorers are given in GenerationOptions, and the points each returns are
+// added to the built-in relevance score of the candidate when choosing
+// between candidates.
+type ReplyScorer func(candidate Sentence, input []Sentence) int
+
+// AlliterationScorer returns a scorer that awards the given bonus for each
+// pair of consecutive words in the candidate that begin with the same letter,
+// ignoring punctuation and case.
+func AlliterationScorer(bonus int) ReplyScorer {
+	return func(candidate Sentence, input []Sentence) int {
+		score := 0
+		var prev rune
+		for _, w := range candidate {
+			if w.IsPunctuation() {
+				continue
+			}
+			first, _ := utf8.DecodeRuneInString(w.Text)
+			first = unicode.ToLower(first)
+			if !unicode.IsLetter(first) {
+				prev = 0
+				continue
+			}
+			if first == prev {
+				score += bonus
+			}
+			prev = first
+		}
+		return score
+	}
+}
+
+// RhymeScorer returns a scorer that awards the given bonus if the final word
+// of the candidate rhymes with the final word of the last input sentence,
+// ignoring punctuation.
+//
+// If dict is non-nil then rhymes are detected using the pronunciations it
+// contains. Otherwise, or for words not in the dictionary, rhymes are
+// detected by comparing spelling, which is much less accurate.
+func RhymeScorer(bonus int, dict *PronouncingDict) ReplyScorer {
+	return func(candidate Sentence, input []Sentence) int {
+		if len(input) == 0 {
+			return 0
+		}
+		a, ok := lastNonPunctuation(candidate)
+		if !ok {
+			return 0
+		}
+		b, ok := lastNonPunctuation(input[len(input)-1])
+		if !ok {
+			return 0
+		}
+		if dict.Rhymes(a.Text, b.Text) {
+			return bonus
+		}
+		return 0
+	}
+}
+
+// lastNonPunctuation returns the last word in the given sentence that isn't
+// punctuation, or false if there is no such word.
+func lastNonPunctuation(s Sentence) (Word, bool) {
+	for i := len(s) - 1; i >= 0; i-- {
+		if !s[i].IsPunctuation() {
+			return s[i], true
+		}
+	}
+	return Word{}, false
+}
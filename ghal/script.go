@@ -0,0 +1,228 @@
+package ghal
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	prose "gopkg.in/jdkato/prose.v2"
+)
+
+// The prose tokenizer measures the length of the text it is tokenizing in
+// characters but then walks through it in bytes, so it stops early and
+// silently discards the end of any text containing characters that take
+// more than one byte in UTF-8. That scrambles text in scripts like Arabic
+// and Hebrew beyond recognition, and loses the ends of sentences containing
+// even a few accented letters.
+//
+// To work around that, proseTokens replaces each word containing such
+// characters with an ASCII placeholder word before tokenizing, and each
+// other such character with a placeholder word of its own, and then puts
+// them back in the resulting tokens.
+
+// textPlaceholderPrefix and textPlaceholderSuffix surround the index of the
+// original text in the placeholder words that replace non-ASCII text before
+// tokenizing, much like the placeholders for emoji.
+const (
+	textPlaceholderPrefix = "qqtext"
+	textPlaceholderSuffix = "qq"
+)
+
+var textPlaceholderRe = regexp.MustCompile(textPlaceholderPrefix + `([0-9]+)` + textPlaceholderSuffix)
+
+// proseModel returns the prose library's tagging model, loading it the
+// first time it is needed. Each prose document otherwise loads the model
+// again, which takes far longer than tagging a typical sentence. The model
+// is only read while tagging, so it is safe to share between goroutines.
+var proseModel = sync.OnceValue(func() *prose.Model {
+	doc, err := prose.NewDocument("", prose.WithSegmentation(false), prose.WithExtraction(false))
+	if err != nil {
+		// Should never happen, because there is nothing to parse.
+		panic(err)
+	}
+	return doc.Model
+})
+
+// proseTokens tokenizes the given text using the prose library with the
+// given options, working around its mishandling of non-ASCII text and of
+// apostrophes. The text isn't divided into sentences, which saves prose
+// loading its sentence segmentation model each time.
+func proseTokens(text string, docOpts []prose.DocOpt) ([]prose.Token, error) {
+	text, apostrophes := separateApostrophes(text)
+	text, protected := protectText(text)
+	docOpts = append(docOpts[:len(docOpts):len(docOpts)], prose.WithSegmentation(false))
+	doc, err := prose.NewDocument(text, docOpts...)
+	if err != nil {
+		return nil, err
+	}
+	toks := doc.Tokens()
+	if len(protected) > 0 {
+		for i, tok := range toks {
+			toks[i] = restoreText(tok, protected)
+		}
+	}
+	if len(apostrophes) > 0 {
+		tagApostrophes(toks, apostrophes)
+	}
+	return toks, nil
+}
+
+// protectText replaces each word in the given text that contains any
+// non-ASCII characters with a placeholder word, and each other non-ASCII
+// character with a standalone placeholder word, returning the new text and
+// the replaced text, in order, so that the placeholders can be replaced
+// again after tokenizing using restoreText.
+//
+// Curly quotes are left as-is, because the prose tokenizer replaces them
+// with their ASCII equivalents before it looks at the length of the text.
+func protectText(text string) (string, []string) {
+	if isASCII(text) {
+		return text, nil
+	}
+
+	var ret strings.Builder
+	var protected []string
+	addPlaceholder := func(s string) {
+		ret.WriteString(textPlaceholderPrefix)
+		ret.WriteString(strconv.Itoa(len(protected)))
+		ret.WriteString(textPlaceholderSuffix)
+		protected = append(protected, s)
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case isWordRune(r):
+			end := i + size
+			for end < len(text) {
+				r, size := utf8.DecodeRuneInString(text[end:])
+				if !isWordRune(r) {
+					break
+				}
+				end += size
+			}
+			word := text[i:end]
+			switch {
+			case isASCII(word):
+				ret.WriteString(word)
+			case strings.IndexFunc(word, isLetterOrDigit) < 0:
+				// Directional marks and combining marks that aren't
+				// attached to any letter can't be kept with any word, so
+				// we discard them.
+			default:
+				addPlaceholder(word)
+			}
+			i = end
+			continue
+		case r < utf8.RuneSelf, r == '“', r == '”', r == '‘', r == '’':
+			ret.WriteString(text[i : i+size])
+		case unicode.IsSpace(r):
+			ret.WriteByte(' ')
+		default:
+			ret.WriteByte(' ')
+			addPlaceholder(text[i : i+size])
+			ret.WriteByte(' ')
+		}
+		i += size
+	}
+	return ret.String(), protected
+}
+
+// restoreText returns the given token with any placeholders produced by
+// protectText replaced with the text they stand for. A token that is a
+// placeholder for a single punctuation mark or symbol is tagged according to
+// what kind of punctuation it is, since the tagger can't tell.
+func restoreText(tok prose.Token, protected []string) prose.Token {
+	if !strings.Contains(tok.Text, textPlaceholderPrefix) {
+		return tok
+	}
+	tok.Text = textPlaceholderRe.ReplaceAllStringFunc(tok.Text, func(p string) string {
+		idx, err := strconv.Atoi(p[len(textPlaceholderPrefix) : len(p)-len(textPlaceholderSuffix)])
+		if err != nil || idx >= len(protected) {
+			return p
+		}
+		return protected[idx]
+	})
+	if r, size := utf8.DecodeRuneInString(tok.Text); size == len(tok.Text) && !isWordRune(r) && tok.Tag != "" {
+		tok.Tag = punctTag(r)
+	}
+	return tok
+}
+
+// isWordRune returns true if the given character can be part of a word,
+// including the combining marks and directional marks that belong with the
+// letters around them.
+func isWordRune(r rune) bool {
+	return isLetterOrDigit(r) || unicode.IsMark(r) || unicode.Is(unicode.Cf, r)
+}
+
+func isLetterOrDigit(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// punctTag returns the tag for the given non-ASCII punctuation mark or
+// symbol, which is the tag of the ASCII punctuation that plays the same role
+// in English text.
+func punctTag(r rune) string {
+	switch r {
+	case '。', '｡', '？', '！', '…', '؟', '۔':
+		return "."
+	case '、', '，', '､', '،':
+		return ","
+	}
+	switch {
+	case unicode.Is(unicode.Ps, r):
+		return "("
+	case unicode.Is(unicode.Pe, r):
+		return ")"
+	case unicode.Is(unicode.Pi, r):
+		return "``"
+	case unicode.Is(unicode.Pf, r):
+		return "''"
+	case unicode.Is(unicode.Sc, r):
+		return "$"
+	case unicode.IsSymbol(r):
+		return "SYM"
+	default:
+		return ":"
+	}
+}
+
+// isBidiControl returns true if the given character is one of the explicit
+// directional embeddings, overrides, or isolates, as opposed to the
+// directional marks that affect only the characters around them.
+//
+// The parser removes these because each one must be closed by a later
+// character, and once the words of a sentence are recombined into new
+// sentences the two halves of each pair are unlikely to stay together, which
+// would leave the direction of the rest of any text displayed after the
+// sentence scrambled.
+func isBidiControl(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069')
+}
+
+// removeBidiControls returns the given text with any characters for which
+// isBidiControl returns true removed.
+func removeBidiControls(text string) string {
+	if strings.IndexFunc(text, isBidiControl) < 0 {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if isBidiControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
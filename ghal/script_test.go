@@ -0,0 +1,175 @@
+package ghal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTextRightToLeft(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Sentence
+	}{
+		{
+			"Arabic",
+			"مرحبا بالعالم. كيف حالك؟",
+			[]Sentence{
+				{MakeWord("NN", "مرحبا"), MakeWord("NN", "بالعالم"), MakeWord(".", ".")},
+				{MakeWord("NN", "كيف"), MakeWord("NN", "حالك"), MakeWord(".", "؟")},
+			},
+		},
+		{
+			"Arabic commas",
+			"الكتاب، القلم، والورقة.",
+			[]Sentence{
+				{
+					MakeWord("NN", "الكتاب"), MakeWord(",", "،"),
+					MakeWord("NN", "القلم"), MakeWord(",", "،"),
+					MakeWord("NN", "والورقة"), MakeWord(".", "."),
+				},
+			},
+		},
+		{
+			"Arabic with vowel marks",
+			"ك\u064eت\u064eب\u064e الو\u064eل\u064eد\u064f.",
+			[]Sentence{
+				{MakeWord("NN", "ك\u064eت\u064eب\u064e"), MakeWord("NN", "الو\u064eل\u064eد\u064f"), MakeWord(".", ".")},
+			},
+		},
+		{
+			"Hebrew",
+			"שלום עולם! מה שלומך?",
+			[]Sentence{
+				{MakeWord("NN", "שלום"), MakeWord("NN", "עולם"), MakeWord(".", "!")},
+				{MakeWord("NN", "מה"), MakeWord("NN", "שלומך"), MakeWord(".", "?")},
+			},
+		},
+		{
+			// Directional marks stay with the word they're attached to.
+			"Hebrew in English with marks",
+			"abc \u200fשלום\u200f def.",
+			[]Sentence{
+				{MakeWord("NN", "abc"), MakeWord("NN", "\u200fשלום\u200f"), MakeWord("NN", "def"), MakeWord(".", ".")},
+			},
+		},
+		{
+			// Embeddings and isolates are removed, because their closing
+			// characters won't stay with them once words are recombined.
+			"Hebrew with embedding",
+			"\u202bשלום\u202c \u2067עולם\u2069.",
+			[]Sentence{
+				{MakeWord("NN", "שלום"), MakeWord("NN", "עולם"), MakeWord(".", ".")},
+			},
+		},
+		{
+			// Combining sequences are normalized to composed characters.
+			"decomposed Latin",
+			"Cafe\u0301 au lait.",
+			[]Sentence{
+				{MakeWord("NN", "caf\u00e9"), MakeWord("NN", "au"), MakeWord("NN", "lait"), MakeWord(".", ".")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseText(test.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTruncateTextKeepsCombiningMarks(t *testing.T) {
+	// "e" followed by a combining acute accent, with no spaces to truncate
+	// at, so the accent can't be separated from its letter.
+	text := "cafe\u0301e\u0301"
+	tests := []struct {
+		maxLen int
+		want   string
+	}{
+		{4, "caf"},
+		{5, "caf"},
+		{6, "cafe\u0301"},
+		{7, "cafe\u0301"},
+		{9, "cafe\u0301e\u0301"},
+	}
+	for _, test := range tests {
+		if got := truncateText(text, test.maxLen); got != test.want {
+			t.Errorf("truncateText(%q, %d) = %q; want %q", text, test.maxLen, got, test.want)
+		}
+	}
+}
+
+func TestScriptDetection(t *testing.T) {
+	tests := []struct {
+		r           rune
+		word        bool
+		bidiControl bool
+		noSpace     bool
+	}{
+		{'a', true, false, false},
+		{'7', true, false, false},
+		{'ש', true, false, false},      // Hebrew letter
+		{'م', true, false, false},      // Arabic letter
+		{'\u064e', true, false, false}, // Arabic fatha, a combining mark
+		{'\u0301', true, false, false}, // combining acute accent
+		{'\u200f', true, false, false}, // right-to-left mark
+		{'\u200e', true, false, false}, // left-to-right mark
+		{'\u202b', true, true, false},  // right-to-left embedding
+		{'\u202e', true, true, false},  // right-to-left override
+		{'\u2067', true, true, false},  // right-to-left isolate
+		{'\u2069', true, true, false},  // pop directional isolate
+		{'.', false, false, false},     // full stop
+		{'؟', false, false, false},     // Arabic question mark
+		{'،', false, false, false},     // Arabic comma
+		{'漢', true, false, true},       // Han ideograph
+		{'カ', true, false, true},       // Katakana
+	}
+	for _, test := range tests {
+		if got := isWordRune(test.r); got != test.word {
+			t.Errorf("isWordRune(%q) = %t; want %t", test.r, got, test.word)
+		}
+		if got := isBidiControl(test.r); got != test.bidiControl {
+			t.Errorf("isBidiControl(%q) = %t; want %t", test.r, got, test.bidiControl)
+		}
+		if got := isNoSpaceRune(test.r); got != test.noSpace {
+			t.Errorf("isNoSpaceRune(%q) = %t; want %t", test.r, got, test.noSpace)
+		}
+	}
+}
+
+func TestPunctTag(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want string
+	}{
+		{'؟', "."}, // Arabic question mark
+		{'۔', "."}, // Urdu full stop
+		{'،', ","}, // Arabic comma
+		{'。', "."},
+		{'、', ","},
+		{'«', "``"},
+		{'»', "''"},
+		{'€', "$"},
+		{'־', ":"}, // Hebrew maqaf
+	}
+	for _, test := range tests {
+		if got := punctTag(test.r); got != test.want {
+			t.Errorf("punctTag(%q) = %q; want %q", test.r, got, test.want)
+		}
+	}
+}
+
+func TestRemoveBidiControls(t *testing.T) {
+	text := "\u202bשלום\u202c \u200fעולם\u200f \u2066abc\u2069"
+	want := "שלום \u200fעולם\u200f abc"
+	if got := removeBidiControls(text); got != want {
+		t.Errorf("wrong result\ngot:  %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package ghal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SearchText returns all of the words the brain knows, in any part of
+// speech, whose text contains the given substring, in sorted order. The
+// substring is normalized in the same way as the text of a word, so the
+// search is not case-sensitive.
+//
+// This is intended for finding where an unwanted word lives in the brain's
+// model, such as to make it taboo using TabooWord.
+func (b *Brain) SearchText(substr string) []Word {
+	substr = MakeWord("", substr).Text
+	return b.searchWords(func(text string) bool {
+		return strings.Contains(text, substr)
+	})
+}
+
+// SearchRegexp is like SearchText but returns the words whose text matches
+// the given regular expression. Since the text of words is always lowercase,
+// the expression should be written to match lowercase text.
+func (b *Brain) SearchRegexp(re *regexp.Regexp) []Word {
+	return b.searchWords(re.MatchString)
+}
+
+// searchWords is the main implementation of SearchText and SearchRegexp.
+func (b *Brain) searchWords(match func(text string) bool) []Word {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	var ret []Word
+	b.forEachWord(func(w Word) {
+		if match(w.Text) {
+			ret = append(ret, w)
+		}
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		return wordLess(ret[i], ret[j])
+	})
+	return ret
+}
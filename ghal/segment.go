@@ -0,0 +1,211 @@
+package ghal
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	prose "gopkg.in/jdkato/prose.v2"
+)
+
+// Segmentation selects how the parser divides text into sentences and words.
+type Segmentation int
+
+const (
+	// SegmentWords divides text into words at whitespace and punctuation,
+	// which is suitable for languages like English that put spaces between
+	// words. This is the default.
+	SegmentWords Segmentation = iota
+
+	// SegmentCharacters treats each character of scripts that don't put
+	// spaces between words, such as Chinese and Japanese, as a word of its
+	// own, along with each full-width punctuation mark. Runs of text in
+	// other scripts are divided into words as with SegmentWords, so text
+	// that mixes the two is still parsed sensibly.
+	//
+	// The parser has no dictionary for these scripts, so Han and Katakana
+	// characters are all tagged as nouns and Hiragana characters are tagged
+	// as particles. That's crude, but it allows the brain to choose
+	// keywords from the text.
+	SegmentCharacters
+)
+
+// ParseOptions customizes how ParseTextWithOptions parses text.
+type ParseOptions struct {
+	// Limits constrains how much text is processed. The zero value imposes
+	// no limits.
+	Limits ParseLimits
+
+	// Segmentation selects how text is divided into words.
+	Segmentation Segmentation
+
+	// Numbers selects what to do with numbers in the text. The zero value
+	// is KeepNumbers.
+	Numbers NumberHandling
+
+	// Tagger, if set, assigns the part-of-speech tags in place of the tagger
+	// built in to this package.
+	Tagger Tagger
+}
+
+// ParseTextWithOptions is like ParseText but parses the text as described by
+// the given options.
+func ParseTextWithOptions(text string, opts ParseOptions) ([]Sentence, error) {
+	return parseText(text, nil, opts)
+}
+
+// isNoSpaceRune returns true if the given character belongs to a script that
+// doesn't use spaces to separate words.
+func isNoSpaceRune(r rune) bool {
+	// The prolonged sound mark is shared between Hiragana and Katakana and
+	// so it isn't in either of those scripts.
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) || r == 'ー'
+}
+
+// noSpacePunct is the full-width punctuation used in Chinese, Japanese, and
+// Korean text, which is never separated from the surrounding text by spaces.
+var noSpacePunct = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x3001, Hi: 0x303f, Stride: 1}, // CJK symbols and punctuation
+		{Lo: 0x30fb, Hi: 0x30fb, Stride: 1}, // katakana middle dot
+		{Lo: 0xff01, Hi: 0xff65, Stride: 1}, // full-width and half-width forms
+	},
+}
+
+// isNoSpacePunct returns true if the given character is full-width
+// punctuation.
+func isNoSpacePunct(r rune) bool {
+	return unicode.Is(noSpacePunct, r) && (unicode.IsPunct(r) || unicode.IsSymbol(r))
+}
+
+// noSpaceBetween returns true if Sentence.String should not insert a space
+// between words with the given text, because the boundary between them is
+// within text that doesn't use spaces or is beside full-width punctuation.
+func noSpaceBetween(prev, next string) bool {
+	last, _ := utf8.DecodeLastRuneInString(prev)
+	first, _ := utf8.DecodeRuneInString(next)
+	if isNoSpacePunct(last) || isNoSpacePunct(first) {
+		return true
+	}
+	return isNoSpaceRune(last) && isNoSpaceRune(first)
+}
+
+// noSpaceTag returns the tag for the given character when it appears in text
+// parsed with SegmentCharacters, or false if the character should instead be
+// parsed as part of a space-separated word.
+func noSpaceTag(r rune) (string, bool) {
+	switch {
+	case isNoSpacePunct(r):
+		return punctTag(r), true
+	case unicode.Is(unicode.Hiragana, r):
+		return "RP", true
+	case isNoSpaceRune(r):
+		return "NN", true
+	default:
+		return "", false
+	}
+}
+
+// splitSentencesNoSpace divides the given text into sentences for
+// SegmentCharacters, ending a sentence after any full-width terminating
+// punctuation or after any other terminating punctuation that is followed by
+// whitespace, along with any closing brackets or quotes that follow it.
+//
+// We can't use the prose library's sentence segmenter here because it
+// assumes that most characters are a single byte long, and so it mangles
+// text in these scripts beyond recognition.
+func splitSentencesNoSpace(text string) []prose.Sentence {
+	var ret []prose.Sentence
+	add := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			ret = append(ret, prose.Sentence{Text: s})
+		}
+	}
+
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+
+		switch r {
+		case '。', '｡', '？', '！', '…':
+		case '.', '?', '!':
+			if next, _ := utf8.DecodeRuneInString(text[i:]); i < len(text) && !unicode.IsSpace(next) {
+				continue
+			}
+		default:
+			continue
+		}
+		for i < len(text) {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if !endsSentence(r) {
+				break
+			}
+			i += size
+		}
+		add(text[start:i])
+		start = i
+	}
+	add(text[start:])
+	return ret
+}
+
+// endsSentence returns true if the given character can appear at the end of
+// a sentence after its terminating punctuation, including further
+// terminating punctuation as in "?!".
+func endsSentence(r rune) bool {
+	switch r {
+	case '.', '?', '!':
+		return true
+	}
+	switch tag, _ := noSpaceTag(r); tag {
+	case ".", ")", "''":
+		return true
+	default:
+		return unicode.Is(unicode.Pf, r)
+	}
+}
+
+// tokenizeNoSpace divides the given sentence into tokens for
+// SegmentCharacters, using the prose library to tokenize and tag only the
+// runs of text that aren't in a script that lacks spaces.
+func tokenizeNoSpace(text string, skipTagging bool, docOpts []prose.DocOpt) ([]prose.Token, error) {
+	var ret []prose.Token
+	var other strings.Builder
+	flush := func() error {
+		defer other.Reset()
+		if strings.TrimSpace(other.String()) == "" {
+			return nil
+		}
+		toks, err := proseTokens(other.String(), docOpts)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, toks...)
+		return nil
+	}
+
+	for _, r := range text {
+		if unicode.IsMark(r) && other.Len() == 0 && len(ret) > 0 {
+			// A combining mark belongs with the character before it.
+			ret[len(ret)-1].Text += string(r)
+			continue
+		}
+		tag, ok := noSpaceTag(r)
+		if !ok {
+			other.WriteRune(r)
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		if skipTagging {
+			tag = ""
+		}
+		ret = append(ret, prose.Token{Tag: tag, Text: string(r)})
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
 
 	"golang.org/x/text/unicode/norm"
@@ -24,8 +25,14 @@ var Period = MakeWord(".", ".")
 var QuestionMark = MakeWord(".", "?")
 var ExclamationMark = MakeWord(".", "!")
 
+// MakeWord returns a word with the given tag and text, normalizing the text
+// to lowercase in Unicode normalization form C. The text of emoji, tagged
+// with EmojiTag, is used verbatim so that emoticons like ":D" and emoji
+// sequences aren't altered.
 func MakeWord(tag, text string) Word {
-	text = strings.ToLower(norm.NFC.String(text))
+	if tag != EmojiTag {
+		text = strings.ToLower(norm.NFC.String(text))
+	}
 	return Word{tag, text}
 }
 
@@ -33,22 +40,29 @@ func (w Word) GoString() string {
 	return fmt.Sprintf("ghal.MakeWord(%q, %q)", w.Tag, w.Text)
 }
 
-func (w Word) IsNoun() bool {
-	switch w.Tag {
-	case "NN", "NNS", "NNP", "NNPS":
-		return true
-	default:
-		return false
+// wordLess defines a total order over words, for situations where we need
+// to produce results in a stable order. Words are ordered primarily by their
+// text and then by their tag.
+func wordLess(a, b Word) bool {
+	if a.Text != b.Text {
+		return a.Text < b.Text
 	}
+	return a.Tag < b.Tag
+}
+
+func (w Word) IsNoun() bool {
+	pos := w.UPOS()
+	return pos == NOUN || pos == PROPN
 }
 
 func (w Word) IsProperNoun() bool {
-	switch w.Tag {
-	case "NNP", "NNPS":
-		return true
-	default:
-		return false
-	}
+	return w.UPOS() == PROPN
+}
+
+// IsPunctuation returns true if the word is tagged as a punctuation mark,
+// such as a comma, a quote, or sentence-terminating punctuation.
+func (w Word) IsPunctuation() bool {
+	return w.UPOS() == PUNCT
 }
 
 func (w Word) IsHashtag() bool {
@@ -104,6 +118,30 @@ func (s Sentence) ProperNouns() WordSet {
 	return ret
 }
 
+// Novelty returns the fraction of the words in the receiver that are not in
+// the given set, ignoring punctuation. The result is between 0, meaning that
+// every word is in the set, and 1, meaning that no words are in the set.
+//
+// This can be used to measure how different a reply is from the sentences
+// it is replying to, by passing a set of all of the words in those sentences.
+// A sentence containing only punctuation has a novelty of zero.
+func (s Sentence) Novelty(against WordSet) float64 {
+	total, novel := 0, 0
+	for _, w := range s {
+		if w.IsPunctuation() {
+			continue
+		}
+		total++
+		if !against.Has(w) {
+			novel++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(novel) / float64(total)
+}
+
 // TrimPeriod tests whether the final "word" in the receiver is a period and
 // if so returns a new slice with the same backing array that does not include
 // that trailing period. Otherwise, returns the receiver verbatim.
@@ -116,21 +154,11 @@ func (s Sentence) ProperNouns() WordSet {
 // cause the brain to learn sentences without trailing periods) or on a
 // sentence constructed by a brain (to cosmetically remove trailing periods
 // even though the brain itself considers them part of a sentence).
+//
+// TrimPeriod recognizes only the period from DefaultTerminators. Use
+// Brain.TrimPeriod to respect a brain's configured terminators instead.
 func (s Sentence) TrimPeriod() Sentence {
-	switch {
-	case len(s) == 0:
-		return s
-	case s[len(s)-1] == Period:
-		// As a special case, if the token right before the period is _also_
-		// a period then we'll leave things as-is, assuming we've found an
-		// ellipsis.
-		if len(s) > 1 && s[len(s)-2] == Period {
-			return s
-		}
-		return s[:len(s)-1]
-	default:
-		return s
-	}
+	return DefaultTerminators.TrimPeriod(s)
 }
 
 func (s Sentence) String() string {
@@ -141,9 +169,16 @@ func (s Sentence) String() string {
 			// exceptions.
 			prev := s[i-1]
 			switch {
+			case w.IsEmoji():
+				// Emoji are always separate from the words before them, even
+				// if they contain characters that look like punctuation.
+				ret.WriteByte(' ')
 			case w.Tag == "." || w.Tag == "," || w.Tag == ":" || w.Tag == ")" || w.Tag == "''":
 			case prev.Tag == "(" || prev.Tag == "``" || prev.Tag == "$":
-			case strings.Contains(w.Text, "'"):
+			case w.isClitic():
+			case noSpaceBetween(prev.Text, w.Text):
+				// Scripts like Chinese and Japanese don't put spaces
+				// between words, or around full-width punctuation.
 			default:
 				// In all other cases we insert a space.
 				ret.WriteByte(' ')
@@ -208,6 +243,57 @@ func (s WordSet) Union(others ...WordSet) WordSet {
 	return ret
 }
 
+// Intersect returns a new set containing only the words that are in both the
+// receiver and all of the other given sets.
+func (s WordSet) Intersect(others ...WordSet) WordSet {
+	ret := make(WordSet)
+Words:
+	for w := range s {
+		for _, os := range others {
+			if !os.Has(w) {
+				continue Words
+			}
+		}
+		ret.Add(w)
+	}
+	return ret
+}
+
+// Subtract returns a new set containing the words in the receiver that are
+// not in any of the other given sets.
+func (s WordSet) Subtract(others ...WordSet) WordSet {
+	ret := make(WordSet)
+Words:
+	for w := range s {
+		for _, os := range others {
+			if os.Has(w) {
+				continue Words
+			}
+		}
+		ret.Add(w)
+	}
+	return ret
+}
+
+// Equal returns true if the receiver and the given set contain exactly the
+// same words.
+func (s WordSet) Equal(other WordSet) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for w := range s {
+		if !other.Has(w) {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the number of words in the set.
+func (s WordSet) Len() int {
+	return len(s)
+}
+
 // ProperNouns returns the set of words within the receiver that are proper nouns.
 func (s WordSet) ProperNouns() WordSet {
 	ret := make(WordSet)
@@ -219,6 +305,19 @@ func (s WordSet) ProperNouns() WordSet {
 	return ret
 }
 
+// Sorted returns the words in the receiver as a slice, ordered by text and
+// then by tag.
+func (s WordSet) Sorted() []Word {
+	ret := make([]Word, 0, len(s))
+	for w := range s {
+		ret = append(ret, w)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return wordLess(ret[i], ret[j])
+	})
+	return ret
+}
+
 // ChooseRandom will choose up to n words pseudo-randomly from the receiving
 // set, returning a slice with n or fewer elements.
 func (s WordSet) ChooseRandom(n int) []Word {
@@ -265,7 +364,49 @@ func (s WordSet) ChooseRandomInto(into []Word) []Word {
 	return into
 }
 
+// ParseText splits the given text into sentences and tags each of the words
+// in those sentences with its part of speech.
+//
+// When parsing a large amount of text, such as training material, use a
+// Parser instead to reduce memory usage.
 func ParseText(text string) ([]Sentence, error) {
+	return parseText(text, nil, ParseOptions{})
+}
+
+// parseText is the main implementation of ParseText, ParseTextWithLimits,
+// ParseTextWithOptions, and Parser.ParseText. If p is nil then each sentence
+// is allocated separately.
+func parseText(text string, p *Parser, opts ParseOptions) ([]Sentence, error) {
+	limits := opts.Limits
+	skipTagging := p != nil && p.SkipTagging
+	tagger := opts.Tagger
+	if skipTagging {
+		tagger = nil
+	}
+	// The built-in tagger is also skipped if there's an external tagger,
+	// which tags all of the sentences at once after they are tokenized.
+	builtinTagging := !skipTagging && tagger == nil
+	text = truncateText(text, limits.MaxTextLen)
+
+	// We normalize the whole text before tokenizing it, rather than each
+	// word afterwards, so that a combining sequence can't be split between
+	// words.
+	text = norm.NFC.String(removeBidiControls(text))
+
+	// We must record how words were capitalized before the text is
+	// converted to lowercase below.
+	if p != nil {
+		if p.casing == nil {
+			p.casing = make(CaseCounts)
+		}
+		p.casing.countCase(text)
+	}
+
+	// The tokenizer splits multi-codepoint emoji apart and doesn't
+	// recognize emoticons at all, so we'll replace them with placeholder
+	// words and then put them back afterwards.
+	text, emoji := extractEmoji(text)
+
 	// We parse all text in lowercase, because the POS tagger will use case
 	// to identify proper nouns and so if we were to provide correctly-cased
 	// text sometimes we would need to provide it every time to get consistent
@@ -274,23 +415,89 @@ func ParseText(text string) ([]Sentence, error) {
 	// with conversational sentences that tend to not be capitalized.
 	text = strings.ToLower(text)
 
-	whole, err := prose.NewDocument(text)
-	if err != nil {
-		return nil, err
+	// We never use prose's named entities, so we don't waste time extracting
+	// them.
+	docOpts := []prose.DocOpt{prose.WithExtraction(false)}
+	if builtinTagging {
+		docOpts = append(docOpts, prose.UsingModel(proseModel()))
+	} else {
+		docOpts = append(docOpts, prose.WithTagging(false))
 	}
-	sents := whole.Sentences()
-	sentences := make([]Sentence, 0, len(sents))
-	for _, s := range sents {
-		sDoc, err := prose.NewDocument(s.Text)
+
+	var sents []prose.Sentence
+	if opts.Segmentation == SegmentCharacters {
+		sents = splitSentencesNoSpace(text)
+	} else {
+		whole, err := prose.NewDocument(text, docOpts...)
 		if err != nil {
 			return nil, err
 		}
-		toks := sDoc.Tokens()
-		sentence := make(Sentence, len(toks))
+		sents = whole.Sentences()
+	}
+	sentences := make([]Sentence, 0, len(sents))
+	addSentence := func(toks []prose.Token) {
+		sentence := p.makeSentence(len(toks))
 		for i, token := range toks {
-			sentence[i] = MakeWord(token.Tag, token.Text)
+			sentence[i] = p.makeWord(token.Tag, token.Text)
+		}
+		sentence = handleNumbers(fixupParsedSentence(sentence), opts.Numbers)
+		if sentence == nil {
+			return
+		}
+		sentences = append(sentences, sentence)
+	}
+
+	// With an external tagger, the sentences are collected here so that
+	// they can be tagged all at once before we add them.
+	var untagged [][]prose.Token
+	for _, s := range sents {
+		if limits.MaxSentences > 0 && len(sentences) >= limits.MaxSentences {
+			break
+		}
+		if tooManyWords(s.Text, limits.MaxSentenceWords) {
+			// We can skip the expensive tagging step for sentences that are
+			// obviously too long.
+			continue
+		}
+		var toks []prose.Token
+		if opts.Segmentation == SegmentCharacters {
+			var err error
+			toks, err = tokenizeNoSpace(s.Text, !builtinTagging, docOpts)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			var err error
+			toks, err = proseTokens(s.Text, docOpts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if limits.MaxSentenceWords > 0 && len(toks) > limits.MaxSentenceWords {
+			continue
+		}
+		for i, token := range toks {
+			if e, ok := restoreEmoji(token.Text, emoji); ok {
+				toks[i] = prose.Token{Tag: EmojiTag, Text: e}
+			}
+		}
+		if tagger != nil {
+			untagged = append(untagged, toks)
+			continue
+		}
+		addSentence(toks)
+	}
+
+	if len(untagged) > 0 {
+		if err := tagTokens(tagger, untagged); err != nil {
+			return nil, err
+		}
+		for _, toks := range untagged {
+			if limits.MaxSentences > 0 && len(sentences) >= limits.MaxSentences {
+				break
+			}
+			addSentence(toks)
 		}
-		sentences = append(sentences, fixupParsedSentence(sentence))
 	}
 	return sentences, nil
 }
@@ -314,6 +521,20 @@ func fixupParsedSentence(s Sentence) Sentence {
 	}
 
 	for i, w := range s {
+		// The tagger has no idea what a hashtag is and so tags them
+		// inconsistently, but they are almost always used as nouns.
+		if isHashtagText(w.Text) {
+			s[i].Tag = "NN"
+			continue
+		}
+
+		// The tagger doesn't recognize terminating punctuation from other
+		// writing systems, so we'll tag it in the same way as a period.
+		if t := CJKTerminators; t.IsStatement(w) || t.IsQuestion(w) || t.IsExclamation(w) {
+			s[i].Tag = "."
+			continue
+		}
+
 		// First we'll handle the given tags as documented, in case a
 		// future version of prose starts handling them, so we don't
 		// confuse ourselves here.
@@ -350,6 +571,9 @@ func fixupParsedSentence(s Sentence) Sentence {
 			}
 			openQuotes[double] = !openQuotes[double] // toggle
 		case `'`: // this is safe because non-quote apostrophes get grouped in with other characters
+			if w.Tag == possessiveTag {
+				continue
+			}
 			if openQuotes[single] {
 				s[i].Tag = close
 			} else {
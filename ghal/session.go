@@ -0,0 +1,86 @@
+package ghal
+
+// BeginSession starts a session of ephemeral learning, during which the
+// sentences given to Learn are learned by a separate session brain rather
+// than by the conversation's brain, so that the shared brain isn't affected
+// by them unless the session is committed.
+//
+// Replies are still constructed from the conversation's brain, but are also
+// constructed from the session brain, with the higher-scoring of the two
+// chosen, so that the conversation can make use of what it was taught
+// during the session.
+//
+// Beginning a session while one is already in progress discards the one in
+// progress.
+func (c *Conversation) BeginSession() {
+	c.session = newBrainSized(c.Brain.Order(), 0, 0)
+	c.session.filter = c.Brain.Allows
+	c.sessionLearned = nil
+}
+
+// InSession returns true if a session of ephemeral learning begun with
+// BeginSession is in progress.
+func (c *Conversation) InSession() bool {
+	return c.session != nil
+}
+
+// SessionLearned returns the number of sentences learned during the current
+// session, which would be learned by the conversation's brain if the session
+// were committed.
+func (c *Conversation) SessionLearned() int {
+	return len(c.sessionLearned)
+}
+
+// CommitSession ends the current session, teaching the conversation's brain
+// all of the sentences that were learned during it, with their original
+// sources. It does nothing if no session is in progress.
+func (c *Conversation) CommitSession() {
+	if c.session == nil {
+		return
+	}
+	c.Brain.AddUtterances(c.sessionLearned, "")
+	c.DiscardSession()
+}
+
+// DiscardSession ends the current session, forgetting all of the sentences
+// that were learned during it. It does nothing if no session is in progress.
+func (c *Conversation) DiscardSession() {
+	c.session = nil
+	c.sessionLearned = nil
+}
+
+// learnInSession teaches the session brain the given sentences, remembering
+// those that the conversation's brain would learn in case the session is
+// later committed.
+func (c *Conversation) learnInSession(ss []Sentence, source string) {
+	c.session.AddSentencesFrom(ss, source)
+	for _, s := range ss {
+		if !c.session.Allows(s) {
+			continue
+		}
+		c.sessionLearned = append(c.sessionLearned, Utterance{
+			Sentence: s,
+			Source:   source,
+		})
+	}
+}
+
+// makeReply constructs a reply to the given sentences from the conversation's
+// brain and, if a session is in progress, from the session brain too,
+// returning whichever scores higher.
+func (c *Conversation) makeReply(opts GenerationOptions, ss []Sentence) ScoredReply {
+	ret := c.Brain.MakeReplyWithOptions(opts, ss...)
+	if len(c.sessionLearned) == 0 {
+		return ret
+	}
+	sessionRet := c.session.MakeReplyWithOptions(opts, ss...)
+	// Ties go to the session brain, since what was taught most recently is
+	// probably what the user wants to hear about.
+	if len(sessionRet.Sentence) > 0 && (len(ret.Sentence) == 0 || sessionRet.Score >= ret.Score) {
+		debugf("using reply from session brain")
+		sessionRet.Candidates += ret.Candidates
+		return sessionRet
+	}
+	ret.Candidates += sessionRet.Candidates
+	return ret
+}
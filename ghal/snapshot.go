@@ -0,0 +1,174 @@
+//go:build !js
+
+package ghal
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotTimeFormat is the layout of the timestamp added to the name of a
+// brain file to make the name of a snapshot of it, which sorts in time
+// order and contains no characters that are troublesome in filenames.
+const snapshotTimeFormat = "2006-01-02T150405.000Z"
+
+// SnapshotPolicy describes which previous versions of a brain file are kept
+// as snapshots by SaveFileWithSnapshots.
+type SnapshotPolicy struct {
+	// Keep is the maximum number of snapshots to keep. Zero disables
+	// snapshots entirely.
+	Keep int
+
+	// MaxAge is the age beyond which snapshots are deleted even if there
+	// are fewer than Keep of them. Zero means that snapshots are deleted
+	// only when there are more than Keep of them.
+	MaxAge time.Duration
+}
+
+// Snapshot is a previous version of a brain file, as returned by Snapshots.
+type Snapshot struct {
+	// Filename is the name of the file containing the snapshot.
+	Filename string
+
+	// Time is when the snapshot was taken, which is when the version of the
+	// brain it contains was replaced.
+	Time time.Time
+}
+
+// SaveFileWithSnapshots is like SaveFile but, if the policy enables
+// snapshots, first keeps the existing file with the given name as a
+// timestamped snapshot alongside it, and then deletes any snapshots that
+// the policy no longer retains.
+//
+// Unlike SaveFile, the brain is first written to a temporary file that then
+// replaces the existing file, so the existing file is never left only
+// partially written.
+func (b *Brain) SaveFileWithSnapshots(filename string, policy SnapshotPolicy) error {
+	tempName := tempFilename(filename)
+	err := b.SaveFile(tempName)
+	if err != nil {
+		os.Remove(tempName)
+		return err
+	}
+	return replaceWithSnapshot(filename, tempName, policy)
+}
+
+// Snapshots returns the snapshots of the brain file with the given name,
+// from newest to oldest.
+func Snapshots(filename string) ([]Snapshot, error) {
+	dir, base := filepath.Split(filename)
+	entries, err := os.ReadDir(filepath.Join(dir, "."))
+	if err != nil {
+		return nil, err
+	}
+	var ret []Snapshot
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), base+".")
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		t, err := time.Parse(snapshotTimeFormat, suffix)
+		if err != nil {
+			continue // not a snapshot
+		}
+		ret = append(ret, Snapshot{
+			Filename: filepath.Join(dir, entry.Name()),
+			Time:     t,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Time.After(ret[j].Time)
+	})
+	return ret, nil
+}
+
+// RestoreSnapshot replaces the brain file with the given name with a copy of
+// the given snapshot of it. If the policy enables snapshots, the version
+// being replaced is itself kept as a snapshot, so that restoring can be
+// undone.
+func RestoreSnapshot(filename string, snap Snapshot, policy SnapshotPolicy) error {
+	src, err := os.Open(snap.Filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tempName := tempFilename(filename)
+	dst, err := os.Create(tempName)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempName)
+		return err
+	}
+	return replaceWithSnapshot(filename, tempName, policy)
+}
+
+// replaceWithSnapshot renames the file tempName to filename, first keeping
+// any existing file with that name as a snapshot if the policy enables
+// snapshots, and then deletes the snapshots the policy no longer retains.
+func replaceWithSnapshot(filename, tempName string, policy SnapshotPolicy) error {
+	if policy.Keep > 0 {
+		now := time.Now().UTC()
+		// We link rather than rename so that there's never a moment when
+		// the brain file doesn't exist.
+		err := os.Link(filename, filename+"."+now.Format(snapshotTimeFormat))
+		switch {
+		case err == nil, os.IsNotExist(err):
+			// If there's no existing file then there's nothing to keep.
+		case os.IsExist(err):
+			// We already took a snapshot within the same millisecond, which
+			// we'll keep since it's the older of the two versions.
+		default:
+			os.Remove(tempName)
+			return err
+		}
+	}
+
+	err := os.Rename(tempName, filename)
+	if err != nil {
+		os.Remove(tempName)
+		return err
+	}
+
+	if policy.Keep > 0 {
+		return pruneSnapshots(filename, policy)
+	}
+	return nil
+}
+
+// pruneSnapshots deletes the snapshots of the brain file with the given name
+// that the given policy does not retain.
+func pruneSnapshots(filename string, policy SnapshotPolicy) error {
+	snaps, err := Snapshots(filename)
+	if err != nil {
+		return err
+	}
+	for i, snap := range snaps {
+		if i < policy.Keep && (policy.MaxAge == 0 || time.Since(snap.Time) <= policy.MaxAge) {
+			continue
+		}
+		debugf("deleting snapshot %s", snap.Filename)
+		err := os.Remove(snap.Filename)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// tempFilename returns the name of a hidden temporary file in the same
+// directory as the given file, which can be renamed to replace it.
+func tempFilename(filename string) string {
+	dir, base := filepath.Split(filename)
+	return filepath.Join(dir, "."+base+".new")
+}
@@ -0,0 +1,71 @@
+package ghal
+
+// BrainStats is a summary of the size and recent activity of a brain, as
+// returned by Brain.Stats, intended for monitoring a long-running bot. For a
+// brain that hasn't learned anything, all of the fields are zero except for
+// MemoryEstimate.
+type BrainStats struct {
+	// Words is the number of distinct words appearing in the brain's chains.
+	Words int
+
+	// Chains is the number of distinct chains the brain knows.
+	Chains int
+
+	// Sources is the number of distinct training sources recorded using
+	// AddSentencesFrom and related methods.
+	Sources int
+
+	// MemoryEstimate is an estimate of the number of bytes of memory the
+	// brain occupies, as returned by MemoryEstimate.
+	MemoryEstimate int64
+
+	// Learning is how quickly the brain has been learning recently, as
+	// returned by LearningRate.
+	Learning LearningRate
+}
+
+// Stats returns a summary of the size and recent activity of the brain.
+func (b *Brain) Stats() BrainStats {
+	// MemoryEstimate, LearningRate, and Sources acquire their own locks, so
+	// we must call them before acquiring ours.
+	ret := BrainStats{
+		Sources:        len(b.Sources()),
+		MemoryEstimate: b.MemoryEstimate(),
+		Learning:       b.LearningRate(),
+	}
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	ret.Words = b.wordCount()
+	ret.Chains = b.chainCount()
+	return ret
+}
+
+// IsTrained returns true if the brain knows at least the given number of
+// chains, including those of its base if it is an overlay, so that an
+// application can tell its users that the brain needs training rather than
+// silently saying nothing. If minChains is zero or less, it instead returns
+// true if the brain has learned anything at all, including sentences too
+// short to make a chain.
+//
+// An untrained brain is still safe to use: the methods that construct
+// sentences, like MakeReply and MakeQuestion, return nil if the brain
+// doesn't know enough to construct one, and Stats reports zero words and
+// chains.
+func (b *Brain) IsTrained(minChains int) bool {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	if minChains > 0 {
+		return b.chainCount() >= minChains
+	}
+	return b.hasLearned()
+}
+
+// hasLearned returns true if the brain or its base has learned any sentence.
+// The caller must hold at least a read lock on the brain.
+func (b *Brain) hasLearned() bool {
+	// Every sentence contributes its first word to the bigram model, even
+	// if it's too short to make a chain.
+	return len(b.chains) > 0 || len(b.bigrams.starts) > 0 || (b.base != nil && b.base.hasLearned())
+}
@@ -0,0 +1,49 @@
+package ghal
+
+// ReplyStyle constrains the kind of sentence a brain constructs, by
+// requiring particular terminating punctuation.
+type ReplyStyle int
+
+const (
+	// AnyStyle allows sentences to end in any way. This is the default.
+	AnyStyle ReplyStyle = iota
+
+	// StatementStyle requires declarative sentences, which may end with a
+	// period or with no terminating punctuation at all, but not with a
+	// question mark or exclamation mark.
+	StatementStyle
+
+	// QuestionStyle requires sentences ending with a question mark.
+	QuestionStyle
+
+	// ExclamationStyle requires sentences ending with an exclamation mark.
+	ExclamationStyle
+)
+
+// acceptsEnd returns true if a sentence ending with the given word has the
+// receiving style, according to the given terminators.
+func (s ReplyStyle) acceptsEnd(last Word, t Terminators) bool {
+	switch s {
+	case StatementStyle:
+		return !t.IsQuestion(last) && !t.IsExclamation(last)
+	case QuestionStyle:
+		return t.IsQuestion(last)
+	case ExclamationStyle:
+		return t.IsExclamation(last)
+	default:
+		return true
+	}
+}
+
+func (s ReplyStyle) String() string {
+	switch s {
+	case StatementStyle:
+		return "statement"
+	case QuestionStyle:
+		return "question"
+	case ExclamationStyle:
+		return "exclamation"
+	default:
+		return "any"
+	}
+}
@@ -0,0 +1,165 @@
+package ghal
+
+import (
+	"sort"
+)
+
+// SetSwap configures the brain to swap the word with the text from for the
+// word with the text to when choosing the keywords of a reply, in the same
+// way as MegaHAL's swap table, so that for example a message about "my cat"
+// can be answered with a sentence about "your cat". The texts are
+// normalized in the same way as the text of words, and a keyword is swapped
+// only if the brain knows a word with the replacement text. Swaps are saved
+// and loaded along with the rest of the brain.
+//
+// A word can be swapped for only one other word, so setting a swap replaces
+// any existing swap for the same word.
+//
+// SetSwap panics if the brain has been frozen using Freeze.
+func (b *Brain) SetSwap(from, to string) {
+	b.lock()
+	defer b.mut.Unlock()
+	b.swaps[b.strs.intern(MakeWord("", from).Text)] = b.strs.intern(MakeWord("", to).Text)
+}
+
+// RemoveSwap removes the swap for the word with the given text, if there is
+// one. For an overlay brain, this cannot remove a swap from its base.
+//
+// RemoveSwap panics if the brain has been frozen using Freeze.
+func (b *Brain) RemoveSwap(from string) {
+	b.lock()
+	defer b.mut.Unlock()
+	delete(b.swaps, MakeWord("", from).Text)
+}
+
+// Swaps returns all of the swaps configured using SetSwap, as a map from the
+// text of each word to the text it is swapped for.
+func (b *Brain) Swaps() map[string]string {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	ret := make(map[string]string)
+	for l := b; l != nil; l = l.base {
+		for from, to := range l.swaps {
+			if _, ok := ret[from]; !ok {
+				ret[from] = to
+			}
+		}
+	}
+	return ret
+}
+
+// swapFor returns the text that the given text is swapped for, consulting
+// any brain the brain is layered over, or false if it isn't swapped. The
+// caller must hold at least a read lock on the brain.
+func (b *Brain) swapFor(text string) (string, bool) {
+	if to, ok := b.swaps[text]; ok {
+		return to, true
+	}
+	if b.base != nil {
+		return b.base.swapFor(text)
+	}
+	return "", false
+}
+
+// swapKeywords returns the given keywords with any that have swaps replaced
+// by the words they are swapped for, or the set itself if none of them are
+// swapped. A keyword is replaced by the word with the replacement text and
+// the same tag if the brain knows one, or otherwise by the most frequent of
+// the words with the replacement text, or kept if there is no such word.
+//
+// Finding the words with a replacement text means looking through the
+// brain's whole vocabulary, since words are indexed only by their text and
+// tag together, but that happens at most once per call and only when some
+// replacement isn't known with the keyword's own tag.
+func (b *Brain) swapKeywords(ws WordSet) WordSet {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	swaps := make(map[Word]string)
+	var lookup map[string]struct{}
+	for w := range ws {
+		to, ok := b.swapFor(w.Text)
+		if !ok {
+			continue
+		}
+		swaps[w] = to
+		if b.wordFrequency(Word{Tag: w.Tag, Text: to}) == 0 {
+			if lookup == nil {
+				lookup = make(map[string]struct{})
+			}
+			lookup[to] = struct{}{}
+		}
+	}
+	if len(swaps) == 0 {
+		return ws
+	}
+	var known map[string][]Word
+	if lookup != nil {
+		known = b.wordsWithTexts(lookup)
+	}
+
+	// We build the result from the original keywords rather than editing a
+	// copy of them in place, so that swaps in both directions, like "my"
+	// for "your" and "your" for "my", don't undo one another.
+	ret := make(WordSet, len(ws))
+	for w := range ws {
+		to, ok := swaps[w]
+		if !ok {
+			ret.Add(w)
+			continue
+		}
+		if swapped := (Word{Tag: w.Tag, Text: to}); b.wordFrequency(swapped) > 0 {
+			ret.Add(swapped)
+		} else if swapped, ok := b.mostFrequentWord(known[to]); ok {
+			ret.Add(swapped)
+		} else {
+			ret.Add(w)
+		}
+	}
+	return ret
+}
+
+// wordsWithTexts returns the words the brain knows with each of the given
+// texts, keyed by text. This looks through the brain's whole vocabulary, so
+// callers should look up all of the texts they need at once. The caller must
+// hold at least a read lock on the brain.
+func (b *Brain) wordsWithTexts(texts map[string]struct{}) map[string][]Word {
+	if len(texts) == 0 {
+		return nil
+	}
+	ret := make(map[string][]Word, len(texts))
+	b.forEachWord(func(w Word) {
+		if _, ok := texts[w.Text]; ok {
+			ret[w.Text] = append(ret[w.Text], w)
+		}
+	})
+	return ret
+}
+
+// mostFrequentWord returns the most frequent of the given words, or false if
+// there are none. The caller must hold at least a read lock on the brain.
+func (b *Brain) mostFrequentWord(words []Word) (Word, bool) {
+	if len(words) == 0 {
+		return Word{}, false
+	}
+	ret, retFreq := words[0], b.wordFrequency(words[0])
+	for _, w := range words[1:] {
+		freq := b.wordFrequency(w)
+		if freq > retFreq || (freq == retFreq && wordLess(w, ret)) {
+			ret, retFreq = w, freq
+		}
+	}
+	return ret, true
+}
+
+// sortedSwaps returns the words that have swaps in the given table, in
+// sorted order.
+func sortedSwaps(swaps map[string]string) []string {
+	ret := make([]string, 0, len(swaps))
+	for from := range swaps {
+		ret = append(ret, from)
+	}
+	sort.Strings(ret)
+	return ret
+}
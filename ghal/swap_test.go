@@ -0,0 +1,72 @@
+package ghal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSwapKeywords(t *testing.T) {
+	b := NewBrain()
+	b.AddSentence(testSentence("my", "cat", "sat", "on", "the", "mat"))
+	b.AddSentence(testSentence("your", "dog", "ran", "home"))
+	b.AddSentence(Sentence{MakeWord("PRP", "i"), MakeWord("VBP", "like"), MakeWord("NN", "fish"), Period})
+	b.SetSwap("my", "your")
+	b.SetSwap("your", "my")
+	b.SetSwap("you", "i")
+	b.SetSwap("cat", "kitten")
+
+	my, your := MakeWord("NN", "my"), MakeWord("NN", "your")
+	tests := []struct {
+		name string
+		in   WordSet
+		want WordSet
+	}{
+		{"one way", WordSet{my: {}}, WordSet{your: {}}},
+		{"both ways", WordSet{my: {}, your: {}}, WordSet{my: {}, your: {}}},
+		{
+			"different tag",
+			WordSet{MakeWord("NN", "you"): {}},
+			WordSet{MakeWord("PRP", "i"): {}},
+		},
+		{
+			"unknown replacement",
+			WordSet{MakeWord("NN", "cat"): {}},
+			WordSet{MakeWord("NN", "cat"): {}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := b.swapKeywords(test.in); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result\ngot:  %v\nwant: %v", got.Sorted(), test.want.Sorted())
+			}
+		})
+	}
+}
+
+func TestSwapsSavedWithBrain(t *testing.T) {
+	b := NewBrain()
+	b.AddSentence(testSentence("my", "cat", "sat", "on", "the", "mat"))
+	b.SetSwap("my", "your")
+	b.SetSwap("I", "you")
+	b.SetGreetings([]string{"hello", "hi"})
+
+	loaded, err := LoadBrainBytes(saveBrain(t, b))
+	if err != nil {
+		t.Fatalf("failed to load brain: %s", err)
+	}
+	if got, want := loaded.Swaps(), map[string]string{"my": "your", "i": "you"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong swaps\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if got, want := loaded.Greetings(), []string{"hello", "hi"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong greetings\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	loaded.RemoveSwap("my")
+	reloaded, err := LoadBrainBytes(saveBrain(t, loaded))
+	if err != nil {
+		t.Fatalf("failed to load brain: %s", err)
+	}
+	if got, want := reloaded.Swaps(), map[string]string{"i": "you"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong swaps after removing one\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package ghal
+
+// TabooWord adds the given word to the brain's taboo list, which excludes it
+// from being selected as a keyword and from appearing in any sentence the
+// brain generates, without forgetting anything the brain has learned about
+// it. This is intended for moderation, since it takes effect immediately
+// and can be reversed using UntabooWord. The taboo list is saved and loaded
+// along with the rest of the brain.
+//
+// TabooWord panics if the brain has been frozen using Freeze.
+func (b *Brain) TabooWord(w Word) {
+	b.lock()
+	defer b.mut.Unlock()
+	b.taboo.Add(b.strs.internWord(w))
+}
+
+// UntabooWord removes the given word from the brain's taboo list, if it is
+// present, so that the brain can once again use it in sentences. For an
+// overlay brain, this cannot remove a word from the taboo list of its base.
+//
+// UntabooWord panics if the brain has been frozen using Freeze.
+func (b *Brain) UntabooWord(w Word) {
+	b.lock()
+	defer b.mut.Unlock()
+	delete(b.taboo, w)
+}
+
+// IsTaboo returns true if the given word is on the brain's taboo list.
+func (b *Brain) IsTaboo(w Word) bool {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	return b.isTaboo(w)
+}
+
+// TabooWords returns all of the words on the brain's taboo list, in sorted
+// order.
+func (b *Brain) TabooWords() []Word {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	ret := make(WordSet)
+	for l := b; l != nil; l = l.base {
+		for w := range l.taboo {
+			ret.Add(w)
+		}
+	}
+	return ret.Sorted()
+}
+
+// isTaboo returns true if the given word is on the taboo list of the brain
+// or of any brain it is layered over, and hasTaboos returns true if any of
+// those taboo lists has any words at all. The caller must hold at least a
+// read lock on the brain.
+func (b *Brain) isTaboo(w Word) bool {
+	return b.taboo.Has(w) || (b.base != nil && b.base.isTaboo(w))
+}
+
+func (b *Brain) hasTaboos() bool {
+	return len(b.taboo) > 0 || (b.base != nil && b.base.hasTaboos())
+}
+
+// containsTaboo returns true if any of the given words is taboo. The caller
+// must hold at least a read lock on the brain.
+func (b *Brain) containsTaboo(ws []Word) bool {
+	for _, w := range ws {
+		if b.isTaboo(w) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutTaboo returns the given options extended with a transition
+// constraint that keeps taboo words out of the sentence being constructed,
+// or the options unchanged if there are no taboo words. The caller must
+// hold at least a read lock on the brain for as long as the returned
+// options are in use.
+func (b *Brain) withoutTaboo(opts GenerationOptions) GenerationOptions {
+	if !b.hasTaboos() {
+		return opts
+	}
+	notTaboo := func(x, y Word) bool {
+		return !b.isTaboo(x) && !b.isTaboo(y)
+	}
+	// We mustn't modify the caller's slice of transitions.
+	opts.Transitions = append(opts.Transitions[:len(opts.Transitions):len(opts.Transitions)], notTaboo)
+	return opts
+}
+
+// chainsWithoutTaboo returns the chains from the given set that contain no
+// taboo words, or the set itself if there are no taboo words. The caller
+// must hold at least a read lock on the brain.
+func (b *Brain) chainsWithoutTaboo(cs chainSet) chainSet {
+	if !b.hasTaboos() {
+		return cs
+	}
+	ret := make(chainSet)
+	for c := range cs {
+		if !b.containsTaboo(c.words()) {
+			ret.Add(c)
+		}
+	}
+	return ret
+}
+
+// withoutTabooWords returns the words from the given set that aren't taboo,
+// or the set itself if there are no taboo words.
+func (b *Brain) withoutTabooWords(ws WordSet) WordSet {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	if !b.hasTaboos() {
+		return ws
+	}
+	return filterWords(ws, func(w Word) bool {
+		return !b.isTaboo(w)
+	})
+}
@@ -0,0 +1,66 @@
+package ghal
+
+import (
+	"fmt"
+
+	prose "gopkg.in/jdkato/prose.v2"
+)
+
+// Tagger assigns part-of-speech tags to words, in place of the tagger built
+// in to this package, for applications that need better tagging quality or
+// languages other than English. See ParseOptions and Parser.
+//
+// Text is still divided into sentences and words by this package before it
+// is given to the tagger, and punctuation like quotes and apostrophes is
+// still tagged by this package, so that the rest of the package can rely on
+// how it is tagged. The tags may be either Penn Treebank tags or Universal
+// POS tags.
+//
+// CommandTagger and HTTPTagger use an external program or service.
+type Tagger interface {
+	// Tag returns the tag of each of the words in each of the given
+	// sentences, in the same order, or an error if the words can't be
+	// tagged.
+	Tag(sentences [][]string) ([][]string, error)
+}
+
+// TaggerFunc is an adapter allowing an ordinary function to be used as a
+// Tagger.
+type TaggerFunc func(sentences [][]string) ([][]string, error)
+
+// Tag calls the receiving function.
+func (f TaggerFunc) Tag(sentences [][]string) ([][]string, error) {
+	return f(sentences)
+}
+
+// tagTokens uses the given tagger to assign tags to each of the tokens in the
+// given sentences that don't already have one, modifying them in-place.
+func tagTokens(t Tagger, sents [][]prose.Token) error {
+	words := make([][]string, 0, len(sents))
+	for _, toks := range sents {
+		ws := make([]string, len(toks))
+		for i, tok := range toks {
+			ws[i] = tok.Text
+		}
+		words = append(words, ws)
+	}
+
+	tags, err := t.Tag(words)
+	if err != nil {
+		return fmt.Errorf("failed to tag words: %w", err)
+	}
+	if len(tags) != len(sents) {
+		return fmt.Errorf("tagger returned tags for %d sentences, but was given %d", len(tags), len(sents))
+	}
+	for i, toks := range sents {
+		if len(tags[i]) != len(toks) {
+			return fmt.Errorf("tagger returned %d tags for sentence %d, which has %d words", len(tags[i]), i, len(toks))
+		}
+		for j := range toks {
+			if toks[j].Tag == "" {
+				toks[j].Tag = tags[i][j]
+			}
+		}
+	}
+	return nil
+}
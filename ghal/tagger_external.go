@@ -0,0 +1,326 @@
+package ghal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// defaultTaggerBatchSize and defaultTaggerTimeout are the batch size and
+// timeout used by the external taggers when they are not given.
+const (
+	defaultTaggerBatchSize = 100
+	defaultTaggerTimeout   = 30 * time.Second
+)
+
+// CommandTagger is a Tagger that runs an external program to tag the words,
+// such as UDPipe, or a script using spaCy with spacy-conll.
+//
+// The words are written to the program's standard input with one sentence
+// per line and the words separated by spaces, which UDPipe calls the
+// "horizontal" format, and the program must write the tagged words to its
+// standard output in CoNLL-U format without changing how the words are
+// divided. For example:
+//
+//	udpipe --input=horizontal --tag english-ewt.udpipe
+//
+// The program is run separately for each batch of sentences, so a program
+// that is slow to start is better run as a service and used with
+// HTTPTagger.
+type CommandTagger struct {
+	// Command is the name of the program to run followed by its arguments.
+	Command []string
+
+	// BatchSize is the greatest number of sentences given to each run of
+	// the program. Zero means 100.
+	BatchSize int
+
+	// Timeout is the longest that each run of the program may take before
+	// it is killed and tagging fails. Zero means 30 seconds.
+	Timeout time.Duration
+
+	// UniversalTags selects the Universal POS tags from the program's
+	// output rather than the language-specific tags, which for English are
+	// the Penn Treebank tags. The Universal POS tags are used anyway for
+	// any word that has no language-specific tag.
+	UniversalTags bool
+}
+
+// Tag implements Tagger.
+func (t CommandTagger) Tag(sentences [][]string) ([][]string, error) {
+	if len(t.Command) == 0 {
+		return nil, errors.New("no tagger command")
+	}
+	timeout := taggerTimeout(t.Timeout)
+	return tagInBatches(sentences, t.BatchSize, t.UniversalTags, func(input string) (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, t.Command[0], t.Command[1:]...)
+		cmd.Stdin = strings.NewReader(input)
+		out, err := cmd.Output()
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("tagger command timed out after %s", timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("tagger command failed: %s: %s", err, bytes.TrimSpace(exitErr.Stderr))
+		} else if err != nil {
+			return "", fmt.Errorf("tagger command failed: %w", err)
+		}
+		return string(out), nil
+	})
+}
+
+// HTTPTagger is a Tagger that calls an external service over HTTP to tag the
+// words, such as the UDPipe REST service, or a spaCy server that accepts the
+// same requests.
+//
+// Each request is a POST request with a form body containing the words in
+// the field "data", with one sentence per line and the words separated by
+// spaces, along with the fields "input=horizontal", "tagger", and
+// "output=conllu" that request tagging in the UDPipe REST API. The response
+// must contain the tagged words in CoNLL-U format without changing how the
+// words are divided, either directly or, if it is a JSON response, in the
+// "result" property of a JSON object.
+type HTTPTagger struct {
+	// URL is the URL to make requests to, such as
+	// "http://localhost:8080/process". Any query parameters are sent in the
+	// request body along with the other fields.
+	URL string
+
+	// Params are any additional fields to send in each request, such as the
+	// "model" field that selects the language of the UDPipe REST service.
+	Params url.Values
+
+	// Client is the client used to make requests. If it is nil then
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// BatchSize is the greatest number of sentences sent in each request.
+	// Zero means 100.
+	BatchSize int
+
+	// Timeout is the longest that each request may take before it is
+	// cancelled and tagging fails. Zero means 30 seconds.
+	Timeout time.Duration
+
+	// UniversalTags has the same meaning as for CommandTagger.
+	UniversalTags bool
+}
+
+// Tag implements Tagger.
+func (t HTTPTagger) Tag(sentences [][]string) ([][]string, error) {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tagger URL: %w", err)
+	}
+	form := u.Query()
+	u.RawQuery = ""
+	for k, vs := range t.Params {
+		form[k] = vs
+	}
+	form.Set("input", "horizontal")
+	form.Set("tagger", "")
+	form.Set("output", "conllu")
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	timeout := taggerTimeout(t.Timeout)
+
+	return tagInBatches(sentences, t.BatchSize, t.UniversalTags, func(input string) (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		form.Set("data", input)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("tagger request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to read tagger response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("tagger request failed: %s: %s", resp.Status, bytes.TrimSpace(body))
+		}
+
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/json" {
+			var result struct {
+				Result string `json:"result"`
+			}
+			if err := json.Unmarshal(body, &result); err != nil {
+				return "", fmt.Errorf("invalid tagger response: %w", err)
+			}
+			return result.Result, nil
+		}
+		return string(body), nil
+	})
+}
+
+func taggerTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return defaultTaggerTimeout
+	}
+	return timeout
+}
+
+// tagInBatches divides the given sentences into batches of at most the
+// given size and tags each batch by calling the given function with the
+// batch in "horizontal" format, expecting it to return the tagged batch in
+// CoNLL-U format.
+func tagInBatches(sentences [][]string, batchSize int, universal bool, tag func(input string) (string, error)) ([][]string, error) {
+	if batchSize <= 0 {
+		batchSize = defaultTaggerBatchSize
+	}
+	ret := make([][]string, 0, len(sentences))
+	for start := 0; start < len(sentences); start += batchSize {
+		batch := sentences[start:min(start+batchSize, len(sentences))]
+
+		// The horizontal format can't represent an empty sentence, so we
+		// leave those out and give them no tags.
+		var input strings.Builder
+		nonEmpty := 0
+		for _, s := range batch {
+			if len(s) == 0 {
+				continue
+			}
+			for i, w := range s {
+				if i > 0 {
+					input.WriteByte(' ')
+				}
+				input.WriteString(horizontalWord(w))
+			}
+			input.WriteByte('\n')
+			nonEmpty++
+		}
+		if nonEmpty == 0 {
+			ret = append(ret, make([][]string, len(batch))...)
+			continue
+		}
+
+		output, err := tag(input.String())
+		if err != nil {
+			return nil, err
+		}
+		tags, err := parseCoNLLUTags(output, universal)
+		if err != nil {
+			return nil, err
+		}
+		if len(tags) != nonEmpty {
+			return nil, fmt.Errorf("tagger returned %d sentences, but was given %d", len(tags), nonEmpty)
+		}
+		for _, s := range batch {
+			if len(s) == 0 {
+				ret = append(ret, nil)
+				continue
+			}
+			ret = append(ret, tags[0])
+			tags = tags[1:]
+		}
+	}
+	return ret, nil
+}
+
+// horizontalWord returns the given word with any whitespace within it
+// replaced with underscores, since whitespace separates the words in the
+// "horizontal" format.
+func horizontalWord(w string) string {
+	if w == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, w)
+}
+
+// parseCoNLLUTags returns the tags of the words in each of the sentences in
+// the given CoNLL-U text, which are the language-specific tags if available
+// unless universal is set, and otherwise the Universal POS tags.
+//
+// A multiword token, such as Spanish "del" for "de el", is given the tag of
+// its first word, so that there is one tag for each of the words that were
+// given to the tagger.
+func parseCoNLLUTags(src string, universal bool) ([][]string, error) {
+	var ret [][]string
+	var tags []string
+	multiwordEnd := 0    // the ID of the last word of the current multiword token
+	multiwordTag := true // set while awaiting the first word of a multiword token
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			if len(tags) > 0 {
+				ret = append(ret, tags)
+				tags = nil
+			}
+			multiwordEnd = 0
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("invalid CoNLL-U on line %d: too few fields", i+1)
+		}
+		id := fields[0]
+		if strings.Contains(id, ".") {
+			// An empty node, which doesn't correspond to any word.
+			continue
+		}
+		if first, last, ok := strings.Cut(id, "-"); ok {
+			end, err := strconv.Atoi(last)
+			if _, err2 := strconv.Atoi(first); err != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid CoNLL-U on line %d: invalid ID %q", i+1, id)
+			}
+			multiwordEnd = end
+			multiwordTag = true
+			continue
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CoNLL-U on line %d: invalid ID %q", i+1, id)
+		}
+		if n <= multiwordEnd {
+			if !multiwordTag {
+				continue
+			}
+			multiwordTag = false
+		}
+
+		tag := fields[4]
+		if universal || tag == "_" {
+			tag = fields[3]
+		}
+		if tag == "_" {
+			tag = ""
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) > 0 {
+		ret = append(ret, tags)
+	}
+	return ret, nil
+}
@@ -0,0 +1,117 @@
+package ghal
+
+// Terminators is the punctuation that a brain recognizes as ending each kind
+// of sentence, given as the text of each punctuation word. A sentence ending
+// with a word that isn't any kind of terminator is treated as a statement
+// without terminating punctuation.
+//
+// The tokenizer tags terminating punctuation with the tag ".", but
+// terminators are recognized by their text alone so that punctuation from
+// other writing systems is recognized however it was tagged.
+type Terminators struct {
+	Statement   []string
+	Question    []string
+	Exclamation []string
+}
+
+// DefaultTerminators is the terminating punctuation that brains recognize
+// unless configured otherwise using SetTerminators, which is the punctuation
+// used in English. Period, QuestionMark, and ExclamationMark are the words
+// for these terminators.
+var DefaultTerminators = Terminators{
+	Statement:   []string{"."},
+	Question:    []string{"?"},
+	Exclamation: []string{"!"},
+}
+
+// CJKTerminators is like DefaultTerminators but also recognizes the
+// full-width punctuation used in Chinese, Japanese, and Korean text, along
+// with the ellipsis character.
+var CJKTerminators = Terminators{
+	Statement:   []string{".", "。", "｡", "…"},
+	Question:    []string{"?", "？"},
+	Exclamation: []string{"!", "！"},
+}
+
+// ArabicTerminators is like DefaultTerminators but also recognizes the
+// question mark used in Arabic, Persian, and Urdu text, and the full stop
+// used in Urdu.
+var ArabicTerminators = Terminators{
+	Statement:   []string{".", "۔"},
+	Question:    []string{"?", "؟"},
+	Exclamation: []string{"!"},
+}
+
+// IsStatement, IsQuestion, and IsExclamation return true if the given word
+// terminates a sentence of the corresponding kind.
+func (t Terminators) IsStatement(w Word) bool {
+	return containsString(t.Statement, w.Text)
+}
+
+func (t Terminators) IsQuestion(w Word) bool {
+	return containsString(t.Question, w.Text)
+}
+
+func (t Terminators) IsExclamation(w Word) bool {
+	return containsString(t.Exclamation, w.Text)
+}
+
+// TrimPeriod is like Sentence.TrimPeriod but removes any of the receiver's
+// statement terminators rather than only a period.
+func (t Terminators) TrimPeriod(s Sentence) Sentence {
+	switch {
+	case len(s) == 0:
+		return s
+	case t.IsStatement(s[len(s)-1]):
+		// As a special case, if the token right before the terminator is
+		// _also_ a terminator then we'll leave things as-is, assuming we've
+		// found an ellipsis.
+		if len(s) > 1 && t.IsStatement(s[len(s)-2]) {
+			return s
+		}
+		return s[:len(s)-1]
+	default:
+		return s
+	}
+}
+
+// SetTerminators configures the terminating punctuation that the brain
+// recognizes, which is saved and loaded along with the rest of the brain.
+// An overlay brain uses the terminators of its base unless they are set for
+// the overlay itself.
+//
+// SetTerminators panics if the brain has been frozen using Freeze.
+func (b *Brain) SetTerminators(t Terminators) {
+	b.lock()
+	defer b.mut.Unlock()
+	b.terminators = &t
+}
+
+// Terminators returns the terminating punctuation that the brain recognizes,
+// which is DefaultTerminators unless configured otherwise using
+// SetTerminators.
+func (b *Brain) Terminators() Terminators {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	return b.terms()
+}
+
+// terms is the main implementation of Terminators, which expects the caller
+// to already be holding at least a read lock.
+func (b *Brain) terms() Terminators {
+	switch {
+	case b.terminators != nil:
+		return *b.terminators
+	case b.base != nil:
+		return b.base.terms()
+	default:
+		return DefaultTerminators
+	}
+}
+
+// TrimPeriod is like Sentence.TrimPeriod but removes any of the brain's
+// statement terminators rather than only a period.
+func (b *Brain) TrimPeriod(s Sentence) Sentence {
+	return b.Terminators().TrimPeriod(s)
+}
@@ -0,0 +1,55 @@
+package ghal
+
+import (
+	"sort"
+)
+
+// WordFrequency returns the number of distinct chains the brain knows that
+// contain the given word, which serves as a measure of how often the word
+// appeared in the training material and how well-connected it is in the
+// brain's model.
+//
+// The result is zero if the brain does not know the given word at all.
+func (b *Brain) WordFrequency(w Word) int {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+	return b.wordFrequency(w)
+}
+
+// TopWords returns up to n of the words the brain knows, ranked by descending
+// frequency as defined by WordFrequency.
+//
+// If filter is non-nil, only words for which it returns true are considered.
+// For example, passing Word.IsNoun will return the topics the brain is most
+// likely to talk about.
+//
+// Words with equal frequency are returned in a stable order, so the result
+// is deterministic for a given brain.
+func (b *Brain) TopWords(n int, filter func(Word) bool) []Word {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	if n <= 0 {
+		return nil
+	}
+
+	var ret []Word
+	b.forEachWord(func(w Word) {
+		if filter == nil || filter(w) {
+			ret = append(ret, w)
+		}
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		fi, fj := b.wordFrequency(ret[i]), b.wordFrequency(ret[j])
+		if fi != fj {
+			return fi > fj
+		}
+		return wordLess(ret[i], ret[j])
+	})
+	if len(ret) > n {
+		ret = ret[:n]
+	}
+	return ret
+}
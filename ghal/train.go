@@ -0,0 +1,19 @@
+package ghal
+
+// TrainFromText parses the given text and teaches the resulting sentences to
+// the given brain, along with how the words in the text were capitalized,
+// returning the number of sentences learned. This is a convenience for
+// programs that embed a brain and want to teach it some text without
+// managing a Parser of their own.
+//
+// TrainFromText panics if the brain has been frozen using Freeze.
+func TrainFromText(brain *Brain, text string) (int, error) {
+	p := NewParser()
+	ss, err := p.ParseText(text)
+	if err != nil {
+		return 0, err
+	}
+	brain.AddSentences(ss)
+	brain.AddCaseCounts(p.TakeCaseCounts())
+	return len(ss), nil
+}
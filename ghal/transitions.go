@@ -0,0 +1,138 @@
+package ghal
+
+// transitionCounts records how many times each word has followed or preceded
+// each chain, for a brain's wordsAfter or wordsBefore sets. To save memory,
+// only the transitions seen more than once are recorded, and each word in
+// the corresponding set that isn't recorded here has been seen only once.
+type transitionCounts map[chain]map[Word]int
+
+// count returns the number of times the given word has followed or preceded
+// the given chain, which must be in the corresponding set.
+func (tc transitionCounts) count(c chain, w Word) int {
+	if n := tc[c][w]; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// add records that the given word, which must already be in the
+// corresponding set, has followed or preceded the given chain n more times.
+func (tc transitionCounts) add(c chain, w Word, n int) {
+	m := tc[c]
+	if m == nil {
+		m = make(map[Word]int)
+		tc[c] = m
+	}
+	m[w] = tc.count(c, w) + n
+}
+
+// remove records that the given word, which must be in the corresponding
+// set, has followed or preceded the given chain one fewer time, returning
+// the number of times that remain. If none remain, the caller must remove
+// the word from the set.
+func (tc transitionCounts) remove(c chain, w Word) int {
+	n := tc.count(c, w) - 1
+	switch {
+	case n > 1:
+		tc[c][w] = n
+	case n == 1:
+		delete(tc[c], w)
+		if len(tc[c]) == 0 {
+			delete(tc, c)
+		}
+	}
+	return n
+}
+
+// removeWords removes the counts for the given words from all chains.
+func (tc transitionCounts) removeWords(ws WordSet) {
+	for c, m := range tc {
+		removeCounts(m, ws)
+		if len(m) == 0 {
+			delete(tc, c)
+		}
+	}
+}
+
+func removeCounts(m map[Word]int, ws WordSet) {
+	for w := range m {
+		if ws.Has(w) {
+			delete(m, w)
+		}
+	}
+}
+
+// chainCounts records how many times each chain has started or ended a
+// sentence, for a brain's startChains or endChains sets. Like
+// transitionCounts, only the chains seen more than once are recorded.
+type chainCounts map[chain]int
+
+// count returns the number of times the given chain, which must be in the
+// corresponding set, has started or ended a sentence.
+func (cc chainCounts) count(c chain) int {
+	if n := cc[c]; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// add records that the given chain, which must already be in the
+// corresponding set, has started or ended a sentence n more times.
+func (cc chainCounts) add(c chain, n int) {
+	cc[c] = cc.count(c) + n
+}
+
+// remove is like transitionCounts.remove, but for chains.
+func (cc chainCounts) remove(c chain) int {
+	n := cc.count(c) - 1
+	if n > 1 {
+		cc[c] = n
+	} else {
+		delete(cc, c)
+	}
+	return n
+}
+
+// addTransitions records that the given word has followed or preceded the
+// given chain n more times, in the given set of transitions and its counts.
+// The caller must hold the write lock on the brain they belong to.
+func addTransitions(sets map[chain]WordSet, counts transitionCounts, c chain, w Word, n int) {
+	ws, ok := sets[c]
+	if !ok {
+		ws = make(WordSet)
+		sets[c] = ws
+	}
+	if !ws.Has(w) {
+		ws.Add(w)
+		n--
+	}
+	if n > 0 {
+		counts.add(c, w, n)
+	}
+}
+
+// afterCount and beforeCount return the number of times the given word has
+// followed and preceded the given chain respectively, in both the brain's own
+// layer and in its base, or zero if it never has. The caller must hold at
+// least a read lock on the brain.
+func (b *Brain) afterCount(c chain, w Word) int {
+	n := 0
+	if b.wordsAfter[c].Has(w) {
+		n = b.afterCounts.count(c, w)
+	}
+	if b.base != nil {
+		n += b.base.afterCount(c, w)
+	}
+	return n
+}
+
+func (b *Brain) beforeCount(c chain, w Word) int {
+	n := 0
+	if b.wordsBefore[c].Has(w) {
+		n = b.beforeCounts.count(c, w)
+	}
+	if b.base != nil {
+		n += b.base.beforeCount(c, w)
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+package ghal
+
+import (
+	"unicode/utf8"
+)
+
+// trimToLength attempts to shorten the given sentence so that it has no more
+// than maxWords words, by cutting it at the latest point where the brain
+// knows a sentence can end or, failing that, at the latest clause boundary.
+// The result always still contains the given keyword.
+//
+// Returns the sentence unchanged if it's already short enough, or nil if
+// there is no suitable place to cut it. The caller must hold at least a read
+// lock on the brain.
+func (b *Brain) trimToLength(s Sentence, maxWords int, keyword Word) Sentence {
+	if maxWords <= 0 || len(s) <= maxWords {
+		return s
+	}
+
+	// keywordEnd is the smallest length we can trim to while still
+	// retaining the keyword.
+	keywordEnd := -1
+	for i, w := range s {
+		if w == keyword {
+			keywordEnd = i + 1
+			break
+		}
+	}
+	if keywordEnd < 0 || keywordEnd > maxWords {
+		return nil
+	}
+
+	// Our first preference is to find a chain that the brain has seen end a
+	// sentence, since that'll produce the most natural result.
+	for end := maxWords; end >= b.order && end >= keywordEnd; end-- {
+		if b.isEndChain(makeChain(s[end-b.order : end])) {
+			debugf("trimmed %q at end chain after %d words", s, end)
+			return s[:end]
+		}
+	}
+
+	// Otherwise we'll look for a clause boundary, like a comma or a
+	// conjunction, and cut just before it. In this case we'll also
+	// transplant any terminating punctuation from the original sentence.
+	var terminator Sentence
+	if last := s[len(s)-1]; last.Tag == "." {
+		terminator = Sentence{last}
+	}
+	limit := maxWords - len(terminator)
+	for end := limit; end > 0 && end >= keywordEnd; end-- {
+		if end >= len(s) {
+			continue
+		}
+		if next := s[end]; next.Tag == "," || next.Tag == ":" || next.Tag == "CC" {
+			debugf("trimmed %q at clause boundary after %d words", s, end)
+			ret := make(Sentence, 0, end+len(terminator))
+			ret = append(ret, s[:end]...)
+			return append(ret, terminator...)
+		}
+	}
+
+	debugf("no suitable place to trim %q to %d words", s, maxWords)
+	return nil
+}
+
+// trimToChars is like trimToLength but shortens the given sentence so that
+// its string representation, as returned by String, has no more than
+// maxChars characters. The caller must hold at least a read lock on the
+// brain.
+func (b *Brain) trimToChars(s Sentence, maxChars int, keyword Word) Sentence {
+	if maxChars <= 0 || s.charLen() <= maxChars {
+		return s
+	}
+	// We'll find the most words that can fit, and then trim to that many
+	// in the same way as for MaxWords. The result of trimToLength can only
+	// be shorter than the words we measured, because it either ends at one
+	// of those words or replaces the word after its end with terminating
+	// punctuation, which is no longer than a clause boundary.
+	maxWords := 0
+	for end := 1; end <= len(s); end++ {
+		if s[:end].charLen() > maxChars {
+			break
+		}
+		maxWords = end
+	}
+	if maxWords == 0 {
+		return nil
+	}
+	return b.trimToLength(s, maxWords, keyword)
+}
+
+// FitWithin returns the longest prefix of the sentence whose string
+// representation, as returned by String, has no more than n characters,
+// which is useful for posting to platforms that limit the length of each
+// message. The sentence is cut only between words, preferring to cut at a
+// clause boundary, like a comma or a conjunction, if there is one, and any
+// terminating punctuation is kept.
+//
+// Returns the sentence unchanged if it already fits or is empty, or nil if
+// not even its first word fits.
+func (s Sentence) FitWithin(n int) Sentence {
+	if len(s) == 0 || s.charLen() <= n {
+		return s
+	}
+
+	var terminator Sentence
+	if last := s[len(s)-1]; last.Tag == "." {
+		terminator = Sentence{last}
+	}
+	fits := func(end int) bool {
+		ret := make(Sentence, 0, end+len(terminator))
+		ret = append(ret, s[:end]...)
+		return append(ret, terminator...).charLen() <= n
+	}
+
+	longest := 0
+	for end := 1; end < len(s) && fits(end); end++ {
+		longest = end
+	}
+	if longest == 0 {
+		return nil
+	}
+	end := longest
+	for clause := longest; clause > 0; clause-- {
+		if next := s[clause]; next.Tag == "," || next.Tag == ":" || next.Tag == "CC" {
+			end = clause
+			break
+		}
+	}
+	ret := make(Sentence, 0, end+len(terminator))
+	ret = append(ret, s[:end]...)
+	// We don't want to end with a dangling comma or similar, which could
+	// be the last word if we didn't find a clause boundary.
+	for len(ret) > 1 && ret[len(ret)-1].IsPunctuation() {
+		ret = ret[:len(ret)-1]
+	}
+	return append(ret, terminator...)
+}
+
+// charLen returns the number of characters in the sentence's string
+// representation.
+func (s Sentence) charLen() int {
+	return utf8.RuneCountInString(s.String())
+}
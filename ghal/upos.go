@@ -0,0 +1,132 @@
+package ghal
+
+// UPOS is a coarse part-of-speech tag from the Universal Dependencies
+// project's universal tag set, which is the same regardless of language and
+// is much smaller than the Penn Treebank tag set the default parser uses.
+type UPOS string
+
+// The Universal POS tags.
+const (
+	ADJ   UPOS = "ADJ"   // adjective
+	ADP   UPOS = "ADP"   // adposition
+	ADV   UPOS = "ADV"   // adverb
+	AUX   UPOS = "AUX"   // auxiliary
+	CCONJ UPOS = "CCONJ" // coordinating conjunction
+	DET   UPOS = "DET"   // determiner
+	INTJ  UPOS = "INTJ"  // interjection
+	NOUN  UPOS = "NOUN"  // noun
+	NUM   UPOS = "NUM"   // numeral
+	PART  UPOS = "PART"  // particle
+	PRON  UPOS = "PRON"  // pronoun
+	PROPN UPOS = "PROPN" // proper noun
+	PUNCT UPOS = "PUNCT" // punctuation
+	SCONJ UPOS = "SCONJ" // subordinating conjunction
+	SYM   UPOS = "SYM"   // symbol
+	VERB  UPOS = "VERB"  // verb
+	X     UPOS = "X"     // other
+)
+
+// pennTreebankUPOS maps each of the Penn Treebank tags that the default
+// parser assigns to words to the corresponding Universal POS tag, following
+// the Universal Dependencies project's conversion table.
+var pennTreebankUPOS = map[string]UPOS{
+	"CC":   CCONJ,
+	"CD":   NUM,
+	"DT":   DET,
+	"EX":   PRON,
+	"FW":   X,
+	"IN":   ADP,
+	"JJ":   ADJ,
+	"JJR":  ADJ,
+	"JJS":  ADJ,
+	"LS":   X,
+	"MD":   AUX,
+	"NN":   NOUN,
+	"NNS":  NOUN,
+	"NNP":  PROPN,
+	"NNPS": PROPN,
+	"PDT":  DET,
+	"POS":  PART,
+	"PRP":  PRON,
+	"PRP$": PRON,
+	"RB":   ADV,
+	"RBR":  ADV,
+	"RBS":  ADV,
+	"RP":   ADP,
+	"SYM":  SYM,
+	"TO":   PART,
+	"UH":   INTJ,
+	"VB":   VERB,
+	"VBD":  VERB,
+	"VBG":  VERB,
+	"VBN":  VERB,
+	"VBP":  VERB,
+	"VBZ":  VERB,
+	"WDT":  DET,
+	"WP":   PRON,
+	"WP$":  PRON,
+	"WRB":  ADV,
+	"$":    SYM,
+	"#":    SYM,
+	".":    PUNCT,
+	",":    PUNCT,
+	":":    PUNCT,
+	"``":   PUNCT,
+	"''":   PUNCT,
+	"(":    PUNCT,
+	")":    PUNCT,
+	"HYPH": PUNCT,
+	"NFP":  PUNCT,
+	"AFX":  ADJ,
+	"ADD":  X,
+	"GW":   X,
+	"XX":   X,
+
+	EmojiTag: SYM,
+}
+
+// universalTags is the set of all of the Universal POS tags, used to
+// recognize words that were tagged with them directly.
+var universalTags = map[UPOS]struct{}{
+	ADJ: {}, ADP: {}, ADV: {}, AUX: {}, CCONJ: {}, DET: {}, INTJ: {}, NOUN: {},
+	NUM: {}, PART: {}, PRON: {}, PROPN: {}, PUNCT: {}, SCONJ: {}, SYM: {},
+	VERB: {}, X: {},
+}
+
+// PennTreebankToUPOS returns the Universal POS tag corresponding to the
+// given Penn Treebank tag, as assigned by the default parser. The second
+// return value is false if the tag isn't a Penn Treebank tag.
+//
+// A parser with a different tag set should assign Universal POS tags
+// directly, since Word.UPOS can't interpret any other tags.
+func PennTreebankToUPOS(tag string) (UPOS, bool) {
+	pos, ok := pennTreebankUPOS[tag]
+	return pos, ok
+}
+
+// UPOS returns the Universal POS tag corresponding to the word's tag. Tags
+// that are already Universal POS tags, as assigned by a parser for a
+// language other than English, are returned as-is, and tags that are
+// neither Universal POS tags nor Penn Treebank tags, as recognized by
+// PennTreebankToUPOS, are reported as X.
+func (w Word) UPOS() UPOS {
+	if pos, ok := pennTreebankUPOS[w.Tag]; ok {
+		return pos
+	}
+	if _, ok := universalTags[UPOS(w.Tag)]; ok {
+		return UPOS(w.Tag)
+	}
+	return X
+}
+
+// IsContentWord returns true if the word is a noun, proper noun, verb,
+// adjective, or adverb, as opposed to a "stopword" like a determiner,
+// pronoun, or preposition.
+func (w Word) IsContentWord() bool {
+	switch w.UPOS() {
+	case NOUN, PROPN, VERB, ADJ, ADV:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,85 @@
+package ghal
+
+import (
+	"sort"
+)
+
+// SetWordMeta records a metadata value for the given word under the given
+// key, replacing any existing value for that key.
+//
+// Metadata is arbitrary information about a word that isn't needed for
+// sentence generation but may be useful to callers, such as the language a
+// word belongs to or the kind of entity it names. The brain doesn't interpret
+// metadata at all, but it is saved and loaded along with the rest of the
+// brain. Metadata can be recorded even for words the brain doesn't otherwise
+// know.
+//
+// SetWordMeta panics if the brain has been frozen using Freeze.
+func (b *Brain) SetWordMeta(w Word, key, value string) {
+	b.lock()
+	defer b.mut.Unlock()
+	b.setWordMeta(b.strs.internWord(w), b.strs.intern(key), value)
+}
+
+// setWordMeta is the main implementation of SetWordMeta, which expects the
+// caller to already be holding the write lock.
+func (b *Brain) setWordMeta(w Word, key, value string) {
+	meta, exists := b.wordMeta[w]
+	if !exists {
+		meta = make(map[string]string, 1)
+		b.wordMeta[w] = meta
+	}
+	meta[key] = value
+}
+
+// DeleteWordMeta removes the metadata value for the given word under the
+// given key, if any.
+//
+// DeleteWordMeta panics if the brain has been frozen using Freeze.
+func (b *Brain) DeleteWordMeta(w Word, key string) {
+	b.lock()
+	defer b.mut.Unlock()
+
+	meta := b.wordMeta[w]
+	delete(meta, key)
+	if len(meta) == 0 {
+		delete(b.wordMeta, w)
+	}
+}
+
+// WordMeta returns the metadata value recorded for the given word under the
+// given key. The second return value is false if there is no such value.
+func (b *Brain) WordMeta(w Word, key string) (string, bool) {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	v, ok := b.wordMeta[w][key]
+	if !ok && b.base != nil {
+		return b.base.WordMeta(w, key)
+	}
+	return v, ok
+}
+
+// WordMetaKeys returns the keys of all of the metadata values recorded for
+// the given word, in sorted order.
+func (b *Brain) WordMetaKeys(w Word) []string {
+	if b.rlock() {
+		defer b.mut.RUnlock()
+	}
+
+	meta := b.wordMeta[w]
+	var ret []string
+	for k := range meta {
+		ret = append(ret, k)
+	}
+	if b.base != nil {
+		for _, k := range b.base.WordMetaKeys(w) {
+			if _, overridden := meta[k]; !overridden {
+				ret = append(ret, k)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
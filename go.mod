@@ -1,21 +1,31 @@
 module github.com/apparentlymart/gopherhal
 
+go 1.23
+
 require (
-	github.com/PuerkitoBio/goquery v1.5.0 // indirect
 	github.com/c-bata/go-prompt v0.2.3
 	github.com/davecgh/go-spew v1.1.1
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/mmcdole/gofeed v1.0.0-beta2
+	github.com/spf13/pflag v1.0.3
+	github.com/vmihailenco/msgpack v4.0.1+incompatible
+	golang.org/x/net v0.0.0-20181207154023-610586996380
+	golang.org/x/text v0.3.0
+	gopkg.in/jdkato/prose.v2 v2.0.0-20180825173540-767a23049b9e
+)
+
+require (
+	github.com/PuerkitoBio/goquery v1.5.0 // indirect
+	github.com/andybalholm/cascadia v1.0.0 // indirect
 	github.com/deckarep/golang-set v1.7.1 // indirect
 	github.com/mattn/go-runewidth v0.0.3 // indirect
 	github.com/mingrammer/commonregex v1.0.0 // indirect
-	github.com/mmcdole/gofeed v1.0.0-beta2
 	github.com/mmcdole/goxpp v0.0.0-20181012175147-0068e33feabf // indirect
 	github.com/montanaflynn/stats v0.0.0-20180911141734-db72e6cae808 // indirect
 	github.com/pkg/term v0.0.0-20181116001808-27bbf2edb814 // indirect
-	github.com/spf13/pflag v1.0.3
-	github.com/vmihailenco/msgpack v4.0.1+incompatible
-	golang.org/x/net v0.0.0-20181207154023-610586996380
-	golang.org/x/text v0.3.0
+	golang.org/x/exp v0.0.0-20180321215751-8460e604b9de // indirect
+	golang.org/x/sys v0.0.0-20191005200804-aed5e4c7ecf9 // indirect
+	golang.org/x/tools v0.0.0-20180525024113-a5b4c53f6e8b // indirect
 	gonum.org/v1/gonum v0.0.0-20181208210948-435185761cc9 // indirect
-	gopkg.in/jdkato/prose.v2 v2.0.0-20180825173540-767a23049b9e
 	gopkg.in/neurosnap/sentences.v1 v1.0.6 // indirect
 )
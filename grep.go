@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// grepAttempts is the number of times the "grep" command tries to construct
+// each example sentence it wants, to find distinct examples.
+const grepAttempts = 5
+
+// grep implements the "grep" command, which lists the words the brain knows
+// whose text contains the given pattern, or matches it as a regular
+// expression if useRegexp is set, along with some example sentences the
+// brain can construct using each one.
+func grep(brainFile string, pattern string, useRegexp bool, examples int) int {
+	var re *regexp.Regexp
+	if useRegexp {
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid pattern: %s\n", err)
+			return 1
+		}
+	}
+
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	var words []ghal.Word
+	if re != nil {
+		words = brain.SearchRegexp(re)
+	} else {
+		words = brain.SearchText(pattern)
+	}
+	if len(words) == 0 {
+		fmt.Fprintf(os.Stderr, "The brain doesn't know any words matching %q\n", pattern)
+		return 1
+	}
+	summary := grepSummary{
+		Pattern: pattern,
+		Words:   make([]grepMatch, 0, len(words)),
+	}
+	for _, w := range words {
+		match := grepMatch{
+			Word:      w,
+			Frequency: brain.WordFrequency(w),
+			Taboo:     brain.IsTaboo(w),
+			Examples:  []string{},
+		}
+		if !match.Taboo {
+			match.Examples = grepExamples(brain, w, examples)
+		}
+		summary.Words = append(summary.Words, match)
+	}
+	if jsonOutput {
+		return printJSON(summary)
+	}
+
+	for _, match := range summary.Words {
+		fmt.Printf("%8d  %s/%s\n", match.Frequency, match.Word.Text, match.Word.Tag)
+		if match.Taboo {
+			// The brain won't construct sentences containing taboo words.
+			fmt.Printf("          (taboo)\n")
+			continue
+		}
+		for _, text := range match.Examples {
+			fmt.Printf("          %s\n", text)
+		}
+	}
+	return 0
+}
+
+// grepExamples returns up to the given number of distinct example sentences
+// that the given brain can construct using the given word, as they should be
+// displayed.
+func grepExamples(brain *ghal.Brain, w ghal.Word, examples int) []string {
+	// Construction is random, so we make a few extra attempts to find
+	// distinct examples.
+	ret := []string{}
+	seen := make(map[string]bool)
+	for i := 0; i < examples*grepAttempts && len(seen) < examples; i++ {
+		s := brain.MakeSentenceWithKeyword(w)
+		if len(s) == 0 {
+			break
+		}
+		text := displayed(brain, s).String()
+		if seen[text] {
+			continue
+		}
+		seen[text] = true
+		ret = append(ret, text)
+	}
+	return ret
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+	"github.com/apparentlymart/gopherhal/trainhal"
+)
+
+// jsonOutput is whether the commands that print a summary or report print
+// it to stdout as JSON instead of text, for use by scripts, as set by the
+// --json flag. Progress messages are still logged to stderr as usual.
+var jsonOutput bool
+
+// jsonCommands are the commands that can print their results as JSON, which
+// are the only ones that accept the --json flag.
+var jsonCommands = map[string]bool{
+	"analyze": true,
+	"compare": true,
+	"diff":    true,
+	"eval":    true,
+	"grep":    true,
+	"purge":   true,
+	"stats":   true,
+	"story":   true,
+	"train":   true,
+}
+
+// printJSON writes the given value to stdout as indented JSON, returning the
+// exit status for the command.
+func printJSON(v interface{}) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write JSON: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// jsonStats is the JSON representation of ghal.BrainStats.
+type jsonStats struct {
+	Words              int     `json:"words"`
+	Chains             int     `json:"chains"`
+	Sources            int     `json:"sources"`
+	MemoryEstimate     int64   `json:"memory_estimate"`
+	SentencesPerMinute float64 `json:"sentences_per_minute"`
+	ChainsPerMinute    float64 `json:"chains_per_minute"`
+}
+
+func newJSONStats(brain *ghal.Brain) jsonStats {
+	stats := brain.Stats()
+	return jsonStats{
+		Words:              stats.Words,
+		Chains:             stats.Chains,
+		Sources:            stats.Sources,
+		MemoryEstimate:     stats.MemoryEstimate,
+		SentencesPerMinute: stats.Learning.SentencesPerMinute,
+		ChainsPerMinute:    stats.Learning.ChainsPerMinute,
+	}
+}
+
+// trainSummary is the output of the "train" command when --json is set.
+type trainSummary struct {
+	Brain   string               `json:"brain"`
+	Sources []trainSourceSummary `json:"sources"`
+	Stats   jsonStats            `json:"stats"`
+}
+
+// trainSourceSummary describes what was learned from one of the files or
+// directories given to the "train" command. Learned is false if it was
+// skipped because the brain had already learned it. Sentences is the total
+// number of sentences the brain has learned from it, as recorded in its
+// training manifest.
+type trainSourceSummary struct {
+	Name      string `json:"name"`
+	Learned   bool   `json:"learned"`
+	Sentences int    `json:"sentences"`
+}
+
+// evalSummary is the output of the "eval" command when --json is set.
+type evalSummary struct {
+	Sentences         int     `json:"sentences"`
+	Replies           int     `json:"replies"`
+	ReplyRate         float64 `json:"reply_rate"`
+	AverageCandidates float64 `json:"average_candidates"`
+	DuplicateReplies  int     `json:"duplicate_replies"`
+	DuplicateRate     float64 `json:"duplicate_rate"`
+	Perplexity        float64 `json:"perplexity"`
+	PerplexityWords   int     `json:"perplexity_words"`
+}
+
+// diffSummary is the output of the "diff" command when --json is set, with
+// the same limits on the number of samples as the text output but with all
+// of the words and chains listed.
+type diffSummary struct {
+	Old            string          `json:"old"`
+	New            string          `json:"new"`
+	Identical      bool            `json:"identical"`
+	WordsAdded     []ghal.Word     `json:"words_added"`
+	WordsRemoved   []ghal.Word     `json:"words_removed"`
+	ChainsAdded    []ghal.Sentence `json:"chains_added"`
+	ChainsRemoved  []ghal.Sentence `json:"chains_removed"`
+	SamplesAdded   []string        `json:"samples_added"`
+	SamplesRemoved []string        `json:"samples_removed"`
+}
+
+// compareSummary is the output of the "compare" command when --json is set.
+type compareSummary struct {
+	Brains [2]string           `json:"brains"`
+	Inputs []compareInput      `json:"inputs"`
+	Same   int                 `json:"identical_replies"`
+	Stats  [2]compareStatsJSON `json:"stats"`
+}
+
+// compareInput is one line of the input to the "compare" command and each
+// brain's reply to it, which is empty if it couldn't reply.
+type compareInput struct {
+	Input   string    `json:"input"`
+	Replies [2]string `json:"replies"`
+}
+
+// compareStatsJSON is the JSON representation of compareStats, with the
+// averages that the text output reports rather than the totals.
+type compareStatsJSON struct {
+	Replies           int     `json:"replies"`
+	ReplyRate         float64 `json:"reply_rate"`
+	AverageWords      float64 `json:"average_words"`
+	AverageScore      float64 `json:"average_score"`
+	AverageCandidates float64 `json:"average_candidates"`
+}
+
+func (s compareStats) json(lines int) compareStatsJSON {
+	ret := compareStatsJSON{
+		Replies:   s.replies,
+		ReplyRate: float64(s.replies) / float64(lines),
+	}
+	if s.replies > 0 {
+		ret.AverageWords = float64(s.words) / float64(s.replies)
+		ret.AverageScore = float64(s.score) / float64(s.replies)
+		ret.AverageCandidates = float64(s.candidates) / float64(s.replies)
+	}
+	return ret
+}
+
+// storySummary is the output of the "story" command when --json is set.
+type storySummary struct {
+	Keyword   ghal.Word `json:"keyword"`
+	Sentences []string  `json:"sentences"`
+}
+
+// analyzeSummary is the output of the "analyze" command when --json is set.
+// The sentence lengths are in words, including punctuation, and are zero if
+// there are no sentences.
+type analyzeSummary struct {
+	Sources            int            `json:"sources"`
+	Sentences          int            `json:"sentences"`
+	DuplicateSentences int            `json:"duplicate_sentences"`
+	DuplicateRatio     float64        `json:"duplicate_ratio"`
+	Words              int            `json:"words"`
+	Vocabulary         int            `json:"vocabulary"`
+	MedianLength       int            `json:"median_length"`
+	Length90th         int            `json:"length_90th_percentile"`
+	LongestLength      int            `json:"longest_length"`
+	LengthCounts       []int          `json:"length_counts"`
+	Languages          map[string]int `json:"languages"`
+	TopTokens          []tokenCount   `json:"top_tokens"`
+}
+
+type tokenCount struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+func newAnalyzeSummary(stats trainhal.CorpusStats) analyzeSummary {
+	ret := analyzeSummary{
+		Sources:            stats.Sources,
+		Sentences:          stats.Sentences,
+		DuplicateSentences: stats.DuplicateSentences,
+		DuplicateRatio:     stats.DuplicateRatio(),
+		Words:              stats.Words,
+		Vocabulary:         stats.Vocabulary,
+		LengthCounts:       stats.LengthCounts,
+		Languages:          make(map[string]int, len(stats.Languages)),
+		TopTokens:          make([]tokenCount, len(stats.TopTokens)),
+	}
+	if stats.Sentences > 0 {
+		ret.MedianLength = stats.LengthPercentile(0.5)
+		ret.Length90th = stats.LengthPercentile(0.9)
+		ret.LongestLength = len(stats.LengthCounts) - 1
+	}
+	for lang, n := range stats.Languages {
+		if lang == "" {
+			lang = "unknown"
+		}
+		ret.Languages[lang] = n
+	}
+	for i, tc := range stats.TopTokens {
+		ret.TopTokens[i] = tokenCount{
+			Text:  tc.Text,
+			Count: tc.Count,
+		}
+	}
+	return ret
+}
+
+// grepSummary is the output of the "grep" command when --json is set.
+type grepSummary struct {
+	Pattern string      `json:"pattern"`
+	Words   []grepMatch `json:"words"`
+}
+
+// grepMatch is one of the words listed by the "grep" command, along with the
+// example sentences constructed using it, which are omitted if it's taboo.
+type grepMatch struct {
+	Word      ghal.Word `json:"word"`
+	Frequency int       `json:"frequency"`
+	Taboo     bool      `json:"taboo"`
+	Examples  []string  `json:"examples"`
+}
+
+// purgeSummary is the output of the "purge" command when --json is set,
+// listing the words that were removed, or that would have been removed if
+// DryRun is set.
+type purgeSummary struct {
+	Pattern string      `json:"pattern"`
+	DryRun  bool        `json:"dry_run"`
+	Words   []purgeWord `json:"words"`
+
+	// Swaps and Greetings are the numbers of swaps and greetings that were
+	// removed, or would have been, because they matched the pattern.
+	Swaps     int `json:"swaps"`
+	Greetings int `json:"greetings"`
+}
+
+// purgeWord is one of the words removed by the "purge" command, along with
+// the number of chains it appeared in before it was removed.
+type purgeWord struct {
+	Word      ghal.Word `json:"word"`
+	Frequency int       `json:"frequency"`
+}
+
+// chainSentences returns the words of each of the given chains.
+func chainSentences(cs []ghal.Chain) []ghal.Sentence {
+	ret := make([]ghal.Sentence, len(cs))
+	for i, c := range cs {
+		ret[i] = c.Words()
+	}
+	return ret
+}
+
+// sentenceTexts returns the text of each of the given sentences.
+func sentenceTexts(ss []ghal.Sentence) []string {
+	ret := make([]string, len(ss))
+	for i, s := range ss {
+		ret[i] = s.String()
+	}
+	return ret
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// stdinSource is the source name recorded for sentences learned from the
+// standard input stream by the "learn" command.
+const stdinSource = "stdin"
+
+// learnSaveInterval is how often the "learn" command saves the brain while
+// it is receiving new sentences.
+const learnSaveInterval = time.Minute
+
+// learnSpikeAlert is how the "learn" command recognizes an abnormally fast
+// rate of learning, which it reports as a warning since it may be someone
+// attempting to flood the brain with junk.
+var learnSpikeAlert = ghal.LearningAlert{
+	Ratio:        10,
+	MinSentences: 100,
+}
+
+// learnStdin learns each line read from stdin as it arrives, until stdin is
+// closed or the program is interrupted, periodically saving the brain.
+func learnStdin(brainFile string) int {
+	brain, err := loadBrainFile(brainFile)
+	if os.IsNotExist(err) {
+		log.Printf("Starting with a new, empty brain")
+		brain = newBrain()
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+	alert := learnSpikeAlert
+	alert.Func = func(spike ghal.LearningSpike) {
+		log.Printf("Warning: learned %d sentences (%d new chains) in the minute from %s, compared to %.1f per minute in the last hour", spike.Sentences, spike.Chains, spike.Time.Format(time.Kitchen), spike.Baseline)
+	}
+	brain.SetLearningAlert(alert)
+
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		br := bufio.NewReader(os.Stdin)
+		for {
+			line, err := br.ReadString('\n')
+			if len(line) > 0 {
+				lines <- line
+			}
+			if err != nil {
+				if err != io.EOF {
+					readErr <- err
+				}
+				return
+			}
+		}
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(learnSaveInterval)
+	defer ticker.Stop()
+
+	learned, unsaved := 0, false
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				if unsaved {
+					safeSaveBrain(brain, brainFile)
+				}
+				log.Printf("Learned %d sentences", learned)
+				select {
+				case err := <-readErr:
+					fmt.Fprintf(os.Stderr, "Failed to read input: %s\n", err)
+					return 1
+				default:
+					return 0
+				}
+			}
+			sentences, err := parseChatText(line)
+			if err != nil {
+				log.Printf("Failed to parse %q: %s", line, err)
+				continue
+			}
+			if len(sentences) == 0 {
+				continue
+			}
+			brain.AddSentencesFrom(sentences, stdinSource)
+			learned += len(sentences)
+			unsaved = true
+		case <-ticker.C:
+			if unsaved {
+				safeSaveBrain(brain, brainFile)
+				warnMemoryBudget(brain)
+				rate := brain.LearningRate()
+				log.Printf("Learning %.1f sentences and %.1f new chains per minute", rate.SentencesPerMinute, rate.ChainsPerMinute)
+				unsaved = false
+			}
+		case <-interrupt:
+			if unsaved {
+				safeSaveBrain(brain, brainFile)
+			}
+			log.Printf("Learned %d sentences", learned)
+			return 0
+		}
+	}
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
-	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/apparentlymart/gopherhal/ghal"
 	"github.com/apparentlymart/gopherhal/trainhal"
@@ -13,58 +23,530 @@ import (
 	"github.com/spf13/pflag"
 )
 
+// chatSource is the source name recorded for sentences learned from chat.
+const chatSource = "chat"
+
+// minTrainedChains is the number of chains below which the chat command
+// explains that the brain needs training when it has nothing to say, rather
+// than just saying that it's speechless.
+const minTrainedChains = 100
+
+// noveltyBonus is the number of points awarded by the optional novelty
+// scorers, like alliteration and rhyme, which is chosen to be large enough
+// to outweigh a few matching keywords.
+const noveltyBonus = 10
+
+// responseKeywords is the number of keywords chosen from responses to
+// similar messages when --respond is set.
+const responseKeywords = 2
+
+// rakePhrases is the number of phrases the RAKE keyword extractor selects
+// keywords from.
+const rakePhrases = 3
+
+// tfidfKeywords is the number of keywords the TF-IDF keyword extractor
+// selects.
+const tfidfKeywords = 3
+
+// snapshotPolicy is the policy for keeping previous versions of the brain
+// file each time it is saved, as set by the --snapshots and --snapshot-age
+// flags.
+var snapshotPolicy ghal.SnapshotPolicy
+
+// segmentation is how text is divided into words when parsing, as set by the
+// --segmentation flag.
+var segmentation ghal.Segmentation
+
+// numbers is what to do with numbers in text when parsing, as set by the
+// --numbers flag.
+var numbers ghal.NumberHandling
+
+// tagger is the external tagger used in place of the built-in one when
+// parsing, as set by the --tagger-command or --tagger-url flags, or nil to
+// use the built-in one.
+var tagger ghal.Tagger
+
+// brainFilter is the filter set on every brain that is loaded or created,
+// as set by the --blocklist flag, or nil for no filter.
+var brainFilter func(ghal.Sentence) bool
+
+// brainOrder is the number of words in each chain of a newly-created
+// brain, as set by the --order flag.
+var brainOrder int
+
+// waitForLock is whether commands that save a brain wait for any other
+// process using it to finish, rather than failing, as set by the --wait flag.
+var waitForLock bool
+
+// brainLocks are the locks held on the brain files that the command saves,
+// which are kept here so that they remain held until the process exits.
+var brainLocks []*ghal.FileLock
+
+// caseThreshold is the threshold for capitalizing words in the sentences
+// the bot says, as set by the --case-threshold flag.
+var caseThreshold float64
+
 var why = ghal.MakeWord("WRB", "why")
 var because = ghal.MakeWord("IN", "because")
 
 func main() {
-	brainFile := pflag.String("brain", "gopherhal.brain", "file to use to load/save the bot's brain")
+	brainFiles := pflag.StringArray("brain", []string{"gopherhal.brain"}, "file to use to load/save the bot's brain; give twice for converse")
 	debug := pflag.Bool("debug", false, "show verbose word tagging during chat")
+	jsonFlag := pflag.Bool("json", false, "for train, eval, diff, compare, story, analyze, grep, purge, and stats, print the results to stdout as JSON for use by scripts")
+	debugJSONFlag := pflag.Bool("debug-json", false, "write a line of JSON to stderr describing how each chat reply was chosen")
+	noColor := pflag.Bool("no-color", false, "don't color chat output, which is also disabled by setting NO_COLOR")
+	reviewLearning := pflag.Bool("review", false, "stage sentences learned during chat for review instead of learning them immediately")
+	ephemeral := pflag.Bool("ephemeral", false, "for chat, learn only for the rest of the session unless committed with /commit")
+	minNovelty := pflag.Float64("min-novelty", 0, "minimum fraction of words in a reply that must not appear in the input")
+	temperature := pflag.Float64("temperature", 0, "randomness of word selection, from near 0 (favor common words) upwards (favor all words equally); 0 to ignore how common each word is")
+	uniform := pflag.Bool("uniform", false, "choose among the words that have followed each chain equally, rather than favoring those that followed it most often")
+	lowOrderWeight := pflag.Float64("low-order-weight", 0, "weight from 0 to 1 given to the low-order model when choosing words, for more varied but less coherent replies")
+	continueBackward := pflag.Float64("continue-backward", 0, "probability from 0 to 1 of extending a reply backwards where it could start, or 0 for 0.5; higher makes longer replies")
+	continueForward := pflag.Float64("continue-forward", 0, "probability from 0 to 1 of extending a reply forwards where it could end, or 0 for 0.5; higher makes longer replies")
+	continueHalfLife := pflag.Int("continue-half-life", 0, "number of words after which the chances of extending a reply halve, or 0 for chances that don't depend on length")
+	attempts := pflag.Int("attempts", 0, "number of candidate replies to generate for each keyword before giving up on it when they don't meet the other options, or 0 for 5")
+	maxWords := pflag.Int("max-words", 0, "maximum number of words in each reply, or 0 for no limit")
+	maxChars := pflag.Int("max-chars", 0, "maximum number of characters in each reply, such as 500 for Mastodon, or 0 for no limit")
+	hashtags := pflag.Int("hashtags", 0, "number of related hashtags to include in each reply")
+	alliterate := pflag.Bool("alliterate", false, "prefer replies with alliteration")
+	rhyme := pflag.Bool("rhyme", false, "prefer replies that rhyme with the input")
+	topicMemory := pflag.Int("topic-memory", 3, "number of previous exchanges whose topics influence each reply")
+	style := pflag.String("style", "any", "kind of sentence to reply with: any, statement, question, or exclamation")
+	keywords := pflag.String("keywords", "nouns", "how to choose the keywords for replies: nouns, rake, or tfidf")
+	respond := pflag.Bool("respond", false, "also choose keywords from how similar messages were responded to in dialogues learned with --dialogue")
+	emojiKeywords := pflag.Bool("emoji-keywords", false, "also use any emoji in the input as keywords for replies")
+	pronouncingDict := pflag.String("pronouncing-dict", "", "file in CMU Pronouncing Dictionary format to use for detecting rhymes")
+	blocklistFile := pflag.String("blocklist", "", "file of banned words, one per line, so that sentences containing them are never learned or said")
+	quotesFile := pflag.String("quotes", "", "file of quotations, one per line or separated by lines of % as in fortune files, for chat, converse, and compare to sometimes reply with them verbatim when they are relevant")
+	turns := pflag.Int("turns", 20, "number of turns for converse")
+	watch := pflag.Bool("watch", false, "for train, keep watching the corpus files and directories for new content")
+	include := pflag.StringArray("include", nil, "for train, a regular expression matching the URLs of web archive pages to learn; may be given more than once")
+	exclude := pflag.StringArray("exclude", nil, "for train, a regular expression matching the URLs of web archive pages not to learn; may be given more than once")
+	code := pflag.Bool("code", false, "for train, treat directories as code repositories to learn the documentation and comments from, rather than as web mirrors")
+	stripLogPrefixes := pflag.Bool("strip-log-prefixes", false, "for train, remove timestamps, log levels, and bracketed prefixes from the start of each line of plain text files, as for log files")
+	dialogue := pflag.Bool("dialogue", false, "for train, learn subtitles and chat logs as dialogues, recording how each message was responded to")
+	order := pflag.Int("order", ghal.DefaultOrder, "for train and learn, number of words in each chain when creating a new brain; lower orders give more varied but less coherent replies")
+	force := pflag.Bool("force", false, "for train, learn files again even if the brain has already learned them")
+	weight := pflag.Int("weight", 1, "for train, the number of times each sentence in the given files counts as learned, so that important sources have more influence over replies than bulk ones")
+	stream := pflag.Bool("stream", false, "for train, read plain text and MegaHAL files a little at a time, saving the brain periodically, so that corpora larger than memory can be learned")
+	base := pflag.String("base", "", "read-only brain file to layer the brain given with --brain over, so that only what is newly learned is saved there")
+	wait := pflag.Bool("wait", false, "for commands that save the brain, wait for any other gopherhal process using it to finish rather than failing")
+	snapshots := pflag.Int("snapshots", 0, "number of previous versions of the brain file to keep as snapshots for rollback each time it is saved")
+	snapshotAge := pflag.Duration("snapshot-age", 0, "maximum age of the snapshots to keep, or 0 for no limit")
+	caseThresholdFlag := pflag.Float64("case-threshold", ghal.DefaultCaseThreshold, "capitalize words in replies that were capitalized at least this fraction of the times they were seen in training, or 0 for all lowercase")
+	segment := pflag.String("segmentation", "words", "how to divide text into words: words, or characters for languages like Chinese and Japanese that don't put spaces between words")
+	numbersFlag := pflag.String("numbers", "keep", "what to do with numbers when parsing: keep, collapse to learn them all as one placeholder word, or drop to ignore sentences that are mostly numbers")
+	taggerCommand := pflag.String("tagger-command", "", "program to run to tag words in place of the built-in tagger, such as \"udpipe --input=horizontal --tag english-ewt.udpipe\", which reads sentences one per line and writes CoNLL-U")
+	taggerURL := pflag.String("tagger-url", "", "URL of a UDPipe-compatible REST service to tag words in place of the built-in tagger, such as http://localhost:8080/process?model=english")
+	useRegexp := pflag.Bool("regexp", false, "for grep, treat the pattern as a regular expression rather than a substring")
+	examples := pflag.Int("examples", 1, "for grep, number of example sentences to show for each matching word")
+	pattern := pflag.String("pattern", "", "for purge, regular expression matching the text of the words to remove")
+	dryRun := pflag.Bool("dry-run", false, "for purge, list the words that would be removed without removing them")
+	inputFile := pflag.String("input", "", "for compare, file of messages to reply to, one per line")
+	seed := pflag.Int64("seed", 1, "for compare, seed for the random choices made when replying to each message")
+	learn := pflag.BoolSlice("learn", nil, "for converse, whether each brain learns from the other, in the same order as --brain")
 	pflag.Parse()
 	args := pflag.Args()
 	if len(args) == 0 {
 		errUsage()
 	}
 
+	baseBrainFile = *base
+	snapshotPolicy = ghal.SnapshotPolicy{
+		Keep:   *snapshots,
+		MaxAge: *snapshotAge,
+	}
+	switch *segment {
+	case "words":
+		segmentation = ghal.SegmentWords
+	case "characters":
+		segmentation = ghal.SegmentCharacters
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid segmentation %q; must be words or characters\n", *segment)
+		os.Exit(1)
+	}
+	switch *numbersFlag {
+	case "keep":
+		numbers = ghal.KeepNumbers
+	case "collapse":
+		numbers = ghal.CollapseNumbers
+	case "drop":
+		numbers = ghal.DropNumeric
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid numbers option %q; must be keep, collapse, or drop\n", *numbersFlag)
+		os.Exit(1)
+	}
+	switch {
+	case *taggerCommand != "" && *taggerURL != "":
+		fmt.Fprintf(os.Stderr, "Only one of --tagger-command and --tagger-url may be given\n")
+		os.Exit(1)
+	case *taggerCommand != "":
+		tagger = ghal.CommandTagger{Command: strings.Fields(*taggerCommand)}
+	case *taggerURL != "":
+		tagger = ghal.HTTPTagger{URL: *taggerURL}
+	}
+	if *order < ghal.MinOrder || *order > ghal.MaxOrder {
+		fmt.Fprintf(os.Stderr, "Invalid order %d; must be between %d and %d\n", *order, ghal.MinOrder, ghal.MaxOrder)
+		os.Exit(1)
+	}
+	brainOrder = *order
+	waitForLock = *wait
+	if *blocklistFile != "" {
+		words, err := loadBlocklist(*blocklistFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading blocklist from %q: %s\n", *blocklistFile, err)
+			os.Exit(1)
+		}
+		brainFilter = ghal.BlocklistFilter(words...)
+	}
+
+	// Most commands use only one brain, so they use the last one given.
+	brainFile := (*brainFiles)[len(*brainFiles)-1]
+
+	caseThreshold = *caseThresholdFlag
+	colorOutput = useColor(*noColor)
+	debugJSON = *debugJSONFlag
+	jsonOutput = *jsonFlag
+	switch {
+	case jsonOutput && !jsonCommands[args[0]]:
+		fmt.Fprintf(os.Stderr, "The --json option can't be used with the %s command\n", args[0])
+		os.Exit(1)
+	case jsonOutput && *watch:
+		os.Stderr.WriteString("The --json option can't be used with --watch\n")
+		os.Exit(1)
+	}
 	if *debug {
-		ghal.SetDebugLog(os.Stderr, "brain: ")
+		ghal.SetDebugLog(debugWriter(os.Stderr), "brain: ")
+	}
+
+	// generationOptions builds the options for generating replies from the
+	// flags, for the commands that generate replies.
+	generationOptions := func() ghal.GenerationOptions {
+		opts := ghal.GenerationOptions{
+			MinNovelty:         *minNovelty,
+			Temperature:        *temperature,
+			LowOrderWeight:     *lowOrderWeight,
+			ContinueBackward:   *continueBackward,
+			ContinueForward:    *continueForward,
+			ContinueHalfLife:   *continueHalfLife,
+			UniformTransitions: *uniform,
+			Attempts:           *attempts,
+			MaxWords:           *maxWords,
+			MaxChars:           *maxChars,
+			Hashtags:           *hashtags,
+		}
+		switch *style {
+		case "any":
+			opts.Style = ghal.AnyStyle
+		case "statement":
+			opts.Style = ghal.StatementStyle
+		case "question":
+			opts.Style = ghal.QuestionStyle
+		case "exclamation":
+			opts.Style = ghal.ExclamationStyle
+		default:
+			fmt.Fprintf(os.Stderr, "Invalid reply style %q; must be any, statement, question, or exclamation\n", *style)
+			os.Exit(1)
+		}
+		switch *keywords {
+		case "nouns":
+			opts.Keywords = ghal.DefaultKeywords
+		case "rake":
+			opts.Keywords = ghal.RAKEKeywords(rakePhrases)
+		case "tfidf":
+			opts.Keywords = ghal.TFIDFKeywords(tfidfKeywords)
+		default:
+			fmt.Fprintf(os.Stderr, "Invalid keyword extractor %q; must be nouns, rake, or tfidf\n", *keywords)
+			os.Exit(1)
+		}
+		if *respond {
+			opts.Keywords = ghal.ResponseKeywords(opts.Keywords, responseKeywords)
+		}
+		if *emojiKeywords {
+			opts.Keywords = ghal.EmojiKeywords(opts.Keywords)
+		}
+		if *alliterate {
+			opts.Scorers = append(opts.Scorers, ghal.AlliterationScorer(noveltyBonus))
+		}
+		if *rhyme {
+			var dict *ghal.PronouncingDict
+			if *pronouncingDict != "" {
+				var err error
+				dict, err = loadPronouncingDict(*pronouncingDict)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error loading pronouncing dictionary from %q: %s\n", *pronouncingDict, err)
+					os.Exit(1)
+				}
+			}
+			opts.Scorers = append(opts.Scorers, ghal.RhymeScorer(noveltyBonus, dict))
+		}
+		if *quotesFile != "" {
+			quotes, err := loadQuotes(*quotesFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading quotes from %q: %s\n", *quotesFile, err)
+				os.Exit(1)
+			}
+			opts.Generators = []ghal.ReplyGenerator{ghal.MarkovGenerator, quotes}
+		}
+		return opts
 	}
-	rand.Seed(time.Now().Unix())
 
 	switch args[0] {
 	case "chat":
 		if len(args) != 1 {
 			errUsage()
 		}
-		os.Exit(chat(*brainFile, *debug))
+		opts := generationOptions()
+		lockBrain(brainFile)
+		os.Exit(chat(brainFile, *debug, *reviewLearning, *ephemeral, opts, *topicMemory))
 	case "train":
-		os.Exit(train(*brainFile, args[1:]))
+		filter, err := urlFilter(*include, *exclude)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid URL pattern: %s\n", err)
+			os.Exit(1)
+		}
+		if *weight < 1 {
+			os.Stderr.WriteString("The --weight option must be at least 1\n")
+			os.Exit(1)
+		}
+		if *dialogue && *weight != 1 {
+			os.Stderr.WriteString("The --weight option can't be used with --dialogue\n")
+			os.Exit(1)
+		}
+		trainOpts := trainOptions{
+			force:    *force,
+			code:     *code,
+			dialogue: *dialogue,
+			stream:   *stream,
+			weight:   *weight,
+			filter:   filter,
+			plain: trainhal.PlainTextOptions{
+				StripLogPrefixes: *stripLogPrefixes,
+			},
+		}
+		lockBrain(brainFile)
+		if *watch {
+			os.Exit(watchTraining(brainFile, args[1:], trainOpts))
+		}
+		os.Exit(train(brainFile, args[1:], trainOpts))
+	case "review":
+		if len(args) != 1 {
+			errUsage()
+		}
+		lockBrain(brainFile)
+		os.Exit(review(brainFile))
+	case "diff":
+		if len(args) != 3 {
+			os.Stderr.WriteString("Usage: gopherhal diff <old-brain-file> <new-brain-file>\n")
+			os.Exit(1)
+		}
+		os.Exit(diff(args[1], args[2]))
+	case "topics":
+		if len(args) != 1 {
+			errUsage()
+		}
+		os.Exit(topics(brainFile))
+	case "stats":
+		if len(args) != 1 {
+			errUsage()
+		}
+		os.Exit(stats(brainFile))
+	case "explore":
+		if len(args) != 1 {
+			errUsage()
+		}
+		os.Exit(explore(brainFile))
+	case "eval":
+		os.Exit(evaluate(brainFile, args[1:], generationOptions()))
+	case "learn":
+		if len(args) != 2 || args[1] != "-" {
+			os.Stderr.WriteString("Usage: gopherhal learn -\n")
+			os.Exit(1)
+		}
+		lockBrain(brainFile)
+		os.Exit(learnStdin(brainFile))
+	case "analyze":
+		os.Exit(analyze(args[1:]))
+	case "flatten":
+		if len(args) != 2 {
+			os.Stderr.WriteString("Usage: gopherhal flatten --base <base-brain-file> --brain <brain-file> <output-file>\n")
+			os.Exit(1)
+		}
+		lockBrain(args[1])
+		os.Exit(flatten(brainFile, args[1]))
+	case "merge":
+		if len(args) < 2 {
+			os.Stderr.WriteString("Usage: gopherhal merge <brain-file>...\n")
+			os.Exit(1)
+		}
+		lockBrain(brainFile)
+		os.Exit(merge(brainFile, args[1:]))
+	case "rollback":
+		if len(args) > 2 {
+			os.Stderr.WriteString("Usage: gopherhal rollback [latest|<snapshot-time>]\n")
+			os.Exit(1)
+		}
+		if len(args) > 1 {
+			lockBrain(brainFile)
+		}
+		os.Exit(rollback(brainFile, args[1:]))
+	case "taboo":
+		if len(args) == 2 || (len(args) > 2 && args[1] != "add" && args[1] != "remove") {
+			os.Stderr.WriteString("Usage: gopherhal taboo [add|remove <word>...]\n")
+			os.Exit(1)
+		}
+		if len(args) > 1 {
+			lockBrain(brainFile)
+		}
+		os.Exit(taboo(brainFile, args[1:]))
+	case "grep":
+		if len(args) != 2 {
+			os.Stderr.WriteString("Usage: gopherhal grep [--regexp] [--examples <n>] <pattern>\n")
+			os.Exit(1)
+		}
+		os.Exit(grep(brainFile, args[1], *useRegexp, *examples))
+	case "purge":
+		if len(args) != 1 || *pattern == "" {
+			os.Stderr.WriteString("Usage: gopherhal purge --pattern <regexp> [--dry-run]\n")
+			os.Exit(1)
+		}
+		if !*dryRun {
+			lockBrain(brainFile)
+		}
+		os.Exit(purge(brainFile, *pattern, *dryRun))
+	case "compare":
+		if len(args) != 3 || *inputFile == "" {
+			os.Stderr.WriteString("Usage: gopherhal compare <brain-file> <brain-file> --input <file> [--seed <n>]\n")
+			os.Exit(1)
+		}
+		os.Exit(compare([2]string{args[1], args[2]}, *inputFile, *seed, generationOptions()))
+	case "swap":
+		if !(len(args) == 1 || (len(args) == 4 && args[1] == "add") || (len(args) == 3 && args[1] == "remove")) {
+			os.Stderr.WriteString("Usage: gopherhal swap [add <word> <replacement> | remove <word>]\n")
+			os.Exit(1)
+		}
+		if len(args) > 1 {
+			lockBrain(brainFile)
+		}
+		os.Exit(swap(brainFile, args[1:]))
+	case "greetings":
+		if len(args) > 1 && args[1] != "set" {
+			os.Stderr.WriteString("Usage: gopherhal greetings [set <word>...]\n")
+			os.Exit(1)
+		}
+		if len(args) > 1 {
+			lockBrain(brainFile)
+		}
+		os.Exit(greetings(brainFile, args[1:]))
+	case "story":
+		if len(args) < 2 || len(args) > 3 {
+			os.Stderr.WriteString("Usage: gopherhal story <keyword> [<sentences>]\n")
+			os.Exit(1)
+		}
+		os.Exit(story(brainFile, args[1:]))
+	case "converse":
+		if len(args) != 1 || len(*brainFiles) != 2 || len(*learn) > 2 {
+			os.Stderr.WriteString("Usage: gopherhal converse --brain <brain-file> --brain <brain-file> [--turns <n>] [--learn <bool>,<bool>]\n")
+			os.Exit(1)
+		}
+		learnFlags := make([]bool, 2)
+		copy(learnFlags, *learn)
+		if learnFlags[0] && learnFlags[1] && sameFile((*brainFiles)[0], (*brainFiles)[1]) {
+			// Each side would save over what the other learned.
+			os.Stderr.WriteString("The same brain can't learn on both sides of a conversation\n")
+			os.Exit(1)
+		}
+		for i, filename := range *brainFiles {
+			if learnFlags[i] {
+				lockBrain(filename)
+			}
+		}
+		os.Exit(converse(*brainFiles, learnFlags, *turns, generationOptions(), *topicMemory))
 	default:
 		errUsage()
 	}
 }
 
-func chat(brainFile string, debug bool) int {
-	brain, err := ghal.LoadBrainFile(brainFile)
+func chat(brainFile string, debug bool, reviewLearning bool, ephemeral bool, opts ghal.GenerationOptions, topicMemory int) int {
+	brain, err := loadBrainFile(brainFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
 		return 1
 	}
+	conv := ghal.NewConversation(brain)
+	conv.Options = opts
+	conv.TopicMemory = topicMemory
+	if ephemeral {
+		conv.BeginSession()
+	}
+	completer := newChatCompleter(brain, ephemeral)
 
-	// We'll open with a question, to start the "discussion".
-	opener := brain.MakeQuestion()
-	if len(opener) > 0 {
-		fmt.Printf("hello! %s\n", opener)
+	// We'll open with a greeting if the brain has any greeting words, or
+	// otherwise with a question, to start the "discussion".
+	opener := brain.MakeGreeting()
+	if len(opener) == 0 {
+		opener = brain.MakeQuestion()
+	}
+	if !brain.IsTrained(0) {
+		fmt.Printf("%s\n", colorize(colorReply, "hello! i don't know anything yet, so i need training first. teach me with \"gopherhal train\", or just talk to me and i'll learn as we go"))
+	} else if len(opener) > 0 {
+		fmt.Printf("%s\n", colorize(colorReply, "hello! "+displayed(brain, opener).String()))
 	} else {
-		fmt.Printf("hello!\n")
+		fmt.Printf("%s\n", colorize(colorReply, "hello!"))
 	}
 
+	// lastReply is the most recent reply, before any cosmetic trimming, so
+	// that we can explain where it came from or discourage it if asked.
+	var lastReply ghal.Sentence
+
 	for {
-		inp := prompt.Input("> ", noComplete)
+		inp := prompt.Input("> ", completer.Complete, chatPromptOptions()...)
 		if inp == "exit" || inp == "quit" {
 			fmt.Printf("bye!\n")
 			break
 		}
-		sentences, err := ghal.ParseText(inp)
+		if inp == "/why" {
+			printAttribution(brain, lastReply)
+			continue
+		}
+		if inp == "/good" || inp == "/bad" {
+			rateReply(brain, lastReply, inp == "/good")
+			continue
+		}
+		if inp == "/topic" {
+			printTopic(conv)
+			continue
+		}
+		if inp == "/stats" {
+			printStats(brain)
+			continue
+		}
+		if ephemeral && (inp == "/commit" || inp == "/discard") {
+			n := conv.SessionLearned()
+			if inp == "/commit" {
+				conv.CommitSession()
+				completer.Learned()
+				fmt.Printf("ok, i'll remember the %d sentences you taught me\n", n)
+			} else {
+				conv.DiscardSession()
+				fmt.Printf("ok, i've forgotten the %d sentences you taught me\n", n)
+			}
+			conv.BeginSession()
+			continue
+		}
+		if strings.HasPrefix(inp, "/connect ") {
+			printConnection(brain, strings.Fields(strings.TrimPrefix(inp, "/connect ")))
+			continue
+		}
+		if strings.HasPrefix(inp, "/acrostic ") {
+			printAcrostic(brain, strings.TrimPrefix(inp, "/acrostic "))
+			continue
+		}
+		if strings.HasPrefix(inp, "/forget ") {
+			forgetText(brain, strings.TrimPrefix(inp, "/forget "))
+			continue
+		}
+		sentences, err := parseChatText(inp)
 		if err != nil {
 			fmt.Printf("sorry... i'm afraid I can't make any sense of that :(\n%s\n", err)
 			continue
@@ -72,99 +554,716 @@ func chat(brainFile string, debug bool) int {
 		if debug {
 			fmt.Printf("Here's how I understood your message:\n")
 			for _, sentence := range sentences {
-				fmt.Printf("- %s\n", sentence.StringTagged())
+				fmt.Printf("- %s\n", colorTagged(colorInput, sentence))
 			}
 			fmt.Printf("\n")
 		}
 
-		var reply ghal.Sentence
+		var reply ghal.ScoredReply
+		var kind string
 
 		// If this seems to be a "why" question then we'll try to randomly
 		// select a "because..." sentence to respond with.
 		if len(sentences) > 0 && len(sentences[0]) > 0 {
 			if sentences[0][0] == why {
-				reply = brain.MakeReason()
+				reply = ghal.ScoredReply{Sentence: brain.MakeReason()}
+				kind = "reason"
+				conv.NoteOutput(reply.Sentence)
 			}
 		}
 
-		if len(reply) == 0 {
-			reply = brain.MakeReply(sentences...)
+		if len(reply.Sentence) == 0 {
+			reply = conv.MakeReply(sentences...)
+			kind = "reply"
 		}
-		if len(reply) == 0 {
-			reply = brain.MakeQuestion()
+		if len(reply.Sentence) == 0 {
+			reply = ghal.ScoredReply{Sentence: brain.MakeQuestion()}
+			kind = "question"
+			conv.NoteOutput(reply.Sentence)
+		}
+		if debugJSON {
+			trace := newChatTrace(brain, opts, sentences)
+			if len(reply.Sentence) > 0 {
+				trace.setReply(brain, kind, reply)
+			}
+			trace.write(os.Stderr)
 		}
-		if len(reply) == 0 {
-			fmt.Printf("i am speechless :(\n")
+		if len(reply.Sentence) == 0 {
+			if !brain.IsTrained(minTrainedChains) {
+				fmt.Printf("i need training first :(\n")
+			} else {
+				fmt.Printf("i am speechless :(\n")
+			}
 			continue
 		}
-		reply = reply.TrimPeriod()
+		lastReply = reply.Sentence
+		trimmed := displayed(brain, reply.Sentence)
 		if debug {
-			fmt.Printf("My response:\n- %s\n", reply.StringTagged())
+			fmt.Printf("My response:\n- %s\n", colorTagged(colorReply, trimmed))
 		} else {
-			fmt.Printf("%s\n", reply)
+			fmt.Printf("%s\n", colorize(colorReply, trimmed.String()))
 		}
 
 		// Learn the sentences the user typed, but we'll trim off trailing
 		// periods to preserve the bot's conversational style.
-		for _, sentence := range sentences {
-			brain.AddSentence(sentence.TrimPeriod())
+		for i, sentence := range sentences {
+			sentences[i] = brain.TrimPeriod(sentence)
+		}
+		if reviewLearning {
+			// In review mode the sentences are only staged, and an operator
+			// must approve them with "gopherhal review" before they are
+			// actually learned.
+			err := appendPending(pendingFilename(brainFile), conv.FilterOwnOutput(sentences))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to stage sentences for review: %s\n", err)
+			}
+		} else {
+			conv.Learn(sentences, chatSource)
+			completer.Learned()
 		}
 	}
 	safeSaveBrain(brain, brainFile)
 	return 0
 }
 
-func train(brainFile string, corpusFiles []string) int {
+// printAttribution prints the sources that contributed each part of the
+// given sentence, for the "/why" chat command.
+func printAttribution(brain *ghal.Brain, s ghal.Sentence) {
+	spans := brain.Attribution(s)
+	if len(spans) == 0 {
+		fmt.Printf("i haven't said anything yet!\n")
+		return
+	}
+	fmt.Printf("Here's where my last reply came from:\n")
+	for _, span := range spans {
+		sources := "(unknown)"
+		if len(span.Sources) > 0 {
+			sources = strings.Join(span.Sources, ", ")
+		}
+		fmt.Printf("- %q: %s\n", s[span.Start:span.End].String(), sources)
+	}
+}
+
+// rateReply makes the brain more likely to say the given reply again if good
+// is set, or otherwise less likely, for the "/good" and "/bad" chat
+// commands.
+func rateReply(brain *ghal.Brain, s ghal.Sentence, good bool) {
+	switch {
+	case len(s) == 0:
+		fmt.Printf("i haven't said anything yet!\n")
+	case good && brain.Reinforce(s):
+		fmt.Printf("thanks! i'll say things like that more often\n")
+	case good:
+		fmt.Printf("thanks!\n")
+	case brain.Discourage(s):
+		fmt.Printf("sorry! i'll try not to say that again\n")
+	default:
+		fmt.Printf("sorry! but i don't know enough to say anything else\n")
+	}
+}
+
+// printTopic prints the words that make up the current topic of the given
+// conversation, for the "/topic" chat command.
+func printTopic(conv *ghal.Conversation) {
+	topic := conv.Topic()
+	if len(topic) == 0 {
+		fmt.Printf("we haven't really talked about anything yet\n")
+		return
+	}
+	words := make([]string, 0, len(topic))
+	for _, w := range topic.Sorted() {
+		words = append(words, w.Text)
+	}
+	fmt.Printf("we've been talking about: %s\n", strings.Join(words, ", "))
+}
+
+// printStats prints a summary of the size of the brain and how quickly it
+// has been learning, for the "/stats" chat command.
+func printStats(brain *ghal.Brain) {
+	stats := brain.Stats()
+	fmt.Printf("i know %d words in %d chains from %d sources, using around %s of memory\n", stats.Words, stats.Chains, stats.Sources, formatBytes(stats.MemoryEstimate))
+	fmt.Printf("lately i've been learning %.1f sentences and %.1f new chains per minute\n", stats.Learning.SentencesPerMinute, stats.Learning.ChainsPerMinute)
+}
+
+// printAcrostic prints an acrostic for the given word, for the "/acrostic"
+// chat command.
+func printAcrostic(brain *ghal.Brain, word string) {
+	if strings.IndexFunc(word, unicode.IsLetter) < 0 {
+		fmt.Printf("i need some letters to work with!\n")
+		return
+	}
+	ss := brain.MakeAcrostic(word)
+	if len(ss) == 0 {
+		fmt.Printf("i can't think of anything for those letters :(\n")
+		return
+	}
+	for _, s := range ss {
+		if len(s) == 0 {
+			fmt.Printf("...\n")
+			continue
+		}
+		fmt.Printf("%s\n", brain.TrimPeriod(s))
+	}
+}
+
+// forgetText makes the brain forget each of the sentences in the given
+// text, for the "/forget" chat command. Sentences learned in chat have their
+// trailing periods trimmed, so each sentence is also tried without one.
+func forgetText(brain *ghal.Brain, text string) {
+	sentences, err := parseChatText(text)
+	if err != nil {
+		fmt.Printf("sorry... i'm afraid I can't make any sense of that :(\n%s\n", err)
+		return
+	}
+	forgotten := 0
+	for _, s := range sentences {
+		if brain.ForgetSentence(s) || brain.ForgetSentence(brain.TrimPeriod(s)) {
+			forgotten++
+		}
+	}
+	switch {
+	case len(sentences) == 0:
+		fmt.Printf("what should i forget?\n")
+	case forgotten == 0:
+		fmt.Printf("i don't remember learning that\n")
+	case forgotten < len(sentences):
+		fmt.Printf("ok, i've forgotten %d of those %d sentences, but i don't remember learning the others\n", forgotten, len(sentences))
+	default:
+		fmt.Printf("ok, i've forgotten that\n")
+	}
+}
+
+// connectMaxLen is the maximum length of the connecting sequence of words
+// the "/connect" chat command will search for.
+const connectMaxLen = 12
+
+// printConnection prints a sentence connecting the two given words, for the
+// "/connect" chat command.
+func printConnection(brain *ghal.Brain, words []string) {
+	if len(words) != 2 {
+		fmt.Printf("i can only connect two words at a time!\n")
+		return
+	}
+	from, ok := lookupWord(brain, words[0])
+	if !ok {
+		fmt.Printf("i don't know anything about %q :(\n", words[0])
+		return
+	}
+	to, ok := lookupWord(brain, words[1])
+	if !ok {
+		fmt.Printf("i don't know anything about %q :(\n", words[1])
+		return
+	}
+	s := brain.FindPath(from, to, connectMaxLen)
+	if len(s) == 0 {
+		fmt.Printf("i can't think of any connection between those\n")
+		return
+	}
+	fmt.Printf("%s\n", brain.TrimPeriod(s))
+}
+
+// lookupWord finds the word with the given text that the brain knows best,
+// since the user can't tell us which part of speech they mean.
+func lookupWord(brain *ghal.Brain, text string) (ghal.Word, bool) {
+	want := ghal.MakeWord("", text).Text
+	found := brain.TopWords(1, func(w ghal.Word) bool {
+		return w.Text == want
+	})
+	if len(found) == 0 {
+		return ghal.Word{}, false
+	}
+	return found[0], true
+}
+
+// trainOptions are the settings from the command line that affect how
+// training sources are learned.
+type trainOptions struct {
+	force    bool
+	code     bool
+	dialogue bool
+	stream   bool
+	weight   int
+	filter   trainhal.URLFilter
+	plain    trainhal.PlainTextOptions
+}
+
+func train(brainFile string, corpusFiles []string, opts trainOptions) int {
 	if len(corpusFiles) == 0 {
-		os.Stderr.WriteString("Usage: gopherhal train <corpus-file>...\n")
+		os.Stderr.WriteString("Usage: gopherhal train [--force] [--order <n>] [--include <pattern>] [--exclude <pattern>] [--code] [--dialogue] [--stream] [--weight <n>] [--strip-log-prefixes] <corpus-file-or-directory>...\n")
 		return 1
 	}
 
-	brain, err := ghal.LoadBrainFile(brainFile)
+	brain, err := loadBrainFile(brainFile)
 	if os.IsNotExist(err) {
 		log.Printf("Starting training with a new, empty brain")
-		brain = ghal.NewBrain()
+		brain = newBrain()
 	} else if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
 		return 1
 	}
 
+	// A single parser shared across all of the files allows them to share
+	// memory for the words they have in common.
+	parser := newParser()
+
+	summary := trainSummary{
+		Brain:   brainFile,
+		Sources: make([]trainSourceSummary, 0, len(corpusFiles)),
+	}
 	for _, filename := range corpusFiles {
-		f, err := os.Open(filename)
+		var learned bool
+		if info, statErr := os.Stat(filename); statErr == nil && info.IsDir() {
+			parse := func() ([]ghal.Utterance, error) {
+				if opts.code {
+					return trainhal.ParseCodeRepository(filename, trainhal.CodeOptions{}, parser)
+				}
+				return trainhal.ParseMirror(filename, opts.filter, parser)
+			}
+			learned, err = trainDirectory(brain, filename, opts.force, opts.weight, parse)
+		} else if opts.stream && !opts.dialogue {
+			learned, err = trainFileStreamed(brain, parser, filename, brainFile, opts)
+		} else {
+			learned, err = trainFile(brain, parser, filename, false, opts)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to open %s: %s\n", filename, err)
+			fmt.Fprintf(os.Stderr, "Failed to read %s: %s\n", filename, err)
 			return 1
 		}
+		if learned {
+			brain.AddCaseCounts(parser.TakeCaseCounts())
+			// Overwrite our initial brain file after each successful import.
+			safeSaveBrain(brain, brainFile)
+		}
+		ts, _ := brain.TrainedSource(filename)
+		summary.Sources = append(summary.Sources, trainSourceSummary{
+			Name:      filename,
+			Learned:   learned,
+			Sentences: ts.Sentences,
+		})
+	}
+
+	log.Printf("All done! Update brain saved in %s", brainFile)
+
+	if jsonOutput {
+		summary.Stats = newJSONStats(brain)
+		return printJSON(summary)
+	}
+	return 0
+}
+
+// displayed returns the given sentence constructed by the given brain as it
+// should be displayed, with its trailing period trimmed and its words
+// capitalized as selected by the --case-threshold flag.
+func displayed(brain *ghal.Brain, s ghal.Sentence) ghal.Sentence {
+	return brain.Capitalize(brain.TrimPeriod(s), caseThreshold)
+}
 
-		log.Printf("Reading training content from %s...", filename)
-		log.Print("Content extraction can be slow, so larger files may take minutes to import.")
-		sentences, err := trainhal.ParseTrainingInput(f, filename, "")
+// newBrain returns a new, empty brain of the order selected by the --order
+// flag, with the filter from the --blocklist flag.
+func newBrain() *ghal.Brain {
+	brain, err := ghal.NewBrainWithOrder(brainOrder)
+	if err != nil {
+		// Should never happen, because we validate the flag in main.
+		panic(err)
+	}
+	if brainFilter != nil {
+		brain.SetFilter(brainFilter)
+	}
+	return brain
+}
+
+// newParser returns a parser for training material that divides text into
+// words, handles numbers, and tags words as selected by the --segmentation,
+// --numbers, and tagger flags.
+func newParser() *ghal.Parser {
+	parser := ghal.NewParser()
+	parser.Segmentation = segmentation
+	parser.Numbers = numbers
+	parser.Tagger = tagger
+	return parser
+}
+
+// parseChatText parses a message typed or received during a chat, which is
+// parsed with ChatParseLimits, with words, numbers, and tags handled as
+// selected by the --segmentation, --numbers, and tagger flags.
+func parseChatText(text string) ([]ghal.Sentence, error) {
+	return ghal.ParseTextWithOptions(text, ghal.ParseOptions{
+		Limits:       ghal.ChatParseLimits,
+		Segmentation: segmentation,
+		Numbers:      numbers,
+		Tagger:       tagger,
+	})
+}
+
+// trainFile teaches the given brain the sentences in the given file, unless
+// the brain's training manifest shows that it has already learned them and
+// the force option isn't set, returning true if the brain learned anything.
+//
+// If the file is in a line-oriented format and has grown since it was last
+// learned, only the new lines are learned. If wholeLines is set then any
+// incomplete line at the end of such a file is left to be learned later,
+// which is appropriate for files that are still being written.
+func trainFile(brain *ghal.Brain, parser *ghal.Parser, filename string, wholeLines bool, opts trainOptions) (bool, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+	lineOriented := trainhal.IsLineOriented(filename, "")
+
+	end := len(src)
+	if lineOriented && wholeLines {
+		end = bytes.LastIndexByte(src, '\n') + 1
+	}
+	hash := hashTrainingSource(src[:end])
+
+	start, sentencesBefore := 0, 0
+	if prev, ok := brain.TrainedSource(filename); ok && !opts.force {
+		prevSize := int(prev.Size)
+		if prevSize == 0 {
+			prevSize = len(src)
+		}
+		switch {
+		case prev.Partial > 0 && prev.Hash == hash:
+			log.Printf("Skipping %s, which was only partly learned with --stream on %s; use --stream to finish learning it, or --force to learn it again", filename, prev.Time.Format(time.RFC3339))
+			return false, nil
+		case prevSize == end && prev.Hash == hash:
+			log.Printf("Skipping %s, which was already learned on %s; use --force to learn it again", filename, prev.Time.Format(time.RFC3339))
+			return false, nil
+		case lineOriented && prevSize < end && prev.Hash == hashTrainingSource(src[:prevSize]):
+			log.Printf("%s has grown since it was learned on %s, so learning only the new lines", filename, prev.Time.Format(time.RFC3339))
+			start, sentencesBefore = prevSize, prev.Sentences
+		default:
+			log.Printf("%s has changed since it was learned on %s", filename, prev.Time.Format(time.RFC3339))
+		}
+	}
+	if start == end {
+		return false, nil
+	}
+
+	log.Printf("Reading training content from %s...", filename)
+	log.Print("Content extraction can be slow, so larger files may take minutes to import.")
+	ts := ghal.TrainedSource{
+		Name: filename,
+		Hash: hash,
+		Size: int64(end),
+	}
+	if opts.dialogue {
+		turns, err := trainhal.ParseDialogue(bytes.NewReader(src[start:end]), filename, "", parser)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to read %s: %s\n", filename, err)
-			return 1
+			return false, err
 		}
+		var sentences []ghal.Sentence
+		for _, turn := range turns {
+			sentences = append(sentences, turn.Sentences...)
+		}
+		logSentences(sentences)
+		brain.AddDialogue(turns, filename)
+		ts.Sentences = sentencesBefore + len(sentences)
+		recordTrainedSource(brain, ts)
+		return true, nil
+	}
 
-		log.Printf("Sentences found: %d", len(sentences))
-		for i, sentence := range sentences {
-			if i == 5 {
-				log.Printf("- (etc...)")
-				break
-			}
-			log.Printf("- %s", sentence)
+	var utterances []ghal.Utterance
+	if trainhal.IsWebArchive(filename, "") {
+		utterances, err = trainhal.ParseWARC(bytes.NewReader(src), opts.filter, parser)
+	} else if trainhal.IsPlainText(filename, "") {
+		utterances, err = trainhal.ParsePlainText(bytes.NewReader(src[start:end]), opts.plain, parser)
+	} else {
+		utterances, err = trainhal.ParseTrainingUtterances(bytes.NewReader(src[start:end]), filename, "", parser)
+	}
+	if err != nil {
+		return false, err
+	}
+	ts.Sentences = sentencesBefore + len(utterances)
+	learnUtterances(brain, filename, utterances, opts.weight, ts)
+	return true, nil
+}
+
+// streamCheckpointInterval is how often the brain is saved while learning
+// a file with the --stream option.
+const streamCheckpointInterval = 5 * time.Minute
+
+// trainFileStreamed is like trainFile but reads the file a little at a time
+// rather than all at once, periodically saving the brain to the given file,
+// so that it can learn files larger than the memory available.
+//
+// Each time the brain is saved before the whole file has been learned, such
+// as when learning is interrupted, the manifest records how far it got, so
+// that learning the same file again resumes from there rather than learning
+// the same sentences twice.
+func trainFileStreamed(brain *ghal.Brain, parser *ghal.Parser, filename, brainFile string, opts trainOptions) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	prev, hasPrev := brain.TrainedSource(filename)
+	hasPrev = hasPrev && !opts.force
+
+	// We hash the file in a separate pass first, so that we don't spend
+	// hours learning a file we already know. If it has been learned before
+	// then we also hash the part that was learned, in case it has only
+	// grown since.
+	h := sha256.New()
+	var prefixLen int64
+	prefixHash := ""
+	if hasPrev && prev.Partial == 0 && prev.Size > 0 {
+		prefixLen, err = io.CopyN(h, f, prev.Size)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if prefixLen == prev.Size {
+			prefixHash = fmt.Sprintf("sha256:%x", h.Sum(nil))
+		}
+	}
+	restLen, err := io.Copy(h, f)
+	if err != nil {
+		return false, err
+	}
+	size := prefixLen + restLen
+	hash := fmt.Sprintf("sha256:%x", h.Sum(nil))
+
+	var start int64
+	skip, sentencesBefore := 0, 0
+	if hasPrev {
+		switch {
+		case prev.Partial > 0 && prev.Hash == hash:
+			log.Printf("Resuming learning %s where it was interrupted on %s", filename, prev.Time.Format(time.RFC3339))
+			start, skip, sentencesBefore = prev.Size, prev.Partial, prev.Sentences
+		case prev.Partial == 0 && (prev.Size == 0 || prev.Size == size) && prev.Hash == hash:
+			log.Printf("Skipping %s, which was already learned on %s; use --force to learn it again", filename, prev.Time.Format(time.RFC3339))
+			return false, nil
+		case prev.Partial == 0 && trainhal.IsLineOriented(filename, "") && prefixHash == prev.Hash:
+			log.Printf("%s has grown since it was learned on %s, so learning only the new lines", filename, prev.Time.Format(time.RFC3339))
+			start, sentencesBefore = prev.Size, prev.Sentences
+		default:
+			log.Printf("%s has changed since it was learned on %s", filename, prev.Time.Format(time.RFC3339))
 		}
-		brain.AddSentences(sentences)
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-		// Overwrite our initial brain file after each successful import.
+	log.Printf("Streaming training content from %s...", filename)
+	stream := trainhal.Stream(trainhal.CorpusSource{
+		Name:   filename,
+		Reader: f,
+	}, trainhal.StreamOptions{
+		Parser:    parser,
+		PlainText: opts.plain,
+	})
+
+	// read counts the sentences read from the stream, including those
+	// skipped because an earlier, interrupted run learned them, so that we
+	// can record how far we got. Consume learns everything it has read
+	// before it calls Checkpoint or returns, so at those points read is
+	// exactly the number of sentences the brain has seen.
+	read := 0
+	seq := func(yield func(ghal.Sentence, error) bool) {
+		for s, err := range stream {
+			if err == nil {
+				read++
+				if read <= skip {
+					if read == skip {
+						// The case counts of the sentences we skipped
+						// were recorded by the earlier run.
+						parser.TakeCaseCounts()
+					}
+					continue
+				}
+			}
+			if !yield(s, err) {
+				return
+			}
+		}
+	}
+	saveProgress := func(learned int) {
+		recordTrainedSource(brain, ghal.TrainedSource{
+			Name:      filename,
+			Hash:      hash,
+			Size:      start,
+			Sentences: sentencesBefore + learned,
+			Partial:   read,
+		})
+		brain.AddCaseCounts(parser.TakeCaseCounts())
 		safeSaveBrain(brain, brainFile)
 	}
 
-	log.Printf("All done! Update brain saved in %s", brainFile)
+	learned, err := brain.Consume(ctx, seq, ghal.ConsumeOptions{
+		Source:             filename,
+		Weight:             opts.weight,
+		CheckpointInterval: streamCheckpointInterval,
+		Checkpoint: func(learned int) error {
+			log.Printf("Learned %d sentences from %s so far; saving a checkpoint", learned, filename)
+			saveProgress(learned)
+			return nil
+		},
+	})
+	log.Printf("Sentences found: %d", learned)
+	if err != nil {
+		if read > skip {
+			saveProgress(learned)
+		}
+		return false, err
+	}
+	recordTrainedSource(brain, ghal.TrainedSource{
+		Name:      filename,
+		Hash:      hash,
+		Size:      size,
+		Sentences: sentencesBefore + learned,
+	})
+	return true, nil
+}
 
-	return 0
+// trainDirectory teaches the given brain the sentences that the given parse
+// function finds in the given directory tree, unless the brain's training
+// manifest shows that it has already learned them and force is false,
+// returning true if the brain learned anything. Each sentence counts as
+// learned the given number of times.
+func trainDirectory(brain *ghal.Brain, dir string, force bool, weight int, parse func() ([]ghal.Utterance, error)) (bool, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", path, len(src))
+		h.Write(src)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	hash := fmt.Sprintf("sha256:%x", h.Sum(nil))
+
+	if prev, ok := brain.TrainedSource(dir); ok && !force {
+		if prev.Hash == hash {
+			log.Printf("Skipping %s, which was already learned on %s; use --force to learn it again", dir, prev.Time.Format(time.RFC3339))
+			return false, nil
+		}
+		log.Printf("%s has changed since it was learned on %s", dir, prev.Time.Format(time.RFC3339))
+	}
+
+	log.Printf("Reading training content from %s...", dir)
+	log.Print("Content extraction can be slow, so larger directories may take minutes to import.")
+	utterances, err := parse()
+	if err != nil {
+		return false, err
+	}
+	learnUtterances(brain, dir, utterances, weight, ghal.TrainedSource{
+		Name:      dir,
+		Hash:      hash,
+		Sentences: len(utterances),
+	})
+	return true, nil
+}
+
+// learnUtterances teaches the given brain the given utterances from the
+// given source, counting each as learned the given number of times, and
+// records the source in the brain's training manifest.
+func learnUtterances(brain *ghal.Brain, source string, utterances []ghal.Utterance, weight int, ts ghal.TrainedSource) {
+	sentences := make([]ghal.Sentence, len(utterances))
+	for i, u := range utterances {
+		sentences[i] = u.Sentence
+		utterances[i].Weight = weight
+	}
+	logSentences(sentences)
+	brain.AddUtterances(utterances, source)
+	recordTrainedSource(brain, ts)
+}
+
+// logSentences logs the number of sentences found in a training source,
+// along with the first few of them.
+func logSentences(sentences []ghal.Sentence) {
+	log.Printf("Sentences found: %d", len(sentences))
+	for i, sentence := range sentences {
+		if i == 5 {
+			log.Printf("- (etc...)")
+			break
+		}
+		log.Printf("- %s", sentence)
+	}
+}
+
+// recordTrainedSource records the given source, which the given brain has
+// just learned, in the brain's training manifest.
+func recordTrainedSource(brain *ghal.Brain, ts ghal.TrainedSource) {
+	ts.Time = time.Now()
+	brain.RecordTrainedSource(ts)
+	warnMemoryBudget(brain)
+}
+
+// urlFilter compiles the given include and exclude patterns into a filter for
+// the pages of web archives.
+func urlFilter(include, exclude []string) (trainhal.URLFilter, error) {
+	var ret trainhal.URLFilter
+	for _, pattern := range include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return ret, err
+		}
+		ret.Include = append(ret.Include, re)
+	}
+	for _, pattern := range exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return ret, err
+		}
+		ret.Exclude = append(ret.Exclude, re)
+	}
+	return ret, nil
+}
+
+// hashTrainingSource returns the digest of the given training source content
+// that is recorded in a brain's training manifest.
+func hashTrainingSource(src []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(src))
+}
+
+func loadPronouncingDict(filename string) (*ghal.PronouncingDict, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ghal.LoadPronouncingDict(f)
+}
+
+func loadBlocklist(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ghal.ReadBlocklist(f)
+}
+
+func loadQuotes(filename string) (*ghal.QuoteDB, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// Quotes are replied with verbatim, so their numbers are kept even if
+	// --numbers would collapse or drop them in what the brain learns.
+	parser := newParser()
+	parser.Numbers = ghal.KeepNumbers
+	quotes, err := trainhal.ParseQuotes(f, parser)
+	if err != nil {
+		return nil, err
+	}
+	return ghal.NewQuoteDB(quotes...), nil
 }
 
 func errUsage() {
-	os.Stderr.WriteString("Usage: gopherhal <chat|train>\n")
+	os.Stderr.WriteString("Usage: gopherhal <chat|train|review|diff|topics|explore|converse|eval|analyze|learn|rollback|flatten|merge|story|taboo|grep|purge|compare|swap|greetings|stats>\n")
 	os.Exit(1)
 }
 
@@ -172,16 +1271,45 @@ func noComplete(d prompt.Document) []prompt.Suggest {
 	return nil
 }
 
-func safeSaveBrain(brain *ghal.Brain, filename string) {
-	tempName := "." + filename + ".new"
-	err := brain.SaveFile(tempName)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to save brain: %s\n", err)
+// lockBrain takes the lock on the given brain file for the rest of the
+// process, for the commands that save it, so that two such commands can't
+// run at once and save over each other's changes. If another process is
+// using the brain, lockBrain exits with an error unless --wait was given,
+// in which case it waits for the other process to finish.
+func lockBrain(filename string) {
+	lock, err := ghal.LockBrainFile(filename, false)
+	if errors.Is(err, ghal.ErrBrainInUse) && waitForLock {
+		log.Printf("Waiting for another process using %s to finish...", filename)
+		lock, err = ghal.LockBrainFile(filename, true)
+	}
+	switch {
+	case errors.Is(err, ghal.ErrBrainInUse):
+		fmt.Fprintf(os.Stderr, "Error: brain %q is in use by another process; use --wait to wait for it to finish\n", filename)
 		os.Exit(1)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error locking brain %q: %s\n", filename, err)
+		os.Exit(1)
+	}
+	brainLocks = append(brainLocks, lock)
+}
+
+// sameFile returns true if the two given filenames refer to the same file,
+// including if neither exists yet but both have the same absolute path.
+func sameFile(a, b string) bool {
+	aInfo, aErr := os.Stat(a)
+	bInfo, bErr := os.Stat(b)
+	if aErr == nil && bErr == nil {
+		return os.SameFile(aInfo, bInfo)
 	}
-	err = os.Rename(tempName, filename)
+	aAbs, aErr := filepath.Abs(a)
+	bAbs, bErr := filepath.Abs(b)
+	return aErr == nil && bErr == nil && aAbs == bAbs
+}
+
+func safeSaveBrain(brain *ghal.Brain, filename string) {
+	err := brain.SaveFileWithSnapshots(filename, snapshotPolicy)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to replace brain snapshot with new snapshot: %s\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to save brain: %s\n", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// brainFileExpansion is the approximate ratio between the memory used by a
+// loaded brain and the size of its file. This varies depending on the brain,
+// but is good enough to warn about loads that are very likely to fail.
+const brainFileExpansion = 40
+
+// loadBrainFile is like ghal.LoadBrainFile but first warns if the brain is
+// likely to need more memory than is available.
+//
+// If a base brain was given with --base, the brain is loaded as an overlay
+// over it, and a brain file that doesn't exist yet is treated as an empty
+// overlay.
+//
+// The brain's filter is set from the --blocklist flag, if it was given.
+func loadBrainFile(filename string) (*ghal.Brain, error) {
+	var brain *ghal.Brain
+	var err error
+	if baseBrainFile != "" {
+		brain, err = loadOverlayFile(filename)
+	} else {
+		if err := warnBrainFileMemory(filename); err != nil {
+			return nil, err
+		}
+		brain, err = ghal.LoadBrainFile(filename)
+	}
+	if err == nil && brainFilter != nil {
+		brain.SetFilter(brainFilter)
+	}
+	return brain, err
+}
+
+// warnBrainFileMemory warns if loading the given brain file is likely to
+// need more memory than is available, returning an error only if the file
+// can't be found at all.
+func warnBrainFileMemory(filename string) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if avail, ok := availableMemory(); ok {
+		need := info.Size() * brainFileExpansion
+		if need > avail {
+			log.Printf("Warning: loading %s may need around %s of memory, but only %s is available", filename, formatBytes(need), formatBytes(avail))
+		}
+	}
+	return nil
+}
+
+// warnMemoryBudget warns if the given brain is using most of the memory
+// that was available to it, which is a sign that further training is likely
+// to exhaust memory.
+func warnMemoryBudget(brain *ghal.Brain) {
+	avail, ok := availableMemory()
+	if !ok {
+		return
+	}
+	// The available memory doesn't include what the brain is already using.
+	used := brain.MemoryEstimate()
+	if used > avail {
+		log.Printf("Warning: the brain is using around %s of memory, and only %s more is available", formatBytes(used), formatBytes(avail))
+	}
+}
+
+// availableMemory returns the amount of memory available for new
+// allocations without swapping, in bytes. The second return value is false
+// if this information is not available on the current platform.
+func availableMemory() (int64, bool) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 || fields[0] != "MemAvailable:" || fields[2] != "kB" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return kb * 1024, true
+	}
+	return 0, false
+}
+
+// formatBytes returns a human-readable representation of the given number
+// of bytes.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + "B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + "KMGTPE"[exp:exp+1] + "iB"
+}
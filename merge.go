@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// merge implements the "merge" command, which teaches the brain everything
+// that the brains in the given files know, creating the brain if it doesn't
+// exist yet.
+func merge(brainFile string, otherFiles []string) int {
+	brain, err := loadBrainFile(brainFile)
+	if os.IsNotExist(err) {
+		// We'll create the brain once we know the order of the first of
+		// the other brains.
+		brain = nil
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	for _, filename := range otherFiles {
+		if err := warnBrainFileMemory(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", filename, err)
+			return 1
+		}
+		other, err := ghal.LoadBrainFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", filename, err)
+			return 1
+		}
+		if brain == nil {
+			brain, err = ghal.NewBrainWithOrder(other.Order())
+			if err != nil {
+				// Should never happen, because the file was valid.
+				panic(err)
+			}
+		}
+		if err := brain.Merge(other); err != nil {
+			fmt.Fprintf(os.Stderr, "Error merging %q: %s\n", filename, err)
+			return 1
+		}
+		fmt.Printf("Merged %s, which knows %d chains\n", filename, other.Stats().Chains)
+	}
+
+	safeSaveBrain(brain, brainFile)
+	fmt.Printf("Saved %s, which now knows %d chains\n", brainFile, brain.Stats().Chains)
+	return 0
+}
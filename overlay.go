@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// baseBrainFile is the brain file given with --base, which is loaded as a
+// read-only base for the brain given with --brain, or an empty string if
+// brains are not layered.
+var baseBrainFile string
+
+// baseBrain is the brain loaded from baseBrainFile, once it has been loaded.
+// Brains that share a base, like the two brains in converse, share a single
+// copy of it.
+var baseBrain *ghal.Brain
+
+// loadOverlayFile loads the given brain file as an overlay over the base
+// brain, or returns an empty overlay if the file doesn't exist yet.
+func loadOverlayFile(filename string) (*ghal.Brain, error) {
+	if baseBrain == nil {
+		if err := warnBrainFileMemory(baseBrainFile); err != nil {
+			return nil, fmt.Errorf("failed to load base brain: %s", err)
+		}
+		base, err := ghal.LoadBrainFile(baseBrainFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load base brain: %s", err)
+		}
+		baseBrain = base
+	}
+
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return ghal.NewOverlay(baseBrain), nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ghal.LoadOverlay(baseBrain, f)
+}
+
+// flatten combines the brain given with --brain and its base brain into a
+// single standalone brain, saved in the given file.
+func flatten(brainFile, outFile string) int {
+	if baseBrainFile == "" {
+		fmt.Fprintf(os.Stderr, "There is nothing to flatten unless a base brain is given with --base\n")
+		return 1
+	}
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+	safeSaveBrain(brain.Flatten(), outFile)
+	fmt.Printf("Saved %s and its base %s together in %s\n", brainFile, baseBrainFile, outFile)
+	return 0
+}
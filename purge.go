@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// purge implements the "purge" command, which permanently removes the words
+// matching the given regular expression from the brain, or only lists them
+// if dryRun is set.
+func purge(brainFile string, pattern string, dryRun bool) int {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid pattern: %s\n", err)
+		return 1
+	}
+
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	words := brain.MatchingWords(re)
+	if jsonOutput {
+		summary := purgeSummary{
+			Pattern: pattern,
+			DryRun:  dryRun,
+			Words:   make([]purgeWord, len(words)),
+		}
+		for i, w := range words {
+			summary.Words[i] = purgeWord{
+				Word:      w,
+				Frequency: brain.WordFrequency(w),
+			}
+		}
+		if dryRun {
+			summary.Swaps, summary.Greetings = matchingSettings(brain, re)
+		} else {
+			result := brain.PurgeMatching(re)
+			summary.Swaps, summary.Greetings = result.Swaps, result.Greetings
+			if result != (ghal.PurgeResult{}) {
+				safeSaveBrain(brain, brainFile)
+			}
+		}
+		return printJSON(summary)
+	}
+
+	if dryRun {
+		swaps, greetings := matchingSettings(brain, re)
+		if len(words) == 0 && swaps == 0 && greetings == 0 {
+			fmt.Printf("No words match %q.\n", pattern)
+			return 0
+		}
+		for _, w := range words {
+			fmt.Printf("%8d  %s/%s\n", brain.WordFrequency(w), w.Text, w.Tag)
+		}
+		fmt.Printf("Would remove %d words and the chains containing them, %d swaps, and %d greetings; run again without --dry-run to remove them.\n", len(words), swaps, greetings)
+		return 0
+	}
+
+	result := brain.PurgeMatching(re)
+	if result == (ghal.PurgeResult{}) {
+		fmt.Printf("No words match %q.\n", pattern)
+		return 0
+	}
+	safeSaveBrain(brain, brainFile)
+	fmt.Printf("Removed %d words and the chains containing them, %d swaps, and %d greetings.\n", result.Words, result.Swaps, result.Greetings)
+	if baseBrainFile != "" {
+		fmt.Printf("The base brain %s was not changed, and must be purged separately.\n", baseBrainFile)
+	}
+	return 0
+}
+
+// matchingSettings returns the number of swaps and greetings in the given
+// brain that PurgeMatching would remove for the given regular expression.
+func matchingSettings(brain *ghal.Brain, re *regexp.Regexp) (swaps, greetings int) {
+	for from, to := range brain.Swaps() {
+		if re.MatchString(from) || re.MatchString(to) {
+			swaps++
+		}
+	}
+	for _, text := range brain.Greetings() {
+		if re.MatchString(text) {
+			greetings++
+		}
+	}
+	return swaps, greetings
+}
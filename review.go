@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+	prompt "github.com/c-bata/go-prompt"
+)
+
+// reviewBatchSize is the number of pending sentences presented together
+// for approval or rejection in the "review" command.
+const reviewBatchSize = 10
+
+// pendingFilename returns the filename of the journal where sentences are
+// staged for review before they are learned by the brain in the given file.
+func pendingFilename(brainFile string) string {
+	return brainFile + ".pending"
+}
+
+// lockPending takes the lock on the pending journal in the given file,
+// waiting for any other process holding it, so that sentences appended
+// while the "review" command is rewriting the journal aren't lost.
+func lockPending(filename string) (*ghal.FileLock, error) {
+	return ghal.LockBrainFile(filename, true)
+}
+
+// appendPending adds the given sentences to the end of the pending journal
+// in the given file, creating it if necessary.
+//
+// The journal has one JSON-encoded sentence per line, using the same
+// representation of sentences as the "JSON Utter" training format.
+func appendPending(filename string, ss []ghal.Sentence) error {
+	lock, err := lockPending(filename)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, s := range ss {
+		err := enc.Encode(s)
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// readPending reads all of the sentences from the pending journal in the
+// given file. A nonexistent journal is treated as empty.
+func readPending(filename string) ([]ghal.Sentence, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret []ghal.Sentence
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var s ghal.Sentence
+		err := json.Unmarshal([]byte(line), &s)
+		if err != nil {
+			return ret, fmt.Errorf("invalid pending sentence %d: %s", len(ret)+1, err)
+		}
+		ret = append(ret, s)
+	}
+	return ret, sc.Err()
+}
+
+// writePending replaces the pending journal in the given file with the given
+// sentences, removing the file altogether if there are none. The caller must
+// hold the lock on the journal, taken using lockPending.
+func writePending(filename string, ss []ghal.Sentence) error {
+	if len(ss) == 0 {
+		err := os.Remove(filename)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	tempName := filename + ".new"
+	f, err := os.Create(tempName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, s := range ss {
+		err := enc.Encode(s)
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempName, filename)
+}
+
+func review(brainFile string) int {
+	brain, err := loadBrainFile(brainFile)
+	if os.IsNotExist(err) {
+		brain = newBrain()
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	pendingFile := pendingFilename(brainFile)
+	pending, err := readPendingLocked(pendingFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading pending sentences from %q: %s\n", pendingFile, err)
+		return 1
+	}
+	if len(pending) == 0 {
+		fmt.Printf("There are no sentences waiting for review.\n")
+		return 0
+	}
+
+	// Chat sessions may append more sentences while we're reviewing, so we
+	// remember how many we read in order to keep any others.
+	read := len(pending)
+
+	approved, rejected := 0, 0
+Batches:
+	for len(pending) > 0 {
+		n := reviewBatchSize
+		if n > len(pending) {
+			n = len(pending)
+		}
+		batch := pending[:n]
+
+		fmt.Printf("\n%d sentences awaiting review. Next batch:\n", len(pending))
+		for i, s := range batch {
+			fmt.Printf("%3d. %s\n", i+1, s)
+		}
+
+		inp := prompt.Input("[a]pprove, [r]eject, or [q]uit? ", noComplete)
+		switch strings.ToLower(strings.TrimSpace(inp)) {
+		case "a", "approve":
+			brain.AddSentencesFrom(batch, chatSource)
+			approved += n
+		case "r", "reject":
+			rejected += n
+		case "q", "quit", "exit":
+			fmt.Printf("Leaving the remaining sentences for later review.\n")
+			break Batches
+		default:
+			fmt.Printf("Please answer \"a\", \"r\", or \"q\".\n")
+			continue
+		}
+		pending = pending[n:]
+	}
+
+	if approved > 0 {
+		safeSaveBrain(brain, brainFile)
+	}
+	err = updatePending(pendingFile, read, pending)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to update pending sentences in %q: %s\n", pendingFile, err)
+		return 1
+	}
+	fmt.Printf("Approved %d sentences and rejected %d sentences.\n", approved, rejected)
+	return 0
+}
+
+// readPendingLocked is like readPending but holds the lock on the journal
+// while reading it, so that it doesn't see a partly appended sentence.
+func readPendingLocked(filename string) ([]ghal.Sentence, error) {
+	lock, err := lockPending(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+	return readPending(filename)
+}
+
+// updatePending replaces the pending journal in the given file with the given
+// sentences left over from the first n that were read from it, followed by
+// any sentences that have been appended to it since they were read.
+func updatePending(filename string, n int, remaining []ghal.Sentence) error {
+	lock, err := lockPending(filename)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	current, err := readPending(filename)
+	if err != nil {
+		return err
+	}
+	if len(current) > n {
+		remaining = append(remaining, current[n:]...)
+	}
+	return writePending(filename, remaining)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// rollback restores the given brain file from one of its snapshots, selected
+// by the time shown in the list of snapshots or by "latest". With no
+// arguments, it just lists the available snapshots.
+func rollback(brainFile string, args []string) int {
+	snaps, err := ghal.Snapshots(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find snapshots of %q: %s\n", brainFile, err)
+		return 1
+	}
+	if len(snaps) == 0 {
+		fmt.Fprintf(os.Stderr, "There are no snapshots of %q; use --snapshots when saving it to keep some\n", brainFile)
+		return 1
+	}
+
+	if len(args) == 0 {
+		fmt.Printf("Snapshots of %s, newest first:\n", brainFile)
+		for _, snap := range snaps {
+			fmt.Printf("  %s  (%s)\n", snap.Time.Format(time.RFC3339Nano), snap.Filename)
+		}
+		return 0
+	}
+
+	var snap ghal.Snapshot
+	if args[0] == "latest" {
+		snap = snaps[0]
+	} else {
+		t, err := time.Parse(time.RFC3339, args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid snapshot time %q; must be \"latest\" or a time as listed by \"gopherhal rollback\"\n", args[0])
+			return 1
+		}
+		found := false
+		for _, candidate := range snaps {
+			if candidate.Time.Equal(t) {
+				snap, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "There is no snapshot of %q from %s\n", brainFile, args[0])
+			return 1
+		}
+	}
+
+	// We'll make sure the snapshot is actually usable before we replace the
+	// current brain with it.
+	if _, err := ghal.LoadBrainFile(snap.Filename); err != nil {
+		fmt.Fprintf(os.Stderr, "Snapshot %q is not a valid brain: %s\n", snap.Filename, err)
+		return 1
+	}
+	err = ghal.RestoreSnapshot(brainFile, snap, snapshotPolicy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to restore snapshot %q: %s\n", snap.Filename, err)
+		return 1
+	}
+	fmt.Printf("Restored %s from the snapshot taken at %s\n", brainFile, snap.Time.Format(time.RFC3339Nano))
+	return 0
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// stats implements the "stats" command, which prints a summary of the size
+// of the brain in the given file.
+func stats(brainFile string) int {
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	if jsonOutput {
+		return printJSON(newJSONStats(brain))
+	}
+
+	// The learning rate isn't shown, since it covers only what this process
+	// has learned, which is nothing.
+	s := brain.Stats()
+	fmt.Printf("Words:   %d\n", s.Words)
+	fmt.Printf("Chains:  %d\n", s.Chains)
+	fmt.Printf("Sources: %d\n", s.Sources)
+	fmt.Printf("Memory:  around %s\n", formatBytes(s.MemoryEstimate))
+	return 0
+}
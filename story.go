@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// storySentences is the number of sentences the "story" command writes if
+// the number isn't given.
+const storySentences = 5
+
+// story prints a paragraph that begins with a sentence about the given
+// keyword, for the "story" command.
+func story(brainFile string, args []string) int {
+	n := storySentences
+	if len(args) == 2 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "Invalid number of sentences %q\n", args[1])
+			return 1
+		}
+	}
+
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+	if !brain.IsTrained(0) {
+		fmt.Fprintf(os.Stderr, "The brain in %q needs training first; use \"gopherhal train\" to teach it\n", brainFile)
+		return 1
+	}
+	keyword, ok := lookupWord(brain, args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "The brain doesn't know the word %q\n", args[0])
+		return 1
+	}
+
+	paragraph := brain.MakeParagraph(keyword, n)
+	if len(paragraph) == 0 {
+		fmt.Fprintf(os.Stderr, "Couldn't write anything about %q\n", args[0])
+		return 1
+	}
+	texts := make([]string, len(paragraph))
+	for i, s := range paragraph {
+		texts[i] = brain.Capitalize(s, caseThreshold).String()
+	}
+	if jsonOutput {
+		return printJSON(storySummary{
+			Keyword:   keyword,
+			Sentences: texts,
+		})
+	}
+	fmt.Println(strings.Join(texts, " "))
+	return 0
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// swap implements the "swap" command, which lists the brain's word swaps or
+// adds or removes one.
+func swap(brainFile string, args []string) int {
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	if len(args) == 0 {
+		swaps := brain.Swaps()
+		if len(swaps) == 0 {
+			fmt.Printf("There are no word swaps.\n")
+			return 0
+		}
+		froms := make([]string, 0, len(swaps))
+		for from := range swaps {
+			froms = append(froms, from)
+		}
+		sort.Strings(froms)
+		for _, from := range froms {
+			fmt.Printf("  %s -> %s\n", from, swaps[from])
+		}
+		return 0
+	}
+
+	if args[0] == "add" {
+		brain.SetSwap(args[1], args[2])
+		fmt.Printf("%s is now swapped for %s\n", args[1], args[2])
+	} else {
+		brain.RemoveSwap(args[1])
+		fmt.Printf("%s is no longer swapped\n", args[1])
+	}
+	safeSaveBrain(brain, brainFile)
+	return 0
+}
+
+// greetings implements the "greetings" command, which lists the brain's
+// greeting words or replaces them with the given words.
+func greetings(brainFile string, args []string) int {
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	if len(args) == 0 {
+		texts := brain.Greetings()
+		if len(texts) == 0 {
+			fmt.Printf("There are no greeting words.\n")
+			return 0
+		}
+		fmt.Printf("  %s\n", strings.Join(texts, " "))
+		return 0
+	}
+
+	brain.SetGreetings(args[1:])
+	if len(args) == 1 {
+		fmt.Printf("The greeting words have been cleared\n")
+	} else {
+		fmt.Printf("The greeting words are now: %s\n", strings.Join(brain.Greetings(), " "))
+	}
+	safeSaveBrain(brain, brainFile)
+	return 0
+}
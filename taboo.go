@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// taboo implements the "taboo" command, which lists the brain's taboo words
+// or adds or removes words, in every part of speech the brain knows them as.
+func taboo(brainFile string, args []string) int {
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	if len(args) == 0 {
+		words := brain.TabooWords()
+		if len(words) == 0 {
+			fmt.Printf("There are no taboo words.\n")
+			return 0
+		}
+		for _, w := range words {
+			fmt.Printf("  %s/%s\n", w.Text, w.Tag)
+		}
+		return 0
+	}
+
+	for _, text := range args[1:] {
+		words := lookupWords(brain, text)
+		if len(words) == 0 {
+			// The brain doesn't know the word yet, but it might learn it
+			// later, so we'll make it taboo as a noun, which is the part of
+			// speech keywords are most likely to be.
+			words = []ghal.Word{ghal.MakeWord("NN", text)}
+		}
+		for _, w := range words {
+			if args[0] == "add" {
+				brain.TabooWord(w)
+				fmt.Printf("%s/%s is now taboo\n", w.Text, w.Tag)
+			} else {
+				brain.UntabooWord(w)
+				fmt.Printf("%s/%s is no longer taboo\n", w.Text, w.Tag)
+			}
+		}
+	}
+	safeSaveBrain(brain, brainFile)
+	return 0
+}
+
+// lookupWords finds all of the words with the given text that the brain
+// knows, in any part of speech.
+func lookupWords(brain *ghal.Brain, text string) []ghal.Word {
+	want := ghal.MakeWord("", text).Text
+	return brain.TopWords(int(^uint(0)>>1), func(w ghal.Word) bool {
+		return w.Text == want
+	})
+}
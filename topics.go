@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// topicsCount is the number of topics listed by the "topics" command.
+const topicsCount = 30
+
+func topics(brainFile string) int {
+	brain, err := loadBrainFile(brainFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	words := brain.TopWords(topicsCount, ghal.Word.IsNoun)
+	if len(words) == 0 {
+		fmt.Printf("I don't know about anything yet.\n")
+		return 0
+	}
+	for _, w := range words {
+		fmt.Printf("%8d  %s\n", brain.WordFrequency(w), w.Text)
+	}
+	return 0
+}
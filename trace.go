@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// debugJSON is whether chat writes a chatTrace for each exchange to stderr,
+// as set by the --debug-json flag.
+var debugJSON bool
+
+// chatTrace is the record of how one reply was chosen in chat, which is
+// written as a line of JSON for each exchange when --debug-json is set, so
+// that tools can analyze the replies across a whole transcript.
+type chatTrace struct {
+	// Input is the sentences the reply is to, as parsed.
+	Input []ghal.Sentence `json:"input"`
+
+	// Keywords are the keywords selected from the input, which candidate
+	// replies were constructed around.
+	Keywords []ghal.Word `json:"keywords"`
+
+	// Kind is how the reply was made: "reply" for a reply made from the
+	// keywords, "reason" for a reason given in answer to a "why" question,
+	// or "question" for a question asked when no reply could be made.
+	// It is empty if there was no reply at all.
+	Kind string `json:"kind,omitempty"`
+
+	// Reply is the chosen reply, both as words and as text.
+	Reply     ghal.Sentence `json:"reply"`
+	ReplyText string        `json:"reply_text"`
+
+	// Score and Candidates are the score of the chosen reply and the
+	// number of candidates it was chosen from, for a reply of kind "reply".
+	Score      int `json:"score"`
+	Candidates int `json:"candidates"`
+
+	// Attribution is the sources that contributed each part of the reply.
+	Attribution []chatTraceSpan `json:"attribution,omitempty"`
+}
+
+// chatTraceSpan is one span of the attribution in a chatTrace, as returned
+// by Brain.Attribution.
+type chatTraceSpan struct {
+	Text    string   `json:"text"`
+	Sources []string `json:"sources"`
+}
+
+// newChatTrace starts the trace of an exchange with the given input.
+func newChatTrace(brain *ghal.Brain, opts ghal.GenerationOptions, input []ghal.Sentence) *chatTrace {
+	keywords := opts.Keywords
+	if keywords == nil {
+		keywords = ghal.DefaultKeywords
+	}
+	return &chatTrace{
+		Input:    input,
+		Keywords: keywords.Keywords(brain, input).Sorted(),
+	}
+}
+
+// setReply records the chosen reply in the trace.
+func (t *chatTrace) setReply(brain *ghal.Brain, kind string, reply ghal.ScoredReply) {
+	t.Kind = kind
+	t.Reply = reply.Sentence
+	t.ReplyText = reply.Sentence.String()
+	t.Score = reply.Score
+	t.Candidates = reply.Candidates
+	t.Attribution = nil
+	for _, span := range brain.Attribution(reply.Sentence) {
+		t.Attribution = append(t.Attribution, chatTraceSpan{
+			Text:    reply.Sentence[span.Start:span.End].String(),
+			Sources: span.Sources,
+		})
+	}
+}
+
+// write writes the trace to the given writer as a single line of JSON.
+func (t *chatTrace) write(w io.Writer) {
+	err := json.NewEncoder(w).Encode(t)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write debug trace: %s\n", err)
+	}
+}
@@ -0,0 +1,282 @@
+package trainhal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"unicode"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// analyzeTopTokens is the number of most-frequent tokens that AnalyzeCorpus
+// reports.
+const analyzeTopTokens = 20
+
+// CorpusSource is a single training input given to AnalyzeCorpus. Name and
+// MediaType are used to select a parser in the same way as the filename and
+// mediaType arguments to ParseTrainingInput.
+type CorpusSource struct {
+	Name      string
+	MediaType string
+	Reader    io.Reader
+
+	// Weight is the number of times each sentence in the source counts as
+	// having been learned when used with TrainBrain, as for
+	// Brain.AddSentenceWeighted, so that important sources can have more
+	// influence than bulk ones. Zero or less means one. AnalyzeCorpus
+	// ignores the weight.
+	Weight int
+}
+
+// CorpusStats summarizes a training corpus, as returned by AnalyzeCorpus.
+type CorpusStats struct {
+	// Sources is the number of sources that were analyzed.
+	Sources int
+
+	// Sentences is the total number of sentences found across all sources,
+	// including duplicates.
+	Sentences int
+
+	// DuplicateSentences is the number of sentences that exactly repeat an
+	// earlier sentence, possibly from a different source.
+	DuplicateSentences int
+
+	// Words is the total number of words across all sentences, not counting
+	// punctuation.
+	Words int
+
+	// Vocabulary is the number of distinct words across all sentences, not
+	// counting punctuation.
+	Vocabulary int
+
+	// LengthCounts is the distribution of sentence lengths in words,
+	// including punctuation, where the element at index n is the number of
+	// sentences that are n words long.
+	LengthCounts []int
+
+	// TopTokens are the most frequent words in the corpus, in decreasing
+	// order of frequency.
+	TopTokens []TokenCount
+
+	// Languages is the number of sentences detected as being in each
+	// language, keyed by an ISO 639-1 code. Sentences whose language
+	// couldn't be guessed are counted under the empty string.
+	Languages map[string]int
+}
+
+// TokenCount is a word and the number of times it appears in a corpus.
+type TokenCount struct {
+	Text  string
+	Count int
+}
+
+// DuplicateRatio returns the fraction of sentences that repeat an earlier
+// sentence, between zero and one.
+func (s *CorpusStats) DuplicateRatio() float64 {
+	if s.Sentences == 0 {
+		return 0
+	}
+	return float64(s.DuplicateSentences) / float64(s.Sentences)
+}
+
+// ShortSentences returns the number of sentences that are shorter than the
+// given number of words, which is useful for deciding on a chain length
+// since a brain can learn nothing from a sentence shorter than its chains.
+func (s *CorpusStats) ShortSentences(n int) int {
+	count := 0
+	for l, c := range s.LengthCounts {
+		if l >= n {
+			break
+		}
+		count += c
+	}
+	return count
+}
+
+// LengthPercentile returns the sentence length, in words, that the given
+// fraction of sentences are no longer than. For example, LengthPercentile(0.5)
+// returns the median sentence length.
+func (s *CorpusStats) LengthPercentile(p float64) int {
+	want := int(p * float64(s.Sentences))
+	seen := 0
+	for l, c := range s.LengthCounts {
+		seen += c
+		if seen > want {
+			return l
+		}
+	}
+	return len(s.LengthCounts) - 1
+}
+
+// AnalyzeCorpus parses all of the given sources and summarizes the
+// sentences they contain, so that a corpus can be checked before spending
+// the time to train a brain on it.
+//
+// The sources are parsed without part-of-speech tagging, which makes
+// analysis much faster than training but means that punctuation is
+// recognized only by the absence of letters and digits.
+func AnalyzeCorpus(sources []CorpusSource) (CorpusStats, error) {
+	stats := CorpusStats{
+		Languages: make(map[string]int),
+	}
+	p := ghal.NewParser()
+	p.SkipTagging = true
+
+	seen := make(map[string]struct{})
+	freqs := make(map[string]int)
+	for _, src := range sources {
+		sentences, err := ParseTrainingInputWithParser(src.Reader, src.Name, src.MediaType, p)
+		if err != nil {
+			return stats, fmt.Errorf("%s: %s", src.Name, err)
+		}
+		stats.Sources++
+
+		for _, s := range sentences {
+			stats.Sentences++
+			text := s.String()
+			if _, dup := seen[text]; dup {
+				stats.DuplicateSentences++
+			} else {
+				seen[text] = struct{}{}
+			}
+
+			for len(stats.LengthCounts) <= len(s) {
+				stats.LengthCounts = append(stats.LengthCounts, 0)
+			}
+			stats.LengthCounts[len(s)]++
+
+			for _, w := range s {
+				if !isWordToken(w.Text) {
+					continue
+				}
+				stats.Words++
+				freqs[w.Text]++
+			}
+
+			stats.Languages[detectLanguage(s)]++
+		}
+	}
+
+	stats.Vocabulary = len(freqs)
+	stats.TopTokens = topTokens(freqs, analyzeTopTokens)
+	return stats, nil
+}
+
+func topTokens(freqs map[string]int, n int) []TokenCount {
+	ret := make([]TokenCount, 0, len(freqs))
+	for text, count := range freqs {
+		ret = append(ret, TokenCount{Text: text, Count: count})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count != ret[j].Count {
+			return ret[i].Count > ret[j].Count
+		}
+		return ret[i].Text < ret[j].Text
+	})
+	if len(ret) > n {
+		ret = ret[:n]
+	}
+	return ret
+}
+
+// isWordToken returns true if the given token contains at least one letter
+// or digit, and is thus not punctuation.
+func isWordToken(text string) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// languageStopwords are some very common words in each of the languages
+// that detectLanguage can distinguish amongst those written in the Latin
+// script. Words that several of the languages share count as a vote for
+// each of them.
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "is", "of", "to", "it", "that", "you", "with", "was", "for", "this"},
+	"es": {"el", "los", "las", "es", "y", "que", "por", "con", "una", "pero", "para", "muy"},
+	"fr": {"le", "les", "est", "et", "une", "pas", "avec", "pour", "que", "dans", "je", "vous"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "mit", "ein", "eine", "zu", "sie"},
+	"it": {"il", "gli", "è", "che", "non", "per", "una", "sono", "di", "con", "ma", "anche"},
+	"pt": {"os", "as", "é", "que", "não", "uma", "com", "para", "um", "mas", "muito", "do"},
+	"nl": {"het", "een", "en", "van", "ik", "niet", "dat", "is", "zijn", "met", "ook", "maar"},
+}
+
+var stopwordLanguages = func() map[string][]string {
+	ret := make(map[string][]string)
+	for lang, words := range languageStopwords {
+		for _, w := range words {
+			ret[w] = append(ret[w], lang)
+		}
+	}
+	return ret
+}()
+
+// detectLanguage makes a rough guess at the language of the given sentence,
+// returning its ISO 639-1 code or an empty string if it can't tell. Scripts
+// that are used by only one common language are recognized directly, and
+// otherwise the guess is based on the stopwords the sentence contains.
+func detectLanguage(s ghal.Sentence) string {
+	scripts := make(map[string]int)
+	for _, w := range s {
+		for _, r := range w.Text {
+			switch {
+			case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+				scripts["ja"]++
+			case unicode.Is(unicode.Han, r):
+				scripts["zh"]++
+			case unicode.Is(unicode.Hangul, r):
+				scripts["ko"]++
+			case unicode.Is(unicode.Cyrillic, r):
+				scripts["ru"]++
+			case unicode.Is(unicode.Greek, r):
+				scripts["el"]++
+			case unicode.Is(unicode.Arabic, r):
+				scripts["ar"]++
+			case unicode.Is(unicode.Hebrew, r):
+				scripts["he"]++
+			case unicode.Is(unicode.Thai, r):
+				scripts["th"]++
+			case unicode.Is(unicode.Devanagari, r):
+				scripts["hi"]++
+			}
+		}
+	}
+	if scripts["ja"] > 0 {
+		// Japanese text mixes kana with Han characters, so any kana at all
+		// is a better signal than the Han characters alone.
+		return "ja"
+	}
+	if lang := mostCommon(scripts); lang != "" {
+		return lang
+	}
+
+	votes := make(map[string]int)
+	for _, w := range s {
+		for _, lang := range stopwordLanguages[w.Text] {
+			votes[lang]++
+		}
+	}
+	return mostCommon(votes)
+}
+
+// mostCommon returns the key with the greatest count, or an empty string if
+// there is no single such key.
+func mostCommon(counts map[string]int) string {
+	best, bestCount, tied := "", 0, false
+	for k, c := range counts {
+		switch {
+		case c > bestCount:
+			best, bestCount, tied = k, c, false
+		case c == bestCount:
+			tied = true
+		}
+	}
+	if tied {
+		return ""
+	}
+	return best
+}
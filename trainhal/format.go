@@ -5,6 +5,7 @@ import (
 	"io"
 	"mime"
 	"path/filepath"
+	"strings"
 
 	"github.com/apparentlymart/gopherhal/ghal"
 	"golang.org/x/text/encoding"
@@ -21,6 +22,9 @@ const (
 	formatPlain     fileFormat = "txt"
 	formatMegaHAL   fileFormat = "mhtrn"
 	formatJSONUtter fileFormat = "jsonu"
+	formatWARC      fileFormat = "warc"
+	formatJupyter   fileFormat = "ipynb"
+	formatSubtitles fileFormat = "subs"
 )
 
 // selectFormat tries to determine a file format and suggested character
@@ -71,12 +75,19 @@ func selectFormatFromMediaType(mediaType string) (fileFormat, encoding.Encoding)
 		return formatFeed, enc
 	case "text/plain":
 		return formatPlain, enc
+	case "text/vtt", "application/x-subrip":
+		return formatSubtitles, enc
+	case "application/warc":
+		return formatWARC, enc
 	default:
 		return formatUnknown, enc
 	}
 }
 
 func selectFormatFromFilename(filename string) fileFormat {
+	if strings.HasSuffix(filename, ".warc.gz") {
+		return formatWARC
+	}
 	ext := filepath.Ext(filename)
 	if ext == "" {
 		return formatUnknown
@@ -100,25 +111,42 @@ func selectFormatFromFilename(filename string) fileFormat {
 		return formatMegaHAL
 	case ".jsonutter":
 		return formatJSONUtter
+	case ".warc":
+		return formatWARC
+	case ".ipynb":
+		return formatJupyter
+	case ".srt", ".vtt":
+		return formatSubtitles
 	default:
 		return formatUnknown
 	}
 }
 
-func parseSource(r io.Reader, format fileFormat, maybeEnc encoding.Encoding) ([]ghal.Sentence, error) {
+func parseSource(r io.Reader, format fileFormat, maybeEnc encoding.Encoding, p *ghal.Parser) ([]ghal.Sentence, error) {
 	switch format {
 	case formatHTML:
-		return parseHTML(r)
+		return parseHTML(r, p)
 	case formatMarkdown:
-		return parseMarkdown(r)
+		return parseMarkdown(r, p)
 	case formatFeed:
-		return parseFeed(r)
+		return parseFeed(r, p)
 	case formatPlain:
-		return parsePlain(r, maybeEnc)
+		return parsePlain(r, maybeEnc, p)
 	case formatMegaHAL:
-		return parseMegaHALTraining(r)
+		return parseMegaHALTraining(r, p)
 	case formatJSONUtter:
-		return parseJSONUtter(r)
+		return parseJSONUtter(r, p)
+	case formatJupyter:
+		return parseJupyter(r, p)
+	case formatSubtitles:
+		return parseSubtitles(r, p)
+	case formatWARC:
+		us, err := ParseWARC(r, URLFilter{}, p)
+		ret := make([]ghal.Sentence, len(us))
+		for i, u := range us {
+			ret[i] = u.Sentence
+		}
+		return ret, err
 	default:
 		return nil, fmt.Errorf("unknown file format")
 	}
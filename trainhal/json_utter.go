@@ -1,35 +1,105 @@
 package trainhal
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/apparentlymart/gopherhal/ghal"
 )
 
-func parseJSONUtter(r io.Reader) ([]ghal.Sentence, error) {
+// jsonUtterance is the object form of an element in a "JSON Utter" file,
+// which allows a sentence to carry its own provenance information. Elements
+// can alternatively be just the sentence's array of words.
+type jsonUtterance struct {
+	Words   ghal.Sentence `json:"words"`
+	Source  string        `json:"source,omitempty"`
+	Time    string        `json:"time,omitempty"`
+	Speaker string        `json:"speaker,omitempty"`
+}
+
+func parseJSONUtter(r io.Reader, p *ghal.Parser) ([]ghal.Sentence, error) {
+	us, _, err := parseJSONUtterances(r, p)
+	ret := make([]ghal.Sentence, len(us))
+	for i, u := range us {
+		ret[i] = u.Sentence
+	}
+	return ret, err
+}
+
+// parseJSONUtterDialogue parses a "JSON Utter" file as a dialogue, where each
+// run of consecutive sentences with the same speaker is a turn. Sentences
+// without a speaker are each treated as a separate turn.
+func parseJSONUtterDialogue(r io.Reader, p *ghal.Parser) ([]ghal.DialogueTurn, error) {
+	us, speakers, err := parseJSONUtterances(r, p)
+	var ret []ghal.DialogueTurn
+	for i, u := range us {
+		if n := len(ret); n > 0 && speakers[i] != "" && ret[n-1].Speaker == speakers[i] {
+			ret[n-1].Sentences = append(ret[n-1].Sentences, u.Sentence)
+			continue
+		}
+		ret = append(ret, ghal.DialogueTurn{
+			Speaker:   speakers[i],
+			Sentences: []ghal.Sentence{u.Sentence},
+		})
+	}
+	return ret, err
+}
+
+// parseJSONUtterances parses a "JSON Utter" file, returning its sentences
+// along with the speaker of each one, which is empty if not given.
+func parseJSONUtterances(r io.Reader, p *ghal.Parser) ([]ghal.Utterance, []string, error) {
 	// "JSON Utter" is a special JSON format that has already-parsed,
 	// pre-tagged sentences. This is a fast way to import training data
 	// that was parsed in a separate preprocessing step.
+	//
+	// Each element is either an array of words or an object with a "words"
+	// property and optional "source", "time", and "speaker" properties,
+	// with the time in RFC 3339 format. The brain keeps only the earliest
+	// time for each source, not the time of each sentence.
 	dec := json.NewDecoder(r)
 
-	var ret []ghal.Sentence
+	var ret []ghal.Utterance
+	var speakers []string
 
 	tok, err := dec.Token()
 	if err != nil {
-		return ret, nil
+		return ret, speakers, nil
 	}
 	if tok != json.Delim('[') {
-		return ret, fmt.Errorf("JSON does not have array at root")
+		return ret, speakers, fmt.Errorf("JSON does not have array at root")
 	}
 	for dec.More() {
-		var sentence ghal.Sentence
-		err = dec.Decode(&sentence)
+		var raw json.RawMessage
+		err = dec.Decode(&raw)
+		if err != nil {
+			return ret, speakers, err
+		}
+
+		var ju jsonUtterance
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte{'{'}) {
+			err = json.Unmarshal(raw, &ju)
+		} else {
+			err = json.Unmarshal(raw, &ju.Words)
+		}
 		if err != nil {
-			return ret, err
+			return ret, speakers, fmt.Errorf("invalid sentence %d: %s", len(ret), err)
+		}
+
+		u := ghal.Utterance{
+			Sentence: p.Intern(ju.Words),
+			Source:   ju.Source,
+		}
+		if ju.Time != "" {
+			u.Time, err = time.Parse(time.RFC3339, ju.Time)
+			if err != nil {
+				return ret, speakers, fmt.Errorf("invalid time for sentence %d: %s", len(ret), err)
+			}
 		}
-		ret = append(ret, sentence)
+		ret = append(ret, u)
+		speakers = append(speakers, ju.Speaker)
 	}
-	return ret, nil
+	return ret, speakers, nil
 }
@@ -0,0 +1,327 @@
+package trainhal
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// CommentSyntax describes how to find the prose in the source code of a
+// particular programming language.
+type CommentSyntax struct {
+	// Line are the prefixes that begin comments extending to the end of
+	// the line, like "//" or "#".
+	Line []string
+
+	// Block are the pairs of delimiters that begin and end comments that
+	// may span many lines, like "/*" and "*/".
+	Block [][2]string
+
+	// Strings are the delimiters of the language's string literals, so that
+	// comment markers inside them aren't mistaken for comments.
+	Strings []string
+
+	// Docstrings are the delimiters of string literals that are considered
+	// to be documentation when they appear at the start of a line, like
+	// Python's triple-quoted strings.
+	Docstrings []string
+}
+
+// DefaultCommentSyntax is the comment syntax used for the languages that
+// CodeOptions doesn't override, keyed by filename extension.
+var DefaultCommentSyntax = map[string]CommentSyntax{
+	".go":    cStyleComments("\"", "'", "`"),
+	".c":     cStyleComments("\"", "'"),
+	".h":     cStyleComments("\"", "'"),
+	".cc":    cStyleComments("\"", "'"),
+	".cpp":   cStyleComments("\"", "'"),
+	".hpp":   cStyleComments("\"", "'"),
+	".java":  cStyleComments("\"", "'"),
+	".js":    cStyleComments("\"", "'", "`"),
+	".ts":    cStyleComments("\"", "'", "`"),
+	".rs":    cStyleComments("\""),
+	".swift": cStyleComments("\""),
+	".kt":    cStyleComments("\"", "'"),
+	".cs":    cStyleComments("\"", "'"),
+	".py": {
+		Line:       []string{"#"},
+		Strings:    []string{`"""`, "'''", "\"", "'"},
+		Docstrings: []string{`"""`, "'''"},
+	},
+	".rb": {
+		Line:    []string{"#"},
+		Block:   [][2]string{{"=begin", "=end"}},
+		Strings: []string{"\"", "'"},
+	},
+	".sh": {
+		Line:    []string{"#"},
+		Strings: []string{"\"", "'"},
+	},
+}
+
+func cStyleComments(strs ...string) CommentSyntax {
+	return CommentSyntax{
+		Line:    []string{"//"},
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: strs,
+	}
+}
+
+// CodeOptions customizes how ParseCodeRepository extracts prose.
+type CodeOptions struct {
+	// Languages overrides DefaultCommentSyntax for the given filename
+	// extensions. Files with extensions in neither table are skipped,
+	// except for Markdown and Jupyter notebook files. Mapping an extension
+	// to a zero CommentSyntax skips files with that extension.
+	Languages map[string]CommentSyntax
+}
+
+func (o CodeOptions) syntax(filename string) (CommentSyntax, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if syntax, ok := o.Languages[ext]; ok {
+		return syntax, len(syntax.Line) > 0 || len(syntax.Block) > 0 || len(syntax.Docstrings) > 0
+	}
+	syntax, ok := DefaultCommentSyntax[ext]
+	return syntax, ok
+}
+
+// ParseCodeRepository extracts the prose from the files in the given
+// directory tree containing source code, learning from Markdown files such
+// as READMEs, from the Markdown cells and code comments in Jupyter notebooks,
+// and from the comments and docstrings in source files, while skipping the
+// code itself.
+//
+// Each of the returned utterances has the path of the file it came from,
+// relative to the given directory, as its source. Hidden directories like
+// .git are skipped, as are directories of third-party code like "vendor"
+// and "node_modules".
+func ParseCodeRepository(dir string, opts CodeOptions, p *ghal.Parser) ([]ghal.Utterance, error) {
+	var ret []ghal.Utterance
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		var paras []string
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".md", ".markdown":
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			paras = markdownParagraphs(string(src))
+		case ".ipynb":
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			paras, err = jupyterParagraphs(f, opts)
+			f.Close()
+			if err != nil {
+				// A notebook we can't understand shouldn't prevent us from
+				// learning the rest of the repository.
+				return nil
+			}
+		default:
+			syntax, ok := opts.syntax(path)
+			if !ok {
+				return nil
+			}
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			paras = codeComments(string(src), syntax)
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		source := filepath.ToSlash(rel)
+		for _, para := range paras {
+			ss, _ := p.ParseText(para)
+			for _, s := range ss {
+				ret = append(ret, ghal.Utterance{
+					Sentence: s,
+					Source:   source,
+				})
+			}
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// codeComments returns the prose paragraphs in the comments and docstrings of
+// the given source code. Consecutive line comments are joined together, and
+// each blank comment line or block comment begins a new paragraph. Comments
+// that look more like code or compiler directives than prose are skipped.
+func codeComments(src string, syntax CommentSyntax) []string {
+	var ret []string
+	var para []string
+	endPara := func() {
+		if len(para) > 0 {
+			text := strings.Join(para, " ")
+			if looksLikeProse(text) {
+				ret = append(ret, text)
+			}
+			para = para[:0]
+		}
+	}
+	addLines := func(text string) {
+		for _, line := range strings.Split(text, "\n") {
+			// Block comments often have a decorative margin of asterisks.
+			line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "*"))
+			if line == "" {
+				endPara()
+				continue
+			}
+			para = append(para, line)
+		}
+	}
+
+	lineStart, lastLineComment := true, -1
+	line := 0
+	for i := 0; i < len(src); {
+		if src[i] == '\n' {
+			line++
+			lineStart = true
+			i++
+			if lastLineComment != line-1 {
+				endPara()
+			}
+			continue
+		}
+		if src[i] == ' ' || src[i] == '\t' || src[i] == '\r' {
+			i++
+			continue
+		}
+		atLineStart := lineStart
+		lineStart = false
+
+		if prefix := hasAnyPrefix(src[i:], syntax.Line); prefix != "" {
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			text := src[i+len(prefix) : i+end]
+			if lastLineComment != line-1 && lastLineComment != line {
+				endPara()
+			}
+			addLines(strings.TrimLeft(text, strings.TrimSpace(prefix)))
+			lastLineComment = line
+			i += end
+			continue
+		}
+		if open, close := hasAnyBlockPrefix(src[i:], syntax.Block); open != "" {
+			end := strings.Index(src[i+len(open):], close)
+			if end < 0 {
+				end = len(src) - i - len(open)
+			}
+			text := src[i+len(open) : i+len(open)+end]
+			endPara()
+			addLines(text)
+			endPara()
+			line += strings.Count(text, "\n")
+			i += len(open) + end + len(close)
+			continue
+		}
+		if delim := hasAnyPrefix(src[i:], syntax.Strings); delim != "" {
+			end := stringLiteralEnd(src[i+len(delim):], delim)
+			text := src[i+len(delim) : i+len(delim)+end]
+			if atLineStart && isDocstringDelim(delim, syntax) {
+				endPara()
+				addLines(text)
+				endPara()
+			}
+			line += strings.Count(text, "\n")
+			i += len(delim) + end + len(delim)
+			continue
+		}
+		i++
+	}
+	endPara()
+	return ret
+}
+
+// stringLiteralEnd returns the index in the given source, which begins just
+// after the opening delimiter of a string literal, of the closing delimiter,
+// skipping any backslash-escaped characters. It returns the length of the
+// source if the literal isn't closed.
+func stringLiteralEnd(src, delim string) int {
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '\\' && delim != "`":
+			i++
+		case strings.HasPrefix(src[i:], delim):
+			return i
+		case src[i] == '\n' && len(delim) == 1 && delim != "`":
+			// Single-quoted strings can't span lines in most languages, so
+			// we've probably misunderstood something like an apostrophe.
+			return i
+		}
+	}
+	return len(src)
+}
+
+func isDocstringDelim(delim string, syntax CommentSyntax) bool {
+	for _, d := range syntax.Docstrings {
+		if d == delim {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) string {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+func hasAnyBlockPrefix(s string, delims [][2]string) (string, string) {
+	for _, pair := range delims {
+		if strings.HasPrefix(s, pair[0]) {
+			return pair[0], pair[1]
+		}
+	}
+	return "", ""
+}
+
+// looksLikeProse returns true if the given comment text seems to be written
+// in natural language, rather than being commented-out code, a compiler
+// directive, or a decorative separator.
+func looksLikeProse(text string) bool {
+	if strings.HasPrefix(text, "!") || strings.HasPrefix(text, "go:") || strings.HasPrefix(text, "+build") {
+		return false
+	}
+	words, codeChars := 0, 0
+	for _, f := range strings.Fields(text) {
+		if isWordToken(f) {
+			words++
+		}
+	}
+	for _, r := range text {
+		if strings.ContainsRune("{}();=<>[]|&$", r) {
+			codeChars++
+		}
+	}
+	return words >= 3 && codeChars*20 < len(text)
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/mmcdole/gofeed"
 )
 
-func parseFeed(r io.Reader) ([]ghal.Sentence, error) {
+func parseFeed(r io.Reader, p *ghal.Parser) ([]ghal.Sentence, error) {
 	parser := gofeed.NewParser()
 	feed, err := parser.Parse(r)
 	if err != nil {
@@ -18,15 +18,15 @@ func parseFeed(r io.Reader) ([]ghal.Sentence, error) {
 
 	var ret []ghal.Sentence
 	for _, item := range feed.Items {
-		ss, _ := ghal.ParseText(item.Title)
+		ss, _ := p.ParseText(item.Title)
 		ret = append(ret, ss...)
 
 		contentR := strings.NewReader(item.Content)
-		ss, _ = parseHTMLFragment(contentR)
+		ss, _ = parseHTMLFragment(contentR, p)
 		ret = append(ret, ss...)
 
 		contentR = strings.NewReader(item.Description)
-		ss, _ = parseHTMLFragment(contentR)
+		ss, _ = parseHTMLFragment(contentR, p)
 		ret = append(ret, ss...)
 	}
 	return ret, nil
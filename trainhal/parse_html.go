@@ -10,15 +10,15 @@ import (
 	htmla "golang.org/x/net/html/atom"
 )
 
-func parseHTML(r io.Reader) ([]ghal.Sentence, error) {
+func parseHTML(r io.Reader, p *ghal.Parser) ([]ghal.Sentence, error) {
 	node, err := html.Parse(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %s", err)
 	}
-	return extractHTMLNode(node), nil
+	return extractHTMLNode(node, p), nil
 }
 
-func parseHTMLFragment(r io.Reader) ([]ghal.Sentence, error) {
+func parseHTMLFragment(r io.Reader, p *ghal.Parser) ([]ghal.Sentence, error) {
 	nodes, err := html.ParseFragment(r, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %s", err)
@@ -27,19 +27,19 @@ func parseHTMLFragment(r io.Reader) ([]ghal.Sentence, error) {
 		// If we have direct text nodes at our root then that suggests
 		// we're already inside a prose content element and so we'll
 		// just slurp up all our text content.
-		return extractHTMLNodesTextContent(nodes), nil
+		return extractHTMLNodesTextContent(nodes, p), nil
 	}
 	var ret []ghal.Sentence
 	for _, node := range nodes {
-		ret = append(ret, extractHTMLNode(node)...)
+		ret = append(ret, extractHTMLNode(node, p)...)
 	}
 	return ret, nil
 }
 
-func extractHTMLNode(node *html.Node) []ghal.Sentence {
+func extractHTMLNode(node *html.Node, p *ghal.Parser) []ghal.Sentence {
 	switch node.Type {
 	case html.DocumentNode:
-		return extractHTMLNodeChildren(node)
+		return extractHTMLNodeChildren(node, p)
 	case html.ElementNode:
 		// What we'll do here depends on the element type:
 		// - Some are considered effectively leaf elements that can't possibly
@@ -54,38 +54,38 @@ func extractHTMLNode(node *html.Node) []ghal.Sentence {
 		switch node.DataAtom {
 		case htmla.P, htmla.Li:
 			// Direct child text nodes are probably content.
-			return extractHTMLNodeTextContent(node)
+			return extractHTMLNodeTextContent(node, p)
 		default:
 			// For everything else, we'll just visit the child nodes.
-			return extractHTMLNodeChildren(node)
+			return extractHTMLNodeChildren(node, p)
 		}
 	}
 	return nil
 }
 
-func extractHTMLNodeChildren(node *html.Node) []ghal.Sentence {
+func extractHTMLNodeChildren(node *html.Node, p *ghal.Parser) []ghal.Sentence {
 	var ret []ghal.Sentence
 	node = node.FirstChild
 	for node != nil {
-		ret = append(ret, extractHTMLNode(node)...)
+		ret = append(ret, extractHTMLNode(node, p)...)
 		node = node.NextSibling
 	}
 	return ret
 }
 
-func extractHTMLNodeTextContent(node *html.Node) []ghal.Sentence {
+func extractHTMLNodeTextContent(node *html.Node, p *ghal.Parser) []ghal.Sentence {
 	var buf strings.Builder
 	appendHTMLNodeTextContent(node, &buf)
-	ss, _ := ghal.ParseText(buf.String())
+	ss, _ := p.ParseText(buf.String())
 	return ss
 }
 
-func extractHTMLNodesTextContent(nodes []*html.Node) []ghal.Sentence {
+func extractHTMLNodesTextContent(nodes []*html.Node, p *ghal.Parser) []ghal.Sentence {
 	var buf strings.Builder
 	for _, node := range nodes {
 		appendHTMLNodeTextContent(node, &buf)
 	}
-	ss, _ := ghal.ParseText(buf.String())
+	ss, _ := p.ParseText(buf.String())
 	return ss
 }
 
@@ -0,0 +1,76 @@
+package trainhal
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// jupyterNotebook is the subset of the Jupyter notebook format that we use
+// to find prose.
+type jupyterNotebook struct {
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   json.RawMessage `json:"source"`
+	} `json:"cells"`
+	Metadata struct {
+		LanguageInfo struct {
+			FileExtension string `json:"file_extension"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+func parseJupyter(r io.Reader, p *ghal.Parser) ([]ghal.Sentence, error) {
+	paras, err := jupyterParagraphs(r, CodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var ret []ghal.Sentence
+	for _, para := range paras {
+		ss, _ := p.ParseText(para)
+		ret = append(ret, ss...)
+	}
+	return ret, nil
+}
+
+// jupyterParagraphs returns the prose paragraphs in the given Jupyter
+// notebook, from its Markdown cells and from the comments in its code cells.
+// The notebook's language is assumed to be Python unless its metadata says
+// otherwise.
+func jupyterParagraphs(r io.Reader, opts CodeOptions) ([]string, error) {
+	var nb jupyterNotebook
+	err := json.NewDecoder(r).Decode(&nb)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := nb.Metadata.LanguageInfo.FileExtension
+	if ext == "" {
+		ext = ".py"
+	}
+	syntax, hasSyntax := opts.syntax("notebook" + ext)
+
+	var ret []string
+	for _, cell := range nb.Cells {
+		// A cell's source is either a single string or an array of lines.
+		var src string
+		var lines []string
+		if err := json.Unmarshal(cell.Source, &lines); err == nil {
+			src = strings.Join(lines, "")
+		} else if err := json.Unmarshal(cell.Source, &src); err != nil {
+			continue
+		}
+
+		switch cell.CellType {
+		case "markdown":
+			ret = append(ret, markdownParagraphs(src)...)
+		case "code":
+			if hasSyntax {
+				ret = append(ret, codeComments(src, syntax)...)
+			}
+		}
+	}
+	return ret, nil
+}
@@ -1,12 +1,118 @@
 package trainhal
 
 import (
-	"fmt"
+	"bufio"
 	"io"
+	"regexp"
+	"strings"
 
 	"github.com/apparentlymart/gopherhal/ghal"
 )
 
-func parseMarkdown(r io.Reader) ([]ghal.Sentence, error) {
-	return nil, fmt.Errorf("markdown training not yet supported")
+func parseMarkdown(r io.Reader, p *ghal.Parser) ([]ghal.Sentence, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var ret []ghal.Sentence
+	for _, para := range markdownParagraphs(string(src)) {
+		ss, _ := p.ParseText(para)
+		ret = append(ret, ss...)
+	}
+	return ret, nil
+}
+
+var (
+	markdownFence      = regexp.MustCompile("^\\s*(```|~~~)")
+	markdownListMarker = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+`)
+	markdownRefDef     = regexp.MustCompile(`^\s*\[[^\]]+\]:\s`)
+	markdownCodeSpan   = regexp.MustCompile("`+[^`]*`+")
+	markdownImage      = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	markdownLink       = regexp.MustCompile(`\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])`)
+	markdownAutolink   = regexp.MustCompile(`<[a-z]+:[^>]*>`)
+	markdownHTMLTag    = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	markdownEmphasis   = regexp.MustCompile(`[*_~]+`)
+)
+
+// markdownParagraphs returns the prose paragraphs in the given Markdown
+// source, with all of the markup removed. Code blocks, headings, tables,
+// and HTML blocks are skipped entirely, since they rarely contain whole
+// sentences, and each list item is treated as a separate paragraph.
+func markdownParagraphs(src string) []string {
+	var ret []string
+	var para []string
+	endPara := func() {
+		if len(para) > 0 {
+			ret = append(ret, markdownInline(strings.Join(para, " ")))
+			para = para[:0]
+		}
+	}
+
+	sc := bufio.NewScanner(strings.NewReader(src))
+	sc.Buffer(nil, 1024*1024)
+	inFence, prevBlank := "", true
+	for sc.Scan() {
+		line := sc.Text()
+		if m := markdownFence.FindStringSubmatch(line); m != nil {
+			switch {
+			case inFence == "":
+				endPara()
+				inFence = m[1]
+			case inFence == m[1]:
+				inFence = ""
+			}
+			continue
+		}
+		if inFence != "" {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		blank := trimmed == ""
+		indentedCode := prevBlank && len(para) == 0 && (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t"))
+		prevBlank = blank
+		switch {
+		case blank:
+			endPara()
+		case indentedCode, markdownRefDef.MatchString(line):
+			// Not prose.
+		case strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, "|"), strings.HasPrefix(trimmed, "<"):
+			// Headings, tables, and HTML blocks.
+			endPara()
+		case strings.Trim(trimmed, "-=*_ ") == "":
+			// Thematic breaks and setext heading underlines. The latter
+			// means that the preceding "paragraph" was really a heading.
+			para = para[:0]
+		default:
+			for strings.HasPrefix(trimmed, ">") {
+				trimmed = strings.TrimSpace(trimmed[1:])
+			}
+			if loc := markdownListMarker.FindStringIndex(trimmed); loc != nil {
+				endPara()
+				trimmed = trimmed[loc[1]:]
+			}
+			para = append(para, trimmed)
+		}
+	}
+	endPara()
+	return ret
+}
+
+// markdownInline removes the inline markup from the given Markdown text,
+// keeping the text of links and of code spans that are just a single name,
+// but discarding other code spans and images entirely.
+func markdownInline(text string) string {
+	text = markdownCodeSpan.ReplaceAllStringFunc(text, func(span string) string {
+		span = strings.Trim(span, "`")
+		if strings.ContainsAny(span, " \t") {
+			return ""
+		}
+		return span
+	})
+	text = markdownImage.ReplaceAllString(text, "")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = markdownAutolink.ReplaceAllString(text, "")
+	text = markdownHTMLTag.ReplaceAllString(text, "")
+	text = markdownEmphasis.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
 }
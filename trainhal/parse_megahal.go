@@ -8,17 +8,32 @@ import (
 	"github.com/apparentlymart/gopherhal/ghal"
 )
 
-func parseMegaHALTraining(r io.Reader) ([]ghal.Sentence, error) {
-	sc := bufio.NewScanner(r)
+func parseMegaHALTraining(r io.Reader, p *ghal.Parser) ([]ghal.Sentence, error) {
 	var ret []ghal.Sentence
+	err := scanMegaHALTraining(r, p, func(s ghal.Sentence) bool {
+		ret = append(ret, s)
+		return true
+	})
+	return ret, err
+}
+
+// scanMegaHALTraining is the main implementation of parseMegaHALTraining,
+// which calls the given function with each sentence as soon as it is parsed,
+// stopping early if the function returns false.
+func scanMegaHALTraining(r io.Reader, p *ghal.Parser, yield func(ghal.Sentence) bool) error {
+	sc := bufio.NewScanner(r)
 	for sc.Scan() {
 		line := strings.TrimSpace(sc.Text())
 		if strings.HasPrefix(line, "#") {
 			// It's a comment, so ignore it.
 			continue
 		}
-		sentences, _ := ghal.ParseText(line)
-		ret = append(ret, sentences...)
+		sentences, _ := p.ParseText(line)
+		for _, s := range sentences {
+			if !yield(s) {
+				return nil
+			}
+		}
 	}
-	return ret, nil
+	return nil
 }
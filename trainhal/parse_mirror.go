@@ -0,0 +1,71 @@
+package trainhal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// ParseMirror extracts sentences from the HTML pages in the given directory
+// tree, such as one created by "wget --mirror", learning only the pages whose
+// URLs are selected by the given filter.
+//
+// The URL of each page is taken to be its path relative to the given
+// directory, so with wget's default layout, where the directory contains
+// a subdirectory for each host, URLs have the form "example.com/path/page.html".
+// Each of the returned utterances has the URL of the page it came from as
+// its source. Sentences repeated across many pages are assumed to be
+// boilerplate and are omitted.
+func ParseMirror(dir string, filter URLFilter, p *ghal.Parser) ([]ghal.Utterance, error) {
+	var pages webPages
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !isHTMLFilename(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		url := filepath.ToSlash(rel)
+		if !filter.Match(url) {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		err = pages.add(url, "text/html", f, p)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pages.utterances(), nil
+}
+
+// isHTMLFilename returns true if the given filename looks like an HTML page,
+// including the filenames wget creates for URLs with query strings, like
+// "index.html?page=2".
+func isHTMLFilename(filename string) bool {
+	if i := strings.IndexByte(filepath.Base(filename), '?'); i >= 0 {
+		filename = filename[:len(filename)-len(filepath.Base(filename))+i]
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".html", ".htm", ".xhtml":
+		return true
+	default:
+		return false
+	}
+}
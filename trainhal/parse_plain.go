@@ -1,13 +1,156 @@
 package trainhal
 
 import (
-	"fmt"
+	"bufio"
 	"io"
+	"regexp"
+	"strings"
 
 	"github.com/apparentlymart/gopherhal/ghal"
 	"golang.org/x/text/encoding"
 )
 
-func parsePlain(r io.Reader, maybeEnc encoding.Encoding) ([]ghal.Sentence, error) {
-	return nil, fmt.Errorf("plain text training not yet supported")
+// PlainTextOptions customizes how ParsePlainText extracts sentences from
+// plain text.
+type PlainTextOptions struct {
+	// StripLogPrefixes removes the timestamps, log levels, and bracketed
+	// prefixes like "[main]" from the start of each line, as written by many
+	// programs to their log files, and treats each line that had such a
+	// prefix as a paragraph of its own.
+	StripLogPrefixes bool
+}
+
+func parsePlain(r io.Reader, maybeEnc encoding.Encoding, p *ghal.Parser) ([]ghal.Sentence, error) {
+	return parsePlainText(r, maybeEnc, PlainTextOptions{}, p)
+}
+
+// ParsePlainText extracts sentences from the given plain text, which is
+// UTF-8 encoded, as configured by the given options. Paragraphs are separated
+// by blank lines, and the lines within each paragraph are joined together
+// before parsing so that a sentence can span several lines.
+//
+// None of the returned utterances have a source or time.
+func ParsePlainText(r io.Reader, opts PlainTextOptions, p *ghal.Parser) ([]ghal.Utterance, error) {
+	sentences, err := parsePlainText(r, nil, opts, p)
+	ret := make([]ghal.Utterance, len(sentences))
+	for i, s := range sentences {
+		ret[i].Sentence = s
+	}
+	return ret, err
+}
+
+func parsePlainText(r io.Reader, maybeEnc encoding.Encoding, opts PlainTextOptions, p *ghal.Parser) ([]ghal.Sentence, error) {
+	var ret []ghal.Sentence
+	err := scanPlainText(r, maybeEnc, opts, p, func(s ghal.Sentence) bool {
+		ret = append(ret, s)
+		return true
+	})
+	return ret, err
+}
+
+// maxParagraphLen is the greatest length in bytes of the text that
+// scanPlainText parses at once. A longer paragraph, such as a whole file with
+// a sentence on each line and no blank lines, is parsed in pieces, at the
+// risk of dividing a sentence that spans two lines.
+const maxParagraphLen = 64 * 1024
+
+// scanPlainText is the main implementation of parsePlainText, which calls
+// the given function with each sentence as soon as it is parsed, stopping
+// early if the function returns false. It holds no more than one paragraph
+// in memory at once, so it can parse arbitrarily large files.
+func scanPlainText(r io.Reader, maybeEnc encoding.Encoding, opts PlainTextOptions, p *ghal.Parser, yield func(ghal.Sentence) bool) error {
+	if maybeEnc != nil {
+		r = maybeEnc.NewDecoder().Reader(r)
+	}
+
+	var para []string
+	paraLen := 0
+	stopped := false
+	endPara := func() {
+		if len(para) > 0 && !stopped {
+			ss, _ := p.ParseText(strings.Join(para, " "))
+			for _, s := range ss {
+				if !yield(s) {
+					stopped = true
+					break
+				}
+			}
+		}
+		para = para[:0]
+		paraLen = 0
+	}
+	addLine := func(line string) {
+		para = append(para, line)
+		paraLen += len(line)
+		if paraLen >= maxParagraphLen {
+			endPara()
+		}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1024*1024)
+	for !stopped && sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if opts.StripLogPrefixes {
+			if stripped := stripLogPrefix(line); stripped != line {
+				endPara()
+				if stripped != "" {
+					addLine(stripped)
+					endPara()
+				}
+				continue
+			}
+		}
+		if line == "" {
+			endPara()
+			continue
+		}
+		addLine(line)
+	}
+	endPara()
+	return sc.Err()
+}
+
+// logPrefix matches one of the parts of the prefix that programs commonly
+// write at the start of each line of a log file, along with any separator
+// after it:
+//
+//   - dates and times like "2023-04-01 12:00:01", "2023-04-01T12:00:01.5Z",
+//     "Apr  1 12:00:01", or "12:00:01", including the host and program name
+//     after a syslog timestamp
+//   - Unix timestamps like "1680350401.123"
+//   - log levels like "INFO" or "warning:", and Android log tags like
+//     "E/ActivityManager(123):"
+//   - bracketed or angled prefixes like "[main]", "(pid 12)", or "<nick>"
+//   - syslog program names like "sshd[123]:"
+var logPrefix = regexp.MustCompile(`^(?:` +
+	`\d{4}[-/.]\d{1,2}[-/.]\d{1,2}(?:[T ]\d{1,2}:\d{2}(?::\d{2})?(?:[.,]\d+)?)?(?:Z|\s?[+-]\d{2}:?\d{2}|\s?UTC)?` +
+	`|(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)\s+\d{1,2}(?:\s+\d{4})?\s+\d{1,2}:\d{2}:\d{2}(?:\s+[\w.-]+\s+[\w./-]+(?:\[\d+\])?:)?` +
+	`|\d{1,2}:\d{2}:\d{2}(?:[.,]\d+)?` +
+	`|\d{10}(?:\.\d+)?\b` +
+	`|(?:TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|ERR|FATAL|CRITICAL|CRIT|PANIC)\b` +
+	`|(?i:trace|debug|info|notice|warn|warning|error|fatal|critical)(?::|\s+-)` +
+	`|[VDIWEF]/[\w.]+(?:\(\s*\d+\))?:` +
+	`|\[[^\]]*\]|\([^)]*\)|<[^>]*>` +
+	`|[\w.-]+\[\d+\]:` +
+	`)\s*[-:|>]?\s*`)
+
+// stripLogPrefix returns the given line with any log file prefix removed
+// from the start, or returns it verbatim if it doesn't seem to have one.
+//
+// A parenthesized prefix is removed only if it follows some other part of
+// a log prefix, since prose often begins with a parenthetical remark.
+func stripLogPrefix(line string) string {
+	ret := line
+	for {
+		loc := logPrefix.FindStringIndex(ret)
+		if loc == nil || loc[1] == 0 {
+			break
+		}
+		if ret[0] == '(' && ret == line {
+			break
+		}
+		ret = ret[loc[1]:]
+	}
+	return ret
 }
@@ -0,0 +1,73 @@
+package trainhal
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// quoteAttribution matches a line attributing a quote to its author, as in
+// "-- Mark Twain".
+var quoteAttribution = regexp.MustCompile(`^(?:--|—|―|~)\s*\S`)
+
+// ParseQuotes parses a database of quotations for use with ghal.QuoteDB,
+// returning each quotation as a single sentence made of all of the
+// sentences within it.
+//
+// The quotations are in the format of fortune files, separated by lines
+// containing only "%", or if there are no such lines then each line is a
+// quotation of its own. Lines attributing a quotation to its author, such
+// as "-- Mark Twain", are ignored, as are lines beginning with "#" in a
+// file with one quotation per line.
+func ParseQuotes(r io.Reader, p *ghal.Parser) ([]ghal.Sentence, error) {
+	var lines []string
+	fortune := false
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "%" {
+			fortune = true
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	var ret []ghal.Sentence
+	addQuote := func(text string) {
+		ss, _ := p.ParseText(text)
+		var quote ghal.Sentence
+		for _, s := range ss {
+			quote = append(quote, s...)
+		}
+		if len(quote) > 0 {
+			ret = append(ret, quote)
+		}
+	}
+
+	var quote []string
+	for _, line := range lines {
+		switch {
+		case quoteAttribution.MatchString(line):
+			continue
+		case !fortune:
+			if line != "" && !strings.HasPrefix(line, "#") {
+				addQuote(line)
+			}
+		case line == "%":
+			addQuote(strings.Join(quote, " "))
+			quote = quote[:0]
+		case line != "":
+			quote = append(quote, line)
+		}
+	}
+	if len(quote) > 0 {
+		addQuote(strings.Join(quote, " "))
+	}
+	return ret, nil
+}
@@ -0,0 +1,108 @@
+package trainhal
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+var (
+	subtitleTiming  = regexp.MustCompile(`^\s*\d*:?\d+:\d+[.,]\d+\s+-->\s+`)
+	subtitleVoice   = regexp.MustCompile(`^<v(?:\.[^\s>]*)?\s+([^>]*)>`)
+	subtitleTag     = regexp.MustCompile(`</?[a-zA-Z][^>]*>|\{\\[^}]*\}`)
+	subtitleCueNum  = regexp.MustCompile(`^\d+$`)
+	subtitleSpeaker = regexp.MustCompile(`^([A-Z][A-Z .'-]*):\s+`)
+)
+
+func parseSubtitles(r io.Reader, p *ghal.Parser) ([]ghal.Sentence, error) {
+	turns, err := parseSubtitleTurns(r, p)
+	return flattenTurns(turns), err
+}
+
+// parseSubtitleTurns parses subtitles in either SubRip (.srt) or WebVTT
+// (.vtt) format, treating each cue as a turn in a dialogue.
+//
+// Speakers are identified using WebVTT voice tags, or a capitalized name
+// followed by a colon at the start of a cue, as is common in subtitles for
+// the hard of hearing. A cue made of lines beginning with dashes has a line
+// from each of two speakers, and is split into two turns.
+func parseSubtitleTurns(r io.Reader, p *ghal.Parser) ([]ghal.DialogueTurn, error) {
+	var turns []ghal.DialogueTurn
+	var cue []string
+	endCue := func() {
+		defer func() { cue = cue[:0] }()
+		if len(cue) == 0 {
+			return
+		}
+
+		var lines []string
+		if strings.HasPrefix(cue[0], "-") {
+			// Each dash begins a line from a different speaker.
+			for _, line := range cue {
+				if strings.HasPrefix(line, "-") || len(lines) == 0 {
+					lines = append(lines, strings.TrimSpace(strings.TrimLeft(line, "-")))
+				} else {
+					lines[len(lines)-1] += " " + line
+				}
+			}
+		} else {
+			lines = []string{strings.Join(cue, " ")}
+		}
+
+		for _, line := range lines {
+			speaker := ""
+			if m := subtitleVoice.FindStringSubmatch(line); m != nil {
+				speaker = strings.TrimSpace(m[1])
+			} else if m := subtitleSpeaker.FindStringSubmatch(line); m != nil {
+				speaker = strings.TrimSpace(m[1])
+				line = line[len(m[0]):]
+			}
+			line = subtitleTag.ReplaceAllString(line, "")
+			ss, _ := p.ParseText(line)
+			if len(ss) == 0 {
+				continue
+			}
+			turns = append(turns, ghal.DialogueTurn{
+				Speaker:   speaker,
+				Sentences: ss,
+			})
+		}
+	}
+
+	sc := bufio.NewScanner(r)
+	inNote := false
+	for sc.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\ufeff"))
+		switch {
+		case line == "":
+			endCue()
+			inNote = false
+		case inNote:
+		case strings.HasPrefix(line, "WEBVTT"), strings.HasPrefix(line, "NOTE"), strings.HasPrefix(line, "STYLE"), strings.HasPrefix(line, "REGION"):
+			inNote = true
+		case subtitleTiming.MatchString(line):
+			// Anything before the timing line is a cue identifier.
+			cue = cue[:0]
+		case len(cue) == 0 && subtitleCueNum.MatchString(line):
+			// SubRip cue number
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"), strings.HasPrefix(line, "(") && strings.HasSuffix(line, ")"):
+			// Sound descriptions, like "[door slams]".
+		default:
+			cue = append(cue, line)
+		}
+	}
+	endCue()
+	return turns, sc.Err()
+}
+
+// flattenTurns returns all of the sentences from the given dialogue turns.
+func flattenTurns(turns []ghal.DialogueTurn) []ghal.Sentence {
+	var ret []ghal.Sentence
+	for _, turn := range turns {
+		ret = append(ret, turn.Sentences...)
+	}
+	return ret
+}
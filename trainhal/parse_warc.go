@@ -0,0 +1,162 @@
+package trainhal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// maxWARCBlockLen is the largest WARC record block that ParseWARC will read.
+// Larger records are skipped.
+const maxWARCBlockLen = 32 * 1024 * 1024
+
+// ParseWARC extracts sentences from the HTML pages in the given WARC (Web
+// ARChive) file, which may be gzip-compressed, learning only the pages whose
+// URLs are selected by the given filter.
+//
+// Each of the returned utterances has the URL of the page it came from as its
+// source. Sentences repeated across many pages of the archive are assumed
+// to be boilerplate and are omitted.
+func ParseWARC(r io.Reader, filter URLFilter, p *ghal.Parser) ([]ghal.Utterance, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress WARC file: %s", err)
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+	tr := textproto.NewReader(br)
+
+	var pages webPages
+	for i := 0; ; i++ {
+		version, err := readWARCVersion(tr)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %s", i, err)
+		}
+		if !strings.HasPrefix(version, "WARC/") {
+			return nil, fmt.Errorf("record %d: not a WARC record", i)
+		}
+		header, err := tr.ReadMIMEHeader()
+		if err != nil {
+			return nil, fmt.Errorf("record %d: invalid header: %s", i, err)
+		}
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("record %d: invalid Content-Length", i)
+		}
+		if length > maxWARCBlockLen {
+			// Records this large are media or other binary downloads rather
+			// than pages, so we skip them without reading them into memory.
+			if _, err := io.CopyN(io.Discard, br, length); err != nil {
+				return nil, fmt.Errorf("record %d: %s", i, unexpectedEOF(err))
+			}
+			continue
+		}
+		// The buffer grows only as the data actually arrives, so a truncated
+		// archive can't make us allocate more than it contains.
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, br, length); err != nil {
+			return nil, fmt.Errorf("record %d: %s", i, unexpectedEOF(err))
+		}
+		block := buf.Bytes()
+
+		url := header.Get("WARC-Target-URI")
+		if url == "" || !filter.Match(url) {
+			continue
+		}
+		body, contentType, ok := warcPage(header.Get("WARC-Type"), header.Get("Content-Type"), block)
+		if !ok {
+			continue
+		}
+		// A page that fails to parse shouldn't prevent us from learning the
+		// rest of the archive, so we just skip it.
+		pages.add(url, contentType, body, p)
+	}
+
+	return pages.utterances(), nil
+}
+
+// unexpectedEOF converts the io.EOF that io.CopyN returns for a record block
+// that is cut short into io.ErrUnexpectedEOF, as io.ReadFull would.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readWARCVersion reads the version line that begins a WARC record, skipping
+// the blank lines that separate records. It returns io.EOF if there are no
+// more records.
+func readWARCVersion(tr *textproto.Reader) (string, error) {
+	for {
+		line, err := tr.ReadLine()
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return "", err
+		}
+		if line != "" {
+			return line, nil
+		}
+	}
+}
+
+// warcPage returns the body of the HTML page stored in a WARC record of the
+// given type and content type with the given block, along with the page's
+// own content type. The final return value is false if the record doesn't
+// contain a successfully-retrieved HTML page.
+func warcPage(recordType, recordContentType string, block []byte) (io.Reader, string, bool) {
+	switch recordType {
+	case "response":
+		if !strings.HasPrefix(recordContentType, "application/http") {
+			return nil, "", false
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return nil, "", false
+		}
+		contentType := resp.Header.Get("Content-Type")
+		if !isHTMLMediaType(contentType) {
+			return nil, "", false
+		}
+		body := io.Reader(resp.Body)
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(body)
+			if err != nil {
+				return nil, "", false
+			}
+			body = zr
+		}
+		return body, contentType, true
+	case "resource":
+		if !isHTMLMediaType(recordContentType) {
+			return nil, "", false
+		}
+		return bytes.NewReader(block), recordContentType, true
+	default:
+		return nil, "", false
+	}
+}
+
+func isHTMLMediaType(mediaType string) bool {
+	mimeType, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+	return mimeType == "text/html" || mimeType == "application/xhtml+xml"
+}
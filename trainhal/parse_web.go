@@ -0,0 +1,165 @@
+package trainhal
+
+import (
+	"io"
+	"regexp"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+	"golang.org/x/net/html"
+	htmla "golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// boilerplatePages is the number of pages in a web archive that a sentence
+// can appear on before we consider it to be boilerplate, like a copyright
+// notice or a call to subscribe, rather than content.
+const boilerplatePages = 3
+
+// URLFilter selects which pages of a web archive to learn from, by matching
+// their URLs against regular expressions.
+type URLFilter struct {
+	// Include, if non-empty, are patterns at least one of which must match
+	// the URL of a page for it to be learned.
+	Include []*regexp.Regexp
+
+	// Exclude are patterns that prevent a page from being learned if any of
+	// them match its URL, even if it also matches one of Include.
+	Exclude []*regexp.Regexp
+}
+
+// Match returns true if the page with the given URL should be learned.
+func (f URLFilter) Match(url string) bool {
+	for _, re := range f.Exclude {
+		if re.MatchString(url) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, re := range f.Include {
+		if re.MatchString(url) {
+			return true
+		}
+	}
+	return false
+}
+
+// webPages collects the sentences from the pages of a web archive so that
+// boilerplate repeated across many pages can be removed before they are
+// learned.
+type webPages struct {
+	urls      []string
+	sentences [][]ghal.Sentence
+}
+
+// add parses the given HTML page, which has the given URL and Content-Type,
+// and adds its content to the collection.
+func (w *webPages) add(url, contentType string, r io.Reader, p *ghal.Parser) error {
+	r, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return err
+	}
+	node, err := html.Parse(r)
+	if err != nil {
+		return err
+	}
+	w.urls = append(w.urls, url)
+	w.sentences = append(w.sentences, extractWebPage(node, p))
+	return nil
+}
+
+// utterances returns the content of all of the pages, attributed to their
+// URLs, except for any sentences that appear on more than boilerplatePages
+// different pages.
+func (w *webPages) utterances() []ghal.Utterance {
+	pageCounts := make(map[string]int)
+	for _, ss := range w.sentences {
+		seen := make(map[string]struct{}, len(ss))
+		for _, s := range ss {
+			k := s.String()
+			if _, dup := seen[k]; dup {
+				continue
+			}
+			seen[k] = struct{}{}
+			pageCounts[k]++
+		}
+	}
+
+	var ret []ghal.Utterance
+	for i, ss := range w.sentences {
+		for _, s := range ss {
+			if pageCounts[s.String()] > boilerplatePages {
+				continue
+			}
+			ret = append(ret, ghal.Utterance{
+				Sentence: s,
+				Source:   w.urls[i],
+			})
+		}
+	}
+	return ret
+}
+
+// extractWebPage is like extractHTMLNode but tries harder to skip the parts
+// of a page that are not its main content, such as site headers and footers.
+// If the page marks its main content using a "main" or "article" element then
+// only the content of those elements is extracted.
+func extractWebPage(node *html.Node, p *ghal.Parser) []ghal.Sentence {
+	if main := findWebPageContent(node); len(main) > 0 {
+		var ret []ghal.Sentence
+		for _, n := range main {
+			ret = append(ret, extractWebPageNode(n, p)...)
+		}
+		return ret
+	}
+	return extractWebPageNode(node, p)
+}
+
+func extractWebPageNode(node *html.Node, p *ghal.Parser) []ghal.Sentence {
+	if isWebPageBoilerplate(node) {
+		return nil
+	}
+	if node.Type == html.ElementNode {
+		switch node.DataAtom {
+		case htmla.P, htmla.Li:
+			return extractHTMLNodeTextContent(node, p)
+		}
+	}
+	if isLeafHTMLElement(node) {
+		return nil
+	}
+	var ret []ghal.Sentence
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		ret = append(ret, extractWebPageNode(c, p)...)
+	}
+	return ret
+}
+
+// findWebPageContent returns the outermost "main" and "article" elements
+// in the document rooted at the given node, if any.
+func findWebPageContent(node *html.Node) []*html.Node {
+	if node.Type == html.ElementNode && (node.DataAtom == htmla.Main || node.DataAtom == htmla.Article) {
+		return []*html.Node{node}
+	}
+	var ret []*html.Node
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		ret = append(ret, findWebPageContent(c)...)
+	}
+	return ret
+}
+
+// isWebPageBoilerplate returns true if the given node is an element that
+// typically contains navigation or other content repeated across the pages
+// of a website.
+func isWebPageBoilerplate(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+	switch node.DataAtom {
+	case htmla.Header, htmla.Footer, htmla.Aside, htmla.Nav:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,90 @@
+package trainhal
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+)
+
+// StreamOptions are the options for Stream.
+type StreamOptions struct {
+	// Parser is the parser used to parse the text in the source. If it is
+	// nil then each sentence is parsed separately, without sharing memory
+	// for the words they have in common.
+	Parser *ghal.Parser
+
+	// PlainText are the options used for plain text sources.
+	PlainText PlainTextOptions
+}
+
+// Stream returns a sequence of the sentences in the given training source,
+// which is interpreted as for ParseTrainingInput, parsing them only as the
+// sequence is iterated. Along with Brain.Consume, it allows training a brain
+// on a corpus much larger than the memory available, as in:
+//
+//	learned, err := brain.Consume(ctx, trainhal.Stream(src, opts), ghal.ConsumeOptions{})
+//
+// Plain text and MegaHAL training files are read incrementally, holding no
+// more than one paragraph in memory at once. Every other format is parsed
+// in full before its first sentence is produced, since those formats can
+// only be understood as a whole, so large corpora should be given in one of
+// the incremental formats.
+//
+// If the source can't be read, the error is the last element of the
+// sequence, with a nil sentence, after any sentences that were found before
+// the error.
+func Stream(src CorpusSource, opts StreamOptions) iter.Seq2[ghal.Sentence, error] {
+	return func(yield func(ghal.Sentence, error) bool) {
+		format, mimeEnc := selectFormat(src.Name, src.MediaType)
+		send := func(s ghal.Sentence) bool {
+			return yield(s, nil)
+		}
+
+		var err error
+		switch format {
+		case formatUnknown:
+			err = fmt.Errorf("failed to detect file format from filename or media type")
+		case formatPlain:
+			err = scanPlainText(src.Reader, mimeEnc, opts.PlainText, opts.Parser, send)
+		case formatMegaHAL:
+			err = scanMegaHALTraining(src.Reader, opts.Parser, send)
+		default:
+			var sentences []ghal.Sentence
+			sentences, err = parseSource(src.Reader, format, mimeEnc, opts.Parser)
+			for _, s := range sentences {
+				if !send(s) {
+					return
+				}
+			}
+		}
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// TrainBrain teaches the given brain the sentences in each of the given
+// training sources in turn, streaming them as for Stream and learning them
+// as for Brain.Consume, and returns the total number of sentences learned.
+// Each source's name is recorded as the source of its sentences, and its
+// sentences are learned with its weight.
+//
+// TrainBrain stops at the first source that can't be read, returning its
+// error after learning all of the sentences found before the error. If the
+// given context is cancelled, it stops as described for Brain.Consume.
+func TrainBrain(ctx context.Context, b *ghal.Brain, sources []CorpusSource, opts StreamOptions) (int, error) {
+	total := 0
+	for _, src := range sources {
+		learned, err := b.Consume(ctx, Stream(src, opts), ghal.ConsumeOptions{
+			Source: src.Name,
+			Weight: src.Weight,
+		})
+		total += learned
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", src.Name, err)
+		}
+	}
+	return total, nil
+}
@@ -11,18 +11,122 @@ import (
 // by interpreting it as one of a number of text formats:
 //
 //     - HTML
+//     - WARC web archives
 //     - RSS or Atom with HTML body text
 //     - Markdown
+//     - Jupyter notebooks
+//     - SubRip and WebVTT subtitles
 //     - Plain text
 //
 // It uses the given optional filename and mimeType to guess which parser to
 // use. If both are given, the mimeType has precedence.
 // If neither filename nor mimeType are set then it will fail, returning an error.
 func ParseTrainingInput(r io.Reader, filename, mediaType string) ([]ghal.Sentence, error) {
+	return ParseTrainingInputWithParser(r, filename, mediaType, nil)
+}
+
+// ParseTrainingInputWithParser is like ParseTrainingInput but uses the given
+// parser to parse the text it extracts, so that a single parser can share
+// memory across many training inputs. If p is nil, it behaves exactly like
+// ParseTrainingInput.
+func ParseTrainingInputWithParser(r io.Reader, filename, mediaType string, p *ghal.Parser) ([]ghal.Sentence, error) {
 	format, mimeEnc := selectFormat(filename, mediaType)
 	if format == formatUnknown {
 		return nil, fmt.Errorf("failed to detect file format from filename or media type")
 	}
 
-	return parseSource(r, format, mimeEnc)
+	return parseSource(r, format, mimeEnc, p)
+}
+
+// NewBrainFromCorpus returns a new brain that has learned the sentences in
+// the given training input, which is interpreted as for ParseTrainingInput.
+// The format is given as either a filename or bare file extension, like
+// "corpus.md" or ".md", or a media type, like "text/markdown".
+//
+// This is a convenience for programs that embed a small bot and want to
+// teach it a corpus without the bookkeeping of the gopherhal command. Any
+// sentences the input contains are learned even if an error is returned.
+func NewBrainFromCorpus(r io.Reader, format string) (*ghal.Brain, error) {
+	// A format that isn't a media type we recognize is tried as a filename.
+	p := ghal.NewParser()
+	sentences, err := ParseTrainingInputWithParser(r, format, format, p)
+	brain := ghal.NewBrain()
+	brain.AddSentences(sentences)
+	brain.AddCaseCounts(p.TakeCaseCounts())
+	return brain, err
+}
+
+// ParseTrainingUtterances is like ParseTrainingInputWithParser but returns
+// utterances rather than bare sentences, preserving any per-sentence source
+// and time recorded in the input. Only the "JSON Utter" and WARC formats can
+// record this information, so for all other formats the source and time of
+// each utterance are left unset.
+func ParseTrainingUtterances(r io.Reader, filename, mediaType string, p *ghal.Parser) ([]ghal.Utterance, error) {
+	format, mimeEnc := selectFormat(filename, mediaType)
+	if format == formatUnknown {
+		return nil, fmt.Errorf("failed to detect file format from filename or media type")
+	}
+	switch format {
+	case formatJSONUtter:
+		us, _, err := parseJSONUtterances(r, p)
+		return us, err
+	case formatWARC:
+		return ParseWARC(r, URLFilter{}, p)
+	}
+
+	sentences, err := parseSource(r, format, mimeEnc, p)
+	ret := make([]ghal.Utterance, len(sentences))
+	for i, s := range sentences {
+		ret[i].Sentence = s
+	}
+	return ret, err
+}
+
+// ParseDialogue is like ParseTrainingInputWithParser but returns the
+// sentences grouped into the turns of a dialogue, for use with
+// Brain.AddDialogue. Only formats with a notion of speaker turns are
+// supported: SubRip and WebVTT subtitles, where each cue is a turn, and
+// "JSON Utter", where sentences can be annotated with their speakers.
+func ParseDialogue(r io.Reader, filename, mediaType string, p *ghal.Parser) ([]ghal.DialogueTurn, error) {
+	format, _ := selectFormat(filename, mediaType)
+	switch format {
+	case formatSubtitles:
+		return parseSubtitleTurns(r, p)
+	case formatJSONUtter:
+		return parseJSONUtterDialogue(r, p)
+	case formatUnknown:
+		return nil, fmt.Errorf("failed to detect file format from filename or media type")
+	default:
+		return nil, fmt.Errorf("file format does not have speaker turns")
+	}
+}
+
+// CanParse returns true if the given filename and media type, which are
+// interpreted as for ParseTrainingInput, select a format that can be parsed.
+func CanParse(filename, mediaType string) bool {
+	format, _ := selectFormat(filename, mediaType)
+	return format != formatUnknown
+}
+
+// IsWebArchive returns true if the given filename and media type select a
+// web archive format, which can be parsed with a URL filter using ParseWARC.
+func IsWebArchive(filename, mediaType string) bool {
+	format, _ := selectFormat(filename, mediaType)
+	return format == formatWARC
+}
+
+// IsPlainText returns true if the given filename and media type select the
+// plain text format, which can be parsed with options using ParsePlainText.
+func IsPlainText(filename, mediaType string) bool {
+	format, _ := selectFormat(filename, mediaType)
+	return format == formatPlain
+}
+
+// IsLineOriented returns true if the given filename and media type select a
+// format in which each line stands alone, so that any sequence of whole lines
+// from a file can be parsed separately from the rest of the file. Files in
+// such formats can be learned incrementally as lines are appended to them.
+func IsLineOriented(filename, mediaType string) bool {
+	format, _ := selectFormat(filename, mediaType)
+	return format == formatMegaHAL
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/apparentlymart/gopherhal/ghal"
+	"github.com/apparentlymart/gopherhal/trainhal"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettleTime is how long a watched file must go without changing before
+// we learn from it, so that we don't parse a file many times while it's
+// being written.
+const watchSettleTime = 2 * time.Second
+
+// watchTraining is like train but, after learning the given files, continues
+// to watch them for changes until interrupted. Any directories given are
+// watched for new files too. Files in line-oriented formats are treated as
+// append-only logs, so only newly-added lines are learned.
+func watchTraining(brainFile string, paths []string, opts trainOptions) int {
+	if len(paths) == 0 {
+		os.Stderr.WriteString("Usage: gopherhal train --watch <corpus-file-or-directory>...\n")
+		return 1
+	}
+
+	brain, err := loadBrainFile(brainFile)
+	if os.IsNotExist(err) {
+		log.Printf("Starting training with a new, empty brain")
+		brain = newBrain()
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading brain from %q: %s\n", brainFile, err)
+		return 1
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start watching files: %s\n", err)
+		return 1
+	}
+	defer watcher.Close()
+
+	var initial []string
+	for _, path := range paths {
+		err := watcher.Add(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to watch %s: %s\n", path, err)
+			return 1
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to watch %s: %s\n", path, err)
+			return 1
+		}
+		if !info.IsDir() {
+			initial = append(initial, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list %s: %s\n", path, err)
+			return 1
+		}
+		for _, filename := range matches {
+			if trainhal.CanParse(filename, "") {
+				initial = append(initial, filename)
+			}
+		}
+	}
+
+	for _, filename := range initial {
+		watchedFileChanged(brain, brainFile, filename, opts)
+	}
+
+	// --force applies only to the files that were present when we started,
+	// and not to their subsequent changes.
+	opts.force = false
+
+	// pending are the files that have changed since we last learned them,
+	// along with the time of their most recent change.
+	pending := make(map[string]time.Time)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(watchSettleTime / 2)
+	defer ticker.Stop()
+
+	log.Printf("Watching for changes; interrupt to stop")
+	for {
+		select {
+		case ev := <-watcher.Events:
+			if ev.Op&(fsnotify.Create|fsnotify.Write) == 0 || !trainhal.CanParse(ev.Name, "") {
+				continue
+			}
+			pending[ev.Name] = time.Now()
+		case err := <-watcher.Errors:
+			log.Printf("Error watching files: %s", err)
+		case <-ticker.C:
+			var ready []string
+			for filename, changed := range pending {
+				if time.Since(changed) >= watchSettleTime {
+					ready = append(ready, filename)
+				}
+			}
+			sort.Strings(ready)
+			for _, filename := range ready {
+				delete(pending, filename)
+				watchedFileChanged(brain, brainFile, filename, opts)
+			}
+		case <-interrupt:
+			return 0
+		}
+	}
+}
+
+// watchedFileChanged learns any new content from the given file, saving the
+// brain if anything was learned. Errors are only logged, since the file may
+// have been removed or may be in the middle of being rewritten.
+func watchedFileChanged(brain *ghal.Brain, brainFile, filename string, opts trainOptions) {
+	if info, err := os.Stat(filename); err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	// A parser retains all of the strings it has seen, so we use a new one
+	// for each file to avoid growing without bound.
+	parser := newParser()
+	learned, err := trainFile(brain, parser, filename, true, opts)
+	if err != nil {
+		log.Printf("Failed to read %s: %s", filename, err)
+		return
+	}
+	if learned {
+		brain.AddCaseCounts(parser.TakeCaseCounts())
+		safeSaveBrain(brain, brainFile)
+	}
+}